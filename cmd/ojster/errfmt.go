@@ -0,0 +1,127 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+// Error codes of --error-format json. They are part of the CLI's interface:
+// add new ones, but do not rename them.
+const (
+	errCodeConfig      = "config"
+	errCodeUnseal      = "unseal"
+	errCodeMissingKeys = "missing-keys"
+	errCodeIO          = "io"
+	errCodeOther       = "error"
+)
+
+// cutErrorFormat removes a leading --error-format flag from args, which
+// must come before the subcommand, and returns its value ("text" without one).
+func cutErrorFormat(args []string) (string, []string, error) {
+	format := "text"
+	switch {
+	case len(args) > 0 && strings.HasPrefix(args[0], "--error-format="):
+		format, args = strings.TrimPrefix(args[0], "--error-format="), args[1:]
+	case len(args) > 1 && args[0] == "--error-format":
+		format, args = args[1], args[2:]
+	case len(args) > 0 && args[0] == "--error-format":
+		return "", nil, fmt.Errorf("--error-format needs a value: text or json")
+	}
+	if format != "text" && format != "json" {
+		return "", nil, fmt.Errorf("invalid --error-format %q: want text or json", format)
+	}
+	return format, args, nil
+}
+
+// jsonErrors writes each line written to it as a JSON object with the
+// command, an error code and the message, so scripts can branch on the
+// code instead of matching messages.
+type jsonErrors struct {
+	mu      sync.Mutex
+	w       io.Writer
+	command string
+	buf     []byte
+}
+
+func (j *jsonErrors) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.buf = append(j.buf, p...)
+	for {
+		i := bytes.IndexByte(j.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(j.buf[:i])
+		j.buf = j.buf[i+1:]
+		if err := j.emit(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes a last line that did not end in a newline.
+func (j *jsonErrors) Flush() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.buf) > 0 {
+		_ = j.emit(string(j.buf))
+		j.buf = nil
+	}
+}
+
+func (j *jsonErrors) emit(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	b, err := json.Marshal(struct {
+		Command string `json:"command"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}{j.command, errorCode(line), line})
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(b, '\n'))
+	return err
+}
+
+// errorCode classifies a message by the sentinel errors of pqc it quotes
+// and by the wording of file system errors.
+func errorCode(msg string) string {
+	switch {
+	case strings.Contains(msg, pqc.ErrMissingKeys.Error()):
+		return errCodeMissingKeys
+	case strings.Contains(msg, pqc.ErrUnseal.Error()):
+		return errCodeUnseal
+	case strings.Contains(msg, pqc.ErrConfig.Error()):
+		return errCodeConfig
+	}
+	for _, hint := range []string{os.ErrNotExist.Error(), os.ErrPermission.Error(), "no such file or directory", "permission denied", "failed to read", "failed to write", "failed to open", "failed to create"} {
+		if strings.Contains(msg, hint) {
+			return errCodeIO
+		}
+	}
+	return errCodeOther
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestCutErrorFormat(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		format string
+		rest   int
+	}{
+		{[]string{"seal", "KEY"}, "text", 2},
+		{[]string{"--error-format", "json", "seal"}, "json", 1},
+		{[]string{"--error-format=text", "seal"}, "text", 1},
+	} {
+		format, rest, err := cutErrorFormat(tc.args)
+		if err != nil || format != tc.format || len(rest) != tc.rest {
+			t.Errorf("cutErrorFormat(%q) = %q, %q, %v", tc.args, format, rest, err)
+		}
+	}
+	for _, args := range [][]string{{"--error-format"}, {"--error-format=xml", "seal"}} {
+		if _, _, err := cutErrorFormat(args); err == nil {
+			t.Errorf("cutErrorFormat(%q) accepted", args)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	var out bytes.Buffer
+	jw := &jsonErrors{w: &out, command: "unseal"}
+	fmt.Fprintln(jw, fmt.Errorf("%w: KEY", pqc.ErrMissingKeys))
+	fmt.Fprint(jw, "partial ")
+	fmt.Fprint(jw, "line\n\n")
+	fmt.Fprintf(jw, "%v\n%v", fmt.Errorf("%w: bad tag", pqc.ErrUnseal), fmt.Errorf("%w: no key", pqc.ErrConfig))
+	jw.Flush()
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e struct{ Command, Code, Message string }
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.Command != "unseal" {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		got = append(got, e.Code+" "+e.Message)
+	}
+	want := []string{"missing-keys pqc: missing keys: KEY", "error partial line", "unseal pqc: unseal error: bad tag", "config pqc: config error: no key"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEntrypoint_ErrorFormatJSON(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.key")
+	var errb bytes.Buffer
+	code := entrypoint("ojster", []string{"--error-format", "json", "unseal", "--in", missing, "--priv-file", missing}, "v", io.Discard, &errb)
+	if code == 0 {
+		t.Fatal("expected failure")
+	}
+	var e struct{ Command, Code, Message string }
+	if err := json.Unmarshal(bytes.TrimSpace(errb.Bytes()), &e); err != nil {
+		t.Fatalf("stderr is not one JSON object: %q", errb.String())
+	}
+	if e.Command != "unseal" || e.Code != errCodeIO || !strings.Contains(e.Message, missing) {
+		t.Fatalf("unexpected error %+v", e)
+	}
+
+	errb.Reset()
+	if code := entrypoint("ojster", []string{"--error-format", "yaml", "unseal"}, "v", io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "invalid --error-format") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
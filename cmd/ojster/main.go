@@ -15,16 +15,48 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ojster/ojster/internal/agent"
+	"github.com/ojster/ojster/internal/bundle"
 	"github.com/ojster/ojster/internal/client"
+	"github.com/ojster/ojster/internal/compose"
+	"github.com/ojster/ojster/internal/doctor"
+	"github.com/ojster/ojster/internal/envdiff"
+	"github.com/ojster/ojster/internal/gpg"
+	"github.com/ojster/ojster/internal/harden"
+	"github.com/ojster/ojster/internal/importenv"
+	"github.com/ojster/ojster/internal/installinit"
+	"github.com/ojster/ojster/internal/jsonfile"
+	"github.com/ojster/ojster/internal/k8s"
+	"github.com/ojster/ojster/internal/mtls"
 	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/precommit"
+	"github.com/ojster/ojster/internal/protocol"
+	"github.com/ojster/ojster/internal/scan"
 	"github.com/ojster/ojster/internal/server"
+	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/ipc"
+	"github.com/ojster/ojster/internal/util/memlock"
 	"github.com/ojster/ojster/internal/util/tty"
 )
 
@@ -32,8 +64,9 @@ const header = `Ojster — GitOps-safe one-way encrypted secrets for Docker Comp
 
 Environment variables:
   OJSTER_SOCKET_PATH
-      Unix domain socket path used for client ↔ server IPC.
-      Default: /mnt/ojster/ipc.sock
+      Unix domain socket path used for client ↔ server IPC. On Windows,
+      a path under \\.\pipe\ is a named pipe.
+      Default: /mnt/ojster/ipc.sock (\\.\pipe\ojster on Windows)
 
   OJSTER_PRIVATE_KEY_FILE
       Path to the private key file used for decryption..
@@ -41,31 +74,148 @@ Environment variables:
 
   OJSTER_REGEX
       Regex used by the client (run mode) to select which env values to send.
+      serve refuses requests with values it does not match.
+
+  OJSTER_TIMEOUT
+      Time each request of the client (run mode) to the server may take,
+      like run --timeout. Default: 15s
+
+  OJSTER_ROUTE
+      URL path the client posts to, selecting a route of a server started
+      with serve --config. Default: /
+
+  OJSTER_PIN
+      Client: what to do when the server's key differs from the one recorded
+      on first contact, or the server cannot prove it holds that key: warn,
+      refuse or off. Default: warn if OJSTER_STATE_DIR is set, else off
+
+  OJSTER_STATE_DIR
+      Directory of the client's pin file (pins.json).
+      Default: $XDG_STATE_HOME/ojster or ~/.local/state/ojster
+
+  OJSTER_PASSTHROUGH
+      Run mode: set to 1 to exec the command unchanged when no values match
+      OJSTER_REGEX, instead of exiting 2. Handy as docker-init, which takes
+      no flags.
+
+  OJSTER_READY_FILE
+      Run mode: file to write once the values are decrypted, like
+      --ready-file, for healthchecks and depends_on conditions.
+
+  OJSTER_USER
+      Run mode: user[:group] to switch to before exec, like --user.
+
+  OJSTER_CHDIR, OJSTER_UMASK
+      Run mode: working directory and octal umask to set before exec, like
+      --chdir and --umask.
+
+  OJSTER_WAIT, OJSTER_KILL_TIMEOUT
+      Run mode: set OJSTER_WAIT=1 to supervise the command instead of
+      exec'ing it, like --wait, with OJSTER_KILL_TIMEOUT as --kill-timeout.
+
+  OJSTER_RESTART
+      Run mode: restart policy, no or on-failure[:max], like --restart.
+
+  OJSTER_WIRE_FORMAT
+      Encoding of client requests and replies: json or cbor. CBOR avoids
+      JSON overhead and can carry binary values. Default: json
+
+  OJSTER_PASSPHRASE_FILE
+      File holding the passphrase of a private key created with
+      keypair --passphrase. Without it the passphrase is prompted for.
+
+  OJSTER_LISTEN_ADDR, OJSTER_TLS_CERT, OJSTER_TLS_KEY, OJSTER_TLS_CA
+      Serve mode: listen on this host:port with mutual TLS instead of the
+      Unix socket. Clients need a certificate signed by OJSTER_TLS_CA.
 
 Usage:
-  ojster help
-  ojster version
+  ojster [--error-format text|json] COMMAND ...
+      With json, every line on stderr is a JSON object with the command,
+      an error code (config, unseal, missing-keys, io or error) and the
+      message.
+  ojster help [--json]
+  ojster version [--verbose]
 `
 
 const keypairSynopsis = "ojster keypair"
-const keypairDesc = "Generate a new keypair. Writes private and public key files."
-const keypairArgs = "[--priv-file PATH] [--pub-file PATH]"
+const keypairDesc = "Generate a new keypair. Writes private and public key files, or streams them to a file descriptor or stdout."
+const keypairArgs = "[--priv-file PATH | --priv-fd N] [--pub-file PATH | --pub-stdout] [--passphrase | --passphrase-file PATH] [--pem] [--comment TEXT] [--mnemonic | --restore]"
+
+const pubkeySynopsis = "ojster pubkey"
+const pubkeyDesc = "Re-derive the public key from a private key file and print it; its fingerprint goes to stderr."
+const pubkeyArgs = "[--priv-file PATH] [--insecure-key-perms]"
+
+const inspectSynopsis = "ojster inspect"
+const inspectDesc = "Show the kind, fingerprint, creation time and comment of key files."
+const inspectArgs = "FILE..."
 
 const sealSynopsis = "ojster seal"
-const sealDesc = "Encrypt KEY in an env file using the public key."
-const sealArgs = "[--pub-file PATH] [--out PATH] KEY"
+const sealDesc = "Encrypt KEY in an env file (or a dot-separated path in a .json file) using the public key."
+const sealArgs = "[--pub-file PATH | --remote[=SOCKET] | --gpg-recipient ID...] [--out PATH | --compose PATH --service NAME] [--if-changed [--priv-file PATH]] [--allow-lowercase | --key-pattern REGEX] [--frame] [--encoding base64|base64url] (KEY | --stdin-json)"
+
+const importSynopsis = "ojster import"
+const importDesc = "Seal every value of an existing plaintext env file, optionally shredding the source."
+const importArgs = "--from PATH [--out PATH] [--pub-file PATH] [--shred]"
 
 const unsealSynopsis = "ojster unseal"
-const unsealDesc = "Decrypt values from an env file using a private key and print results."
-const unsealArgs = "[--in PATH] [--priv-file PATH] [--json] [KEY...]"
+const unsealDesc = "Decrypt values from an env or .json file using a private key and print results."
+const unsealArgs = "[--in PATH] [--priv-file PATH | --gpg] [--json | --format env|systemd|docker|shell] [--interpolate] [--insecure-key-perms] [--prefix PREFIX...] [KEY|PATTERN...]"
+
+const unsealExecSynopsis = "ojster unseal exec"
+const unsealExecDesc = "Decrypt an env file locally and exec a command with its entries added to the environment (no server)."
+const unsealExecArgs = "[--in PATH] [--priv-file PATH] [--insecure-key-perms] -- command [args...]"
+
+const migrateSynopsis = "ojster migrate"
+const migrateDesc = "Re-seal the OJSTER-1 values of an env file in the framed OJSTER-2 format, using the private key."
+const migrateArgs = "[--in PATH] [--priv-file PATH] [--dry-run] [--insecure-key-perms]"
+
+const diffSynopsis = "ojster diff"
+const diffDesc = "Compare two env files by key, and by decrypted value with --priv-file. Exits 1 if they differ."
+const diffArgs = "[--priv-file PATH] [--show-values] A B"
+
+const bundleSynopsis = "ojster bundle"
+const bundleDesc = "Pack env files into an encrypted .ojster bundle, or unpack one with the private key."
+const bundleArgs = "pack [--pub-file PATH] [--out PATH] FILE... | unpack [--in PATH] [--priv-file PATH] [--dir DIR] [--force]"
+
+const k8sSynopsis = "ojster k8s"
+const k8sDesc = "Render Kubernetes Secret manifests from an env file, or run as an init container that writes decrypted values to a memory-backed volume."
+const k8sArgs = "export [--in PATH] [--priv-file PATH] [--kind secret|external-secret|sealed] [--name NAME] [--namespace NS] [--store NAME] [KEY...] | init [--configmap-dir DIR] [--annotations-file PATH] [--addr HOST:PORT --tls-cert PATH --tls-key PATH --tls-ca PATH] [--out-dir DIR] [--format env|files]"
+
+const checkComposeSynopsis = "ojster check-compose"
+const checkComposeDesc = "Report plaintext-looking secrets, unsealed references and unused sealed keys in a compose project."
+const checkComposeArgs = "[--env-file PATH] [FILE]"
+
+const precommitSynopsis = "ojster precommit"
+const precommitDesc = "Fail if staged (or given) env files contain unsealed secrets, for use as a git pre-commit hook."
+const precommitArgs = "[PATH...]"
+
+const doctorSynopsis = "ojster doctor"
+const doctorDesc = "Check the socket, server, tmpfs, private key, env file and OJSTER_REGEX of a deployment and suggest fixes."
+const doctorArgs = "[--socket PATH] [--priv-file PATH] [--env-file PATH]"
+
+const installInitSynopsis = "ojster install-init"
+const installInitDesc = "Copy this binary into a shared volume as docker-init and print the compose snippet that uses it."
+const installInitArgs = "[--target DIR] [--volume NAME]"
+
+const scanSynopsis = "ojster scan"
+const scanDesc = "Walk directories and flag unsealed secrets (by key name, known token formats or entropy) in env and compose files."
+const scanArgs = "[DIR...]"
 
 const runSynopsis = "ojster run"
 const runDesc = "Client mode: send selected encrypted env values to the server and exec the command."
-const runArgs = "[--] command [args...]"
+const runArgs = "[--socket PATH] [--regex REGEX] [--timeout D] [--passthrough] [--optional N] [--startup-timeout D] [--ready-file PATH] [--ready-signal SIG] [--user USER[:GROUP]] [--chdir DIR] [--umask MODE] [--wait [--kill-timeout D]] [--restart on-failure[:MAX]] [--] command [args...]"
+
+const agentSynopsis = "ojster agent"
+const agentDesc = "Cache decrypted values from the server for a TTL and serve them to local run commands on a per-user socket."
+const agentArgs = "[--socket PATH] [--ttl DURATION]"
 
 const serveSynopsis = "ojster serve"
 const serveDesc = "Server mode: listen on the Unix socket and return decrypted env values to clients."
-const serveArgs = "[--] command [args...]"
+const serveArgs = "[--socket PATH] [--priv-file PATH] [--cmd-timeout D] [--user USER [--group GROUP]] [--no-seccomp] [--no-landlock | --landlock] [--insecure-key-perms] [--pprof ADDR] [--once] [--identify-peers] [--docker-socket PATH] [--acl FILE] [--stdin] [--isolate] [--max-keys N] [--max-value-len N] [--config FILE | [--] command [args...]]"
+
+const pluginSynopsis = "ojster plugin"
+const pluginDesc = "Docker secrets plugin: decrypt sealed swarm secrets created with --driver ojster."
+const pluginArgs = "[--socket PATH] [--priv-file PATH] [--env-file PATH] [--insecure-key-perms]"
 
 var version = "0.0.0"
 
@@ -77,6 +227,47 @@ type RunEnv struct {
 	Regex string
 	// SocketPath is the Unix domain socket path the client will POST to.
 	SocketPath string
+	// Route is the URL path on the server the client will POST to.
+	Route string
+	// Timeout bounds each request to the server (see client.Endpoint).
+	Timeout string
+	// Format is the wire encoding, client.FormatJSON or client.FormatCBOR.
+	Format string
+	// Pin is the key pinning mode: "warn", "refuse" or "off".
+	Pin string
+	// StateDir holds the pin file; pinning is off when it is empty.
+	StateDir string
+	// Passthrough lets run exec the command unchanged when no values match
+	// Regex (see client.RunOptions).
+	Passthrough bool
+	// ReadyFile is written by run once the values are decrypted.
+	ReadyFile string
+	// User is the user[:group] run switches to before exec.
+	User string
+	// Chdir and Umask (octal) are applied by run before exec.
+	Chdir string
+	Umask string
+	// Wait and KillTimeout select run's supervisor mode (see client.RunOptions).
+	Wait        bool
+	KillTimeout string
+	// Restart is run's restart policy, as for --restart.
+	Restart string
+}
+
+// endpoint returns the client endpoint described by e, with warnings about
+// changed server keys going to errw.
+func (e RunEnv) endpoint(errw io.Writer) (client.Endpoint, error) {
+	ep := client.Endpoint{SocketPath: e.SocketPath, Route: e.Route, Format: e.Format}
+	switch e.Pin {
+	case "off":
+	case "warn", "refuse":
+		if e.StateDir != "" {
+			ep.Pins = &client.Pins{File: filepath.Join(e.StateDir, "pins.json"), Refuse: e.Pin == "refuse", Warn: errw}
+		}
+	default:
+		return ep, fmt.Errorf("invalid OJSTER_PIN %q (want warn, refuse or off)", e.Pin)
+	}
+	return ep, nil
 }
 
 // ServeEnv contains the environment-derived values used by the server/serve path.
@@ -85,6 +276,14 @@ type ServeEnv struct {
 	PrivateKeyFile string
 	// SocketPath is the Unix domain socket path the server will listen on.
 	SocketPath string
+	// ListenAddr, if set, makes the server listen on TCP with mutual TLS
+	// using the TLS* files instead of SocketPath.
+	ListenAddr string
+	TLSCert    string
+	TLSKey     string
+	TLSCA      string
+	// Regex is what every value in a request must match (OJSTER_REGEX).
+	Regex string
 }
 
 // getenvDefaultAndUnset returns the value of env key if set, otherwise def.
@@ -99,19 +298,66 @@ func getenvDefaultAndUnset(key, def string) string {
 }
 
 func getSocketPath() string {
-	return getenvDefaultAndUnset("OJSTER_SOCKET_PATH", "/mnt/ojster/ipc.sock")
+	return getenvDefaultAndUnset("OJSTER_SOCKET_PATH", ipc.DefaultPath)
 }
 
 // readRunEnv reads only the env vars needed for run mode and clears them.
 func readRunEnv() RunEnv {
 	re := getenvDefaultAndUnset("OJSTER_REGEX", pqc.DefaultValueRegex())
-	return RunEnv{Regex: re, SocketPath: getSocketPath()}
+	env := RunEnv{
+		Regex:       re,
+		SocketPath:  getSocketPath(),
+		Route:       getenvDefaultAndUnset("OJSTER_ROUTE", "/"),
+		Timeout:     getenvDefaultAndUnset("OJSTER_TIMEOUT", ""),
+		Format:      getenvDefaultAndUnset("OJSTER_WIRE_FORMAT", client.FormatJSON),
+		StateDir:    getenvDefaultAndUnset("OJSTER_STATE_DIR", ""),
+		ReadyFile:   getenvDefaultAndUnset("OJSTER_READY_FILE", ""),
+		User:        getenvDefaultAndUnset("OJSTER_USER", ""),
+		Chdir:       getenvDefaultAndUnset("OJSTER_CHDIR", ""),
+		Umask:       getenvDefaultAndUnset("OJSTER_UMASK", ""),
+		KillTimeout: getenvDefaultAndUnset("OJSTER_KILL_TIMEOUT", ""),
+		Restart:     getenvDefaultAndUnset("OJSTER_RESTART", ""),
+	}
+	// Pins are only worth keeping in a state dir that outlives the
+	// container, so pinning is off unless OJSTER_STATE_DIR is set or
+	// OJSTER_PIN asks for it.
+	pinDefault := "off"
+	if env.StateDir != "" {
+		pinDefault = "warn"
+	}
+	env.Pin = getenvDefaultAndUnset("OJSTER_PIN", pinDefault)
+	if env.StateDir == "" {
+		env.StateDir = defaultStateDir()
+	}
+	env.Wait, _ = strconv.ParseBool(getenvDefaultAndUnset("OJSTER_WAIT", "false"))
+	env.Passthrough, _ = strconv.ParseBool(getenvDefaultAndUnset("OJSTER_PASSTHROUGH", "false"))
+	return env
+}
+
+// defaultStateDir returns $XDG_STATE_HOME/ojster, falling back to
+// ~/.local/state/ojster, or "" without a home directory.
+func defaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ojster")
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return filepath.Join(home, ".local", "state", "ojster")
+	}
+	return ""
 }
 
 // readServeEnv reads only the env vars needed for serve mode and clears them.
 func readServeEnv() ServeEnv {
 	priv := getenvDefaultAndUnset("OJSTER_PRIVATE_KEY_FILE", "/run/secrets/private_key")
-	return ServeEnv{PrivateKeyFile: priv, SocketPath: getSocketPath()}
+	return ServeEnv{
+		PrivateKeyFile: priv,
+		SocketPath:     getSocketPath(),
+		ListenAddr:     getenvDefaultAndUnset("OJSTER_LISTEN_ADDR", ""),
+		TLSCert:        getenvDefaultAndUnset("OJSTER_TLS_CERT", ""),
+		TLSKey:         getenvDefaultAndUnset("OJSTER_TLS_KEY", ""),
+		TLSCA:          getenvDefaultAndUnset("OJSTER_TLS_CA", ""),
+		Regex:          getenvDefaultAndUnset("OJSTER_REGEX", pqc.DefaultValueRegex()),
+	}
 }
 
 // usage prints the composed help text to the provided writer.
@@ -120,32 +366,80 @@ func usage(outw io.Writer) {
 	printCommands(outw)
 }
 
-func printCommands(outw io.Writer) {
-	pairs := [][2]string{
-		{keypairSynopsis, keypairDesc},
-		{sealSynopsis, sealDesc},
-		{unsealSynopsis, unsealDesc},
-		{runSynopsis, runDesc},
-		{serveSynopsis, serveDesc},
-	}
+// command describes a subcommand for the help text and help --json.
+type command struct {
+	synopsis, desc, args string
+}
+
+// commands lists the subcommands in the order help shows them.
+var commands = []command{
+	{keypairSynopsis, keypairDesc, keypairArgs},
+	{pubkeySynopsis, pubkeyDesc, pubkeyArgs},
+	{inspectSynopsis, inspectDesc, inspectArgs},
+	{sealSynopsis, sealDesc, sealArgs},
+	{unsealSynopsis, unsealDesc, unsealArgs},
+	{unsealExecSynopsis, unsealExecDesc, unsealExecArgs},
+	{importSynopsis, importDesc, importArgs},
+	{migrateSynopsis, migrateDesc, migrateArgs},
+	{diffSynopsis, diffDesc, diffArgs},
+	{bundleSynopsis, bundleDesc, bundleArgs},
+	{k8sSynopsis, k8sDesc, k8sArgs},
+	{checkComposeSynopsis, checkComposeDesc, checkComposeArgs},
+	{precommitSynopsis, precommitDesc, precommitArgs},
+	{scanSynopsis, scanDesc, scanArgs},
+	{doctorSynopsis, doctorDesc, doctorArgs},
+	{installInitSynopsis, installInitDesc, installInitArgs},
+	{runSynopsis, runDesc, runArgs},
+	{agentSynopsis, agentDesc, agentArgs},
+	{serveSynopsis, serveDesc, serveArgs},
+	{pluginSynopsis, pluginDesc, pluginArgs},
+}
 
+func printCommands(outw io.Writer) {
 	// compute max synopsis length for alignment
 	max := 0
-	for _, p := range pairs {
-		if l := len(p[0]); l > max {
+	for _, c := range commands {
+		if l := len(c.synopsis); l > max {
 			max = l
 		}
 	}
 
-	for _, p := range pairs {
-		fmt.Fprintf(outw, "  %-*s  %s\n", max, p[0], p[1])
+	for _, c := range commands {
+		fmt.Fprintf(outw, "  %-*s  %s\n", max, c.synopsis, c.desc)
 	}
 }
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// optionalString is a flag that may be given bare ("--remote") or with a
+// value ("--remote=PATH"); a bare flag sets it to the empty string.
+type optionalString struct {
+	set   bool
+	value string
+}
+
+func (o *optionalString) String() string { return o.value }
+func (o *optionalString) Set(v string) error {
+	o.set = true
+	if v != "true" {
+		o.value = v
+	}
+	return nil
+}
+func (o *optionalString) IsBoolFlag() bool { return true }
+
 // parseFlags parses args using fs and handles help/errors consistently.
 // Returns a non‑zero exit code if parsing should stop, otherwise 0.
 
 func parseFlags(fs *flag.FlagSet, args []string, errw io.Writer, cmdName string) int {
+	if collectFlags != nil {
+		collectFlags(fs)
+		return 0
+	}
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
 			return 0
@@ -177,6 +471,15 @@ func main() {
 // Entrypoint writes to the provided writers and returns an exit code.
 // It does not call os.Exit.
 func entrypoint(prog string, args []string, version string, outw io.Writer, errw io.Writer) int {
+	pqc.PassphraseFunc = keyPassphrase
+	errFormat := "text"
+	if prog != "docker-init" {
+		var err error
+		if errFormat, args, err = cutErrorFormat(args); err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+	}
 	if len(args) == 0 {
 		usage(outw)
 		return 0
@@ -191,18 +494,52 @@ func entrypoint(prog string, args []string, version string, outw io.Writer, errw
 		sub = args[0]
 		rawSubArgs = args[1:]
 	}
+	if errFormat == "json" {
+		jw := &jsonErrors{w: errw, command: sub}
+		defer jw.Flush()
+		errw = jw
+	}
 
 	switch sub {
 	case "help":
-		usage(outw)
-		return 0
+		return handleHelp(rawSubArgs, version, outw, errw)
 	case "version":
-		fmt.Fprintln(outw, version)
-		return 0
+		return handleVersion(rawSubArgs, version, outw, errw)
+	case "agent":
+		return handleAgent(rawSubArgs, outw, errw)
+	case "bundle":
+		return handleBundle(rawSubArgs, outw, errw)
+	case "check-compose":
+		return handleCheckCompose(rawSubArgs, outw, errw)
+	case "diff":
+		return handleDiff(rawSubArgs, outw, errw)
+	case "doctor":
+		return handleDoctor(rawSubArgs, outw, errw)
+	case "import":
+		return handleImport(rawSubArgs, outw, errw)
+	case "inspect":
+		return handleInspect(rawSubArgs, outw, errw)
+	case "install-init":
+		return handleInstallInit(rawSubArgs, outw, errw)
+	case server.IsolatedExecCommand:
+		// Not listed in the help: serve --isolate starts commands through it.
+		return server.IsolatedExec(rawSubArgs, errw)
+	case "migrate":
+		return handleMigrate(rawSubArgs, outw, errw)
+	case "pubkey":
+		return handlePubkey(rawSubArgs, outw, errw)
+	case "k8s":
+		return handleK8s(rawSubArgs, outw, errw)
 	case "keypair":
 		return handleKeypair(rawSubArgs, outw, errw)
+	case "plugin":
+		return handlePlugin(rawSubArgs, outw, errw)
+	case "precommit":
+		return handlePrecommit(rawSubArgs, outw, errw)
 	case "run":
 		return handleRun(rawSubArgs, outw, errw)
+	case "scan":
+		return handleScan(rawSubArgs, outw, errw)
 	case "seal":
 		return handleSeal(rawSubArgs, outw, errw)
 	case "serve":
@@ -218,13 +555,78 @@ func entrypoint(prog string, args []string, version string, outw io.Writer, errw
 
 // ------------------------- subcommand handlers ---------------------------
 
-// handleKeypair uses FlagSet semantics and delegates to pqc.KeypairWithPaths.
+// readBuildInfo is a var so tests can supply build information.
+var readBuildInfo = debug.ReadBuildInfo
+
+// handleVersion prints the version, and with --verbose what support
+// requests need: how the binary was built and what it can seal and open.
+func handleVersion(args []string, version string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "version"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	verbose := fs.Bool("verbose", false, "also print build information and the supported formats and algorithms")
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(errw, "version takes no arguments, got %q\n", fs.Args())
+		return 2
+	}
+	fmt.Fprintln(outw, version)
+	if !*verbose {
+		return 0
+	}
+
+	module, commit, goVersion := "unknown", "unknown", runtime.Version()
+	if bi, ok := readBuildInfo(); ok {
+		module, goVersion = bi.Main.Path+" "+bi.Main.Version, bi.GoVersion
+		settings := map[string]string{}
+		for _, s := range bi.Settings {
+			settings[s.Key] = s.Value
+		}
+		if rev := settings["vcs.revision"]; rev != "" {
+			commit = rev
+			if settings["vcs.modified"] == "true" {
+				commit += " (dirty)"
+			}
+			if t := settings["vcs.time"]; t != "" {
+				commit += " from " + t
+			}
+		}
+	}
+	encodings := slices.Clone(pqc.Encodings)
+	encodings[slices.Index(encodings, pqc.EncodingBase64)] += " (default)"
+	for _, line := range [][2]string{
+		{"module", module},
+		{"commit", commit},
+		{"go", goVersion + " " + runtime.GOOS + "/" + runtime.GOARCH},
+		{"protocol", protocol.Versions()},
+		{"formats", strings.Join(pqc.Formats, ", ")},
+		{"kem", pqc.KEM},
+		{"cipher", pqc.Cipher},
+		{"encodings", strings.Join(encodings, ", ")},
+		{"key wrapping", pqc.KDF},
+	} {
+		fmt.Fprintf(outw, "%-13s %s\n", line[0]+":", line[1])
+	}
+	return 0
+}
+
+// handleKeypair uses FlagSet semantics and delegates to pqc.KeypairWithOptions.
 func handleKeypair(args []string, outw io.Writer, errw io.Writer) int {
 	const cmdName = "keypair"
 	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 	fs.SetOutput(outw)
 	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to write")
 	pubPath := fs.String("pub-file", pqc.DefaultPubFile(), "public key filename to write")
+	privFD := fs.Int("priv-fd", -1, "write the private key to this open file descriptor (e.g. 3, or 1 for stdout) instead of --priv-file")
+	pubStdout := fs.Bool("pub-stdout", false, "write the public key to stdout instead of --pub-file; the summary then goes to stderr")
+	askPassphrase := fs.Bool("passphrase", false, "wrap the private key with a passphrase read from the terminal")
+	passphraseFile := fs.String("passphrase-file", "", "wrap the private key with the passphrase in this file")
+	pemOut := fs.Bool("pem", false, "write both keys as PEM blocks with algorithm and creation date headers")
+	comment := fs.String("comment", "", "label stored with both keys, together with the creation time (see inspect)")
+	showMnemonic := fs.Bool("mnemonic", false, "also print the private key as 48 recovery words for a paper backup")
+	restore := fs.Bool("restore", false, "recreate the keypair from recovery words read from the terminal instead of generating one")
 	fs.Usage = func() {
 		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", keypairSynopsis, keypairArgs, keypairDesc)
 		fs.PrintDefaults()
@@ -234,8 +636,70 @@ func handleKeypair(args []string, outw io.Writer, errw io.Writer) int {
 		return code
 	}
 
-	// pqc.KeypairWithPaths follows the writer/exit-code pattern.
-	return pqc.KeypairWithPaths(*privPath, *pubPath, outw, errw)
+	// Keep stdout clean for whichever key is written to it.
+	if strings.ContainsAny(*comment, "\r\n") {
+		fmt.Fprintln(errw, "--comment must be a single line")
+		return 2
+	}
+	opts := pqc.KeypairOptions{PEM: *pemOut, Comment: *comment, Mnemonic: *showMnemonic}
+	if *showMnemonic && *restore {
+		fmt.Fprintln(errw, "--mnemonic and --restore cannot be combined")
+		return 2
+	}
+	summaryw := outw
+	if *pubStdout {
+		opts.PubOut = outw
+		summaryw = errw
+	}
+	switch fd := *privFD; {
+	case fd == -1:
+	case fd == 0 || fd < -1:
+		fmt.Fprintf(errw, "invalid --priv-fd %d\n", fd)
+		return 2
+	case fd == 1 && *pubStdout:
+		fmt.Fprintln(errw, "--priv-fd 1 and --pub-stdout cannot both write to stdout")
+		return 2
+	case fd == 1:
+		opts.PrivOut, opts.PrivOutName = outw, "standard output"
+		summaryw = errw
+	case fd == 2:
+		opts.PrivOut, opts.PrivOutName = errw, "standard error"
+	default:
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+		defer f.Close()
+		opts.PrivOut, opts.PrivOutName = f, fmt.Sprintf("file descriptor %d", fd)
+	}
+
+	if *restore {
+		words, err := tty.ReadSecretFromStdin("Enter the 48 recovery words (input will be hidden). Press Ctrl-D twice when done.\n")
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to read recovery words: %w", err))
+			return 1
+		}
+		seed, err := pqc.SeedFromMnemonic(string(words))
+		memlock.Wipe(words)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		defer memlock.Wipe(seed)
+		opts.Seed = seed
+	}
+	if *askPassphrase && *passphraseFile != "" {
+		fmt.Fprintln(errw, "--passphrase and --passphrase-file cannot be combined")
+		return 2
+	}
+	if *askPassphrase || *passphraseFile != "" {
+		passphrase, err := readPassphrase(*passphraseFile, "Enter a passphrase for the private key (input will be hidden). Press Ctrl-D twice when done.\n")
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		defer memlock.Wipe(passphrase)
+		opts.Passphrase = passphrase
+	}
+
+	return pqc.KeypairWithOptions(*privPath, *pubPath, opts, summaryw, errw)
 }
 
 // handleSeal reads plaintext from tty and calls pqc.SealWithPlaintext.
@@ -244,7 +708,21 @@ func handleSeal(args []string, outw io.Writer, errw io.Writer) int {
 	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 	fs.SetOutput(outw)
 	pubPath := fs.String("pub-file", pqc.DefaultPubFile(), "public key filename to read")
-	outPath := fs.String("out", ".env", "env file path to write")
+	outPath := fs.String("out", ".env", "env file path to write (.json files take a dot-separated KEY path)")
+	var gpgRecipients stringList
+	fs.Var(&gpgRecipients, "gpg-recipient", "seal to this OpenPGP key ID/user ID via gpg instead of the public key file (repeatable)")
+	composePath := fs.String("compose", "", "write into the environment: section of a service in this compose file instead of --out")
+	service := fs.String("service", "", "compose service to write to (requires --compose)")
+	stdinJSON := fs.Bool("stdin-json", false, `read a JSON object {"KEY": "value", ...} from stdin and seal every pair (no KEY argument)`)
+	ifChanged := fs.Bool("if-changed", false, "keep the existing value if it already holds the same plaintext (env files only)")
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "with --if-changed: private key to compare with; without it a commitment comment is stored")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	var remote optionalString
+	fs.Var(&remote, "remote", "seal with the public key of the running server, fetched over its socket (--remote=PATH, default OJSTER_SOCKET_PATH) instead of --pub-file")
+	allowLowercase := fs.Bool("allow-lowercase", false, "accept key names with lowercase letters, which ojster run and serve reject")
+	keyPattern := fs.String("key-pattern", "", "regexp key names must match instead of "+env.KeyNameRegex.String())
+	frame := fs.Bool("frame", false, "seal in the OJSTER-2 format, which reports truncated and altered values; older ojster releases cannot open it")
+	encoding := fs.String("encoding", pqc.EncodingBase64, "encoding of the sealed value: "+strings.Join(pqc.Encodings, " or ")+" (URL-safe, unpadded; unseal detects it)")
 	fs.Usage = func() {
 		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", sealSynopsis, sealArgs, sealDesc)
 		fs.PrintDefaults()
@@ -256,29 +734,273 @@ func handleSeal(args []string, outw io.Writer, errw io.Writer) int {
 
 	var pos = fs.Args()
 
-	if len(pos) != 1 {
+	if *stdinJSON {
+		if len(pos) != 0 {
+			fmt.Fprintln(errw, "seal --stdin-json takes the keys from the JSON input, not as arguments")
+			return 2
+		}
+	} else if len(pos) != 1 {
 		fmt.Fprintln(errw, "seal requires exactly one positional argument: KEY")
 		return 1
 	}
-	keyName := pos[0]
 
-	plaintext, err := tty.ReadSecretFromStdin("Reading plaintext input from stdin (input will be hidden). Press Ctrl-D twice when done.\n")
+	if !slices.Contains(pqc.Encodings, *encoding) {
+		fmt.Fprintf(errw, "invalid --encoding %q: want %s\n", *encoding, strings.Join(pqc.Encodings, " or "))
+		return 2
+	}
+	if (*frame || *encoding != pqc.EncodingBase64) && len(gpgRecipients) > 0 {
+		fmt.Fprintln(errw, "--frame and --encoding only apply to values sealed with the public key")
+		return 2
+	}
+	if remote.set {
+		pubSet := false
+		fs.Visit(func(f *flag.Flag) { pubSet = pubSet || f.Name == "pub-file" })
+		if pubSet || len(gpgRecipients) > 0 {
+			fmt.Fprintln(errw, "--remote cannot be combined with --pub-file or --gpg-recipient")
+			return 2
+		}
+		path, cleanup, code := fetchRemotePubkey(remote.value, errw)
+		if code != 0 {
+			return code
+		}
+		defer cleanup()
+		*pubPath = path
+	}
+	if (*composePath == "") != (*service == "") {
+		fmt.Fprintln(errw, "--compose and --service must be used together")
+		return 2
+	}
+	keyRe := env.KeyNameRegex
+	switch {
+	case *allowLowercase && *keyPattern != "":
+		fmt.Fprintln(errw, "--allow-lowercase and --key-pattern cannot be combined")
+		return 2
+	case *allowLowercase:
+		keyRe = lowercaseKeyNameRegex
+	case *keyPattern != "":
+		re, err := regexp.Compile(*keyPattern)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("invalid --key-pattern: %w", err))
+			return 2
+		}
+		keyRe = re
+	}
+	// Keys of .json files are dot-separated paths, not variable names.
+	checkKeys := *composePath != "" || !jsonfile.IsJSONPath(*outPath)
+	if checkKeys && !*stdinJSON {
+		if code := checkSealKey(pos[0], keyRe, errw); code != 0 {
+			return code
+		}
+	}
+	if *ifChanged && (len(gpgRecipients) > 0 || *composePath != "" || jsonfile.IsJSONPath(*outPath)) {
+		fmt.Fprintln(errw, "--if-changed only supports env files sealed with the public key")
+		return 2
+	}
+	if *ifChanged {
+		if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+			return code
+		}
+	}
+	store := func(keyName, sealed string) int { return writeSealed(*outPath, keyName, sealed, outw, errw) }
+	if *composePath != "" {
+		store = func(keyName, sealed string) int {
+			if err := compose.UpdateFile(*composePath, *service, keyName, sealed); err != nil {
+				fmt.Fprintln(errw, fmt.Errorf("failed to update compose file %s: %w", *composePath, err))
+				return 1
+			}
+			fmt.Fprintf(outw, "Wrote %s to service %s in %s\n", keyName, *service, *composePath)
+			return 0
+		}
+	}
+
+	var entries []pqc.SealEntry
+	if *stdinJSON {
+		data, err := tty.ReadSecretFromStdin("Reading a JSON object of KEY: value pairs from stdin (input will be hidden). Press Ctrl-D twice when done.\n")
+		if err != nil {
+			fmt.Fprintln(errw, err.Error())
+			return 1
+		}
+		if entries, err = parseJSONSecrets(data); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("invalid --stdin-json input: %w", err))
+			return 1
+		}
+		for _, e := range entries {
+			if !checkKeys {
+				break
+			}
+			if code := checkSealKey(e.Key, keyRe, errw); code != 0 {
+				return code
+			}
+		}
+	} else {
+		plaintext, err := tty.ReadSecretFromStdin("Reading plaintext input from stdin (input will be hidden). Press Ctrl-D twice when done.\n")
+		if err != nil {
+			fmt.Fprintln(errw, err.Error())
+			return 1
+		}
+		entries = []pqc.SealEntry{{Key: pos[0], Plaintext: plaintext}}
+	}
+
+	var seal func(plaintext []byte) (string, error)
+	switch {
+	case len(gpgRecipients) > 0:
+		seal = func(plaintext []byte) (string, error) {
+			sealed, err := gpg.Seal(gpgRecipients, plaintext)
+			if err != nil {
+				return "", fmt.Errorf("gpg encryption failed: %w", err)
+			}
+			return sealed, nil
+		}
+	case *composePath != "" || jsonfile.IsJSONPath(*outPath):
+		seal = func(plaintext []byte) (string, error) {
+			return pqc.SealWithOptions(*pubPath, plaintext, pqc.SealOptions{Frame: *frame, Encoding: *encoding})
+		}
+	default:
+		opts := pqc.SealOptions{IfChanged: *ifChanged, PrivPath: *privPath, Frame: *frame, Encoding: *encoding}
+		return pqc.SealEntries(*pubPath, *outPath, entries, opts, outw, errw)
+	}
+	for _, e := range entries {
+		sealed, err := seal(e.Plaintext)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		if code := store(e.Key, sealed); code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// lowercaseKeyNameRegex is the key name pattern of seal --allow-lowercase.
+var lowercaseKeyNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// checkSealKey refuses a key name that does not match re, and warns about one
+// that only matches because of an override, as ojster run and serve will
+// reject it.
+func checkSealKey(key string, re *regexp.Regexp, errw io.Writer) int {
+	if !re.MatchString(key) {
+		if re == env.KeyNameRegex {
+			fmt.Fprintf(errw, "invalid key name %q: it must match %s (uppercase letters, digits and underscores, starting with a letter); use --allow-lowercase or --key-pattern to seal it anyway\n", key, re)
+		} else {
+			fmt.Fprintf(errw, "invalid key name %q: it must match %s\n", key, re)
+		}
+		return 2
+	}
+	if !env.KeyNameRegex.MatchString(key) {
+		fmt.Fprintf(errw, "warning: key name %q does not match %s, so ojster run and serve will reject it\n", key, env.KeyNameRegex)
+	}
+	return 0
+}
+
+// fetchRemotePubkey fetches the public key of the server on socketPath (or
+// OJSTER_SOCKET_PATH and OJSTER_ROUTE) into a temporary file, and returns
+// its path and a function that removes it.
+func fetchRemotePubkey(socketPath string, errw io.Writer) (string, func(), int) {
+	ep, err := readRunEnv().endpoint(errw)
 	if err != nil {
-		fmt.Fprintln(errw, err.Error())
-		return 1
+		fmt.Fprintln(errw, err)
+		return "", nil, 2
+	}
+	if socketPath != "" {
+		ep.SocketPath = socketPath
+	}
+	pub, err := client.FetchPublicKey(context.Background(), ep)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to fetch the public key from %s: %w", ep.SocketPath, err))
+		return "", nil, 1
+	}
+	f, err := os.CreateTemp("", "ojster-pub-*")
+	if err == nil {
+		_, err = f.Write(pub)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to store the fetched public key: %w", err))
+		if f != nil {
+			_ = os.Remove(f.Name())
+		}
+		return "", nil, 1
+	}
+	return f.Name(), func() { _ = os.Remove(f.Name()) }, 0
+}
+
+// parseJSONSecrets reads a flat JSON object of string values, keeping the
+// order of its keys.
+func parseJSONSecrets(data []byte) ([]pqc.SealEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+	var entries []pqc.SealEntry
+	seen := map[string]bool{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %q is not a string", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+		entries = append(entries, pqc.SealEntry{Key: key, Plaintext: []byte(s)})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON object")
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no keys in the JSON object")
 	}
+	return entries, nil
+}
 
-	return pqc.SealWithPlaintext(*pubPath, *outPath, keyName, plaintext, outw, errw)
+// writeSealed stores a sealed value into outPath: under keyName in an env file,
+// or at the keyName path of a .json document.
+func writeSealed(outPath, keyName, sealed string, outw io.Writer, errw io.Writer) int {
+	if jsonfile.IsJSONPath(outPath) {
+		if err := jsonfile.UpdateFile(outPath, keyName, sealed); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to update JSON file %s: %w", outPath, err))
+			return 1
+		}
+	} else if err := env.UpdateEnvFile(outPath, keyName, sealed); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to update env file %s: %w", outPath, err))
+		return 1
+	}
+	fmt.Fprintf(outw, "Wrote %s to %s\n", keyName, outPath)
+	return 0
 }
 
 // handleUnseal uses FlagSet semantics and delegates to pqc.UnsealFromFiles.
 func handleUnseal(args []string, outw io.Writer, errw io.Writer) int {
 	const cmdName = "unseal"
+	if len(args) > 0 && args[0] == "exec" {
+		return handleUnsealExec(args[1:], outw, errw)
+	}
 	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 	fs.SetOutput(outw)
-	inPath := fs.String("in", ".env", "env file path to read")
+	inPath := fs.String("in", ".env", "env file path to read, or a quoted glob such as 'envs/*.env' to unseal several")
 	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read")
-	jsonOut := fs.Bool("json", false, "output decrypted keys/values as JSON object")
+	jsonOut := fs.Bool("json", false, "output decrypted keys/values as JSON object (for .json input: the whole decrypted document)")
+	useGPG := fs.Bool("gpg", false, "decrypt OpenPGP-sealed values via gpg/gpg-agent instead of the private key file")
+	interpolate := fs.Bool("interpolate", false, "expand ${VAR} references after unsealing (docker compose semantics); without KEYs also prints entries that reference other variables")
+	format := fs.String("format", env.FormatEnv, "text output format: env, systemd (EnvironmentFile=), docker (docker run --env-file) or shell (export statements)")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	var prefixes stringList
+	fs.Var(&prefixes, "prefix", "also select the sealed keys starting with this prefix (repeatable)")
 	fs.Usage = func() {
 		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", unsealSynopsis, unsealArgs, unsealDesc)
 		fs.PrintDefaults()
@@ -288,62 +1010,1049 @@ func handleUnseal(args []string, outw io.Writer, errw io.Writer) int {
 		return code
 	}
 
-	return pqc.UnsealFromFiles(*inPath, *privPath, fs.Args(), *jsonOut, outw, errw)
-}
+	keys := fs.Args()
+	for _, p := range prefixes {
+		keys = append(keys, env.PrefixPattern(p))
+	}
 
-// handleRun passes through positional args to client.Run while using FlagSet
-// semantics for the command separator. The command to exec is provided after
-// an optional "--" separator: "ojster run [--] command [args...]".
-func handleRun(args []string, outw io.Writer, errw io.Writer) int {
-	const cmdName = "run"
-	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
-	fs.SetOutput(outw)
-	// No run-specific flags currently; command follows optional "--".
-	fs.Usage = func() {
-		fmt.Fprintf(outw, "%s %s\n\n%s\n", runSynopsis, runArgs, runDesc)
-		fs.PrintDefaults()
+	if *interpolate && *useGPG {
+		fmt.Fprintln(errw, "--interpolate is only supported with --priv-file")
+		return 2
+	}
+	if jsonfile.IsJSONPath(*inPath) && *interpolate {
+		fmt.Fprintln(errw, "--interpolate is not supported for JSON files")
+		return 2
+	}
+	if !slices.Contains(env.Formats, *format) {
+		fmt.Fprintf(errw, "unknown --format %q (want %s)\n", *format, strings.Join(env.Formats, ", "))
+		return 2
+	}
+	if *format != env.FormatEnv && (*jsonOut || jsonfile.IsJSONPath(*inPath)) {
+		fmt.Fprintln(errw, "--format applies to env output only and cannot be combined with --json or JSON files")
+		return 2
+	}
+	if !*useGPG {
+		if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+			return code
+		}
 	}
 
-	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
-		return code
+	unsealTo := func(inPath string, jsonOut bool, outw io.Writer) int {
+		if jsonfile.IsJSONPath(inPath) {
+			return unsealJSON(inPath, *privPath, *useGPG, keys, jsonOut, outw, errw)
+		}
+		if *useGPG {
+			return gpg.UnsealFromFile(inPath, keys, jsonOut, outw, errw)
+		}
+		opts := pqc.UnsealOptions{JSON: jsonOut, Interpolate: *interpolate}
+		return pqc.UnsealFromFilesWithOptions(context.Background(), inPath, *privPath, keys, opts, outw, errw)
+	}
+	unsealOne := func(inPath string, outw io.Writer) int {
+		if *format == env.FormatEnv {
+			return unsealTo(inPath, *jsonOut, outw)
+		}
+		// Other formats are rendered from the JSON result, so every
+		// decryption backend supports them.
+		var buf bytes.Buffer
+		if code := unsealTo(inPath, true, &buf); code != 0 {
+			return code
+		}
+		return writeFormatted(buf.Bytes(), keys, *format, outw, errw)
 	}
 
-	var cmdArgs = fs.Args()
-	if len(cmdArgs) > 0 && cmdArgs[0] == "--" {
-		cmdArgs = cmdArgs[1:]
+	if !isGlob(*inPath) {
+		return unsealOne(*inPath, outw)
 	}
+	return forEachGlobMatch(cmdName, *inPath, *jsonOut, unsealOne, outw, errw)
+}
 
-	if len(cmdArgs) < 1 {
-		fmt.Fprintf(errw, "run requires a next command to execute. Usage: %s %s\n", runSynopsis, runArgs)
-		return 2
+// checkKeyPerms applies pqc.CheckKeyPerms once for a command: the key is
+// refused, or with insecure only warned about and then loaded without further
+// checks. A missing file is left for the command to report.
+func checkKeyPerms(privPath string, insecure bool, errw io.Writer) int {
+	err := pqc.CheckKeyPerms(privPath)
+	switch {
+	case err == nil || errors.Is(err, os.ErrNotExist):
+		return 0
+	case insecure:
+		fmt.Fprintf(errw, "warning: %v\n", err)
+		pqc.InsecureKeyPerms = true
+		return 0
+	default:
+		fmt.Fprintf(errw, "%v; pass --insecure-key-perms to use it anyway\n", err)
+		return 1
 	}
+}
 
-	runEnv := readRunEnv()
-	return client.Run(runEnv.Regex, runEnv.SocketPath, cmdArgs, outw, errw)
+// readPassphrase reads a passphrase from path, or from the terminal with
+// prompt when path is empty. Trailing newlines are not part of it.
+func readPassphrase(path, prompt string) ([]byte, error) {
+	var b []byte
+	var err error
+	if path != "" {
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+	} else if b, err = tty.ReadSecretFromStdin(prompt); err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	_ = memlock.Lock(b)
+	p := bytes.TrimRight(b, "\r\n")
+	if len(p) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return p, nil
 }
 
-// handleServe starts the server. The server accepts a command to run after an
-// optional "--" separator: "ojster serve [--] command [args...]". There are no
-// serve-specific flags at the moment.
-func handleServe(args []string, outw io.Writer, errw io.Writer) int {
-	const cmdName = "serve"
+// keyPassphrase is pqc.PassphraseFunc: it unlocks passphrase-wrapped private
+// keys with OJSTER_PASSPHRASE_FILE, or by prompting for the passphrase.
+func keyPassphrase(privPath string) ([]byte, error) {
+	return readPassphrase(os.Getenv("OJSTER_PASSPHRASE_FILE"), fmt.Sprintf("Enter the passphrase for %s (input will be hidden). Press Ctrl-D twice when done.\n", privPath))
+}
+
+// unlockKey unlocks a passphrase-wrapped private key up front, so the server
+// has it cached before Landlock or a privilege drop could keep it from
+// reading the passphrase. Plain keys are left to be read per request.
+func unlockKey(privPath string, errw io.Writer) int {
+	data, err := os.ReadFile(privPath)
+	if err != nil || !pqc.IsWrappedKey(data) {
+		return 0
+	}
+	if _, err := pqc.LoadDecapsulationKey(privPath); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	return 0
+}
+
+// handleUnsealExec decrypts the sealed entries of an env file with the local
+// private key and execs the command with every entry of the file set in its
+// environment, overriding inherited variables of the same name.
+func handleUnsealExec(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "unseal exec"
 	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
 	fs.SetOutput(outw)
-	// No serve-specific flags currently; command follows optional "--".
+	inPath := fs.String("in", ".env", "env file path to read")
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
 	fs.Usage = func() {
-		fmt.Fprintf(outw, "%s %s\n\n%s\n", serveSynopsis, serveArgs, serveDesc)
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", unsealExecSynopsis, unsealExecArgs, unsealExecDesc)
 		fs.PrintDefaults()
 	}
-
 	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
 		return code
 	}
-
-	var cmdArgs = fs.Args()
+	cmdArgs := fs.Args()
 	if len(cmdArgs) > 0 && cmdArgs[0] == "--" {
 		cmdArgs = cmdArgs[1:]
 	}
+	if len(cmdArgs) < 1 {
+		fmt.Fprintf(errw, "unseal exec requires a command to execute. Usage: %s %s\n", unsealExecSynopsis, unsealExecArgs)
+		return 2
+	}
+	if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+		return code
+	}
 
-	serveEnv := readServeEnv()
-	return server.Serve(serveEnv.PrivateKeyFile, serveEnv.SocketPath, context.Background(), cmdArgs, outw, errw)
+	entries, err := env.ParseEnvFileEntries(*inPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", *inPath, err))
+		return 1
+	}
+	values := env.EntriesMap(entries)
+	decrypted, err := pqc.UnsealMap(context.Background(), values, *privPath, nil)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	maps.Copy(values, decrypted)
+	return client.Exec(cmdArgs, values, errw)
+}
+
+// writeFormatted renders a JSON object of unsealed values in format, in the
+// order of keys if given and sorted otherwise.
+func writeFormatted(js []byte, keys []string, format string, outw io.Writer, errw io.Writer) int {
+	var values map[string]string
+	if err := json.Unmarshal(js, &values); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to decode unsealed values: %w", err))
+		return 1
+	}
+	keys, _ = env.SelectKeys(values, keys, func(string) bool { return true })
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+		line, err := env.FormatAs(format, k, v)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		_, _ = io.WriteString(outw, strings.Join(lines, "\n"))
+	}
+	return 0
+}
+
+// isGlob reports whether path contains filepath.Match metacharacters.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// forEachGlobMatch runs fn for every file matching pattern, in lexical order,
+// and aggregates the results. Text output is separated by "# FILE" header
+// lines; with jsonOut the per-file JSON outputs are combined into one object
+// keyed by file. Every file is attempted; the highest exit code is returned.
+func forEachGlobMatch(cmdName, pattern string, jsonOut bool, fn func(path string, outw io.Writer) int, outw io.Writer, errw io.Writer) int {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		fmt.Fprintf(errw, "invalid pattern %q: %v\n", pattern, err)
+		return 2
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(errw, "no files match %q\n", pattern)
+		return 1
+	}
+
+	code, failed := 0, 0
+	combined := make(map[string]json.RawMessage, len(matches))
+	for _, m := range matches {
+		var buf bytes.Buffer
+		c := fn(m, &buf)
+		if c != 0 {
+			failed++
+			code = max(code, c)
+			continue
+		}
+		if jsonOut {
+			combined[m] = json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+			continue
+		}
+		fmt.Fprintf(outw, "# %s\n", m)
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		_, _ = outw.Write(buf.Bytes())
+	}
+	if jsonOut {
+		j, _ := json.MarshalIndent(combined, "", "  ")
+		fmt.Fprintln(outw, string(j))
+	}
+	if failed > 0 {
+		fmt.Fprintf(errw, "%s: %d of %d files failed\n", cmdName, failed, len(matches))
+	}
+	return code
+}
+
+func handleDiff(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "diff"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	privPath := fs.String("priv-file", "", "private key to decrypt sealed values with before comparing")
+	showValues := fs.Bool("show-values", false, "print the (decrypted) values of differing keys")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", diffSynopsis, diffArgs, diffDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintf(errw, "diff requires two env files. Usage: %s %s\n", diffSynopsis, diffArgs)
+		return 2
+	}
+	return envdiff.Files(fs.Arg(0), fs.Arg(1), *privPath, *showValues, outw, errw)
+}
+
+// handlePubkey writes the public key of a private key file to outw, in the
+// same encoding and with the same metadata as the private key file, so
+// redirecting it restores a lost public key file.
+func handlePubkey(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "pubkey"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", pubkeySynopsis, pubkeyArgs, pubkeyDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(errw, "pubkey takes no arguments. Usage: %s %s\n", pubkeySynopsis, pubkeyArgs)
+		return 2
+	}
+	if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+		return code
+	}
+	pub, fingerprint, err := pqc.PublicKeyFromPrivate(*privPath)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	_, _ = outw.Write(pub)
+	fmt.Fprintf(errw, "Fingerprint: %s\n", fingerprint)
+	return 0
+}
+
+// handleInspect prints the metadata of each key file. A file that cannot be
+// read does not stop the others; the exit code is then 1.
+func handleInspect(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "inspect"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", inspectSynopsis, inspectArgs, inspectDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintf(errw, "inspect requires at least one key file. Usage: %s %s\n", inspectSynopsis, inspectArgs)
+		return 2
+	}
+	code := 0
+	for i, path := range fs.Args() {
+		info, err := pqc.InspectKeyFile(path)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			code = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(outw)
+		}
+		fmt.Fprintf(outw, "%s: %s (%s)\n", path, info.Kind, info.Encoding)
+		for _, f := range [][2]string{
+			{"Algorithm", info.Algorithm},
+			{"Fingerprint", info.Fingerprint},
+			{"KDF", info.KDF},
+			{"Created", info.Created},
+			{"Comment", info.Comment},
+		} {
+			if f[1] != "" {
+				fmt.Fprintf(outw, "  %-12s %s\n", f[0]+":", f[1])
+			}
+		}
+	}
+	return code
+}
+
+func handleImport(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "import"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fromPath := fs.String("from", "", "plaintext env file to import (required)")
+	outPath := fs.String("out", ".env", "env file to write the sealed values to (may equal --from)")
+	pubPath := fs.String("pub-file", pqc.DefaultPubFile(), "public key filename to read")
+	shred := fs.Bool("shred", false, "overwrite the plaintext source with zeros and remove it afterwards")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", importSynopsis, importArgs, importDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if *fromPath == "" {
+		fmt.Fprintf(errw, "import requires --from. Usage: %s %s\n", importSynopsis, importArgs)
+		return 2
+	}
+	return importenv.File(*fromPath, *outPath, *pubPath, *shred, outw, errw)
+}
+
+func handleMigrate(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "migrate"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	inPath := fs.String("in", ".env", "env file to migrate in place")
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read")
+	dryRun := fs.Bool("dry-run", false, "list the values that would be migrated without writing")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", migrateSynopsis, migrateArgs, migrateDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(errw, "migrate takes no arguments. Usage: %s %s\n", migrateSynopsis, migrateArgs)
+		return 2
+	}
+	if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+		return code
+	}
+	return pqc.Migrate(*privPath, *inPath, pqc.MigrateOptions{DryRun: *dryRun}, outw, errw)
+}
+
+// handleK8s dispatches "k8s export" to the k8s package and "k8s init" to client.Init.
+func handleK8s(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "k8s"
+	if len(args) == 0 || (args[0] != "export" && args[0] != "init") {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "help") {
+			fmt.Fprintf(outw, "%s %s\n\n%s\n", k8sSynopsis, k8sArgs, k8sDesc)
+			return 0
+		}
+		fmt.Fprintf(errw, "k8s requires an action. Usage: %s %s\n", k8sSynopsis, k8sArgs)
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet(cmdName+" "+action, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", k8sSynopsis, k8sArgs, k8sDesc)
+		fs.PrintDefaults()
+	}
+
+	if action == "init" {
+		socketPath := fs.String("socket", "", "Unix socket of the ojster server (default: OJSTER_SOCKET_PATH)")
+		addr := fs.String("addr", "", "reach the server on HOST:PORT over mutual TLS instead of the socket")
+		tlsCert := fs.String("tls-cert", "", "client certificate for --addr")
+		tlsKey := fs.String("tls-key", "", "client key for --addr")
+		tlsCA := fs.String("tls-ca", "", "CA that signed the server certificate for --addr")
+		configMapDir := fs.String("configmap-dir", "", "mounted ConfigMap directory (file name = key) to read sealed values from")
+		annotationsFile := fs.String("annotations-file", "", "downward API annotations file with ojster/KEY annotations (k8s export --kind sealed)")
+		outDir := fs.String("out-dir", "/ojster", "directory to write decrypted values to (must be tmpfs)")
+		format := fs.String("format", client.FormatEnv, "output: env (a single .env file) or files (one file per key)")
+		mode := fs.String("mode", "0600", "octal permissions of written files")
+		allowDisk := fs.Bool("allow-disk", false, "allow --out-dir on a non-tmpfs filesystem")
+		if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+			return code
+		}
+		perm, err := strconv.ParseUint(*mode, 8, 32)
+		if err != nil || perm > 0o777 {
+			fmt.Fprintf(errw, "invalid --mode %q\n", *mode)
+			return 2
+		}
+
+		runEnv := readRunEnv()
+		ep, err := runEnv.endpoint(errw)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+		ep.Addr = *addr
+		if *socketPath != "" {
+			ep.SocketPath = *socketPath
+		}
+		if *addr != "" {
+			if ep.TLS, err = mtls.ClientConfig(*tlsCert, *tlsKey, *tlsCA); err != nil {
+				fmt.Fprintln(errw, err)
+				return 1
+			}
+		}
+		opts := client.InitOptions{
+			Regex:           runEnv.Regex,
+			ConfigMapDir:    *configMapDir,
+			AnnotationsFile: *annotationsFile,
+			OutDir:          *outDir,
+			Format:          *format,
+			Mode:            os.FileMode(perm),
+			AllowDisk:       *allowDisk,
+		}
+		return client.Init(context.Background(), ep, opts, outw, errw)
+	}
+
+	inPath := fs.String("in", ".env", "env file path to read")
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read (kind secret only)")
+	kind := fs.String("kind", k8s.KindSecret, "manifest to emit: secret (decrypted values), external-secret, or sealed (ciphertexts as annotations)")
+	name := fs.String("name", "ojster-secrets", "metadata.name of the manifest")
+	namespace := fs.String("namespace", "", "metadata.namespace of the manifest (omitted if empty)")
+	store := fs.String("store", "", "SecretStore name (kind external-secret only)")
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+
+	opts := k8s.ExportOptions{Kind: *kind, Name: *name, Namespace: *namespace, Store: *store}
+	return k8s.ExportFromFile(*inPath, *privPath, fs.Args(), opts, outw, errw)
+}
+
+// handleCheckCompose validates a compose file against its env files.
+func handleCheckCompose(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "check-compose"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	envFile := fs.String("env-file", "", "project env file (default: .env next to the compose file)")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", checkComposeSynopsis, checkComposeArgs, checkComposeDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+
+	composePath := "docker-compose.yml"
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		composePath = fs.Arg(0)
+	default:
+		fmt.Fprintf(errw, "check-compose takes at most one compose file. Usage: %s %s\n", checkComposeSynopsis, checkComposeArgs)
+		return 2
+	}
+	return compose.Check(composePath, *envFile, outw, errw)
+}
+
+func handlePrecommit(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "precommit"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n", precommitSynopsis, precommitArgs, precommitDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	return precommit.Check(fs.Args(), outw, errw)
+}
+
+func handleDoctor(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "doctor"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	socketPath := fs.String("socket", getSocketPath(), "socket to check (default OJSTER_SOCKET_PATH)")
+	privPath := fs.String("priv-file", getenvDefaultAndUnset("OJSTER_PRIVATE_KEY_FILE", "/run/secrets/private_key"), "private key file to check; skipped when the default does not exist")
+	envPath := fs.String("env-file", ".env", "env file to check; without one, OJSTER_REGEX is checked against the environment")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", doctorSynopsis, doctorArgs, doctorDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(errw, "doctor takes no arguments. Usage: %s %s\n", doctorSynopsis, doctorArgs)
+		return 2
+	}
+
+	// A client host has no private key and a server host may have no env
+	// file, so missing defaults are skipped; missing explicit paths are not.
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	opts := doctor.Options{
+		SocketPath:     *socketPath,
+		PrivateKeyFile: *privPath,
+		EnvFile:        *envPath,
+		Regex:          getenvDefaultAndUnset("OJSTER_REGEX", pqc.DefaultValueRegex()),
+	}
+	if _, err := os.Stat(opts.PrivateKeyFile); err != nil && !set["priv-file"] {
+		opts.PrivateKeyFile = ""
+	}
+	if _, err := os.Stat(opts.EnvFile); err != nil && !set["env-file"] {
+		opts.EnvFile = ""
+	}
+	return doctor.Run(opts, outw, errw)
+}
+
+func handleInstallInit(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "install-init"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	target := fs.String("target", "/ojster-bin", "directory, usually a mounted volume, to copy the binary into")
+	volume := fs.String("volume", "ojster-bin", "name of that volume in the printed compose snippet")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", installInitSynopsis, installInitArgs, installInitDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(errw, "install-init takes no arguments. Usage: %s %s\n", installInitSynopsis, installInitArgs)
+		return 2
+	}
+	return installinit.Install(*target, *volume, outw, errw)
+}
+
+func handleScan(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "scan"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n", scanSynopsis, scanArgs, scanDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var findings []scan.Finding
+	for _, dir := range dirs {
+		f, err := scan.Dir(dir)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to scan %s: %w", dir, err))
+			return 1
+		}
+		findings = append(findings, f...)
+	}
+	for _, f := range findings {
+		fmt.Fprintln(outw, f)
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(errw, "%d value(s) should probably be sealed\n", len(findings))
+		return 1
+	}
+	return 0
+}
+
+// unsealJSON binds the selected backend's key material and delegates to jsonfile.UnsealFromFile.
+func unsealJSON(inPath, privPath string, useGPG bool, paths []string, jsonOut bool, outw io.Writer, errw io.Writer) int {
+	var unseal jsonfile.UnsealFunc
+	switch {
+	case useGPG:
+		unseal = func(values map[string]string, keys []string) (map[string]string, error) {
+			m, _, err := gpg.UnsealMap(values, keys)
+			return m, err
+		}
+	default:
+		unseal = func(values map[string]string, keys []string) (map[string]string, error) {
+			return pqc.UnsealMap(context.Background(), values, privPath, keys)
+		}
+	}
+	return jsonfile.UnsealFromFile(inPath, paths, unseal, jsonOut, outw, errw)
+}
+
+// handleBundle dispatches "bundle pack" and "bundle unpack" to the bundle package.
+func handleBundle(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "bundle"
+	if len(args) == 0 || (args[0] != "pack" && args[0] != "unpack") {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "help") {
+			fmt.Fprintf(outw, "%s %s\n\n%s\n", bundleSynopsis, bundleArgs, bundleDesc)
+			return 0
+		}
+		fmt.Fprintf(errw, "bundle requires an action. Usage: %s %s\n", bundleSynopsis, bundleArgs)
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet(cmdName+" "+action, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", bundleSynopsis, bundleArgs, bundleDesc)
+		fs.PrintDefaults()
+	}
+
+	if action == "pack" {
+		pubPath := fs.String("pub-file", pqc.DefaultPubFile(), "public key of the target environment")
+		outPath := fs.String("out", "bundle.ojster", "bundle file path to write")
+		if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+			return code
+		}
+		if fs.NArg() == 0 {
+			fmt.Fprintln(errw, "bundle pack requires at least one FILE")
+			return 1
+		}
+		return bundle.PackToFile(*pubPath, *outPath, fs.Args(), outw, errw)
+	}
+
+	inPath := fs.String("in", "bundle.ojster", "bundle file path to read")
+	privPath := fs.String("priv-file", pqc.DefaultPrivFile(), "private key filename to read")
+	dir := fs.String("dir", ".", "directory to unpack files into")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	return bundle.UnpackToDir(*inPath, *privPath, *dir, *force, outw, errw)
+}
+
+// handleAgent runs the caching agent in front of the server at
+// OJSTER_SOCKET_PATH.
+func handleAgent(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "agent"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	socketPath := fs.String("socket", defaultAgentSocket(), "per-user socket to serve run commands on (point their OJSTER_SOCKET_PATH here)")
+	ttl := fs.Duration("ttl", 5*time.Minute, "how long decrypted values are cached")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", agentSynopsis, agentArgs, agentDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(errw, "agent takes no arguments. Usage: %s %s\n", agentSynopsis, agentArgs)
+		return 2
+	}
+
+	upstream, err := readRunEnv().endpoint(errw)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 2
+	}
+	if upstream.SocketPath == *socketPath {
+		fmt.Fprintln(errw, "the agent cannot listen on the server's socket; set --socket or OJSTER_SOCKET_PATH")
+		return 2
+	}
+	return agent.Serve(agent.Options{SocketPath: *socketPath, Upstream: upstream, TTL: *ttl}, context.Background(), errw)
+}
+
+// defaultAgentSocket returns $XDG_RUNTIME_DIR/ojster/agent.sock, falling
+// back to a directory of the current user in the temp dir.
+func defaultAgentSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ojster", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ojster-%d", os.Getuid()), "agent.sock")
+}
+
+// handleRun passes through positional args to client.Run while using FlagSet
+// semantics for the command separator. The command to exec is provided after
+// an optional "--" separator: "ojster run [--] command [args...]".
+func handleRun(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "run"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	// The command follows an optional "--".
+	socketPath := fs.String("socket", "", "Unix socket of the ojster server (default: OJSTER_SOCKET_PATH)")
+	regex := fs.String("regex", "", "send the env values matching this regex (default: OJSTER_REGEX)")
+	timeout := fs.Duration("timeout", 0, "time each request to the server may take (default: OJSTER_TIMEOUT, else "+client.DefaultTimeout.String()+")")
+	passthrough := fs.Bool("passthrough", false, "exec the command unchanged when no values match OJSTER_REGEX instead of failing (also OJSTER_PASSTHROUGH=1)")
+	optional := fs.Int("optional", 0, "after this many failed attempts to get the values decrypted, exec the command anyway with the sealed variables removed (0: keep retrying)")
+	startupTimeout := fs.Duration("startup-timeout", 0, "give up and exit "+strconv.Itoa(client.ExitStartupTimeout)+" when the values are not decrypted within this time, e.g. 2m (0: wait forever)")
+	readyFile := fs.String("ready-file", "", "write this file (holding the PID) once the values are decrypted, for healthchecks (also OJSTER_READY_FILE)")
+	readySignal := fs.String("ready-signal", "", "send this signal (e.g. USR1) to the parent process once the values are decrypted")
+	runAs := fs.String("user", "", "switch to this user[:group], by name or ID, before exec, like gosu (also OJSTER_USER)")
+	chdir := fs.String("chdir", "", "change to this directory before exec (also OJSTER_CHDIR)")
+	umask := fs.String("umask", "", "set this octal umask, e.g. 027, before exec (also OJSTER_UMASK)")
+	wait := fs.Bool("wait", false, "run the command as a child instead of exec'ing it: forward signals to it and exit with its status (also OJSTER_WAIT=1)")
+	killTimeout := fs.Duration("kill-timeout", 5*time.Second, "with --wait, time the command has to exit after SIGTERM before it gets SIGKILL; 0 waits indefinitely (also OJSTER_KILL_TIMEOUT)")
+	restart := fs.String("restart", "", "no, or on-failure[:max]: decrypt the values again and restart the command with backoff when it exits non-zero; implies --wait (also OJSTER_RESTART)")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n", runSynopsis, runArgs, runDesc)
+		fs.PrintDefaults()
+	}
+
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+
+	var cmdArgs = fs.Args()
+	if len(cmdArgs) > 0 && cmdArgs[0] == "--" {
+		cmdArgs = cmdArgs[1:]
+	}
+
+	if len(cmdArgs) < 1 {
+		fmt.Fprintf(errw, "run requires a next command to execute. Usage: %s %s\n", runSynopsis, runArgs)
+		return 2
+	}
+	if *optional < 0 || *startupTimeout < 0 || *killTimeout < 0 || *timeout < 0 {
+		fmt.Fprintln(errw, "--optional, --startup-timeout, --timeout and --kill-timeout must not be negative")
+		return 2
+	}
+
+	var sig syscall.Signal
+	if *readySignal != "" {
+		var err error
+		if sig, err = parseSignal(*readySignal); err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+	}
+
+	runEnv := readRunEnv()
+	runEnv.SocketPath = cmp.Or(*socketPath, runEnv.SocketPath)
+	runEnv.Regex = cmp.Or(*regex, runEnv.Regex)
+	ep, err := runEnv.endpoint(errw)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 2
+	}
+	ep.Timeout = *timeout
+	if runEnv.Timeout != "" && *timeout == 0 {
+		if ep.Timeout, err = time.ParseDuration(runEnv.Timeout); err != nil || ep.Timeout <= 0 {
+			fmt.Fprintf(errw, "invalid OJSTER_TIMEOUT %q\n", runEnv.Timeout)
+			return 2
+		}
+	}
+	var cred *client.Credential
+	if spec := cmp.Or(*runAs, runEnv.User); spec != "" {
+		if cred, err = client.ParseUser(spec); err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+	}
+	var mask *int
+	if spec := cmp.Or(*umask, runEnv.Umask); spec != "" {
+		n, err := strconv.ParseUint(spec, 8, 32)
+		if err != nil || n > 0o777 {
+			fmt.Fprintf(errw, "invalid umask %q: want an octal mode such as 027\n", spec)
+			return 2
+		}
+		mask = new(int)
+		*mask = int(n)
+	}
+	killTimeoutSet := false
+	fs.Visit(func(f *flag.Flag) { killTimeoutSet = killTimeoutSet || f.Name == "kill-timeout" })
+	if runEnv.KillTimeout != "" && !killTimeoutSet {
+		if *killTimeout, err = time.ParseDuration(runEnv.KillTimeout); err != nil || *killTimeout < 0 {
+			fmt.Fprintf(errw, "invalid OJSTER_KILL_TIMEOUT %q\n", runEnv.KillTimeout)
+			return 2
+		}
+	}
+	restartOnFailure, maxRestarts, err := parseRestart(cmp.Or(*restart, runEnv.Restart))
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 2
+	}
+	opts := client.RunOptions{
+		Passthrough:    *passthrough || runEnv.Passthrough,
+		Optional:       *optional,
+		StartupTimeout: *startupTimeout,
+		ReadyFile:      cmp.Or(*readyFile, runEnv.ReadyFile),
+		ReadySignal:    sig,
+		User:           cred,
+		Dir:            cmp.Or(*chdir, runEnv.Chdir),
+		Umask:          mask,
+		Wait:           *wait || runEnv.Wait || restartOnFailure,
+		KillTimeout:    *killTimeout,
+		Restart:        restartOnFailure,
+		MaxRestarts:    maxRestarts,
+	}
+	return client.Run(context.Background(), runEnv.Regex, ep, opts, cmdArgs, outw, errw)
+}
+
+// parseRestart parses a restart policy: "" or "no", or "on-failure" with an
+// optional positive maximum number of restarts.
+func parseRestart(s string) (bool, int, error) {
+	policy, limit, hasLimit := strings.Cut(s, ":")
+	switch {
+	case (policy == "" || policy == "no") && !hasLimit:
+		return false, 0, nil
+	case policy == "on-failure" && !hasLimit:
+		return true, 0, nil
+	case policy == "on-failure":
+		if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+			return true, n, nil
+		}
+	}
+	return false, 0, fmt.Errorf("invalid restart policy %q: want no or on-failure[:max]", s)
+}
+
+// parseSignal accepts a signal name from readySignals, with or without the
+// SIG prefix, or a signal number.
+func parseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := readySignals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q: use a number or one of HUP, USR1, USR2, CONT, WINCH", s)
+}
+
+// handlePlugin serves the Docker secrets provider plugin API.
+func handlePlugin(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "plugin"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	socketPath := fs.String("socket", server.DefaultPluginSocket, "Unix socket dockerd discovers the plugin on")
+	privPath := fs.String("priv-file", "/run/secrets/private_key", "private key filename to read")
+	envFile := fs.String("env-file", ".env", "env file to look secrets up in (by secret name or the "+server.PluginLabelKey+" label)")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", pluginSynopsis, pluginArgs, pluginDesc)
+		fs.PrintDefaults()
+	}
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if err := harden.DisableCoreDumps(); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	if code := checkKeyPerms(*privPath, *insecureKeyPerms, errw); code != 0 {
+		return code
+	}
+	return server.ServePlugin(*privPath, *envFile, *socketPath, context.Background(), outw, errw)
+}
+
+// serveConfig serves the sockets of a --config file. The per-socket settings
+// replace the single-socket environment variables and command line.
+func serveConfig(path string, serveEnv ServeEnv, cmdArgs []string, insecureKeyPerms bool, opts server.ServeOptions, outw io.Writer, errw io.Writer) int {
+	if len(cmdArgs) > 0 {
+		fmt.Fprintln(errw, "--config cannot be combined with a command; set \"command\" per socket instead")
+		return 2
+	}
+	if serveEnv.ListenAddr != "" {
+		fmt.Fprintln(errw, "--config cannot be combined with OJSTER_LISTEN_ADDR")
+		return 2
+	}
+	cfg, err := server.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 2
+	}
+	var keys []string
+	for _, sock := range cfg.Sockets {
+		if sock.PrivateKeyFile != "" {
+			keys = append(keys, sock.PrivateKeyFile)
+		}
+		for _, route := range sock.Routes {
+			keys = append(keys, route.PrivateKeyFile)
+		}
+	}
+	for _, key := range keys {
+		if code := checkKeyPerms(key, insecureKeyPerms, errw); code != 0 {
+			return code
+		}
+		if code := unlockKey(key, errw); code != 0 {
+			return code
+		}
+	}
+	return server.ServeSockets(cfg.Sockets, context.Background(), opts, outw, errw)
+}
+
+// isLoopbackAddr reports whether addr is host:port with host localhost or a
+// loopback IP, so a debug listener cannot end up on a public interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleServe starts the server. The server accepts a command to run after an
+// optional "--" separator: "ojster serve [--] command [args...]". Core dumps
+// are disabled first so a crash cannot write decrypted secrets to disk.
+func handleServe(args []string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "serve"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	userName := fs.String("user", "", "user (name or UID) to switch to after binding the socket and reading the private key as root")
+	groupName := fs.String("group", "", "group (name or GID) to switch to with --user (default: the user's primary group)")
+	noLandlock := fs.Bool("no-landlock", false, "do not restrict filesystem access to the private key, socket directory and temp dir with Landlock")
+	landlock := fs.Bool("landlock", false, "apply Landlock with a command too, which must then be statically linked")
+	insecureKeyPerms := fs.Bool("insecure-key-perms", false, "only warn when the private key file is accessible by others or owned by another user")
+	noSeccomp := fs.Bool("no-seccomp", false, "do not install the seccomp filter that blocks new sockets, ptrace and (without a command) exec")
+	configPath := fs.String("config", "", "serve the sockets listed in this JSON file, each with its own private key and command, instead of OJSTER_SOCKET_PATH")
+	pprofAddr := fs.String("pprof", "", "serve net/http/pprof on this loopback address (e.g. 127.0.0.1:6060) for profiling")
+	once := fs.Bool("once", false, "exit 0 after answering the first successful decrypt request")
+	identifyPeers := fs.Bool("identify-peers", false, "log the PID, UID and container ID of the process behind each request (run with the host PID namespace to see other containers)")
+	dockerSocket := fs.String("docker-socket", "", "Docker socket to look container names up on for --identify-peers (implies it; needs --no-seccomp)")
+	aclPath := fs.String("acl", "", "JSON file listing the keys each client (container name, container ID or UID) may decrypt; others get 403")
+	stdin := fs.Bool("stdin", false, "pipe the env file to the command's stdin and pass the private key as fd 3, instead of writing .env and .env.keys to a temp directory")
+	socketPath := fs.String("socket", "", "Unix socket to listen on (default: OJSTER_SOCKET_PATH)")
+	privFile := fs.String("priv-file", "", "private key file to decrypt with (default: OJSTER_PRIVATE_KEY_FILE)")
+	cmdTimeout := fs.Duration("cmd-timeout", server.DefaultCommandTimeout, "time the command may take per request before the server answers 504")
+	maxKeys := fs.Int("max-keys", server.DefaultMaxKeys, "refuse requests with more keys than this with 413")
+	maxValueLen := fs.Int("max-value-len", env.DefaultLimits().MaxValueLen, "refuse requests with a value longer than this many bytes with 413")
+	isolate := fs.Bool("isolate", false, "run the command in its own user, mount, PID and network namespaces, able to read only its request's files (Linux only)")
+	fs.Usage = func() {
+		fmt.Fprintf(outw, "%s %s\n\n%s\n\nOptions:\n", serveSynopsis, serveArgs, serveDesc)
+		fs.PrintDefaults()
+	}
+
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+
+	var cmdArgs = fs.Args()
+	if len(cmdArgs) > 0 && cmdArgs[0] == "--" {
+		cmdArgs = cmdArgs[1:]
+	}
+
+	opts := server.ServeOptions{Landlock: !*noLandlock, LandlockCommands: *landlock, Seccomp: !*noSeccomp, PprofAddr: *pprofAddr, Once: *once, IdentifyPeers: *identifyPeers, DockerSocket: *dockerSocket, SubprocessStdin: *stdin, IsolateSubprocess: *isolate, MaxKeys: *maxKeys, MaxValueLen: *maxValueLen, CommandTimeout: *cmdTimeout}
+	if *landlock && *noLandlock {
+		fmt.Fprintln(errw, "--landlock and --no-landlock cannot be combined")
+		return 2
+	}
+	if *stdin && len(cmdArgs) == 0 && *configPath == "" {
+		fmt.Fprintln(errw, "--stdin needs a command to pipe to")
+		return 2
+	}
+	if *maxKeys < 1 {
+		fmt.Fprintln(errw, "--max-keys must be at least 1")
+		return 2
+	}
+	if *maxValueLen < 1 {
+		fmt.Fprintln(errw, "--max-value-len must be at least 1")
+		return 2
+	}
+	if *cmdTimeout <= 0 {
+		fmt.Fprintln(errw, "--cmd-timeout must be positive")
+		return 2
+	}
+	if *configPath != "" && (*socketPath != "" || *privFile != "") {
+		fmt.Fprintln(errw, "--config cannot be combined with --socket or --priv-file; set them per socket instead")
+		return 2
+	}
+	if *isolate && len(cmdArgs) == 0 && *configPath == "" {
+		fmt.Fprintln(errw, "--isolate needs a command to isolate")
+		return 2
+	}
+	if *pprofAddr != "" && !isLoopbackAddr(*pprofAddr) {
+		fmt.Fprintf(errw, "--pprof must be a loopback address such as 127.0.0.1:6060, got %q\n", *pprofAddr)
+		return 2
+	}
+	if *groupName != "" && *userName == "" {
+		fmt.Fprintln(errw, "--group requires --user")
+		return 2
+	}
+	if *userName != "" {
+		creds, err := harden.LookupCredentials(*userName, *groupName)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+		opts.DropTo = &creds
+	}
+	if *aclPath != "" {
+		acl, err := server.LoadACL(*aclPath)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 2
+		}
+		opts.ACL = acl
+	}
+
+	if err := harden.DisableCoreDumps(); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+
+	serveEnv := readServeEnv()
+	serveEnv.SocketPath = cmp.Or(*socketPath, serveEnv.SocketPath)
+	serveEnv.PrivateKeyFile = cmp.Or(*privFile, serveEnv.PrivateKeyFile)
+	valueRe, err := regexp.Compile(serveEnv.Regex)
+	if err != nil {
+		fmt.Fprintf(errw, "invalid OJSTER_REGEX: %v\n", err)
+		return 2
+	}
+	opts.ValueRegex = valueRe
+	if *configPath != "" {
+		return serveConfig(*configPath, serveEnv, cmdArgs, *insecureKeyPerms, opts, outw, errw)
+	}
+	if code := checkKeyPerms(serveEnv.PrivateKeyFile, *insecureKeyPerms, errw); code != 0 {
+		return code
+	}
+	if code := unlockKey(serveEnv.PrivateKeyFile, errw); code != 0 {
+		return code
+	}
+	if serveEnv.ListenAddr != "" {
+		tlsConfig, err := mtls.ServerConfig(serveEnv.TLSCert, serveEnv.TLSKey, serveEnv.TLSCA)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		opts.Addr, opts.TLS = serveEnv.ListenAddr, tlsConfig
+	}
+	return server.ServeWithOptions(serveEnv.PrivateKeyFile, serveEnv.SocketPath, context.Background(), cmdArgs, opts, outw, errw)
 }
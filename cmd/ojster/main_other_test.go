@@ -0,0 +1,89 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestHandleKeypair_Streams(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer r.Close()
+	// handleKeypair closes the descriptor it is given, so hand it a duplicate.
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+
+	var out, errb bytes.Buffer
+	args := []string{"--priv-fd", strconv.Itoa(fd), "--pub-stdout"}
+	code := handleKeypair(args, &out, &errb)
+	w.Close()
+	if code != 0 {
+		t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+	}
+	priv, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read pipe: %v", err)
+	}
+
+	// The streamed halves form a working keypair.
+	td := t.TempDir()
+	privPath, pubPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	if err := os.WriteFile(privPath, priv, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(pubPath, out.Bytes(), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	envFile := filepath.Join(td, ".env")
+	withStdin(t, "v")
+	if code := handleSeal([]string{"--pub-file", pubPath, "--out", envFile, "K"}, io.Discard, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+	var unsealed bytes.Buffer
+	if code := handleUnseal([]string{"--in", envFile, "--priv-file", privPath}, &unsealed, &errb); code != 0 || unsealed.String() != "K=v" {
+		t.Fatalf("unexpected unseal code=%d stdout=%q stderr=%q", code, unsealed.String(), errb.String())
+	}
+	if !strings.Contains(errb.String(), "Wrote private key to file descriptor") {
+		t.Fatalf("expected summary on stderr, got %q", errb.String())
+	}
+	if _, err := os.Stat(pqc.DefaultPrivFile()); err == nil {
+		t.Fatalf("default private key file was written")
+	}
+
+	errb.Reset()
+	if code := handleKeypair([]string{"--priv-fd", "1", "--pub-stdout"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "cannot both write to stdout") {
+		t.Fatalf("expected conflict error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleKeypair([]string{"--priv-fd", "0"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "invalid --priv-fd") {
+		t.Fatalf("expected fd error, got code=%d stderr=%q", code, errb.String())
+	}
+}
@@ -16,10 +16,20 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime/debug"
 	"strings"
 	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
 )
 
 // ----------------------------- small utilities for tests -----------------------------
@@ -74,6 +84,31 @@ func TestEntrypoint_Version(t *testing.T) {
 	}
 }
 
+func TestHandleVersion_Verbose(t *testing.T) {
+	orig := readBuildInfo
+	t.Cleanup(func() { readBuildInfo = orig })
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{GoVersion: "go1.99", Main: debug.Module{Path: "github.com/ojster/ojster", Version: "v1.2.3"}, Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.modified", Value: "true"},
+			{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+		}}, true
+	}
+
+	var out, errb bytes.Buffer
+	if code := entrypoint("ojster", []string{"version", "--verbose"}, "1.2.3", &out, &errb); code != 0 {
+		t.Fatalf("version --verbose returned %d; stderr=%q", code, errb.String())
+	}
+	for _, want := range []string{"1.2.3\n", "github.com/ojster/ojster v1.2.3", "abc123 (dirty) from 2026-01-02T03:04:05Z", "go1.99 ", "OJSTER-2 (with frame, seal --frame)", "ML-KEM-768", "AES-256-GCM", "base64url"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if code := entrypoint("ojster", []string{"version", "extra"}, "1.2.3", io.Discard, io.Discard); code != 2 {
+		t.Fatalf("expected 2 for an argument, got %d", code)
+	}
+}
+
 // TestEntrypoint_Unknown prints header, writes error and returns 1.
 func TestEntrypoint_Unknown(t *testing.T) {
 	var out, errb bytes.Buffer
@@ -138,6 +173,13 @@ func TestEntrypoint_SubcommandDispatch(t *testing.T) {
 			wantCode:        0,
 			wantOutContains: sealDesc,
 		},
+		{
+			name:            "unseal exec help",
+			prog:            "ojster",
+			args:            []string{"unseal", "exec", "-h"},
+			wantCode:        0,
+			wantOutContains: unsealExecDesc,
+		},
 		{
 			name:            "serve help",
 			prog:            "ojster",
@@ -145,6 +187,62 @@ func TestEntrypoint_SubcommandDispatch(t *testing.T) {
 			wantCode:        0,
 			wantOutContains: serveDesc,
 		},
+		{
+			name:            "check-compose help",
+			prog:            "ojster",
+			args:            []string{"check-compose", "-h"},
+			wantCode:        0,
+			wantOutContains: checkComposeDesc,
+		},
+		{
+			name:            "precommit help",
+			prog:            "ojster",
+			args:            []string{"precommit", "-h"},
+			wantCode:        0,
+			wantOutContains: precommitDesc,
+		},
+		{
+			name:            "scan help",
+			prog:            "ojster",
+			args:            []string{"scan", "-h"},
+			wantCode:        0,
+			wantOutContains: scanDesc,
+		},
+		{
+			name:            "diff help",
+			prog:            "ojster",
+			args:            []string{"diff", "-h"},
+			wantCode:        0,
+			wantOutContains: diffDesc,
+		},
+		{
+			name:            "import help",
+			prog:            "ojster",
+			args:            []string{"import", "-h"},
+			wantCode:        0,
+			wantOutContains: importDesc,
+		},
+		{
+			name:            "inspect help",
+			prog:            "ojster",
+			args:            []string{"inspect", "-h"},
+			wantCode:        0,
+			wantOutContains: inspectDesc,
+		},
+		{
+			name:            "pubkey help",
+			prog:            "ojster",
+			args:            []string{"pubkey", "-h"},
+			wantCode:        0,
+			wantOutContains: pubkeyDesc,
+		},
+		{
+			name:            "plugin help",
+			prog:            "ojster",
+			args:            []string{"plugin", "-h"},
+			wantCode:        0,
+			wantOutContains: pluginDesc,
+		},
 		{
 			name:            "unseal help",
 			prog:            "ojster",
@@ -152,6 +250,20 @@ func TestEntrypoint_SubcommandDispatch(t *testing.T) {
 			wantCode:        0,
 			wantOutContains: unsealDesc,
 		},
+		{
+			name:            "bundle help",
+			prog:            "ojster",
+			args:            []string{"bundle", "-h"},
+			wantCode:        0,
+			wantOutContains: bundleDesc,
+		},
+		{
+			name:            "k8s help",
+			prog:            "ojster",
+			args:            []string{"k8s", "-h"},
+			wantCode:        0,
+			wantOutContains: k8sDesc,
+		},
 		{
 			name:            "docker-init behaves like run (help)",
 			prog:            "docker-init",
@@ -192,8 +304,20 @@ func TestEntrypoint_SubcommandFlagParseErrors(t *testing.T) {
 		{"keypair parse error", "keypair", []string{"keypair", "--no-such-flag"}, 2, "failed to parse keypair flags"},
 		{"seal parse error", "seal", []string{"seal", "--no-such-flag"}, 2, "failed to parse seal flags"},
 		{"unseal parse error", "unseal", []string{"unseal", "--no-such-flag"}, 2, "failed to parse unseal flags"},
+		{"unseal exec parse error", "unseal exec", []string{"unseal", "exec", "--no-such-flag"}, 2, "failed to parse unseal exec flags"},
 		{"run parse error", "run", []string{"run", "--no-such-flag"}, 2, "failed to parse run flags"},
 		{"serve parse error", "serve", []string{"serve", "--no-such-flag"}, 2, "failed to parse serve flags"},
+		{"bundle parse error", "bundle", []string{"bundle", "pack", "--no-such-flag"}, 2, "failed to parse bundle flags"},
+		{"k8s parse error", "k8s", []string{"k8s", "export", "--no-such-flag"}, 2, "failed to parse k8s flags"},
+		{"check-compose parse error", "check-compose", []string{"check-compose", "--no-such-flag"}, 2, "failed to parse check-compose flags"},
+		{"precommit parse error", "precommit", []string{"precommit", "--no-such-flag"}, 2, "failed to parse precommit flags"},
+		{"scan parse error", "scan", []string{"scan", "--no-such-flag"}, 2, "failed to parse scan flags"},
+		{"diff parse error", "diff", []string{"diff", "--no-such-flag"}, 2, "failed to parse diff flags"},
+		{"import parse error", "import", []string{"import", "--no-such-flag"}, 2, "failed to parse import flags"},
+		{"inspect parse error", "inspect", []string{"inspect", "--no-such-flag"}, 2, "failed to parse inspect flags"},
+		{"pubkey parse error", "pubkey", []string{"pubkey", "--no-such-flag"}, 2, "failed to parse pubkey flags"},
+		{"plugin parse error", "plugin", []string{"plugin", "--no-such-flag"}, 2, "failed to parse plugin flags"},
+		{"k8s init parse error", "k8s", []string{"k8s", "init", "--no-such-flag"}, 2, "failed to parse k8s flags"},
 	}
 
 	for _, c := range cases {
@@ -340,6 +464,54 @@ func TestHandleRun_DelegatesToClient_NonexistentCommand(t *testing.T) {
 	}
 }
 
+func TestHandleRun_InvalidOptions(t *testing.T) {
+	for _, args := range [][]string{
+		{"--umask", "999", "--", "true"},
+		{"--umask", "1000", "--", "true"},
+		{"--ready-signal", "KILL", "--", "true"},
+		{"--user", "no-such-user-ojster", "--", "true"},
+		{"--optional", "-1", "--", "true"},
+		{"--restart", "always", "--", "true"},
+		{"--timeout", "-1s", "--", "true"},
+	} {
+		var out, errb bytes.Buffer
+		if code := handleRun(args, &out, &errb); code != 2 {
+			t.Fatalf("handleRun(%q) = %d, want 2; stderr=%q", args, code, errb.String())
+		}
+	}
+}
+
+func TestHandleRun_Flags(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "ipc.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"DB_PASS":"decrypted"}`))
+		}))
+	}()
+	t.Cleanup(func() { ln.Close() })
+	t.Setenv("OJSTER_PIN", "off")
+	t.Setenv("OJSTER_SOCKET_PATH", "/nonexistent/ipc.sock")
+	t.Setenv("OJSTER_TIMEOUT", "bogus")
+	t.Setenv("DB_PASS", "enc:x")
+
+	// The flags override the environment variables.
+	var errb bytes.Buffer
+	args := []string{"--socket", socketPath, "--regex", "^enc:", "--timeout", "5s", "--wait", "--", "sh", "-c", `[ "$DB_PASS" = decrypted ]`}
+	if code := handleRun(args, io.Discard, &errb); code != 0 {
+		t.Fatalf("run failed: code=%d stderr=%q", code, errb.String())
+	}
+
+	t.Setenv("OJSTER_TIMEOUT", "bogus")
+	errb.Reset()
+	if code := handleRun([]string{"--", "true"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "invalid OJSTER_TIMEOUT") {
+		t.Fatalf("expected OJSTER_TIMEOUT error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
 // ----------------------------- env reading -----------------------------
 
 func TestReadServeEnv_Defaults(t *testing.T) {
@@ -377,6 +549,39 @@ func TestReadServeEnv_CustomValues(t *testing.T) {
 	}
 }
 
+func TestRunEnvEndpoint_Pins(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OJSTER_STATE_DIR", dir)
+	t.Setenv("OJSTER_PIN", "refuse")
+	ep, err := readRunEnv().endpoint(io.Discard)
+	if err != nil || ep.Pins == nil || !ep.Pins.Refuse || ep.Pins.File != filepath.Join(dir, "pins.json") {
+		t.Fatalf("endpoint = %+v, %v", ep, err)
+	}
+
+	// Without a state dir pinning is off unless asked for.
+	t.Setenv("OJSTER_STATE_DIR", "")
+	t.Setenv("OJSTER_PIN", "")
+	if got := readRunEnv(); got.Pin != "off" {
+		t.Fatalf("Pin = %q without OJSTER_STATE_DIR, want off", got.Pin)
+	}
+	t.Setenv("OJSTER_STATE_DIR", dir)
+	if got := readRunEnv(); got.Pin != "warn" || got.StateDir != dir {
+		t.Fatalf("Pin = %q, StateDir = %q with OJSTER_STATE_DIR, want warn", got.Pin, got.StateDir)
+	}
+
+	if ep, err := (RunEnv{Pin: "off", StateDir: dir}).endpoint(io.Discard); err != nil || ep.Pins != nil {
+		t.Fatalf("pinning not off: %+v, %v", ep, err)
+	}
+	if _, err := (RunEnv{Pin: "maybe", StateDir: dir}).endpoint(io.Discard); err == nil {
+		t.Fatal("expected error for invalid OJSTER_PIN")
+	}
+
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got := defaultStateDir(); got != "/state/ojster" {
+		t.Fatalf("defaultStateDir = %q", got)
+	}
+}
+
 // TestGetenvDefaultAndUnset verifies getenvDefaultAndUnset returns the env value and unsets it,
 // and returns the default when the env var is not set.
 func TestGetenvDefaultAndUnset(t *testing.T) {
@@ -399,3 +604,1133 @@ func TestGetenvDefaultAndUnset(t *testing.T) {
 		t.Fatalf("getenvDefaultAndUnset(%q) = %q; want default %q", key2, got2, "def2")
 	}
 }
+
+// ----------------------------- gpg delegation -----------------------------
+
+func TestStringList(t *testing.T) {
+	var s stringList
+	_ = s.Set("a")
+	_ = s.Set("b")
+	if s.String() != "a,b" || len(s) != 2 {
+		t.Fatalf("unexpected stringList: %v", s)
+	}
+}
+
+// TestHandleSeal_GPGRecipient ensures --gpg-recipient routes sealing through gpg.
+// The recipient does not exist in the (empty) keyring, so gpg fails either way.
+func TestHandleSeal_GPGRecipient(t *testing.T) {
+	t.Setenv("GNUPGHOME", t.TempDir())
+	outPath := filepath.Join(t.TempDir(), "out.env")
+	withStdin(t, "plaintext")
+
+	var out, errb bytes.Buffer
+	code := handleSeal([]string{"--gpg-recipient", "nobody@invalid", "--out", outPath, "MYKEY"}, &out, &errb)
+	if code != 1 {
+		t.Fatalf("expected exit 1, got %d; stderr=%q", code, errb.String())
+	}
+	if !strings.Contains(errb.String(), "gpg encryption failed") {
+		t.Fatalf("expected gpg failure message; got %q", errb.String())
+	}
+	if _, err := os.Stat(outPath); err == nil {
+		t.Fatalf("env file must not be written on failure")
+	}
+}
+
+// TestHandleUnseal_GPG ensures --gpg skips the private key file entirely.
+func TestHandleUnseal_GPG(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "env.env")
+	if err := os.WriteFile(envFile, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	var out, errb bytes.Buffer
+	code := handleUnseal([]string{"--gpg", "--in", envFile, "--priv-file", "/nonexistent"}, &out, &errb)
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d; stderr=%q", code, errb.String())
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output without gpg-sealed values; got %q", out.String())
+	}
+}
+
+// withStdin replaces os.Stdin with a pipe containing data for the duration of the test.
+func withStdin(t *testing.T, data string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	_, _ = w.WriteString(data)
+	_ = w.Close()
+	origStdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		_ = r.Close()
+		os.Stdin = origStdin
+	})
+}
+
+func TestHandleSealUnseal_JSON(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	cfg := filepath.Join(td, "config.json")
+	if err := os.WriteFile(cfg, []byte(`{"name":"app","db":{"host":"db"}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	withStdin(t, "hunter2")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", cfg, "db.password"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: code=%d stderr=%q", code, errb.String())
+	}
+	raw, _ := os.ReadFile(cfg)
+	if !strings.Contains(string(raw), `"password": "OJSTER-1:`) {
+		t.Fatalf("expected sealed value in JSON, got %s", raw)
+	}
+
+	out.Reset()
+	if code := handleUnseal([]string{"--in", cfg, "--priv-file", priv, "--json"}, &out, &errb); code != 0 {
+		t.Fatalf("unseal failed: code=%d stderr=%q", code, errb.String())
+	}
+	want := "{\n  \"name\": \"app\",\n  \"db\": {\n    \"host\": \"db\",\n    \"password\": \"hunter2\"\n  }\n}\n"
+	if out.String() != want {
+		t.Fatalf("unexpected document:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := handleUnseal([]string{"--in", cfg, "--priv-file", priv, "db.password"}, &out, &errb); code != 0 || out.String() != "db.password=hunter2" {
+		t.Fatalf("unexpected path output %q (code %d)", out.String(), code)
+	}
+	if code := handleUnseal([]string{"--in", cfg, "--interpolate"}, &out, &errb); code != 2 {
+		t.Fatalf("expected 2 for --interpolate with JSON, got %d", code)
+	}
+}
+
+func TestHandleSeal_Remote(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	pubFile, err := os.ReadFile(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(td, "ipc.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/pubkey" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(pubFile)
+		}))
+	}()
+	t.Cleanup(func() { ln.Close() })
+	t.Setenv("OJSTER_PIN", "off")
+
+	envPath := filepath.Join(td, ".env")
+	withStdin(t, "hunter2")
+	if code := handleSeal([]string{"--remote=" + socketPath, "--out", envPath, "SECRET"}, &out, &errb); code != 0 {
+		t.Fatalf("seal --remote failed: code=%d stderr=%q", code, errb.String())
+	}
+	out.Reset()
+	if code := handleUnseal([]string{"--in", envPath, "--priv-file", priv, "SECRET"}, &out, &errb); code != 0 || out.String() != "SECRET=hunter2" {
+		t.Fatalf("unexpected unseal output %q (code %d, stderr %q)", out.String(), code, errb.String())
+	}
+
+	t.Setenv("OJSTER_ROUTE", "/missing")
+	if code := handleSeal([]string{"--remote=" + socketPath, "--out", envPath, "SECRET"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "status=404") {
+		t.Fatalf("expected 404 failure, got code=%d stderr=%q", code, errb.String())
+	}
+	if code := handleSeal([]string{"--remote", "--pub-file", pub, "SECRET"}, &out, &errb); code != 2 {
+		t.Fatalf("expected usage error for --remote with --pub-file, got %d", code)
+	}
+}
+
+func TestHandleAgent_Usage(t *testing.T) {
+	var out, errb bytes.Buffer
+	if code := handleAgent([]string{"extra"}, &out, &errb); code != 2 {
+		t.Fatalf("expected 2 for a positional argument, got %d", code)
+	}
+	socketPath := filepath.Join(t.TempDir(), "ipc.sock")
+	t.Setenv("OJSTER_SOCKET_PATH", socketPath)
+	if code := handleAgent([]string{"--socket", socketPath}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "server's socket") {
+		t.Fatalf("expected refusal to listen on the server socket, got %d %q", code, errb.String())
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := defaultAgentSocket(); got != "/run/user/1000/ojster/agent.sock" {
+		t.Fatalf("defaultAgentSocket = %q", got)
+	}
+}
+
+func TestHandleSeal_Compose(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	composeFile := filepath.Join(td, "docker-compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  api:\n    image: x\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+
+	withStdin(t, "hunter2")
+	if code := handleSeal([]string{"--pub-file", pub, "--compose", composeFile, "--service", "api", "PW"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: code=%d stderr=%q", code, errb.String())
+	}
+	raw, _ := os.ReadFile(composeFile)
+	if !strings.Contains(string(raw), "    environment:\n      PW: \"OJSTER-1:") {
+		t.Fatalf("expected sealed value in compose file, got:\n%s", raw)
+	}
+
+	withStdin(t, "x")
+	errb.Reset()
+	if code := handleSeal([]string{"--pub-file", pub, "--compose", composeFile, "--service", "nope", "PW"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "not found") {
+		t.Fatalf("expected missing service failure, got %d %q", code, errb.String())
+	}
+	if code := handleSeal([]string{"--compose", composeFile, "PW"}, &out, &errb); code != 2 {
+		t.Fatalf("expected 2 for --compose without --service, got %d", code)
+	}
+}
+
+func TestHandleSealUnseal_BackendConflicts(t *testing.T) {
+	var out, errb bytes.Buffer
+	if code := handleUnseal([]string{"--gpg", "--interpolate"}, &out, &errb); code != 2 {
+		t.Fatalf("expected 2 for --interpolate with --gpg, got %d", code)
+	}
+}
+
+// ----------------------------- bundle delegation -----------------------------
+
+func TestHandleBundle_PackUnpack(t *testing.T) {
+	td := t.TempDir()
+	t.Chdir(td)
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	if err := os.WriteFile("app.env", []byte("A=1\n"), 0o600); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	if code := handleBundle([]string{"pack", "--pub-file", pub, "--out", "b.ojster", "app.env"}, &out, &errb); code != 0 {
+		t.Fatalf("pack failed: %s", errb.String())
+	}
+	dest := t.TempDir()
+	if code := handleBundle([]string{"unpack", "--priv-file", priv, "--in", "b.ojster", "--dir", dest}, &out, &errb); code != 0 {
+		t.Fatalf("unpack failed: %s", errb.String())
+	}
+	if b, _ := os.ReadFile(filepath.Join(dest, "app.env")); string(b) != "A=1\n" {
+		t.Fatalf("unexpected unpacked content %q", b)
+	}
+
+	errb.Reset()
+	if code := handleBundle(nil, &out, &errb); code != 2 || !strings.Contains(errb.String(), "requires an action") {
+		t.Fatalf("expected missing action error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleBundle([]string{"pack"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "at least one FILE") {
+		t.Fatalf("expected missing file error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- k8s delegation --------------------------------
+
+func TestHandleK8s_Export(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	envPath := filepath.Join(td, ".env")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	withStdin(t, "hunter2")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "PW"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+
+	out.Reset()
+	if code := handleK8s([]string{"export", "--in", envPath, "--priv-file", priv, "--name", "api"}, &out, &errb); code != 0 {
+		t.Fatalf("export failed: code=%d stderr=%q", code, errb.String())
+	}
+	if !strings.Contains(out.String(), "kind: Secret") || !strings.Contains(out.String(), "  PW: aHVudGVyMg==\n") {
+		t.Fatalf("unexpected manifest:\n%s", out.String())
+	}
+
+	errb.Reset()
+	if code := handleK8s(nil, &out, &errb); code != 2 || !strings.Contains(errb.String(), "requires an action") {
+		t.Fatalf("expected missing action error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestHandleK8s_InitFlagErrors(t *testing.T) {
+	var out, errb bytes.Buffer
+	if code := handleK8s([]string{"init", "--mode", "999"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "invalid --mode") {
+		t.Fatalf("expected invalid mode error, got code=%d stderr=%q", code, errb.String())
+	}
+
+	errb.Reset()
+	if code := handleK8s([]string{"init", "--addr", "127.0.0.1:8443"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "mutual TLS requires") {
+		t.Fatalf("expected mTLS config error, got code=%d stderr=%q", code, errb.String())
+	}
+
+	errb.Reset()
+	if code := handleK8s([]string{"init", "--format", "yaml", "--allow-disk", "--out-dir", t.TempDir()}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "unknown format") {
+		t.Fatalf("expected unknown format error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestHandleServe_ListenAddrRequiresTLSFiles(t *testing.T) {
+	t.Setenv("OJSTER_LISTEN_ADDR", "127.0.0.1:0")
+	var out, errb bytes.Buffer
+	if code := handleServe(nil, &out, &errb); code != 1 || !strings.Contains(errb.String(), "mutual TLS requires") {
+		t.Fatalf("expected mTLS config error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestHandleServe_UserFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--group", "12345"}, "--group requires --user"},
+		{[]string{"--user", "root"}, "refusing to drop privileges to root"},
+		{[]string{"--user", "no-such-user-ojster"}, "unknown user"},
+	}
+	for _, tc := range tests {
+		var out, errb bytes.Buffer
+		if code := handleServe(tc.args, &out, &errb); code != 2 || !strings.Contains(errb.String(), tc.want) {
+			t.Fatalf("%v: expected usage error %q, got code=%d stderr=%q", tc.args, tc.want, code, errb.String())
+		}
+	}
+}
+
+// ----------------------------- plugin delegation -----------------------------
+
+func TestHandlePlugin_InvalidSocket(t *testing.T) {
+	notDir := tmpFilePath(t, "file")
+	if err := os.WriteFile(notDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out, errb bytes.Buffer
+	code := handlePlugin([]string{"--socket", filepath.Join(notDir, "ojster.sock")}, &out, &errb)
+	if code != 1 || !strings.Contains(errb.String(), "failed to create socket directory") {
+		t.Fatalf("expected socket directory failure, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- check-compose delegation ----------------------
+
+func TestHandleCheckCompose(t *testing.T) {
+	td := t.TempDir()
+	composePath := filepath.Join(td, "compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  api:\n    environment:\n      DB_PASSWORD: hunter2\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out, errb bytes.Buffer
+	if code := handleCheckCompose([]string{composePath}, &out, &errb); code != 1 || !strings.Contains(out.String(), "DB_PASSWORD looks like a plaintext secret") {
+		t.Fatalf("expected finding, got code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+
+	errb.Reset()
+	if code := handleCheckCompose([]string{"a.yml", "b.yml"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "at most one compose file") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- precommit delegation --------------------------
+
+func TestHandlePrecommit_Paths(t *testing.T) {
+	envPath := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envPath, []byte("DB_PASSWORD=hunter2\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out, errb bytes.Buffer
+	if code := handlePrecommit([]string{envPath}, &out, &errb); code != 1 || !strings.Contains(out.String(), "DB_PASSWORD looks like a plaintext secret") {
+		t.Fatalf("expected finding, got code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+}
+
+// ----------------------------- scan delegation -------------------------------
+
+func TestHandleScan(t *testing.T) {
+	td := t.TempDir()
+	if err := os.WriteFile(filepath.Join(td, "app.env"), []byte("UPSTREAM=wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out, errb bytes.Buffer
+	if code := handleScan([]string{td}, &out, &errb); code != 1 || !strings.Contains(out.String(), "UPSTREAM is a high-entropy value") || !strings.Contains(errb.String(), "1 value(s)") {
+		t.Fatalf("expected finding, got code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+
+	out.Reset()
+	errb.Reset()
+	if code := handleScan([]string{t.TempDir()}, &out, &errb); code != 0 || out.Len() != 0 {
+		t.Fatalf("expected clean scan, got code=%d stdout=%q", code, out.String())
+	}
+	if code := handleScan([]string{filepath.Join(td, "missing")}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "failed to scan") {
+		t.Fatalf("expected scan error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- glob inputs -----------------------------------
+
+func TestHandleUnseal_Glob(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	envs := filepath.Join(td, "envs")
+	for name, value := range map[string]string{"a.env": "alpha", "b.env": "beta"} {
+		withStdin(t, value)
+		if code := handleSeal([]string{"--pub-file", pub, "--out", filepath.Join(envs, name), "PW"}, &out, &errb); code != 0 {
+			t.Fatalf("seal failed: %s", errb.String())
+		}
+	}
+	pattern := filepath.Join(envs, "*.env")
+
+	out.Reset()
+	if code := handleUnseal([]string{"--in", pattern, "--priv-file", priv}, &out, &errb); code != 0 {
+		t.Fatalf("unseal failed: code=%d stderr=%q", code, errb.String())
+	}
+	want := "# " + filepath.Join(envs, "a.env") + "\nPW=alpha\n# " + filepath.Join(envs, "b.env") + "\nPW=beta\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if code := handleUnseal([]string{"--in", pattern, "--priv-file", priv, "--json"}, &out, &errb); code != 0 {
+		t.Fatalf("unseal --json failed: code=%d stderr=%q", code, errb.String())
+	}
+	var combined map[string]map[string]string
+	if err := json.Unmarshal(out.Bytes(), &combined); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if combined[filepath.Join(envs, "b.env")]["PW"] != "beta" || len(combined) != 2 {
+		t.Fatalf("unexpected combined JSON: %#v", combined)
+	}
+
+	// A file lacking the requested key fails on its own; the others still print.
+	if err := os.WriteFile(filepath.Join(envs, "c.env"), []byte("OTHER=x\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out.Reset()
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", pattern, "--priv-file", priv, "PW"}, &out, &errb); code != 2 {
+		t.Fatalf("expected exit 2, got %d stderr=%q", code, errb.String())
+	}
+	if !strings.Contains(out.String(), "PW=beta") || !strings.Contains(errb.String(), "unseal: 1 of 3 files failed") {
+		t.Fatalf("unexpected result stdout=%q stderr=%q", out.String(), errb.String())
+	}
+
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", filepath.Join(td, "*.nope")}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "no files match") {
+		t.Fatalf("expected no-match error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", "[", "--priv-file", priv}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "invalid pattern") {
+		t.Fatalf("expected pattern error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- diff delegation -------------------------------
+
+func TestHandleDiff(t *testing.T) {
+	td := t.TempDir()
+	a, b := filepath.Join(td, "a.env"), filepath.Join(td, "b.env")
+	if err := os.WriteFile(a, []byte("A=1\nB=2\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(b, []byte("A=1\nC=3\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out, errb bytes.Buffer
+	if code := handleDiff([]string{a, b}, &out, &errb); code != 1 || out.String() != "- B\n+ C\n" {
+		t.Fatalf("unexpected diff: code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+	if code := handleDiff([]string{a}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "requires two env files") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- import delegation -----------------------------
+
+func TestHandleImport(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	from, sealed := filepath.Join(td, "plain.env"), filepath.Join(td, "sealed.env")
+	if err := os.WriteFile(from, []byte("PW=hunter2\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if code := handleImport([]string{"--from", from, "--out", sealed, "--pub-file", pub, "--shred"}, &out, &errb); code != 0 {
+		t.Fatalf("import failed: code=%d stderr=%q", code, errb.String())
+	}
+	out.Reset()
+	if code := handleUnseal([]string{"--in", sealed, "--priv-file", priv}, &out, &errb); code != 0 || out.String() != "PW=hunter2" {
+		t.Fatalf("unexpected unseal result code=%d stdout=%q", code, out.String())
+	}
+
+	errb.Reset()
+	if code := handleImport(nil, &out, &errb); code != 2 || !strings.Contains(errb.String(), "requires --from") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- unseal formats --------------------------------
+
+func TestHandleUnseal_Format(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	envFile := filepath.Join(td, ".env")
+	for key, value := range map[string]string{"A": `it's $5 "each"`, "B": "plain"} {
+		withStdin(t, value)
+		if code := handleSeal([]string{"--pub-file", pub, "--out", envFile, key}, &out, &errb); code != 0 {
+			t.Fatalf("seal failed: %s", errb.String())
+		}
+	}
+
+	tests := []struct {
+		format string
+		args   []string
+		want   string
+	}{
+		{"docker", nil, "A=it's $5 \"each\"\nB=plain"},
+		{"systemd", nil, `A="it's \$5 \"each\""` + "\nB=plain"},
+		{"shell", []string{"B", "A"}, "export B='plain'\nexport A='it'\\''s $5 \"each\"'"},
+	}
+	for _, tc := range tests {
+		out.Reset()
+		args := append([]string{"--in", envFile, "--priv-file", priv, "--format", tc.format}, tc.args...)
+		if code := handleUnseal(args, &out, &errb); code != 0 || out.String() != tc.want {
+			t.Fatalf("%s: code=%d stdout=%q stderr=%q, want %q", tc.format, code, out.String(), errb.String(), tc.want)
+		}
+	}
+
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", envFile, "--format", "xml"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "unknown --format") {
+		t.Fatalf("expected format error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", envFile, "--format", "shell", "--json"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "cannot be combined") {
+		t.Fatalf("expected conflict error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+// ----------------------------- key permissions -------------------------------
+
+func TestKeyPerms(t *testing.T) {
+	t.Cleanup(func() { pqc.InsecureKeyPerms = false })
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	envFile := filepath.Join(td, ".env")
+	withStdin(t, "v")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envFile, "K"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+	if err := os.Chmod(priv, 0o644); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", envFile, "--priv-file", priv}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "pass --insecure-key-perms") {
+		t.Fatalf("expected refusal, got code=%d stderr=%q", code, errb.String())
+	}
+	t.Setenv("OJSTER_PRIVATE_KEY_FILE", priv)
+	errb.Reset()
+	if code := handleServe(nil, &out, &errb); code != 1 || !strings.Contains(errb.String(), "too open") {
+		t.Fatalf("expected serve to refuse the key, got code=%d stderr=%q", code, errb.String())
+	}
+
+	out.Reset()
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", envFile, "--priv-file", priv, "--insecure-key-perms"}, &out, &errb); code != 0 || out.String() != "K=v" {
+		t.Fatalf("unexpected result code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+	if !strings.Contains(errb.String(), "warning: permissions 0644") {
+		t.Fatalf("expected warning, got %q", errb.String())
+	}
+}
+
+// ----------------------------- passphrase-wrapped keys -----------------------
+
+func TestKeypair_Passphrase(t *testing.T) {
+	t.Cleanup(func() { pqc.PassphraseFunc = nil })
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	passFile, wrongFile := filepath.Join(td, "pass"), filepath.Join(td, "wrong")
+	if err := os.WriteFile(passFile, []byte("correct horse\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(wrongFile, []byte("battery staple\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var out, errb bytes.Buffer
+	args := []string{"keypair", "--priv-file", priv, "--pub-file", pub, "--passphrase-file", passFile}
+	if code := entrypoint("ojster", args, "v", &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+	}
+	if data, _ := os.ReadFile(priv); !pqc.IsWrappedKey(data) {
+		t.Fatalf("expected a wrapped private key, got %q", data)
+	}
+	envFile := filepath.Join(td, ".env")
+	withStdin(t, "v")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envFile, "K"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+
+	unseal := []string{"unseal", "--in", envFile, "--priv-file", priv}
+	withStdin(t, "")
+	errb.Reset()
+	if code := entrypoint("ojster", unseal, "v", &out, &errb); code != 1 || !strings.Contains(errb.String(), "empty passphrase") {
+		t.Fatalf("expected empty passphrase error, got code=%d stderr=%q", code, errb.String())
+	}
+	t.Setenv("OJSTER_PASSPHRASE_FILE", wrongFile)
+	errb.Reset()
+	if code := entrypoint("ojster", unseal, "v", &out, &errb); code != 1 || !strings.Contains(errb.String(), "wrong passphrase") {
+		t.Fatalf("expected wrong passphrase error, got code=%d stderr=%q", code, errb.String())
+	}
+	t.Setenv("OJSTER_PASSPHRASE_FILE", passFile)
+	out.Reset()
+	if code := entrypoint("ojster", unseal, "v", &out, &errb); code != 0 || out.String() != "K=v" {
+		t.Fatalf("unexpected unseal code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+
+	errb.Reset()
+	if code := handleKeypair([]string{"--passphrase", "--passphrase-file", passFile}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "cannot be combined") {
+		t.Fatalf("expected conflict error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestKeypair_PEM(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.pem"), filepath.Join(td, "pub.pem")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub, "--pem"}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+	}
+	for path, typ := range map[string]string{priv: "OJSTER PRIVATE KEY", pub: "OJSTER PUBLIC KEY"} {
+		if data, _ := os.ReadFile(path); !strings.HasPrefix(string(data), "-----BEGIN "+typ+"-----\n") {
+			t.Fatalf("expected a %s PEM block in %s, got %q", typ, path, data)
+		}
+	}
+	envFile := filepath.Join(td, ".env")
+	withStdin(t, "v")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envFile, "K"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+	out.Reset()
+	if code := handleUnseal([]string{"--in", envFile, "--priv-file", priv}, &out, &errb); code != 0 || out.String() != "K=v" {
+		t.Fatalf("unexpected unseal code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+	}
+}
+
+// ----------------------------- key metadata ----------------------------------
+
+func TestInspect_Comment(t *testing.T) {
+	td := t.TempDir()
+	var out, errb bytes.Buffer
+	for _, enc := range []string{"base64", "PEM"} {
+		priv, pub := filepath.Join(td, enc+".priv"), filepath.Join(td, enc+".pub")
+		args := []string{"--priv-file", priv, "--pub-file", pub, "--comment", "prod 2026"}
+		if enc == "PEM" {
+			args = append(args, "--pem")
+		}
+		if code := handleKeypair(args, &out, &errb); code != 0 {
+			t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+		}
+
+		out.Reset()
+		if code := handleInspect([]string{priv, pub}, &out, &errb); code != 0 {
+			t.Fatalf("inspect failed: code=%d stderr=%q", code, errb.String())
+		}
+		got := out.String()
+		for _, want := range []string{
+			priv + ": private key (" + enc + ")\n",
+			pub + ": public key (" + enc + ")\n",
+			"  Comment:     prod 2026\n",
+			"  Algorithm:   ML-KEM-768\n",
+		} {
+			if !strings.Contains(got, want) {
+				t.Fatalf("%s: inspect output lacks %q:\n%s", enc, want, got)
+			}
+		}
+		// Both halves show the same fingerprint.
+		if fps := regexp.MustCompile(`Fingerprint: (SHA256:\S+)`).FindAllStringSubmatch(got, -1); len(fps) != 2 || fps[0][1] != fps[1][1] {
+			t.Fatalf("%s: expected two matching fingerprints:\n%s", enc, got)
+		}
+
+		// The commented files still work.
+		envFile := filepath.Join(td, enc+".env")
+		withStdin(t, "v")
+		if code := handleSeal([]string{"--pub-file", pub, "--out", envFile, "K"}, io.Discard, &errb); code != 0 {
+			t.Fatalf("seal failed: %s", errb.String())
+		}
+		out.Reset()
+		if code := handleUnseal([]string{"--in", envFile, "--priv-file", priv}, &out, &errb); code != 0 || out.String() != "K=v" {
+			t.Fatalf("unexpected unseal code=%d stdout=%q stderr=%q", code, out.String(), errb.String())
+		}
+	}
+
+	errb.Reset()
+	if code := handleKeypair([]string{"--comment", "a\nb"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "single line") {
+		t.Fatalf("expected comment error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleInspect([]string{filepath.Join(td, "missing")}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "failed to read key file") {
+		t.Fatalf("expected read error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestPubkey(t *testing.T) {
+	td := t.TempDir()
+	var out, errb bytes.Buffer
+	for _, extra := range [][]string{nil, {"--comment", "ci"}, {"--pem", "--comment", "ci"}} {
+		priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+		if code := handleKeypair(append([]string{"--priv-file", priv, "--pub-file", pub}, extra...), &out, &errb); code != 0 {
+			t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+		}
+		want, _ := os.ReadFile(pub)
+		out.Reset()
+		errb.Reset()
+		if code := handlePubkey([]string{"--priv-file", priv}, &out, &errb); code != 0 {
+			t.Fatalf("pubkey failed: code=%d stderr=%q", code, errb.String())
+		}
+		// The re-derived key matches the original file byte for byte.
+		if out.String() != string(want) {
+			t.Fatalf("%v: pubkey printed %q, want %q", extra, out.String(), want)
+		}
+		info, err := pqc.InspectKeyFile(pub)
+		if err != nil || errb.String() != "Fingerprint: "+info.Fingerprint+"\n" {
+			t.Fatalf("%v: unexpected stderr %q (%v)", extra, errb.String(), err)
+		}
+	}
+
+	errb.Reset()
+	if code := handlePubkey([]string{"--priv-file", filepath.Join(td, "pub.key")}, &out, &errb); code != 1 {
+		t.Fatalf("expected an error for a public key, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handlePubkey([]string{"extra"}, &out, &errb); code != 2 || !strings.Contains(errb.String(), "takes no arguments") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestKeypair_MnemonicRestore(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub, "--mnemonic"}, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: code=%d stderr=%q", code, errb.String())
+	}
+	_, words, ok := strings.Cut(out.String(), "keep them offline):\n")
+	if !ok {
+		t.Fatalf("no recovery words in output %q", out.String())
+	}
+
+	restored := filepath.Join(td, "restored.key")
+	withStdin(t, words)
+	if code := handleKeypair([]string{"--priv-file", restored, "--pub-file", filepath.Join(td, "restored.pub"), "--restore"}, io.Discard, &errb); code != 0 {
+		t.Fatalf("restore failed: code=%d stderr=%q", code, errb.String())
+	}
+	a, _ := os.ReadFile(priv)
+	b, _ := os.ReadFile(restored)
+	if !bytes.Equal(a, b) {
+		t.Fatal("restored private key differs from the original")
+	}
+
+	withStdin(t, strings.Repeat("zoo ", 48))
+	errb.Reset()
+	if code := handleKeypair([]string{"--priv-file", restored, "--restore"}, io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "checksum") {
+		t.Fatalf("expected checksum error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleKeypair([]string{"--mnemonic", "--restore"}, io.Discard, &errb); code != 2 {
+		t.Fatalf("expected conflict error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestSeal_IfChanged(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	args := []string{"--pub-file", pub, "--priv-file", priv, "--out", envPath, "--if-changed", "K"}
+	var out, errb bytes.Buffer
+	withStdin(t, "s3cret")
+	if code := handleSeal(args, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: code=%d stderr=%q", code, errb.String())
+	}
+	first, _ := os.ReadFile(envPath)
+	out.Reset()
+	withStdin(t, "s3cret")
+	if code := handleSeal(args, &out, &errb); code != 0 || !strings.Contains(out.String(), "K unchanged") {
+		t.Fatalf("expected unchanged, got code=%d out=%q stderr=%q", code, out.String(), errb.String())
+	}
+	if again, _ := os.ReadFile(envPath); !bytes.Equal(first, again) {
+		t.Fatal("unchanged secret rewrote the env file")
+	}
+
+	errb.Reset()
+	if code := handleSeal([]string{"--if-changed", "--out", filepath.Join(td, "x.json"), "K"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "only supports env files") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestSeal_StdinJSON(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	var out, errb bytes.Buffer
+	withStdin(t, `{"B": "two", "A": "multi\nline"}`)
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--stdin-json"}, &out, &errb); code != 0 {
+		t.Fatalf("seal failed: code=%d stderr=%q", code, errb.String())
+	}
+	if got := out.String(); got != "Wrote B to "+envPath+"\nWrote A to "+envPath+"\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	entries, err := env.ParseEnvFileEntries(envPath)
+	if err != nil || len(entries) != 2 || entries[0].Key != "B" || entries[1].Key != "A" {
+		t.Fatalf("expected B then A, got %+v (%v)", entries, err)
+	}
+	m, _ := env.ParseEnvFile(envPath)
+	got, err := pqc.UnsealMap(context.Background(), m, priv, nil)
+	if err != nil || got["A"] != "multi\nline" || got["B"] != "two" {
+		t.Fatalf("unexpected unsealed values %q (%v)", got, err)
+	}
+
+	for name, input := range map[string]string{
+		"not an object": `["A"]`,
+		"non-string":    `{"A": 1}`,
+		"duplicate":     `{"A": "1", "A": "2"}`,
+		"empty":         `{}`,
+		"trailing":      `{"A": "1"} {}`,
+	} {
+		withStdin(t, input)
+		errb.Reset()
+		if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--stdin-json"}, io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "invalid --stdin-json input") {
+			t.Fatalf("%s: expected input error, got code=%d stderr=%q", name, code, errb.String())
+		}
+	}
+	errb.Reset()
+	if code := handleSeal([]string{"--stdin-json", "KEY"}, io.Discard, &errb); code != 2 {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestSeal_KeyNameValidation(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	var errb bytes.Buffer
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "db_password"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "--allow-lowercase") {
+		t.Fatalf("expected invalid key name, got code=%d stderr=%q", code, errb.String())
+	}
+	if _, err := os.Stat(envPath); !os.IsNotExist(err) {
+		t.Fatal("rejected key name still wrote the env file")
+	}
+
+	errb.Reset()
+	withStdin(t, `{"OK": "1", "not-ok": "2"}`)
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--stdin-json"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), `"not-ok"`) {
+		t.Fatalf("expected invalid key name, got code=%d stderr=%q", code, errb.String())
+	}
+
+	errb.Reset()
+	withStdin(t, "s3cret")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--allow-lowercase", "db_password"}, io.Discard, &errb); code != 0 || !strings.Contains(errb.String(), "warning: key name \"db_password\"") {
+		t.Fatalf("expected a warning, got code=%d stderr=%q", code, errb.String())
+	}
+
+	errb.Reset()
+	withStdin(t, "s3cret")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--key-pattern", `^[a-z.]+$`, "app.token"}, io.Discard, &errb); code != 0 {
+		t.Fatalf("seal with --key-pattern failed: code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--key-pattern", `^[a-z]+$`, "TOKEN"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "must match ^[a-z]+$") {
+		t.Fatalf("expected invalid key name, got code=%d stderr=%q", code, errb.String())
+	}
+
+	for _, args := range [][]string{
+		{"--allow-lowercase", "--key-pattern", "x", "K"},
+		{"--key-pattern", "(", "K"},
+	} {
+		errb.Reset()
+		if code := handleSeal(args, io.Discard, &errb); code != 2 {
+			t.Fatalf("%v: expected usage error, got code=%d stderr=%q", args, code, errb.String())
+		}
+	}
+}
+
+func TestSeal_FrameAndEncoding(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	withStdin(t, "a")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "AES"}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("seal failed: code=%d", code)
+	}
+	withStdin(t, "f")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--frame", "FRAMED"}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("seal --frame failed: code=%d", code)
+	}
+	withStdin(t, "u")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--encoding", "base64url", "URL"}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("seal --encoding failed: code=%d", code)
+	}
+	if b, _ := os.ReadFile(envPath); !bytes.Contains(b, []byte("AES="+pqc.Prefix)) || !bytes.Contains(b, []byte("FRAMED="+pqc.FramedPrefix)) {
+		t.Fatalf("unexpected prefixes:\n%s", b)
+	}
+
+	var out, errb bytes.Buffer
+	if code := handleUnseal([]string{"--in", envPath, "--priv-file", priv}, &out, &errb); code != 0 {
+		t.Fatalf("unseal failed: code=%d stderr=%q", code, errb.String())
+	}
+	if got, want := out.String(), "AES=a\nFRAMED=f\nURL=u"; got != want {
+		t.Fatalf("unexpected output:\nwant %q\ngot  %q", want, got)
+	}
+
+	for _, args := range [][]string{
+		{"--encoding", "hex", "K"},
+		{"--encoding", "base64url", "--gpg-recipient", "alice@example.com", "K"},
+		{"--frame", "--gpg-recipient", "ABCD", "K"},
+	} {
+		errb.Reset()
+		if code := handleSeal(args, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), args[0]) {
+			t.Fatalf("%v: expected usage error, got code=%d stderr=%q", args, code, errb.String())
+		}
+	}
+}
+
+func TestDoctor_Flags(t *testing.T) {
+	td := t.TempDir()
+	t.Setenv("OJSTER_PRIVATE_KEY_FILE", filepath.Join(td, "missing.key"))
+	var out, errb bytes.Buffer
+	if code := handleDoctor([]string{"--socket", filepath.Join(td, "ipc.sock")}, &out, &errb); code != 1 {
+		t.Fatalf("expected problems, got code=%d out=%q", code, out.String())
+	}
+	// A default key file that does not exist is skipped, an explicit one is not.
+	if !strings.Contains(out.String(), "skipped: no private key file") || !strings.Contains(out.String(), "ipc.sock does not exist") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	out.Reset()
+	handleDoctor([]string{"--socket", filepath.Join(td, "ipc.sock"), "--priv-file", filepath.Join(td, "missing.key")}, &out, &errb)
+	if !strings.Contains(out.String(), "failed to read key file") {
+		t.Fatalf("explicit key file was not checked:\n%s", out.String())
+	}
+	if code := handleDoctor([]string{"extra"}, io.Discard, &errb); code != 2 {
+		t.Fatalf("expected usage error, got code=%d", code)
+	}
+}
+
+func TestInstallInit_Flags(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "bin")
+	var out, errb bytes.Buffer
+	if code := handleInstallInit([]string{"--target", target, "--volume", "tools"}, &out, &errb); code != 0 {
+		t.Fatalf("install-init failed: code=%d stderr=%q", code, errb.String())
+	}
+	if fi, err := os.Stat(filepath.Join(target, "docker-init")); err != nil || fi.Mode().Perm() != 0o755 {
+		t.Fatalf("binary not installed: %v", err)
+	}
+	if !strings.Contains(out.String(), "source: tools") {
+		t.Fatalf("snippet lacks volume name:\n%s", out.String())
+	}
+	if code := handleInstallInit([]string{"extra"}, io.Discard, &errb); code != 2 {
+		t.Fatalf("expected usage error, got code=%d", code)
+	}
+}
+
+func TestMigrate_Flags(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	withStdin(t, "s3cret")
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--frame", "TOKEN"}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("seal failed: code=%d", code)
+	}
+	var out, errb bytes.Buffer
+	if code := handleMigrate([]string{"--in", envPath, "--priv-file", priv}, &out, &errb); code != 0 || !strings.Contains(out.String(), "Nothing to migrate") {
+		t.Fatalf("migrate: code=%d out=%q stderr=%q", code, out.String(), errb.String())
+	}
+	if code := handleMigrate([]string{"extra"}, io.Discard, &errb); code != 2 {
+		t.Fatalf("expected usage error, got code=%d", code)
+	}
+}
+
+func TestUnseal_KeyPatterns(t *testing.T) {
+	td := t.TempDir()
+	priv, pub, envPath := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := handleKeypair([]string{"--priv-file", priv, "--pub-file", pub}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	withStdin(t, `{"DB_USER": "u", "DB_PASS": "p", "SMTP_PASS": "s", "OTHER": "o"}`)
+	if code := handleSeal([]string{"--pub-file", pub, "--out", envPath, "--stdin-json"}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("seal failed: code=%d", code)
+	}
+	f, _ := os.OpenFile(envPath, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.WriteString("DB_HOST=localhost\n")
+	f.Close()
+
+	var out, errb bytes.Buffer
+	if code := handleUnseal([]string{"--in", envPath, "--priv-file", priv, "--prefix", "SMTP_", "DB_*"}, &out, &errb); code != 0 {
+		t.Fatalf("unseal failed: code=%d stderr=%q", code, errb.String())
+	}
+	if got, want := out.String(), "DB_PASS=p\nDB_USER=u\nSMTP_PASS=s"; got != want {
+		t.Fatalf("unexpected output:\nwant %q\ngot  %q", want, got)
+	}
+
+	out.Reset()
+	if code := handleUnseal([]string{"--in", envPath, "--priv-file", priv, "--format", "shell", "--prefix", "DB_"}, &out, &errb); code != 0 || strings.Contains(out.String(), "SMTP") || !strings.Contains(out.String(), "DB_USER") {
+		t.Fatalf("unexpected --format output: code=%d out=%q stderr=%q", code, out.String(), errb.String())
+	}
+
+	errb.Reset()
+	if code := handleUnseal([]string{"--in", envPath, "--priv-file", priv, "NOPE_*"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "NOPE_*") {
+		t.Fatalf("expected missing-key error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestUnsealExec_Errors(t *testing.T) {
+	td := t.TempDir()
+	var errb bytes.Buffer
+	if code := handleUnseal([]string{"exec", "--in", filepath.Join(td, ".env")}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "requires a command") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleUnseal([]string{"exec", "--priv-file", filepath.Join(td, "missing.key"), "--", "true"}, io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "failed to read private key") {
+		t.Fatalf("expected key error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestServe_PprofLoopbackOnly(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:6060": true,
+		"[::1]:6060":     true,
+		"localhost:6060": true,
+		"0.0.0.0:6060":   false,
+		":6060":          false,
+		"10.0.0.1:6060":  false,
+		"127.0.0.1":      false,
+	} {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("isLoopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+	var errb bytes.Buffer
+	if code := handleServe([]string{"--pprof", ":6060"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "loopback") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--stdin"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "needs a command") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--isolate"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "needs a command") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--max-keys", "0"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "at least 1") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--max-value-len", "0"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "--max-value-len must be at least 1") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--cmd-timeout", "0s"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "must be positive") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--landlock", "--no-landlock"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "cannot be combined") {
+		t.Fatalf("expected usage error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	t.Setenv("OJSTER_REGEX", "(")
+	if code := handleServe(nil, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "invalid OJSTER_REGEX") {
+		t.Fatalf("expected regex error, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestServe_ConfigConflicts(t *testing.T) {
+	cfg := tmpFilePath(t, "serve.json")
+	if err := os.WriteFile(cfg, []byte(`{"sockets": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var errb bytes.Buffer
+	if code := handleServe([]string{"--config", cfg, "--", "/bin/true"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "per socket") {
+		t.Fatalf("expected command conflict, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--config", cfg, "--socket", "/tmp/x.sock"}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "--socket or --priv-file") {
+		t.Fatalf("expected socket conflict, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := handleServe([]string{"--config", cfg}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "no sockets configured") {
+		t.Fatalf("expected config error, got code=%d stderr=%q", code, errb.String())
+	}
+	t.Setenv("OJSTER_LISTEN_ADDR", "127.0.0.1:9999")
+	errb.Reset()
+	if code := handleServe([]string{"--config", cfg}, io.Discard, &errb); code != 2 || !strings.Contains(errb.String(), "OJSTER_LISTEN_ADDR") {
+		t.Fatalf("expected listen addr conflict, got code=%d stderr=%q", code, errb.String())
+	}
+}
+
+func TestParseRestart(t *testing.T) {
+	cases := []struct {
+		in      string
+		restart bool
+		max     int
+	}{
+		{"", false, 0},
+		{"no", false, 0},
+		{"on-failure", true, 0},
+		{"on-failure:3", true, 3},
+	}
+	for _, c := range cases {
+		restart, max, err := parseRestart(c.in)
+		if err != nil || restart != c.restart || max != c.max {
+			t.Errorf("parseRestart(%q) = %v, %d, %v; want %v, %d", c.in, restart, max, err, c.restart, c.max)
+		}
+	}
+	for _, in := range []string{"always", "on-failure:", "on-failure:0", "on-failure:x", "no:1"} {
+		if _, _, err := parseRestart(in); err == nil {
+			t.Errorf("parseRestart(%q): expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// cliSchema is what help --json prints: every command with its flags and
+// every environment variable, for wrappers and docs generators.
+type cliSchema struct {
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	Commands []commandSchema `json:"commands"`
+	Env      []envSchema     `json:"env"`
+}
+
+type commandSchema struct {
+	Name        string       `json:"name"`
+	Usage       string       `json:"usage"`
+	Description string       `json:"description"`
+	Flags       []flagSchema `json:"flags"`
+}
+
+type flagSchema struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+	Usage   string `json:"usage"`
+}
+
+type envSchema struct {
+	Names       []string `json:"names"`
+	Description string   `json:"description"`
+}
+
+// collectFlags, when set, receives the FlagSet of the command being run
+// instead of it being parsed (see parseFlags), so help --json can read the
+// flags each handler defines without running it.
+var collectFlags func(fs *flag.FlagSet)
+
+// subcommands lists the actions of commands that define their flags per
+// action.
+var subcommands = map[string][]string{"bundle": {"pack", "unpack"}, "k8s": {"export", "init"}}
+
+func handleHelp(args []string, version string, outw io.Writer, errw io.Writer) int {
+	const cmdName = "help"
+	fs := flag.NewFlagSet(cmdName, flag.ContinueOnError)
+	fs.SetOutput(outw)
+	jsonOut := fs.Bool("json", false, "print the commands, their flags and the environment variables as JSON")
+	if code := parseFlags(fs, args, errw, cmdName); code >= 0 {
+		return code
+	}
+	if !*jsonOut {
+		usage(outw)
+		return 0
+	}
+	enc := json.NewEncoder(outw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema(version)); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	return 0
+}
+
+// schema builds the cliSchema from commands, the handlers' FlagSets and the
+// environment variables documented in header.
+func schema(version string) cliSchema {
+	s := cliSchema{Name: "ojster", Version: version, Commands: []commandSchema{}, Env: envFromHeader()}
+	for _, c := range commands {
+		name := strings.TrimPrefix(c.synopsis, "ojster ")
+		actions := subcommands[name]
+		if actions == nil {
+			actions = []string{""}
+		}
+		for _, action := range actions {
+			cs := commandSchema{Name: strings.TrimSpace(name + " " + action), Usage: c.synopsis + " " + c.args, Description: c.desc, Flags: []flagSchema{}}
+			var fs *flag.FlagSet
+			collectFlags = func(f *flag.FlagSet) { fs = f }
+			argv := append(strings.Fields(cs.Name), "-h")
+			entrypoint("ojster", argv, version, io.Discard, io.Discard)
+			collectFlags = nil
+			if fs != nil {
+				fs.VisitAll(func(f *flag.Flag) { cs.Flags = append(cs.Flags, flagOf(f)) })
+			}
+			s.Commands = append(s.Commands, cs)
+		}
+	}
+	return s
+}
+
+func flagOf(f *flag.Flag) flagSchema {
+	typ, usage := flag.UnquoteUsage(f)
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		typ = "bool"
+	} else if typ == "" {
+		typ = "value"
+	}
+	def := f.DefValue
+	if typ == "bool" && def == "false" {
+		def = ""
+	}
+	return flagSchema{Name: f.Name, Type: typ, Default: def, Usage: usage}
+}
+
+// envFromHeader parses the "Environment variables:" section of header, where
+// names are indented by two spaces and their description by six.
+func envFromHeader() []envSchema {
+	_, section, _ := strings.Cut(header, "Environment variables:\n")
+	section, _, _ = strings.Cut(section, "\nUsage:")
+	var env []envSchema
+	for _, line := range strings.Split(section, "\n") {
+		switch {
+		case strings.HasPrefix(line, "      ") && len(env) > 0:
+			e := &env[len(env)-1]
+			e.Description = strings.TrimSpace(e.Description + " " + strings.TrimSpace(line))
+		case strings.HasPrefix(line, "  "):
+			env = append(env, envSchema{Names: strings.Split(strings.TrimSpace(line), ", ")})
+		}
+	}
+	return env
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestHandleHelp_JSON(t *testing.T) {
+	var out, errb bytes.Buffer
+	if code := entrypoint("ojster", []string{"help", "--json"}, "1.2.3", &out, &errb); code != 0 {
+		t.Fatalf("help --json returned %d; stderr=%q", code, errb.String())
+	}
+	var s cliSchema
+	if err := json.Unmarshal(out.Bytes(), &s); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	if s.Version != "1.2.3" {
+		t.Errorf("version %q", s.Version)
+	}
+
+	byName := map[string]commandSchema{}
+	for _, c := range s.Commands {
+		byName[c.Name] = c
+	}
+	for name, flag := range map[string]string{
+		"seal":        "pub-file",
+		"unseal exec": "in",
+		"k8s init":    "configmap-dir",
+		"bundle pack": "out",
+		"run":         "timeout",
+		"serve":       "max-keys",
+	} {
+		c, ok := byName[name]
+		if !ok {
+			t.Errorf("command %q missing", name)
+			continue
+		}
+		if !slices.ContainsFunc(c.Flags, func(f flagSchema) bool { return f.Name == flag }) {
+			t.Errorf("%s lacks --%s: %+v", name, flag, c.Flags)
+		}
+	}
+	if f := byName["run"].Flags[slices.IndexFunc(byName["run"].Flags, func(f flagSchema) bool { return f.Name == "wait" })]; f.Type != "bool" || f.Default != "" {
+		t.Errorf("run --wait described as %+v", f)
+	}
+
+	if !slices.ContainsFunc(s.Env, func(e envSchema) bool {
+		return slices.Equal(e.Names, []string{"OJSTER_WAIT", "OJSTER_KILL_TIMEOUT"}) && e.Description != ""
+	}) {
+		t.Errorf("env lacks OJSTER_WAIT, OJSTER_KILL_TIMEOUT: %+v", s.Env)
+	}
+	for _, e := range s.Env {
+		if e.Description == "" {
+			t.Errorf("%v has no description", e.Names)
+		}
+	}
+
+	// Collecting the flags must not leave parseFlags disabled.
+	if collectFlags != nil {
+		t.Fatal("collectFlags left set")
+	}
+}
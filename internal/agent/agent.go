@@ -0,0 +1,234 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent implements "ojster agent": a per-user process that speaks the
+// server's request protocol on its own socket, forwards sealed values it has
+// not seen to the real server over a kept-alive connection, and caches the
+// decrypted values in locked memory for a TTL. Pointing OJSTER_SOCKET_PATH of
+// "ojster run" at the agent makes repeated short-lived commands skip the
+// round trip to the server.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ojster/ojster/internal/cbor"
+	"github.com/ojster/ojster/internal/client"
+	"github.com/ojster/ojster/internal/protocol"
+	"github.com/ojster/ojster/internal/util/ipc"
+	"github.com/ojster/ojster/internal/util/memlock"
+)
+
+// Options controls Serve.
+type Options struct {
+	// SocketPath is the per-user socket the agent listens on. Its directory
+	// is created with mode 0700 and the socket gets mode 0600.
+	SocketPath string
+	// Upstream is the server requests are forwarded to. The request's URL
+	// path replaces Upstream.Route.
+	Upstream client.Endpoint
+	// TTL is how long a decrypted value is served from the cache.
+	TTL time.Duration
+}
+
+// unsealFunc is a var so tests can stub the upstream server.
+var unsealFunc = client.Unseal
+
+// Serve runs the agent until ctx is cancelled or the listener fails, and
+// returns an exit code. Cached values are wiped on the way out.
+func Serve(opts Options, ctx context.Context, errw io.Writer) int {
+	if opts.TTL <= 0 {
+		fmt.Fprintln(errw, "agent TTL must be positive")
+		return 2
+	}
+	if err := memlock.Probe(); err != nil {
+		fmt.Fprintf(errw, "warning: cached values may be swapped to disk (raise RLIMIT_MEMLOCK): %v\n", err)
+	}
+
+	ln, code := listen(opts.SocketPath, errw)
+	if code != 0 {
+		return code
+	}
+	fmt.Fprintf(errw, "ojster agent serving on %s\n", ipc.Describe(opts.SocketPath))
+
+	a := newAgent(opts.Upstream.KeepAlive(), opts.TTL)
+	defer a.cache.clear()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go a.cache.sweep(ctx, opts.TTL/2)
+
+	srv := &http.Server{Handler: a}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(errw, fmt.Errorf("agent error: %v", err))
+		return 1
+	}
+	return 0
+}
+
+type agent struct {
+	upstream client.Endpoint
+	cache    *cache
+}
+
+func newAgent(upstream client.Endpoint, ttl time.Duration) *agent {
+	return &agent{upstream: upstream, cache: newCache(ttl)}
+}
+
+// ServeHTTP answers a decrypt request from the cache, forwarding only the
+// values it does not hold to the server.
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(protocol.VersionsHeader, protocol.Versions())
+	w.Header().Set(protocol.Header, strconv.Itoa(protocol.Version))
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	v, err := protocol.Check(r.Header.Get(protocol.Header))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set(protocol.Header, strconv.Itoa(v))
+
+	defer r.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(r.Body, 10*1024*1024))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var incoming map[string]string
+	if isCBOR(r.Header.Get("Content-Type")) {
+		incoming, err = cbor.DecodeMap(data)
+	} else {
+		err = json.Unmarshal(data, &incoming)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	route := "/" + strings.TrimPrefix(r.URL.Path, "/")
+	reply := make(map[string]string, len(incoming))
+	var once []string
+	missing := map[string]string{}
+	for k, sealed := range incoming {
+		if v, ok := a.cache.get(route, sealed); ok {
+			reply[k] = v
+		} else {
+			missing[k] = sealed
+		}
+	}
+
+	if len(missing) > 0 {
+		ep := a.upstream
+		ep.Route = route
+		// The caller's request ID is passed on, so the server log matches
+		// its retry messages; without one the server makes one up.
+		got, status, err := unsealFunc(r.Context(), ep, missing, r.Header.Get("X-Request-ID"))
+		if err != nil {
+			if status == 0 || (status >= 200 && status < 300) {
+				status = http.StatusBadGateway
+			}
+			http.Error(w, "upstream: "+err.Error(), status)
+			return
+		}
+		// Single-delivery keys are passed on but not cached, or the
+		// agent would hand them out again.
+		for k, v := range got.Values {
+			if !slices.Contains(got.SingleDelivery, k) {
+				a.cache.put(route, missing[k], v)
+			}
+			reply[k] = v
+		}
+		once = got.SingleDelivery
+	}
+	if len(once) > 0 {
+		w.Header().Set(protocol.SingleDeliveryHeader, strings.Join(once, ","))
+	}
+
+	replyCBOR := false
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		replyCBOR = replyCBOR || isCBOR(accept)
+	}
+	var body []byte
+	if replyCBOR {
+		body = cbor.EncodeMap(reply)
+		w.Header().Set("Content-Type", cbor.ContentType)
+	} else {
+		body, _ = json.Marshal(reply)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// isCBOR reports whether a Content-Type or Accept value names CBOR.
+func isCBOR(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), cbor.ContentType)
+}
+
+// listen listens on the socket at path, inside a directory only its owner
+// can access since cached values are served without further checks. A named
+// pipe on Windows keeps its default access, limited to its creator and
+// administrators. On failure it returns a nil listener and an exit code.
+func listen(path string, errw io.Writer) (net.Listener, int) {
+	if ipc.IsPipe(path) {
+		ln, err := ipc.Listen(path)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to listen on %s: %v", ipc.Describe(path), err))
+			return nil, 1
+		}
+		return ln, 0
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to create socket directory %s: %v", dir, err))
+		return nil, 1
+	}
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(errw, "agent socket directory %s must only be accessible by its owner; run chmod 700 on it\n", dir)
+		return nil, 1
+	}
+	_ = os.RemoveAll(path)
+	ln, err := ipc.Listen(path)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to listen on unix socket %s: %v", path, err))
+		return nil, 1
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to chmod socket %s: %v", path, err))
+		ln.Close()
+		return nil, 1
+	}
+	return ln, 0
+}
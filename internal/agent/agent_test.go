@@ -0,0 +1,200 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ojster/ojster/internal/cbor"
+	"github.com/ojster/ojster/internal/client"
+	"github.com/ojster/ojster/internal/protocol"
+)
+
+// stubUpstream replaces the server with one that "decrypts" a value by
+// prefixing it with the route, and records what was forwarded. Keys starting
+// with ONCE are marked as single-delivery.
+func stubUpstream(t *testing.T) *[]map[string]string {
+	t.Helper()
+	old := unsealFunc
+	t.Cleanup(func() { unsealFunc = old })
+	var calls []map[string]string
+	unsealFunc = func(_ context.Context, ep client.Endpoint, m map[string]string, _ string) (client.Reply, int, error) {
+		calls = append(calls, maps.Clone(m))
+		if _, ok := m["FAIL"]; ok {
+			return client.Reply{}, http.StatusForbidden, errors.New("server returned status=403")
+		}
+		reply := client.Reply{Values: make(map[string]string, len(m))}
+		for k, v := range m {
+			reply.Values[k] = ep.Route + ":" + v
+			if strings.HasPrefix(k, "ONCE") {
+				reply.SingleDelivery = append(reply.SingleDelivery, k)
+			}
+		}
+		return reply, http.StatusOK, nil
+	}
+	return &calls
+}
+
+func post(t *testing.T, a *agent, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestAgent_Caches(t *testing.T) {
+	calls := stubUpstream(t)
+	a := newAgent(client.Endpoint{}, time.Minute)
+	now := time.Unix(1000, 0)
+	a.cache.now = func() time.Time { return now }
+
+	for _, tc := range []struct {
+		path, body, want string
+		forwarded        map[string]string
+	}{
+		{"/", `{"A":"s1","B":"s2"}`, `{"A":"/:s1","B":"/:s2"}`, map[string]string{"A": "s1", "B": "s2"}},
+		{"/", `{"A":"s1","C":"s3"}`, `{"A":"/:s1","C":"/:s3"}`, map[string]string{"C": "s3"}},
+		{"/projectA", `{"A":"s1"}`, `{"A":"/projectA:s1"}`, map[string]string{"A": "s1"}},
+		{"/", `{"A":"s1","B":"s2","C":"s3"}`, `{"A":"/:s1","B":"/:s2","C":"/:s3"}`, nil},
+	} {
+		before := len(*calls)
+		rec := post(t, a, tc.path, tc.body)
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+			t.Fatalf("POST %s %s = %d %q, want %q", tc.path, tc.body, rec.Code, rec.Body.String(), tc.want)
+		}
+		var forwarded map[string]string
+		if len(*calls) > before {
+			forwarded = (*calls)[before]
+		}
+		if !maps.Equal(forwarded, tc.forwarded) {
+			t.Fatalf("POST %s %s forwarded %v, want %v", tc.path, tc.body, forwarded, tc.forwarded)
+		}
+	}
+
+	// Expired values are fetched again, and swept from memory.
+	now = now.Add(time.Minute)
+	if rec := post(t, a, "/", `{"A":"s1"}`); rec.Code != http.StatusOK || !maps.Equal((*calls)[len(*calls)-1], map[string]string{"A": "s1"}) {
+		t.Fatalf("expired value was not forwarded")
+	}
+	now = now.Add(time.Minute)
+	a.cache.expire()
+	if len(a.cache.entries) != 0 {
+		t.Fatalf("expire left %d entries", len(a.cache.entries))
+	}
+}
+
+func TestAgent_SingleDeliveryNotCached(t *testing.T) {
+	calls := stubUpstream(t)
+	a := newAgent(client.Endpoint{}, time.Minute)
+
+	for i := range 2 {
+		rec := post(t, a, "/", `{"A":"s1","ONCE_TOKEN":"s2"}`)
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"A":"/:s1","ONCE_TOKEN":"/:s2"}` {
+			t.Fatalf("POST %d = %d %q", i, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get(protocol.SingleDeliveryHeader); got != "ONCE_TOKEN" {
+			t.Fatalf("POST %d: %s = %q, want ONCE_TOKEN", i, protocol.SingleDeliveryHeader, got)
+		}
+	}
+	// The second request forwarded the single-delivery key again, so the
+	// server decides whether it may be released.
+	if len(*calls) != 2 || !maps.Equal((*calls)[1], map[string]string{"ONCE_TOKEN": "s2"}) {
+		t.Fatalf("forwarded %v", *calls)
+	}
+}
+
+func TestAgent_Errors(t *testing.T) {
+	stubUpstream(t)
+	a := newAgent(client.Endpoint{}, time.Minute)
+
+	if rec := post(t, a, "/", `{"FAIL":"s"}`); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "upstream:") {
+		t.Fatalf("upstream failure relayed as %d %q", rec.Code, rec.Body.String())
+	}
+	if len(a.cache.entries) != 0 {
+		t.Fatal("failed reply was cached")
+	}
+	if rec := post(t, a, "/", `{bad`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid JSON got %d", rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET got %d", rec.Code)
+	}
+}
+
+func TestAgent_CBOR(t *testing.T) {
+	stubUpstream(t)
+	a := newAgent(client.Endpoint{}, time.Minute)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(cbor.EncodeMap(map[string]string{"A": "s1"})))
+	req.Header.Set("Content-Type", cbor.ContentType)
+	req.Header.Set("Accept", cbor.ContentType)
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	if got, err := cbor.DecodeMap(rec.Body.Bytes()); err != nil || got["A"] != "/:s1" {
+		t.Fatalf("CBOR reply = %v, %v", got, err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	stubUpstream(t)
+	dir := filepath.Join(t.TempDir(), "agent")
+	socketPath := filepath.Join(dir, "agent.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	var errb bytes.Buffer
+	go func() { done <- Serve(Options{SocketPath: socketPath, TTL: time.Minute}, ctx, &errb) }()
+
+	// run talks to the agent exactly as it would to the server.
+	var reply client.Reply
+	for i := 0; ; i++ {
+		got, _, err := client.Unseal(context.Background(), client.Endpoint{SocketPath: socketPath, Route: "/"}, map[string]string{"A": "s1", "ONCE_TOKEN": "s2"}, "req-1")
+		if err == nil {
+			reply = got
+			break
+		}
+		if i == 50 {
+			t.Fatalf("agent did not answer: %v (stderr %q)", err, errb.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if reply.Values["A"] != "/:s1" || !slices.Equal(reply.SingleDelivery, []string{"ONCE_TOKEN"}) {
+		t.Fatalf("reply = %+v", reply)
+	}
+	for path, want := range map[string]os.FileMode{dir: 0o700, socketPath: 0o600} {
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != want {
+			t.Fatalf("%s: mode %v, want %v (%v)", path, fi.Mode().Perm(), want, err)
+		}
+	}
+
+	cancel()
+	if code := <-done; code != 0 {
+		t.Fatalf("Serve returned %d: %s", code, errb.String())
+	}
+	if code := Serve(Options{SocketPath: socketPath}, context.Background(), &errb); code != 2 {
+		t.Fatalf("expected 2 without a TTL, got %d", code)
+	}
+}
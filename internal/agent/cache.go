@@ -0,0 +1,111 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ojster/ojster/internal/util/memlock"
+)
+
+// cache maps a route and sealed value to its plaintext until the TTL runs
+// out. Plaintexts are held in locked memory and wiped when they expire.
+type cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	plaintext []byte
+	expires   time.Time
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{ttl: ttl, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+// cacheKey separates routes, which may decrypt with different keys.
+func cacheKey(route, sealed string) string { return route + "\x00" + sealed }
+
+func (c *cache) get(route, sealed string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(route, sealed)
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.now().Before(e.expires) {
+		memlock.Wipe(e.plaintext)
+		delete(c.entries, key)
+		return "", false
+	}
+	return string(e.plaintext), true
+}
+
+func (c *cache) put(route, sealed, plaintext string) {
+	b := []byte(plaintext)
+	_ = memlock.Lock(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(route, sealed)
+	if old, ok := c.entries[key]; ok {
+		memlock.Wipe(old.plaintext)
+	}
+	c.entries[key] = cacheEntry{plaintext: b, expires: c.now().Add(c.ttl)}
+}
+
+// expire wipes and drops every entry whose TTL has run out.
+func (c *cache) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			memlock.Wipe(e.plaintext)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// sweep calls expire every interval until ctx is done, so values leave
+// memory on time even when nobody asks for them again.
+func (c *cache) sweep(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(max(interval, time.Second))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.expire()
+		}
+	}
+}
+
+// clear wipes and drops every entry.
+func (c *cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		memlock.Wipe(e.plaintext)
+		delete(c.entries, key)
+	}
+}
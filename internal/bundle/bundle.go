@@ -0,0 +1,316 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bundle packs several env files plus a manifest into a single
+// encrypted .ojster archive that only the holder of the target private key
+// can unpack.
+//
+// Layout: Magic || ML-KEM ciphertext || AES-GCM blob, where the GCM blob
+// decrypts to a gzip-compressed tar whose first entry is manifest.json.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/mlkem"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+const (
+	// Magic starts every bundle file.
+	Magic        = "OJSTER-BUNDLE-1\n"
+	manifestName = "manifest.json"
+	filesDir     = "files/"
+	// maxBundleSize bounds how much is read and decompressed when unpacking.
+	maxBundleSize = 64 * 1024 * 1024
+)
+
+var ErrFormat = errors.New("bundle: invalid bundle")
+
+// nowFunc is a var so tests can pin the manifest timestamp.
+var nowFunc = time.Now
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	Version int          `json:"version"`
+	Created time.Time    `json:"created"`
+	Files   []FileRecord `json:"files"`
+}
+
+// FileRecord describes one packed file.
+type FileRecord struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// archiveName validates a user supplied path and returns its slash form.
+func archiveName(p string) (string, error) {
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("%s: only relative paths inside the current directory can be bundled", p)
+	}
+	return filepath.ToSlash(filepath.Clean(p)), nil
+}
+
+// Pack reads the files at paths and returns the encrypted bundle for ek.
+func Pack(ek *mlkem.EncapsulationKey768, paths []string) ([]byte, *Manifest, error) {
+	if len(paths) == 0 {
+		return nil, nil, errors.New("no files to bundle")
+	}
+
+	m := &Manifest{Version: 1, Created: nowFunc().UTC().Truncate(time.Second)}
+	contents := make([][]byte, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		name, err := archiveName(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("%s: listed more than once", p)
+		}
+		seen[name] = true
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		sum := sha256.Sum256(b)
+		m.Files = append(m.Files, FileRecord{Name: name, Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])})
+		contents = append(contents, b)
+	}
+
+	mj, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var plain bytes.Buffer
+	gz := gzip.NewWriter(&plain)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: m.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(manifestName, mj); err != nil {
+		return nil, nil, err
+	}
+	for i, f := range m.Files {
+		if err := write(filesDir+f.Name, contents[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	mlkemCiphertext, gcmBlob, err := pqc.SealBytes(ek, plain.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make([]byte, 0, len(Magic)+len(mlkemCiphertext)+len(gcmBlob))
+	out = append(out, Magic...)
+	out = append(out, mlkemCiphertext...)
+	out = append(out, gcmBlob...)
+	return out, m, nil
+}
+
+// Open decrypts a bundle and returns its manifest and file contents keyed by name.
+// Every file is verified against the manifest.
+func Open(dk *mlkem.DecapsulationKey768, data []byte) (*Manifest, map[string][]byte, error) {
+	rest, ok := bytes.CutPrefix(data, []byte(Magic))
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing %q header", ErrFormat, Magic[:len(Magic)-1])
+	}
+	if len(rest) <= mlkem.CiphertextSize768 {
+		return nil, nil, fmt.Errorf("%w: truncated", ErrFormat)
+	}
+	plain, err := pqc.OpenBytes(dk, rest[:mlkem.CiphertextSize768], rest[mlkem.CiphertextSize768:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	tr := tar.NewReader(io.LimitReader(gz, maxBundleSize))
+
+	var m *Manifest
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("%w: unexpected entry %s", ErrFormat, hdr.Name)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		if m == nil {
+			if hdr.Name != manifestName {
+				return nil, nil, fmt.Errorf("%w: manifest must be the first entry", ErrFormat)
+			}
+			m = &Manifest{}
+			if err := json.Unmarshal(b, m); err != nil {
+				return nil, nil, fmt.Errorf("%w: manifest: %v", ErrFormat, err)
+			}
+			if m.Version != 1 {
+				return nil, nil, fmt.Errorf("%w: unsupported manifest version %d", ErrFormat, m.Version)
+			}
+			continue
+		}
+		name, ok := cutFilesDir(hdr.Name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unexpected entry %s", ErrFormat, hdr.Name)
+		}
+		files[name] = b
+	}
+	if m == nil {
+		return nil, nil, fmt.Errorf("%w: missing manifest", ErrFormat)
+	}
+
+	if len(files) != len(m.Files) {
+		return nil, nil, fmt.Errorf("%w: manifest lists %d files, bundle contains %d", ErrFormat, len(m.Files), len(files))
+	}
+	for _, f := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+			return nil, nil, fmt.Errorf("%w: unsafe path %q", ErrFormat, f.Name)
+		}
+		b, ok := files[f.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s listed in manifest but missing", ErrFormat, f.Name)
+		}
+		sum := sha256.Sum256(b)
+		if int64(len(b)) != f.Size || hex.EncodeToString(sum[:]) != f.SHA256 {
+			return nil, nil, fmt.Errorf("%w: %s does not match manifest", ErrFormat, f.Name)
+		}
+	}
+	return m, files, nil
+}
+
+func cutFilesDir(name string) (string, bool) {
+	if len(name) <= len(filesDir) || name[:len(filesDir)] != filesDir {
+		return "", false
+	}
+	return path.Clean(name[len(filesDir):]), true
+}
+
+// PackToFile packs paths for the public key at pubPath into outPath and lists
+// the packed files on outw. Returns an exit code and writes errors to errw.
+func PackToFile(pubPath, outPath string, paths []string, outw io.Writer, errw io.Writer) int {
+	ek, err := pqc.LoadEncapsulationKey(pubPath)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	data, m, err := Pack(ek, paths)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to pack bundle: %w", err))
+		return 1
+	}
+	if err := file.WriteFileAtomic(outPath, data, 0o644); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to write bundle %s: %w", outPath, err))
+		return 1
+	}
+	for _, f := range m.Files {
+		fmt.Fprintf(outw, "Packed %s (%d bytes)\n", f.Name, f.Size)
+	}
+	fmt.Fprintf(outw, "Wrote bundle to %s\n", outPath)
+	return 0
+}
+
+// UnpackToDir decrypts the bundle at inPath with the private key at privPath and
+// writes its files below dir with mode 0600. Existing files are only replaced
+// when force is set. Returns an exit code and writes errors to errw.
+func UnpackToDir(inPath, privPath, dir string, force bool, outw io.Writer, errw io.Writer) int {
+	dk, err := pqc.LoadDecapsulationKey(privPath)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	data, err := readLimited(inPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read bundle %s: %w", inPath, err))
+		return 1
+	}
+	m, files, err := Open(dk, data)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to open bundle %s: %w", inPath, err))
+		return 1
+	}
+
+	// Check every target up front so a conflict doesn't leave a partial unpack.
+	targets := make([]string, len(m.Files))
+	for i, f := range m.Files {
+		targets[i] = filepath.Join(dir, filepath.FromSlash(f.Name))
+		if _, err := os.Lstat(targets[i]); err == nil && !force {
+			fmt.Fprintf(errw, "refusing to overwrite existing %s (use --force)\n", targets[i])
+			return 1
+		}
+	}
+	for i, f := range m.Files {
+		if err := os.MkdirAll(filepath.Dir(targets[i]), 0o755); err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		if err := file.WriteFileAtomic(targets[i], files[f.Name], 0o600); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to write %s: %w", targets[i], err))
+			return 1
+		}
+		fmt.Fprintf(outw, "Unpacked %s\n", targets[i])
+	}
+	return 0
+}
+
+func readLimited(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxBundleSize {
+		return nil, fmt.Errorf("bundle exceeds %d bytes", maxBundleSize)
+	}
+	return b, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/mlkem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// chdirTemp switches into a fresh temp dir for the duration of the test.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	td := t.TempDir()
+	t.Chdir(td)
+	return td
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func keypair(t *testing.T) (priv, pub string) {
+	t.Helper()
+	td := t.TempDir()
+	priv, pub = filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	return priv, pub
+}
+
+// sealRaw encrypts an arbitrary tar.gz payload so Open's validation can be exercised.
+func sealRaw(t *testing.T, ek *mlkem.EncapsulationKey768, entries map[string]string, order []string) []byte {
+	t.Helper()
+	var plain bytes.Buffer
+	gz := gzip.NewWriter(&plain)
+	tw := tar.NewWriter(gz)
+	for _, name := range order {
+		data := entries[name]
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		_, _ = io.WriteString(tw, data)
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	ct, blob, err := pqc.SealBytes(ek, plain.Bytes())
+	if err != nil {
+		t.Fatalf("SealBytes: %v", err)
+	}
+	return append(append([]byte(Magic), ct...), blob...)
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Pack(), Open() and UnpackToDir()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestPackUnpack_Roundtrip(t *testing.T) {
+	priv, pub := keypair(t)
+	chdirTemp(t)
+	writeFile(t, "api.env", "A=1\n")
+	writeFile(t, "envs/db.env", "DB_PASSWORD=OJSTER-1:x:y\n")
+
+	var out, errb bytes.Buffer
+	if code := PackToFile(pub, "stack.ojster", []string{"api.env", "envs/db.env"}, &out, &errb); code != 0 {
+		t.Fatalf("PackToFile failed: %s", errb.String())
+	}
+	if !strings.Contains(out.String(), "Packed envs/db.env") {
+		t.Fatalf("expected file listing, got %q", out.String())
+	}
+	raw, _ := os.ReadFile("stack.ojster")
+	if bytes.Contains(raw, []byte("DB_PASSWORD")) {
+		t.Fatalf("bundle must not contain plaintext")
+	}
+
+	dest := t.TempDir()
+	out.Reset()
+	if code := UnpackToDir("stack.ojster", priv, dest, false, &out, &errb); code != 0 {
+		t.Fatalf("UnpackToDir failed: %s", errb.String())
+	}
+	got, err := os.ReadFile(filepath.Join(dest, "envs", "db.env"))
+	if err != nil || string(got) != "DB_PASSWORD=OJSTER-1:x:y\n" {
+		t.Fatalf("unexpected unpacked content %q (err=%v)", got, err)
+	}
+	fi, _ := os.Stat(filepath.Join(dest, "api.env"))
+	if fi.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600, got %v", fi.Mode().Perm())
+	}
+
+	// second unpack refuses to overwrite unless forced
+	errb.Reset()
+	if code := UnpackToDir("stack.ojster", priv, dest, false, &out, &errb); code != 1 || !strings.Contains(errb.String(), "--force") {
+		t.Fatalf("expected overwrite refusal, got code=%d stderr=%q", code, errb.String())
+	}
+	if code := UnpackToDir("stack.ojster", priv, dest, true, &out, &errb); code != 0 {
+		t.Fatalf("forced unpack failed: %s", errb.String())
+	}
+}
+
+func TestPack_Errors(t *testing.T) {
+	_, pub := keypair(t)
+	ek, _ := pqc.LoadEncapsulationKey(pub)
+	chdirTemp(t)
+	writeFile(t, "a.env", "A=1\n")
+
+	cases := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"none", nil, "no files"},
+		{"absolute", []string{"/etc/passwd"}, "relative paths"},
+		{"parent", []string{"../x.env"}, "relative paths"},
+		{"duplicate", []string{"a.env", "./a.env"}, "more than once"},
+		{"missing", []string{"nope.env"}, "failed to read"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, _, err := Pack(ek, c.paths); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("expected %q error, got %v", c.want, err)
+			}
+		})
+	}
+
+	var out, errb bytes.Buffer
+	if code := PackToFile(filepath.Join(t.TempDir(), "missing.pub"), "x.ojster", []string{"a.env"}, &out, &errb); code != 1 {
+		t.Fatalf("expected failure for missing pub key")
+	}
+	if code := PackToFile(pub, "x.ojster", nil, &out, &errb); code != 1 {
+		t.Fatalf("expected failure without files")
+	}
+	if code := PackToFile(pub, filepath.Join("no", "such", "dir", "x.ojster"), []string{"a.env"}, &out, &errb); code != 1 {
+		t.Fatalf("expected failure for unwritable output")
+	}
+}
+
+func TestOpen_Validation(t *testing.T) {
+	priv, pub := keypair(t)
+	ek, _ := pqc.LoadEncapsulationKey(pub)
+	dk, _ := pqc.LoadDecapsulationKey(priv)
+
+	manifest := `{"version":1,"files":[{"name":"a.env","size":4,"sha256":"` +
+		"8f40ba3a2ac30a4b1c0a6ff0f40d5ac5d6e6f0b7e69b8d2c19ee8d4f3bd30f0a" + `"}]}`
+
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"no magic", []byte("hello"), "header"},
+		{"truncated", []byte(Magic + "abc"), "truncated"},
+		{"manifest not first", sealRaw(t, ek, map[string]string{"files/a.env": "A=1\n"}, []string{"files/a.env"}), "first entry"},
+		{"bad manifest", sealRaw(t, ek, map[string]string{manifestName: "{"}, []string{manifestName}), "manifest"},
+		{"bad version", sealRaw(t, ek, map[string]string{manifestName: `{"version":9}`}, []string{manifestName}), "version"},
+		{"empty", sealRaw(t, ek, nil, nil), "missing manifest"},
+		{"count mismatch", sealRaw(t, ek, map[string]string{manifestName: manifest}, []string{manifestName}), "contains 0"},
+		{"hash mismatch", sealRaw(t, ek, map[string]string{manifestName: manifest, "files/a.env": "A=1\n"}, []string{manifestName, "files/a.env"}), "does not match"},
+		{"stray entry", sealRaw(t, ek, map[string]string{manifestName: manifest, "other": "x"}, []string{manifestName, "other"}), "unexpected entry"},
+		{"unsafe path", sealRaw(t, ek, map[string]string{
+			manifestName: `{"version":1,"files":[{"name":"../x","size":1,"sha256":""}]}`,
+			"files/../x": "x",
+		}, []string{manifestName, "files/../x"}), "unsafe path"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := Open(dk, c.data)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("expected %q error, got %v", c.want, err)
+			}
+		})
+	}
+
+	// wrong key fails decryption rather than format validation
+	otherPriv, _ := keypair(t)
+	otherDk, _ := pqc.LoadDecapsulationKey(otherPriv)
+	chdirTemp(t)
+	writeFile(t, "a.env", "A=1\n")
+	data, _, err := Pack(ek, []string{"a.env"})
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if _, _, err := Open(otherDk, data); err == nil || errors.Is(err, ErrFormat) {
+		t.Fatalf("expected decryption error for wrong key, got %v", err)
+	}
+}
+
+func TestUnpackToDir_Errors(t *testing.T) {
+	priv, _ := keypair(t)
+	var out, errb bytes.Buffer
+	if code := UnpackToDir("x", filepath.Join(t.TempDir(), "missing"), t.TempDir(), false, &out, &errb); code != 1 {
+		t.Fatalf("expected failure for missing priv key")
+	}
+	if code := UnpackToDir(filepath.Join(t.TempDir(), "missing"), priv, t.TempDir(), false, &out, &errb); code != 1 {
+		t.Fatalf("expected failure for missing bundle")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.ojster")
+	_ = os.WriteFile(bad, []byte("garbage"), 0o600)
+	errb.Reset()
+	if code := UnpackToDir(bad, priv, t.TempDir(), false, &out, &errb); code != 1 || !strings.Contains(errb.String(), "failed to open bundle") {
+		t.Fatalf("expected open failure, got %q", errb.String())
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cbor encodes and decodes the one CBOR (RFC 8949) shape ojster puts
+// on the wire: a map of text keys to string values. Values are written as
+// byte strings, so they may hold binary data; both byte and text strings
+// are accepted when decoding. Only definite lengths are supported.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of CBOR payloads.
+const ContentType = "application/cbor"
+
+const (
+	majorBytes = 2
+	majorText  = 3
+	majorMap   = 5
+)
+
+// EncodeMap returns m as a CBOR map with keys in sorted order.
+func EncodeMap(m map[string]string) []byte {
+	size := 9
+	for k, v := range m {
+		size += len(k) + len(v) + 18
+	}
+	return AppendMap(make([]byte, 0, size), m)
+}
+
+// AppendMap appends the encoding of m, as returned by EncodeMap, to out.
+func AppendMap(out []byte, m map[string]string) []byte {
+	out = appendHead(out, majorMap, uint64(len(m)))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		out = appendHead(out, majorText, uint64(len(k)))
+		out = append(out, k...)
+		out = appendHead(out, majorBytes, uint64(len(m[k])))
+		out = append(out, m[k]...)
+	}
+	return out
+}
+
+func appendHead(out []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(out, m|byte(n))
+	case n <= 0xff:
+		return append(out, m|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(out, m|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(out, m|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(out, m|27), n)
+	}
+}
+
+// DecodeMap parses data as a single CBOR map of text keys to byte or text
+// string values. Duplicate keys and trailing data are rejected.
+func DecodeMap(data []byte) (map[string]string, error) {
+	d := decoder{data: data}
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != majorMap {
+		return nil, fmt.Errorf("cbor: expected a map, got major type %d", major)
+	}
+	// Each entry takes at least two bytes, which bounds n by the input.
+	if n > uint64(len(d.data)-d.off)/2 {
+		return nil, errors.New("cbor: map length exceeds input")
+	}
+	out := make(map[string]string, n)
+	for range n {
+		k, err := d.str(majorText)
+		if err != nil {
+			return nil, fmt.Errorf("cbor: key: %w", err)
+		}
+		if !utf8.ValidString(k) {
+			return nil, errors.New("cbor: key is not valid UTF-8")
+		}
+		v, err := d.str(majorBytes, majorText)
+		if err != nil {
+			return nil, fmt.Errorf("cbor: value of %s: %w", k, err)
+		}
+		if _, dup := out[k]; dup {
+			return nil, fmt.Errorf("cbor: duplicate key %s", k)
+		}
+		out[k] = v
+	}
+	if d.off != len(d.data) {
+		return nil, errors.New("cbor: trailing data after map")
+	}
+	return out, nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) head() (major byte, n uint64, err error) {
+	if d.off >= len(d.data) {
+		return 0, 0, errors.New("cbor: unexpected end of input")
+	}
+	b := d.data[d.off]
+	d.off++
+	major, info := b>>5, b&0x1f
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	var size int
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d (indefinite lengths are not supported)", info)
+	}
+	if len(d.data)-d.off < size {
+		return 0, 0, errors.New("cbor: unexpected end of input")
+	}
+	for _, c := range d.data[d.off : d.off+size] {
+		n = n<<8 | uint64(c)
+	}
+	d.off += size
+	return major, n, nil
+}
+
+// str reads a string of one of the allowed major types.
+func (d *decoder) str(allowed ...byte) (string, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(allowed, major) {
+		return "", fmt.Errorf("expected a string, got major type %d", major)
+	}
+	if n > uint64(len(d.data)-d.off) {
+		return "", errors.New("string length exceeds input")
+	}
+	s := string(d.data[d.off : d.off+int(n)])
+	d.off += int(n)
+	return s, nil
+}
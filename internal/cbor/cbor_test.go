@@ -0,0 +1,74 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cbor
+
+import (
+	"bytes"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMap(t *testing.T) {
+	got := EncodeMap(map[string]string{"b": "", "a": "\x01"})
+	want := []byte{0xa2, 0x61, 'a', 0x41, 0x01, 0x61, 'b', 0x40}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("EncodeMap = %x, want %x", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, m := range []map[string]string{
+		{},
+		{"K": "v"},
+		{"BIN": "\x00\xff\xfe binary", "LONG": strings.Repeat("x", 300), "HUGE": strings.Repeat("y", 70000)},
+	} {
+		got, err := DecodeMap(EncodeMap(m))
+		if err != nil {
+			t.Fatalf("DecodeMap: %v", err)
+		}
+		if !maps.Equal(got, m) {
+			t.Fatalf("round trip changed the map")
+		}
+	}
+}
+
+func TestDecodeMap_TextValues(t *testing.T) {
+	// {"a": "xy"} with a text string value, as other encoders may write it.
+	got, err := DecodeMap([]byte{0xa1, 0x61, 'a', 0x62, 'x', 'y'})
+	if err != nil || got["a"] != "xy" {
+		t.Fatalf("DecodeMap = %v, %v", got, err)
+	}
+}
+
+func TestDecodeMap_Errors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":       {},
+		"not a map":   {0x61, 'a'},
+		"truncated":   {0xa1, 0x61},
+		"long map":    {0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"long string": {0xa1, 0x61, 'a', 0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"bytes key":   {0xa1, 0x41, 'a', 0x40},
+		"int value":   {0xa1, 0x61, 'a', 0x01},
+		"indefinite":  {0xbf, 0x61, 'a', 0x40, 0xff},
+		"duplicate":   {0xa2, 0x61, 'a', 0x40, 0x61, 'a', 0x40},
+		"trailing":    {0xa0, 0x00},
+		"bad key":     {0xa1, 0x61, 0xff, 0x40},
+	} {
+		if _, err := DecodeMap(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -16,47 +16,133 @@ package client
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ojster/ojster/internal/cbor"
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
 	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/ipc"
+	"github.com/ojster/ojster/internal/util/redact"
 )
 
 // Assign functions to vars so tests can override them
 var (
 	environFunc             = os.Environ
-	execFunc                = syscall.Exec
+	execFunc                = sysExec
 	postMapToServerJSONFunc = postMapToServerJSON
-	sleepFunc               = time.Sleep
+	sleepFunc               = sleepContext
 	lookPathFunc            = exec.LookPath
+	chdirFunc               = os.Chdir
+	umaskFunc               = sysUmask
 )
 
-// retryWithBackoff logs a formatted message to errw, sleeps for the current backoff,
-// and updates backoff to the next value (capped by maxBackoff).
-func retryWithBackoff(errw io.Writer, backoff *time.Duration, maxBackoff time.Duration, format string, a ...any) {
+// errGaveUp and errStartupTimeout are returned by requestUntilAccepted when
+// it runs out of attempts or time.
+var (
+	errGaveUp         = errors.New("gave up on the server")
+	errStartupTimeout = errors.New("startup timeout expired")
+)
+
+// DefaultTimeout bounds each request to the server unless Endpoint.Timeout
+// is set.
+const DefaultTimeout = 15 * time.Second
+
+// ExitStartupTimeout is Run's exit code when RunOptions.StartupTimeout
+// expires, the same as timeout(1) uses.
+const ExitStartupTimeout = 124
+
+// nowFunc is a var so tests can control the clock with sleepFunc.
+var nowFunc = time.Now
+
+// sleepContext sleeps for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// retryWithBackoff logs a formatted message to errw, sleeps for the current backoff
+// (cut short when ctx is done), and updates backoff to the next value (capped by
+// maxBackoff).
+func retryWithBackoff(ctx context.Context, errw io.Writer, backoff *time.Duration, maxBackoff time.Duration, format string, a ...any) {
 	// append the backoff placeholder to the format and the current backoff to args
 	fullFmt := format + " Retrying in %s\n"
 	args := append(a, *backoff)
 	fmt.Fprintf(errw, fullFmt, args...)
-	sleepFunc(*backoff)
+	sleepFunc(ctx, *backoff)
 	*backoff = min(*backoff*2, maxBackoff)
 }
 
+// RunOptions controls Run.
+type RunOptions struct {
+	// Passthrough execs the command with the environment unchanged when no
+	// values match the regex, so one entrypoint serves services without
+	// secrets too. Without it Run exits 2 in that case.
+	Passthrough bool
+	// Optional, when positive, is the number of failed attempts to get the
+	// values decrypted after which the command is exec'd anyway, with the
+	// sealed variables removed, for services that can start degraded.
+	Optional int
+	// StartupTimeout, when positive, bounds the time spent retrying; when
+	// it expires Run exits with ExitStartupTimeout instead of retrying
+	// forever.
+	StartupTimeout time.Duration
+	// ReadyFile, when set, is written once the values are decrypted (or
+	// none were needed, with Passthrough), just before the command is
+	// exec'd, so healthchecks can wait for it.
+	ReadyFile string
+	// ReadySignal, when non-zero, is sent to the parent process at the same
+	// point.
+	ReadySignal syscall.Signal
+	// User, when set, is switched to after the secrets exchange, just
+	// before the command is exec'd.
+	User *Credential
+	// Dir, when set, becomes the working directory of the command.
+	Dir string
+	// Umask, when set, becomes the umask of the command.
+	Umask *int
+	// Wait runs the command as a child instead of exec'ing it, forwarding
+	// signals and exiting with its status (see superviseNext). KillTimeout
+	// is its grace period after SIGTERM. User and Dir then apply to the
+	// child only.
+	Wait        bool
+	KillTimeout time.Duration
+	// Restart, with Wait, has the values decrypted again and the command
+	// restarted, with backoff, when it exits non-zero. MaxRestarts, when
+	// positive, caps the number of restarts.
+	Restart     bool
+	MaxRestarts int
+}
+
 // Run performs the client "run" flow and follows the writer/exit-code pattern:
 // - nextArgs are the command and args to exec
 // - outw and errw are writers for stdout/stderr
-// Returns an exit code suitable for os.Exit.
-func Run(regex string, socketPath string, nextArgs []string, outw io.Writer, errw io.Writer) int {
+// Returns an exit code suitable for os.Exit. Once ctx is done Run stops
+// retrying and exits 1 without running the command.
+func Run(ctx context.Context, regex string, ep Endpoint, opts RunOptions, nextArgs []string, outw io.Writer, errw io.Writer) int {
 	if len(nextArgs) < 1 {
 		fmt.Fprintln(errw, "run requires a next command to execute.")
 		return 2
@@ -70,22 +156,220 @@ func Run(regex string, socketPath string, nextArgs []string, outw io.Writer, err
 		fmt.Fprintln(errw, "failed to filter environment:", err)
 		return 2
 	}
-	if len(requestMap) == 0 {
+	if len(requestMap) == 0 && !opts.Passthrough {
 		fmt.Fprintln(errw, "no environment variables have values matching OJSTER_REGEX; nothing to send")
 		return 2
 	}
 
+	obtain := func() ([]string, int, bool) {
+		return obtainEnv(ctx, ep, allEnv, requestMap, opts, errw)
+	}
+	if opts.Wait {
+		return superviseRun(nextArgs, opts, obtain, errw)
+	}
+	mergedEnv, code, ok := obtain()
+	if !ok {
+		return code
+	}
+	return execRun(nextArgs, mergedEnv, opts, errw)
+}
+
+// obtainEnv has the values of requestMap decrypted and returns the command's
+// environment, after signalling readiness. On failure it returns the exit
+// code and false.
+func obtainEnv(ctx context.Context, ep Endpoint, allEnv []string, requestMap map[string]string, opts RunOptions, errw io.Writer) ([]string, int, bool) {
+	if len(requestMap) == 0 {
+		fmt.Fprintln(errw, "no environment variables have values matching OJSTER_REGEX; running the command unchanged")
+		if err := signalReady(opts); err != nil {
+			fmt.Fprintln(errw, err)
+			return nil, 1, false
+		}
+		return allEnv, 0, true
+	}
+
+	unique, aliases := dedupeValues(requestMap)
+	newEnv, err := requestUntilAccepted(ctx, func(m map[string]string, requestID string) ([]byte, int, error) {
+		return postToEndpointFunc(ctx, ep, m, requestID)
+	}, unique, retryLimits{attempts: opts.Optional, timeout: opts.StartupTimeout}, errw)
+	if errors.Is(err, errGaveUp) {
+		fmt.Fprintf(errw, "%v; running the command without its sealed variables\n", err)
+		return withoutKeys(allEnv, requestMap), 0, true
+	}
+	if errors.Is(err, errStartupTimeout) {
+		fmt.Fprintln(errw, err)
+		return nil, ExitStartupTimeout, false
+	}
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return nil, 1, false
+	}
+
+	for alias, key := range aliases {
+		if v, ok := newEnv[key]; ok {
+			newEnv[alias] = v
+		}
+	}
+
+	if err := signalReady(opts); err != nil {
+		fmt.Fprintln(errw, err)
+		return nil, 1, false
+	}
+	return buildExecEnv(newEnv), 0, true
+}
+
+// dedupeValues returns requestMap with each distinct value under one key,
+// the first in sorted order, so a sealed value shared by several variables
+// is sent and decrypted once. aliases maps the other keys to that key.
+func dedupeValues(requestMap map[string]string) (unique, aliases map[string]string) {
+	unique = make(map[string]string, len(requestMap))
+	aliases = map[string]string{}
+	byValue := make(map[string]string, len(requestMap))
+	for _, k := range slices.Sorted(maps.Keys(requestMap)) {
+		v := requestMap[k]
+		if first, ok := byValue[v]; ok {
+			aliases[k] = first
+			continue
+		}
+		byValue[v] = k
+		unique[k] = v
+	}
+	return unique, aliases
+}
+
+// Exec replaces the process with nextArgs, its environment being the current
+// one with values set on top (overriding variables of the same name). It
+// serves "unseal exec", which decrypts locally instead of asking a server.
+func Exec(nextArgs []string, values map[string]string, errw io.Writer) int {
+	if len(nextArgs) < 1 {
+		fmt.Fprintln(errw, "exec requires a next command to execute.")
+		return 2
+	}
+	mergedEnv := buildExecEnv(values)
+	present := make(map[string]bool, len(mergedEnv))
+	for _, kv := range mergedEnv {
+		k, _, _ := strings.Cut(kv, "=")
+		present[k] = true
+	}
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if !present[k] {
+			mergedEnv = append(mergedEnv, k+"="+values[k])
+		}
+	}
+	return execNext(nextArgs, mergedEnv, errw)
+}
+
+// withoutKeys returns the entries of environ whose names are not in keys.
+func withoutKeys(environ []string, keys map[string]string) []string {
+	out := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		k, _, _ := strings.Cut(kv, "=")
+		if _, ok := keys[k]; !ok {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// execRun applies the process settings of opts and execs nextArgs. The
+// directory is changed after switching user, so it must be accessible to the
+// user the command runs as.
+func execRun(nextArgs, mergedEnv []string, opts RunOptions, errw io.Writer) int {
+	mergedEnv, dir, err := processEnv(mergedEnv, opts)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	if opts.User != nil {
+		if err := opts.User.drop(); err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+	}
+	if dir != "" {
+		if err := chdirFunc(dir); err != nil {
+			fmt.Fprintf(errw, "failed to change directory: %v\n", err)
+			return 1
+		}
+	}
+	if opts.Umask != nil {
+		umaskFunc(*opts.Umask)
+	}
+	return execNext(nextArgs, mergedEnv, errw)
+}
+
+// processEnv returns mergedEnv with HOME and PWD matching opts.User and
+// opts.Dir, and the absolute form of opts.Dir.
+func processEnv(mergedEnv []string, opts RunOptions) ([]string, string, error) {
+	if opts.User != nil && opts.User.Home != "" {
+		mergedEnv = setEnv(mergedEnv, "HOME", opts.User.Home)
+	}
+	if opts.Dir == "" {
+		return mergedEnv, "", nil
+	}
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to change directory: %v", err)
+	}
+	return setEnv(mergedEnv, "PWD", dir), dir, nil
+}
+
+// setEnv returns environ with name set to value.
+func setEnv(environ []string, name, value string) []string {
+	return append(withoutKeys(environ, map[string]string{name: ""}), name+"="+value)
+}
+
+func execNext(nextArgs, mergedEnv []string, errw io.Writer) int {
+	nextBin := nextArgs[0]
+	nextBinPath, err := lookPathFunc(nextBin)
+	if err != nil {
+		fmt.Fprintf(errw, "executable not found %q: %v\n", nextBin, err)
+		return 2
+	}
+	argv := append([]string{nextBin}, nextArgs[1:]...)
+	if err := execFunc(nextBinPath, argv, mergedEnv); err != nil {
+		fmt.Fprintf(errw, "failed to exec %s: %v\n", nextBinPath, err)
+		return 1
+	}
+
+	// If execFunc succeeds the process is replaced and this is not reached.
+	// For test stubs that return nil, return success.
+	return 0
+}
+
+// retryLimits bounds requestUntilAccepted; zero fields mean no limit.
+type retryLimits struct {
+	attempts int
+	timeout  time.Duration
+}
+
+// requestUntilAccepted posts requestMap via post until the server returns a
+// 2xx JSON reply containing only requested keys, backing off between attempts.
+// Each attempt gets its own request ID, which retry messages include so they
+// can be matched with the server log. A protocol.ErrMismatch or ErrKeyChanged
+// is returned at once, since retrying cannot fix it. When limits are used up
+// the error wraps errGaveUp or errStartupTimeout and the last attempt's
+// message. Once ctx is done the error wraps its cause instead.
+func requestUntilAccepted(ctx context.Context, post func(m map[string]string, requestID string) ([]byte, int, error), requestMap map[string]string, limits retryLimits, errw io.Writer) (map[string]string, error) {
 	requestedKeys := make(map[string]struct{}, len(requestMap))
 	for k := range requestMap {
 		requestedKeys[k] = struct{}{}
 	}
+	// Retry messages quote server replies; keep sealed values out of them.
+	scrub := redact.New(pqc.SealedValueRegexp(), slices.Collect(maps.Values(requestMap))...)
+	errw = scrub.Writer(errw)
 
 	backoff := 1 * time.Second
 	const maxBackoff = 30 * time.Second
-	var newEnv map[string]string
-
-	for {
-		respBody, statusCode, err := postMapToServerJSONFunc(socketPath, requestMap)
+	var deadline time.Time
+	if limits.timeout > 0 {
+		deadline = nowFunc().Add(limits.timeout)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("stopped after %d attempts: %w", attempt-1, context.Cause(ctx))
+		}
+		requestID := newRequestID()
+		respBody, statusCode, err := post(requestMap, requestID)
 
 		// default: we will retry unless we set accept=true
 		accept := false
@@ -94,7 +378,9 @@ func Run(regex string, socketPath string, nextArgs []string, outw io.Writer, err
 		var retryArgs []any
 
 		// transport-level error -> retry
-		if err != nil {
+		if errors.Is(err, protocol.ErrMismatch) || errors.Is(err, ErrKeyChanged) {
+			return nil, err
+		} else if err != nil {
 			retryFormat = "request failed: %v"
 			retryArgs = []any{err}
 		} else if statusCode < 200 || statusCode >= 300 {
@@ -102,10 +388,11 @@ func Run(regex string, socketPath string, nextArgs []string, outw io.Writer, err
 			retryFormat = "server returned status=%d body=%q"
 			retryArgs = []any{statusCode, respBody}
 		} else {
-			// 2xx -> attempt JSON decode
-			decodeErr := json.Unmarshal(respBody, &replyMap)
+			// 2xx -> attempt JSON or CBOR decode
+			var decodeErr error
+			replyMap, decodeErr = decodeReply(respBody)
 			if decodeErr != nil {
-				retryFormat = "failed to decode JSON response (status=%d decodeErr=%v)"
+				retryFormat = "failed to decode response (status=%d decodeErr=%v)"
 				retryArgs = []any{statusCode, decodeErr}
 			} else {
 				unexpected := false
@@ -126,30 +413,83 @@ func Run(regex string, socketPath string, nextArgs []string, outw io.Writer, err
 		}
 
 		if accept {
-			newEnv = replyMap
-			break
+			return replyMap, nil
+		}
+
+		retryFormat = "request_id=%s: " + retryFormat
+		retryArgs = append([]any{requestID}, retryArgs...)
+		if attempt == limits.attempts {
+			last := scrub.String(fmt.Sprintf(retryFormat, retryArgs...))
+			return nil, fmt.Errorf("%w after %d attempts, last: %s", errGaveUp, attempt, last)
+		}
+		if !deadline.IsZero() {
+			left := deadline.Sub(nowFunc())
+			if left <= 0 {
+				last := scrub.String(fmt.Sprintf(retryFormat, retryArgs...))
+				return nil, fmt.Errorf("%w after %s and %d attempts, last: %s", errStartupTimeout, limits.timeout, attempt, last)
+			}
+			// Do not sleep past the deadline; one last attempt follows.
+			backoff = min(backoff, left)
 		}
 
 		// retry path
-		retryWithBackoff(errw, &backoff, maxBackoff, retryFormat, retryArgs...)
+		retryWithBackoff(ctx, errw, &backoff, maxBackoff, retryFormat, retryArgs...)
 	}
+}
 
-	mergedEnv := buildExecEnv(newEnv)
-	nextBin := nextArgs[0]
-	nextBinPath, err := lookPathFunc(nextBin)
+// Reply is the answer of the server to Unseal.
+type Reply struct {
+	Values map[string]string
+	// SingleDelivery lists the keys of Values that the server releases only
+	// once (see protocol.SingleDeliveryHeader). They must not be cached.
+	SingleDelivery []string
+}
+
+// Unseal has the server at ep decrypt m in a single attempt, without the
+// retries of Run. The returned status is that of the server's reply, or 0
+// when there was none.
+func Unseal(ctx context.Context, ep Endpoint, m map[string]string, requestID string) (Reply, int, error) {
+	var header http.Header
+	ep.header = &header
+	respBody, status, err := postToEndpointFunc(ctx, ep, m, requestID)
 	if err != nil {
-		fmt.Fprintf(errw, "executable not found %q: %v\n", nextBin, err)
-		return 2
+		return Reply{}, status, err
 	}
-	argv := append([]string{nextBin}, nextArgs[1:]...)
-	if err := execFunc(nextBinPath, argv, mergedEnv); err != nil {
-		fmt.Fprintf(errw, "failed to exec %s: %v\n", nextBinPath, err)
-		return 1
+	if status < 200 || status >= 300 {
+		return Reply{}, status, fmt.Errorf("server returned status=%d body=%q", status, respBody)
 	}
+	values, err := decodeReply(respBody)
+	if err != nil {
+		return Reply{}, status, fmt.Errorf("failed to decode response: %v", err)
+	}
+	for k := range values {
+		if _, ok := m[k]; !ok {
+			return Reply{}, status, errors.New("reply contains unexpected keys")
+		}
+	}
+	reply := Reply{Values: values}
+	if once := header.Get(protocol.SingleDeliveryHeader); once != "" {
+		reply.SingleDelivery = strings.Split(once, ",")
+	}
+	return reply, status, nil
+}
 
-	// If execFunc succeeds the process is replaced and this is not reached.
-	// For test stubs that return nil, return success.
-	return 0
+// decodeReply decodes a JSON or CBOR reply map. A JSON object starts with
+// '{' or whitespace, while the first byte of a CBOR map is never ASCII.
+func decodeReply(body []byte) (map[string]string, error) {
+	if len(body) > 0 && body[0]>>5 == 5 {
+		return cbor.DecodeMap(body)
+	}
+	var m map[string]string
+	err := json.Unmarshal(body, &m)
+	return m, err
+}
+
+// newRequestID returns a random ID for the X-Request-ID header.
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 // filterEnvByValue returns a map of env key->value for entries whose value matches regex.
@@ -179,35 +519,86 @@ func filterEnvByValue(envMap []string, regex string) (map[string]string, error)
 	return outw, nil
 }
 
-func postMapToServerJSON(socketPath string, m map[string]string) ([]byte, int, error) {
-	j, err := json.Marshal(m)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to marshal request JSON: %v", err)
-	}
+func postMapToServerJSON(ctx context.Context, ep Endpoint, m map[string]string, requestID string) ([]byte, int, error) {
+	tr, base := ep.transport()
+	return postMap(ctx, tr, base+ep.Route, ep, m, requestID)
+}
 
-	tr := &http.Transport{
+// transport returns the transport reaching ep and the URL that paths on
+// the server are appended to.
+func (ep Endpoint) transport() (http.RoundTripper, string) {
+	switch {
+	case ep.Addr == "" && ep.rt != nil:
+		return ep.rt, "http://unix"
+	case ep.rt != nil:
+		return ep.rt, "https://" + ep.Addr
+	case ep.Addr != "":
+		return &http.Transport{TLSClientConfig: ep.TLS}, "https://" + ep.Addr
+	}
+	return &http.Transport{
 		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-			return net.Dial("unix", socketPath)
+			return ipc.DialContext(ctx, ep.SocketPath)
 		},
+	}, "http://unix"
+}
+
+// postMap POSTs m to url through tr, encoded as ep.Format, and returns the
+// body and status. The key of a 2xx reply is checked against ep.Pins, and
+// its headers are stored in ep.header if set. The request is abandoned once
+// ctx is done.
+func postMap(ctx context.Context, tr http.RoundTripper, url string, ep Endpoint, m map[string]string, requestID string) ([]byte, int, error) {
+	format := ep.Format
+	var j []byte
+	contentType := "application/json; charset=utf-8"
+	switch format {
+	case "", FormatJSON:
+		var err error
+		if j, err = json.Marshal(m); err != nil {
+			return nil, 0, fmt.Errorf("failed to marshal request JSON: %v", err)
+		}
+	case FormatCBOR:
+		j, contentType = cbor.EncodeMap(m), cbor.ContentType
+	default:
+		return nil, 0, fmt.Errorf("unknown wire format %q (want %s or %s)", format, FormatJSON, FormatCBOR)
 	}
 
 	client := &http.Client{
-		Timeout:   15 * time.Second,
+		Timeout:   cmp.Or(ep.Timeout, DefaultTimeout),
 		Transport: tr,
 	}
-
-	req, err := http.NewRequest("POST", "http://unix/", bytes.NewReader(j))
+	kp, err := ep.keyProof()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create request: %v", err)
+		return nil, 0, err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-	resp, err := client.Do(req)
+	resp, err := doNegotiated(client, func(version int) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(j))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		kp.setHeader(req.Header)
+		req.Header.Set("Content-Type", contentType)
+		if format == FormatCBOR {
+			req.Header.Set("Accept", cbor.ContentType)
+		}
+		req.Header.Set("X-Request-ID", requestID)
+		req.Header.Set(protocol.Header, strconv.Itoa(version))
+		return req, nil
+	})
 	if err != nil {
-		return nil, 0, fmt.Errorf("request failed: %v", err)
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
+	if ep.Pins != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := ep.Pins.check(ep.serverID(), resp.Header, kp); err != nil {
+			return nil, resp.StatusCode, err
+		}
+	}
+	if ep.header != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		*ep.header = resp.Header
+	}
+
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return respBody, resp.StatusCode, fmt.Errorf("failed to read response body: %v", err)
@@ -216,6 +607,33 @@ func postMapToServerJSON(socketPath string, m map[string]string) ([]byte, int, e
 	return respBody, resp.StatusCode, nil
 }
 
+// doNegotiated sends the request newRequest makes for the newest protocol
+// version, and again for an older one if the server turns out to speak only
+// that (see protocol.Negotiate). A server that shares no version with this
+// build yields protocol.ErrMismatch.
+func doNegotiated(client *http.Client, newRequest func(version int) (*http.Request, error)) (*http.Response, error) {
+	for version := protocol.Version; ; {
+		req, err := newRequest(version)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %v", err)
+		}
+		agreed, err := protocol.Negotiate(resp.Header)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("server: %w", err)
+		}
+		if agreed >= version {
+			return resp, nil
+		}
+		resp.Body.Close()
+		version = agreed
+	}
+}
+
 func buildExecEnv(newMap map[string]string) []string {
 	current := environFunc()
 	out := make([]string, 0, len(current))
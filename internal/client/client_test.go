@@ -16,18 +16,24 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"net"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ojster/ojster/internal/cbor"
 	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
 )
 
 //
@@ -65,14 +71,14 @@ func stubExec(t *testing.T) (*string, *[]string, *[]string) {
 func stubSleep(t *testing.T) {
 	t.Helper()
 	old := sleepFunc
-	sleepFunc = func(time.Duration) {}
+	sleepFunc = func(context.Context, time.Duration) {}
 	t.Cleanup(func() { sleepFunc = old })
 }
 
 func stubPost(t *testing.T) {
 	t.Helper()
 	old := postMapToServerJSONFunc
-	postMapToServerJSONFunc = func(socketPath string, m map[string]string) ([]byte, int, error) {
+	postMapToServerJSONFunc = func(_ context.Context, _ Endpoint, m map[string]string, _ string) ([]byte, int, error) {
 		return nil, 0, fmt.Errorf("stubbed")
 	}
 	t.Cleanup(func() { postMapToServerJSONFunc = old })
@@ -226,7 +232,7 @@ func TestRun_BasicFlow(t *testing.T) {
 	gcm := []byte{0x04, 0x05}
 	sealed := pqc.BuildSealed(mlkem, gcm)
 
-	postMapToServerJSONFunc = func(socketPath string, m map[string]string) ([]byte, int, error) {
+	postMapToServerJSONFunc = func(_ context.Context, _ Endpoint, m map[string]string, _ string) ([]byte, int, error) {
 		if len(m) != 1 || m["SECRET"] != sealed {
 			t.Fatalf("unexpected request map: %#v", m)
 		}
@@ -241,7 +247,7 @@ func TestRun_BasicFlow(t *testing.T) {
 	var errBuf bytes.Buffer
 
 	// Pass regex and socketPath explicitly. socketPath is unused by the stubbed post.
-	code := Run(pqc.DefaultValueRegex(), "unused-socket", []string{"echo", "hello"}, &outBuf, &errBuf)
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo", "hello"}, &outBuf, &errBuf)
 	if code != 0 {
 		t.Fatalf("Run returned non-zero exit code: %d stderr=%q", code, errBuf.String())
 	}
@@ -259,6 +265,32 @@ func TestRun_BasicFlow(t *testing.T) {
 	}
 }
 
+func TestRun_DedupesValues(t *testing.T) {
+	_, _, execEnv := stubExec(t)
+
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	shared, other := pqc.BuildSealed([]byte{1}, []byte{2}), pqc.BuildSealed([]byte{3}, []byte{4})
+	postMapToServerJSONFunc = func(_ context.Context, _ Endpoint, m map[string]string, _ string) ([]byte, int, error) {
+		if !maps.Equal(m, map[string]string{"A_PASS": shared, "C_TOKEN": other}) {
+			t.Fatalf("unexpected request map: %#v", m)
+		}
+		return []byte(`{"A_PASS":"pw","C_TOKEN":"tok"}`), 200, nil
+	}
+	t.Setenv("A_PASS", shared)
+	t.Setenv("B_PASS", shared)
+	t.Setenv("C_TOKEN", other)
+
+	var errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo"}, io.Discard, &errBuf); code != 0 {
+		t.Fatalf("Run returned %d stderr=%q", code, errBuf.String())
+	}
+	envMap := envSliceToMap(*execEnv)
+	if envMap["A_PASS"] != "pw" || envMap["B_PASS"] != "pw" || envMap["C_TOKEN"] != "tok" {
+		t.Fatalf("values not fanned out: A=%q B=%q C=%q", envMap["A_PASS"], envMap["B_PASS"], envMap["C_TOKEN"])
+	}
+}
+
 //
 // ─────────────────────────────────────────────────────────────
 //   run() ERROR PATHS
@@ -312,7 +344,9 @@ func TestRun_RetryScenarios(t *testing.T) {
 			t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
 
 			call := 0
-			postMapToServerJSONFunc = func(socketPath string, m map[string]string) ([]byte, int, error) {
+			var ids []string
+			postMapToServerJSONFunc = func(_ context.Context, _ Endpoint, m map[string]string, requestID string) ([]byte, int, error) {
+				ids = append(ids, requestID)
 				resp := tc.responses[call]
 				code := tc.statuses[call]
 				call++
@@ -330,7 +364,7 @@ func TestRun_RetryScenarios(t *testing.T) {
 			var errBuf bytes.Buffer
 
 			// socketPath unused by stubbed post
-			code := Run(pqc.DefaultValueRegex(), "unused-socket", []string{"echo"}, &outBuf, &errBuf)
+			code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo"}, &outBuf, &errBuf)
 			if code != 0 {
 				t.Fatalf("Run returned non-zero exit code: %d stderr=%q", code, errBuf.String())
 			}
@@ -338,10 +372,42 @@ func TestRun_RetryScenarios(t *testing.T) {
 			if call != tc.wantCalls {
 				t.Fatalf("expected %d calls, got %d", tc.wantCalls, call)
 			}
+			if ids[0] == "" || ids[0] == ids[1] {
+				t.Fatalf("each attempt needs its own request ID, got %q", ids)
+			}
+			if !strings.Contains(errBuf.String(), "request_id="+ids[0]+": ") {
+				t.Fatalf("retry message lacks the request ID: %q", errBuf.String())
+			}
 		})
 	}
 }
 
+func TestRun_RedactsRetryLog(t *testing.T) {
+	_, _, _ = stubExec(t)
+	stubSleep(t)
+	sealed := pqc.BuildSealed([]byte{0x01, 0x02, 0x03}, []byte{0x04, 0x05})
+	t.Setenv("SECRET", sealed)
+
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	call := 0
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		call++
+		if call == 1 {
+			return []byte("failed to decrypt SECRET=" + sealed), 502, nil
+		}
+		return []byte(`{"SECRET":"ok"}`), 200, nil
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo"}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("Run returned %d: %s", code, errBuf.String())
+	}
+	if strings.Contains(errBuf.String(), sealed) || !strings.Contains(errBuf.String(), "SECRET=[REDACTED]") {
+		t.Fatalf("sealed value not redacted: %q", errBuf.String())
+	}
+}
+
 func TestRun_Error_NoNextBinary(t *testing.T) {
 	stubPost(t)
 	t.Setenv("SECRET", "") // ensure no encrypted vars
@@ -349,7 +415,7 @@ func TestRun_Error_NoNextBinary(t *testing.T) {
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
 
-	code := Run(pqc.DefaultValueRegex(), "unused-socket", []string{}, &outBuf, &errBuf)
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{}, &outBuf, &errBuf)
 	if code != 2 {
 		t.Fatalf("expected exit code %d for missing next-binary, got %d stderr=%q", 2, code, errBuf.String())
 	}
@@ -363,17 +429,150 @@ func TestRun_Error_NoMatchingEnv(t *testing.T) {
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
 
-	code := Run(pqc.DefaultValueRegex(), "unused-socket", []string{"echo"}, &outBuf, &errBuf)
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo"}, &outBuf, &errBuf)
 	if code != 2 {
 		t.Fatalf("expected exit code %d for no matching env, got %d stderr=%q", 2, code, errBuf.String())
 	}
 }
 
+func TestRun_PassthroughNoMatchingEnv(t *testing.T) {
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		t.Fatal("server must not be contacted")
+		return nil, 0, nil
+	}
+	execPath, execArgv, execEnv := stubExec(t)
+	t.Setenv("PLAIN", "hello")
+	t.Setenv("SECRET", "")
+
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{Passthrough: true}, []string{"sh", "-c", "true"}, &outBuf, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.HasSuffix(*execPath, "/sh") || len(*execArgv) != 3 {
+		t.Fatalf("unexpected exec: path=%q argv=%v", *execPath, *execArgv)
+	}
+	if !slices.Contains(*execEnv, "PLAIN=hello") {
+		t.Fatalf("environment not passed through: %v", *execEnv)
+	}
+	if !strings.Contains(errBuf.String(), "running the command unchanged") {
+		t.Fatalf("expected a note on stderr, got %q", errBuf.String())
+	}
+}
+
+func TestRun_OptionalGivesUp(t *testing.T) {
+	stubSleep(t)
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	calls := 0
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		calls++
+		return nil, 0, errors.New("connection refused")
+	}
+	_, _, execEnv := stubExec(t)
+	t.Setenv("PLAIN", "hello")
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{Optional: 3}, []string{"sh"}, &outBuf, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+	for _, kv := range *execEnv {
+		if strings.HasPrefix(kv, "SECRET=") {
+			t.Fatalf("sealed variable was not removed: %v", *execEnv)
+		}
+	}
+	if !slices.Contains(*execEnv, "PLAIN=hello") {
+		t.Fatalf("plain variable missing: %v", *execEnv)
+	}
+	if !strings.Contains(errBuf.String(), "after 3 attempts, last: request_id=") || !strings.Contains(errBuf.String(), "connection refused") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_StartupTimeout(t *testing.T) {
+	// A fake clock that only advances while sleeping.
+	now := time.Unix(0, 0)
+	oldNow, oldSleep := nowFunc, sleepFunc
+	t.Cleanup(func() { nowFunc, sleepFunc = oldNow, oldSleep })
+	nowFunc = func() time.Time { return now }
+	var slept []time.Duration
+	sleepFunc = func(_ context.Context, d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		return nil, 0, errors.New("no such file or directory")
+	}
+	stubExec(t)
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{StartupTimeout: 10 * time.Second}, []string{"sh"}, &outBuf, &errBuf)
+	if code != ExitStartupTimeout {
+		t.Fatalf("expected exit code %d, got %d stderr=%q", ExitStartupTimeout, code, errBuf.String())
+	}
+	// 1s, 2s, 4s, then clipped to the 3s left.
+	if want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second}; !slices.Equal(slept, want) {
+		t.Fatalf("sleeps = %v, want %v", slept, want)
+	}
+	if !strings.Contains(errBuf.String(), "startup timeout expired after 10s and 5 attempts, last: request_id=") || !strings.Contains(errBuf.String(), "no such file or directory") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	// The real sleep is kept: it must return as soon as ctx is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	calls := 0
+	postMapToServerJSONFunc = func(ctx context.Context, _ Endpoint, _ map[string]string, _ string) ([]byte, int, error) {
+		calls++
+		cancel()
+		return nil, 0, ctx.Err()
+	}
+	execPath, _, _ := stubExec(t)
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	var errBuf bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- Run(ctx, pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"sh"}, io.Discard, &errBuf)
+	}()
+	select {
+	case code := <-done:
+		if code != 1 || calls != 1 || *execPath != "" {
+			t.Fatalf("expected exit code 1 after 1 attempt without exec, got code=%d calls=%d exec=%q", code, calls, *execPath)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after its context was cancelled")
+	}
+	if !strings.Contains(errBuf.String(), "stopped after 1 attempts: context canceled") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
 func TestRun_Error_ExecNotFound(t *testing.T) {
 	// POST succeeds
 	oldPost := postMapToServerJSONFunc
 	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
-	postMapToServerJSONFunc = func(url string, m map[string]string) ([]byte, int, error) {
+	postMapToServerJSONFunc = func(_ context.Context, _ Endpoint, m map[string]string, _ string) ([]byte, int, error) {
 		return []byte(`{"SECRET":"ok"}`), 200, nil
 	}
 
@@ -382,12 +581,34 @@ func TestRun_Error_ExecNotFound(t *testing.T) {
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
 
-	code := Run(pqc.DefaultValueRegex(), "unused-socket", []string{"does-not-exist"}, &outBuf, &errBuf)
+	code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"does-not-exist"}, &outBuf, &errBuf)
 	if code != 2 {
 		t.Fatalf("expected exec-not-found exit code %d, got %d stderr=%q", 2, code, errBuf.String())
 	}
 }
 
+// Exec overrides inherited variables and appends new ones.
+func TestExec_MergesValues(t *testing.T) {
+	origEnviron := environFunc
+	t.Cleanup(func() { environFunc = origEnviron })
+	environFunc = func() []string { return []string{"PATH=/bin", "A=old"} }
+	execPath, execArgv, execEnv := stubExec(t)
+
+	var errb bytes.Buffer
+	if code := Exec([]string{"sh", "-c", "true"}, map[string]string{"A": "new", "B": "2"}, &errb); code != 0 {
+		t.Fatalf("Exec exit %d: %s", code, errb.String())
+	}
+	if !strings.HasSuffix(*execPath, "/sh") || len(*execArgv) != 3 {
+		t.Fatalf("unexpected exec %q %q", *execPath, *execArgv)
+	}
+	if want := []string{"PATH=/bin", "A=new", "B=2"}; !slices.Equal(*execEnv, want) {
+		t.Fatalf("env = %q, want %q", *execEnv, want)
+	}
+	if code := Exec(nil, nil, &errb); code != 2 {
+		t.Fatalf("expected usage error, got %d", code)
+	}
+}
+
 //
 // ─────────────────────────────────────────────────────────────
 //   postMapToServerJSON
@@ -404,11 +625,20 @@ func TestPostMapToServerJSON(t *testing.T) {
 		if m["A"] != "1" {
 			t.Fatalf("expected A=1")
 		}
+		if got := r.Header.Get("X-Request-ID"); got != "req-1" {
+			t.Fatalf("X-Request-ID = %q", got)
+		}
+		if r.URL.Path != "/projectA" {
+			t.Fatalf("posted to %q, want /projectA", r.URL.Path)
+		}
+		if got := r.Header.Get(protocol.Header); got != strconv.Itoa(protocol.Version) {
+			t.Fatalf("%s = %q", protocol.Header, got)
+		}
 		w.Write([]byte(`{"OK":"yes"}`))
 	}))
 	defer closeSrv()
 
-	respBody, status, err := postMapToServerJSON(socketPath, map[string]string{"A": "1"})
+	respBody, status, err := postMapToServerJSON(context.Background(), Endpoint{SocketPath: socketPath, Route: "/projectA"}, map[string]string{"A": "1"}, "req-1")
 	if err != nil {
 		t.Fatalf("postMapToServerJSON error: %v", err)
 	}
@@ -419,3 +649,81 @@ func TestPostMapToServerJSON(t *testing.T) {
 		t.Fatalf("unexpected body: %s", string(respBody))
 	}
 }
+
+func TestPostMapToServerJSON_CBOR(t *testing.T) {
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != cbor.ContentType || r.Header.Get("Accept") != cbor.ContentType {
+			t.Fatalf("unexpected headers %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if m, err := cbor.DecodeMap(body); err != nil || m["A"] != "1" {
+			t.Fatalf("request = %v, %v", m, err)
+		}
+		w.Write(cbor.EncodeMap(map[string]string{"OK": "\x00\xff"}))
+	}))
+	defer closeSrv()
+
+	respBody, _, err := postMapToServerJSON(context.Background(), Endpoint{SocketPath: socketPath, Route: "/", Format: FormatCBOR}, map[string]string{"A": "1"}, "req-1")
+	if err != nil {
+		t.Fatalf("postMapToServerJSON error: %v", err)
+	}
+	if m, err := decodeReply(respBody); err != nil || m["OK"] != "\x00\xff" {
+		t.Fatalf("decodeReply = %q, %v", m, err)
+	}
+	if m, err := decodeReply([]byte(` {"OK":"yes"}`)); err != nil || m["OK"] != "yes" {
+		t.Fatalf("decodeReply JSON = %q, %v", m, err)
+	}
+	if _, _, err := postMapToServerJSON(context.Background(), Endpoint{SocketPath: socketPath, Format: "xml"}, nil, "req-2"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestPostMap_ProtocolMismatch(t *testing.T) {
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocol.Header, strconv.Itoa(protocol.Version+1))
+		w.Write([]byte(`{}`))
+	}))
+	defer closeSrv()
+
+	_, _, err := postMapToServerJSON(context.Background(), Endpoint{SocketPath: socketPath, Route: "/"}, map[string]string{"A": "1"}, "req-1")
+	if !errors.Is(err, protocol.ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+}
+
+func TestPostMap_NoCommonVersion(t *testing.T) {
+	requests := 0
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(protocol.VersionsHeader, strconv.Itoa(protocol.Version+1)+","+strconv.Itoa(protocol.Version+2))
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer closeSrv()
+
+	_, _, err := postMapToServerJSON(context.Background(), Endpoint{SocketPath: socketPath, Route: "/"}, map[string]string{"A": "1"}, "req-1")
+	if !errors.Is(err, protocol.ErrMismatch) || requests != 1 {
+		t.Fatalf("expected ErrMismatch after one request, got %v after %d", err, requests)
+	}
+}
+
+func TestRun_ProtocolMismatchStops(t *testing.T) {
+	_, _, _ = stubExec(t)
+	stubSleep(t)
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	calls := 0
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		calls++
+		return nil, 200, fmt.Errorf("server: %w", protocol.ErrMismatch)
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, RunOptions{}, []string{"echo"}, &outBuf, &errBuf); code != 1 || calls != 1 {
+		t.Fatalf("expected one attempt and exit 1, got code=%d calls=%d", code, calls)
+	}
+	if !strings.Contains(errBuf.String(), "protocol version mismatch") {
+		t.Fatalf("unexpected stderr %q", errBuf.String())
+	}
+}
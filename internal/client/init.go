@@ -0,0 +1,270 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ojster/ojster/internal/k8s"
+	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// Output formats for Init.
+const (
+	// FormatEnv writes a single .env file into the output directory.
+	FormatEnv = "env"
+	// FormatFiles writes one file per key, named after the key.
+	FormatFiles = "files"
+)
+
+// Endpoint says how to reach the server: its Unix socket, or Addr over TLS.
+type Endpoint struct {
+	SocketPath string
+	// Addr is a host:port; when set TLS is used and should carry a client
+	// certificate (see mtls.ClientConfig).
+	Addr string
+	TLS  *tls.Config
+	// Route is the URL path requests are posted to (default "/"), selecting
+	// one of the routes of a server started with serve --config.
+	Route string
+	// Format is the wire encoding, FormatJSON (the default) or FormatCBOR.
+	Format string
+	// Pins, if set, pins the server's key on first contact.
+	Pins *Pins
+	// Timeout bounds each request to the server; zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// rt, if set by KeepAlive, is shared by all requests to the server.
+	rt http.RoundTripper
+	// header, if set, receives the headers of a 2xx reply.
+	header *http.Header
+}
+
+// KeepAlive returns ep with a transport shared by every request made through
+// the copy, so a long-running caller reuses its connections to the server.
+func (ep Endpoint) KeepAlive() Endpoint {
+	ep.rt, _ = ep.transport()
+	return ep
+}
+
+// keyProof prepares the key challenge of a request to ep, or returns nil
+// without Pins.
+func (ep Endpoint) keyProof() (*keyProof, error) {
+	if ep.Pins == nil {
+		return nil, nil
+	}
+	return ep.Pins.challenge(ep.serverID())
+}
+
+// serverID names the server and route of ep in the pin file.
+func (ep Endpoint) serverID() string {
+	if ep.Addr != "" {
+		return "https://" + ep.Addr + ep.Route
+	}
+	return "unix:" + ep.SocketPath + ep.Route
+}
+
+// Wire formats of Endpoint.Format.
+const (
+	FormatJSON = "json"
+	// FormatCBOR sends requests and asks for replies as CBOR, which avoids
+	// JSON escaping and can carry binary values. Older servers only speak JSON.
+	FormatCBOR = "cbor"
+)
+
+// InitOptions controls Init.
+type InitOptions struct {
+	// Regex selects which values (from env, ConfigMapDir and AnnotationsFile) are sent.
+	Regex string
+	// ConfigMapDir is a mounted ConfigMap: each file name is a key, its content the value.
+	ConfigMapDir string
+	// AnnotationsFile is a downward API annotations file; "ojster/KEY"
+	// annotations (see k8s.KindSealed) provide values.
+	AnnotationsFile string
+	// OutDir receives the decrypted values and must be on tmpfs unless AllowDisk.
+	OutDir    string
+	Format    string
+	Mode      os.FileMode
+	AllowDisk bool
+}
+
+// postToEndpointFunc is a var so tests can stub the transport.
+var postToEndpointFunc = postToEndpoint
+
+func postToEndpoint(ctx context.Context, ep Endpoint, m map[string]string, requestID string) ([]byte, int, error) {
+	ep.Route = "/" + strings.TrimPrefix(ep.Route, "/")
+	if ep.Addr == "" {
+		return postMapToServerJSONFunc(ctx, ep, m, requestID)
+	}
+	tr, base := ep.transport()
+	return postMap(ctx, tr, base+ep.Route, ep, m, requestID)
+}
+
+// Init is the Kubernetes init-container variant of Run: it collects sealed
+// values, has the server at ep decrypt them, and writes the results into
+// opts.OutDir (an emptyDir with medium: Memory) for the main container instead
+// of exec'ing a command. Returns an exit code and writes errors to errw.
+// Cancelling ctx stops the retries.
+func Init(ctx context.Context, ep Endpoint, opts InitOptions, outw io.Writer, errw io.Writer) int {
+	if opts.Format != FormatEnv && opts.Format != FormatFiles {
+		fmt.Fprintf(errw, "unknown format %q (want %s or %s)\n", opts.Format, FormatEnv, FormatFiles)
+		return 2
+	}
+	valRe, err := regexp.Compile(opts.Regex)
+	if err != nil {
+		fmt.Fprintf(errw, "invalid regex %q: %v\n", opts.Regex, err)
+		return 2
+	}
+	if err := os.MkdirAll(opts.OutDir, 0o700); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	if !opts.AllowDisk {
+		if err := file.CheckTmpfs(opts.OutDir); err != nil {
+			fmt.Fprintf(errw, "%v; use a memory-backed emptyDir or --allow-disk\n", err)
+			return 1
+		}
+	}
+
+	requestMap, err := filterEnvByValue(environFunc(), opts.Regex)
+	if err != nil {
+		fmt.Fprintln(errw, "failed to filter environment:", err)
+		return 2
+	}
+	if opts.ConfigMapDir != "" {
+		m, err := readConfigMapDir(opts.ConfigMapDir)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		addMatching(requestMap, m, valRe)
+	}
+	if opts.AnnotationsFile != "" {
+		m, err := readAnnotations(opts.AnnotationsFile)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		addMatching(requestMap, m, valRe)
+	}
+	if len(requestMap) == 0 {
+		fmt.Fprintln(errw, "no values matching OJSTER_REGEX found; nothing to send")
+		return 2
+	}
+
+	decrypted, err := requestUntilAccepted(ctx, func(m map[string]string, requestID string) ([]byte, int, error) {
+		return postToEndpointFunc(ctx, ep, m, requestID)
+	}, requestMap, retryLimits{}, errw)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+
+	keys := slices.Sorted(maps.Keys(decrypted))
+	if opts.Format == FormatEnv {
+		target := filepath.Join(opts.OutDir, ".env")
+		if err := file.WriteFileAtomic(target, []byte(env.FormatEnvEntries(decrypted, keys)+"\n"), opts.Mode); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to write %s: %w", target, err))
+			return 1
+		}
+	} else {
+		for _, k := range keys {
+			target := filepath.Join(opts.OutDir, k)
+			if err := file.WriteFileAtomic(target, []byte(decrypted[k]), opts.Mode); err != nil {
+				fmt.Fprintln(errw, fmt.Errorf("failed to write %s: %w", target, err))
+				return 1
+			}
+		}
+	}
+	fmt.Fprintf(outw, "Wrote %d values to %s\n", len(keys), opts.OutDir)
+	return 0
+}
+
+func addMatching(dst, src map[string]string, valRe *regexp.Regexp) {
+	for k, v := range src {
+		if env.KeyNameRegex.MatchString(k) && valRe.MatchString(v) {
+			dst[k] = v
+		}
+	}
+}
+
+// readConfigMapDir reads a mounted ConfigMap volume, skipping the hidden
+// "..data" bookkeeping entries Kubernetes creates.
+func readConfigMapDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ConfigMap dir %s: %w", dir, err)
+	}
+	out := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		if fi, err := os.Stat(p); err != nil || fi.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		out[e.Name()] = strings.TrimSuffix(string(b), "\n")
+	}
+	return out, nil
+}
+
+// readAnnotations parses a downward API annotations file (key="quoted value"
+// per line) and returns the values of ojster annotations keyed by env key.
+func readAnnotations(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read annotations file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	out := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		k, quoted, ok := strings.Cut(scanner.Text(), "=")
+		name, isOjster := strings.CutPrefix(k, k8s.AnnotationPrefix)
+		if !ok || !isOjster {
+			continue
+		}
+		v, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("malformed annotation %s in %s: %v", k, path, err)
+		}
+		out[name] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read annotations file %s: %w", path, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// stubInitServer answers every request by prefixing each value with "plain-"
+// and records the last request map.
+func stubInitServer(t *testing.T) *map[string]string {
+	t.Helper()
+	var got map[string]string
+	old := postToEndpointFunc
+	postToEndpointFunc = func(_ context.Context, ep Endpoint, m map[string]string, _ string) ([]byte, int, error) {
+		got = m
+		reply := make(map[string]string, len(m))
+		for k, v := range m {
+			reply[k] = "plain-" + v
+		}
+		b, _ := json.Marshal(reply)
+		return b, 200, nil
+	}
+	t.Cleanup(func() { postToEndpointFunc = old })
+	return &got
+}
+
+func stubEnviron(t *testing.T, env ...string) {
+	t.Helper()
+	old := environFunc
+	environFunc = func() []string { return env }
+	t.Cleanup(func() { environFunc = old })
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Init()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestInit_EnvFormat(t *testing.T) {
+	sealedEnv := pqc.BuildSealed([]byte{1}, []byte{2})
+	sealedCM := pqc.BuildSealed([]byte{3}, []byte{4})
+	sealedAnn := pqc.BuildSealed([]byte{5}, []byte{6})
+	got := stubInitServer(t)
+	stubEnviron(t, "FROM_ENV="+sealedEnv, "PLAIN=hello")
+
+	cm := t.TempDir()
+	writeTestFile(t, filepath.Join(cm, "FROM_CM"), sealedCM+"\n")
+	writeTestFile(t, filepath.Join(cm, "NOT_SEALED"), "value\n")
+	writeTestFile(t, filepath.Join(cm, "..data"), sealedCM)
+	if err := os.Mkdir(filepath.Join(cm, "subdir"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	ann := filepath.Join(t.TempDir(), "annotations")
+	writeTestFile(t, ann, `kubernetes.io/config.seen="2026-01-01"`+"\n"+`ojster/FROM_ANN="`+sealedAnn+`"`+"\n")
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	var outBuf, errBuf bytes.Buffer
+	code := Init(context.Background(), Endpoint{SocketPath: "unused"}, InitOptions{
+		Regex:           pqc.DefaultValueRegex(),
+		ConfigMapDir:    cm,
+		AnnotationsFile: ann,
+		OutDir:          outDir,
+		Format:          FormatEnv,
+		Mode:            0o600,
+		AllowDisk:       true,
+	}, &outBuf, &errBuf)
+	if code != 0 {
+		t.Fatalf("Init returned %d stderr=%q", code, errBuf.String())
+	}
+
+	if len(*got) != 3 || (*got)["FROM_ENV"] != sealedEnv || (*got)["FROM_CM"] != sealedCM || (*got)["FROM_ANN"] != sealedAnn {
+		t.Fatalf("unexpected request map: %#v", *got)
+	}
+	b, err := os.ReadFile(filepath.Join(outDir, ".env"))
+	if err != nil {
+		t.Fatalf("read .env: %v", err)
+	}
+	for _, k := range []string{"FROM_ANN", "FROM_CM", "FROM_ENV"} {
+		if !strings.Contains(string(b), k+"=plain-") {
+			t.Fatalf("expected %s in .env, got %q", k, b)
+		}
+	}
+	if !strings.Contains(outBuf.String(), "Wrote 3 values to "+outDir) {
+		t.Fatalf("unexpected stdout: %q", outBuf.String())
+	}
+}
+
+func TestInit_FilesFormat(t *testing.T) {
+	sealed := pqc.BuildSealed([]byte{1}, []byte{2})
+	stubInitServer(t)
+	stubEnviron(t, "TOKEN="+sealed)
+
+	outDir := t.TempDir()
+	var outBuf, errBuf bytes.Buffer
+	code := Init(context.Background(), Endpoint{}, InitOptions{
+		Regex:     pqc.DefaultValueRegex(),
+		OutDir:    outDir,
+		Format:    FormatFiles,
+		Mode:      0o640,
+		AllowDisk: true,
+	}, &outBuf, &errBuf)
+	if code != 0 {
+		t.Fatalf("Init returned %d stderr=%q", code, errBuf.String())
+	}
+	p := filepath.Join(outDir, "TOKEN")
+	b, err := os.ReadFile(p)
+	if err != nil || string(b) != "plain-"+sealed {
+		t.Fatalf("unexpected TOKEN file: %q err=%v", b, err)
+	}
+	if fi, _ := os.Stat(p); fi.Mode().Perm() != 0o640 {
+		t.Fatalf("expected mode 0640, got %v", fi.Mode().Perm())
+	}
+}
+
+func TestInit_Errors(t *testing.T) {
+	stubInitServer(t)
+	stubEnviron(t, "PLAIN=hello")
+	base := InitOptions{Regex: pqc.DefaultValueRegex(), OutDir: t.TempDir(), Format: FormatEnv, AllowDisk: true}
+
+	badAnn := filepath.Join(t.TempDir(), "annotations")
+	writeTestFile(t, badAnn, "ojster/KEY=unquoted\n")
+
+	cases := []struct {
+		name            string
+		mutate          func(o *InitOptions)
+		wantCode        int
+		wantErrContains string
+	}{
+		{"unknown format", func(o *InitOptions) { o.Format = "yaml" }, 2, "unknown format"},
+		{"invalid regex", func(o *InitOptions) { o.Regex = "(" }, 2, "invalid regex"},
+		{"nothing to send", func(o *InitOptions) {}, 2, "nothing to send"},
+		{"missing configmap dir", func(o *InitOptions) { o.ConfigMapDir = "/definitely-not-existing" }, 1, "failed to read ConfigMap dir"},
+		{"missing annotations", func(o *InitOptions) { o.AnnotationsFile = "/definitely-not-existing" }, 1, "failed to read annotations file"},
+		{"malformed annotation", func(o *InitOptions) { o.AnnotationsFile = badAnn }, 1, "malformed annotation ojster/KEY"},
+		{"not tmpfs", func(o *InitOptions) { o.OutDir = "/tmp2"; o.AllowDisk = false }, 1, "use a memory-backed emptyDir"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := base
+			tc.mutate(&opts)
+			var outBuf, errBuf bytes.Buffer
+			code := Init(context.Background(), Endpoint{}, opts, &outBuf, &errBuf)
+			if code != tc.wantCode || !strings.Contains(errBuf.String(), tc.wantErrContains) {
+				t.Fatalf("expected code=%d and %q, got code=%d stderr=%q", tc.wantCode, tc.wantErrContains, code, errBuf.String())
+			}
+		})
+	}
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   postToEndpoint()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestPostToEndpoint_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	ep := Endpoint{Addr: srv.Listener.Addr().String(), TLS: &tls.Config{RootCAs: pool}}
+
+	body, status, err := postToEndpoint(context.Background(), ep, map[string]string{"K": "v"}, "req-1")
+	if err != nil || status != 200 {
+		t.Fatalf("postToEndpoint: status=%d err=%v", status, err)
+	}
+	if !strings.Contains(string(body), `"K":"v"`) {
+		t.Fatalf("unexpected body: %q", body)
+	}
+}
+
+func TestPostToEndpoint_Route(t *testing.T) {
+	old := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = old })
+	var got []string
+	postMapToServerJSONFunc = func(_ context.Context, ep Endpoint, _ map[string]string, _ string) ([]byte, int, error) {
+		got = append(got, ep.Route)
+		return []byte(`{}`), 200, nil
+	}
+	for _, route := range []string{"", "/projectA", "projectA"} {
+		if _, _, err := postToEndpoint(context.Background(), Endpoint{SocketPath: "s", Route: route}, nil, "req-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"/", "/projectA", "/projectA"}; !slices.Equal(got, want) {
+		t.Fatalf("routes = %v, want %v", got, want)
+	}
+}
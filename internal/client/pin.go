@@ -0,0 +1,204 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/mlkem"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// ErrKeyChanged is returned when a server's key fingerprint differs from the
+// one pinned on first contact and Pins.Refuse is set. Retrying cannot fix
+// it, so clients give up instead.
+var ErrKeyChanged = errors.New("server key fingerprint changed")
+
+// ErrKeyProof is returned when a server fails to prove it holds its pinned
+// key and Pins.Refuse is set. Retrying cannot fix it either.
+var ErrKeyProof = errors.New("server did not prove it holds its key")
+
+// Pins records the key each server advertised on first contact (trust on
+// first use) and reports when it changes, as after a key was rotated or a
+// server pointed at the wrong key. Once the public key is pinned, not just
+// its fingerprint, every request challenges the server to prove it holds
+// the private key, so a server on the same socket or address that only
+// advertises the pinned fingerprint is caught too.
+type Pins struct {
+	// File is the JSON state file mapping servers to their pins.
+	File string
+	// Refuse makes a changed or unproven key an error instead of a warning.
+	Refuse bool
+	// Warn receives warnings; nil discards them.
+	Warn io.Writer
+}
+
+// pin is the entry of one server in the pin file.
+type pin struct {
+	Fingerprint string `json:"fingerprint"`
+	// PublicKey is the base64 encapsulation key, once the server handed it
+	// out. Releases before key proofs pinned the fingerprint alone.
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+func (pn *pin) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &pn.Fingerprint)
+	}
+	type plain pin
+	return json.Unmarshal(data, (*plain)(pn))
+}
+
+// keyProof is the key challenge of one request and the answer it expects.
+// Without a pinned public key, challenge is empty and the request asks for
+// the key instead.
+type keyProof struct {
+	challenge string
+	want      []byte
+}
+
+// setHeader adds the challenge, or the request for the public key, to h.
+func (kp *keyProof) setHeader(h http.Header) {
+	switch {
+	case kp == nil:
+	case kp.challenge != "":
+		h.Set(protocol.KeyProofHeader, kp.challenge)
+	default:
+		h.Set(protocol.PublicKeyHeader, "1")
+	}
+}
+
+// challenge prepares the keyProof of a request to server.
+func (p *Pins) challenge(server string) (*keyProof, error) {
+	pins, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	pn := pins[server]
+	if pn.PublicKey == "" {
+		return &keyProof{}, nil
+	}
+	ek, err := parsePublicKey(pn.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of %s in pin file %s: %v", server, p.File, err)
+	}
+	ct, want := pqc.KeyChallenge(ek)
+	return &keyProof{challenge: base64.StdEncoding.EncodeToString(ct), want: want}, nil
+}
+
+// check compares the key in the reply headers h of server with its pin, and
+// pins it if server has none yet. A reply without a fingerprint (from a
+// server that does not advertise one) is only a problem once the server has
+// been pinned. If kp challenged a pinned public key, h must hold the answer.
+func (p *Pins) check(server string, h http.Header, kp *keyProof) error {
+	pins, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	fingerprint := h.Get(protocol.KeyFingerprintHeader)
+	pinned, ok := pins[server]
+	switch {
+	case ok && pinned.Fingerprint != fingerprint:
+		if fingerprint == "" {
+			fingerprint = "none"
+		}
+		return p.fail(fmt.Errorf("%w: %s was pinned to %s and now advertises %s; if the key was rotated on purpose, remove its entry from %s",
+			ErrKeyChanged, server, pinned.Fingerprint, fingerprint, p.File))
+	case ok && kp != nil && kp.want != nil:
+		answer, err := base64.StdEncoding.DecodeString(h.Get(protocol.KeyProofHeader))
+		if err != nil || !hmac.Equal(answer, kp.want) {
+			return p.fail(fmt.Errorf("%w: %s advertises the pinned key %s but cannot decrypt with it", ErrKeyProof, server, fingerprint))
+		}
+		return nil
+	case fingerprint == "", ok && pinned.PublicKey != "":
+		return nil
+	}
+
+	// First contact, or a pin without the public key: pin what the server
+	// hands out, if its fingerprint is the advertised one.
+	pn := pin{Fingerprint: fingerprint}
+	if k := h.Get(protocol.PublicKeyHeader); k != "" {
+		if ek, err := parsePublicKey(k); err != nil {
+			p.warn("warning: invalid public key from %s: %v\n", server, err)
+		} else if got := pqc.Fingerprint(ek); got != fingerprint {
+			p.warn("warning: %s advertises %s but hands out the public key %s\n", server, fingerprint, got)
+		} else {
+			pn.PublicKey = k
+		}
+	}
+	if ok && pn.PublicKey == "" {
+		return nil
+	}
+	pins[server] = pn
+	data, _ := json.MarshalIndent(pins, "", "  ")
+	err = os.MkdirAll(filepath.Dir(p.File), 0o700)
+	if err == nil {
+		err = file.WriteFileAtomic(p.File, append(data, '\n'), 0o600)
+	}
+	if err != nil {
+		p.warn("warning: failed to pin key of %s: %v\n", server, err)
+	}
+	return nil
+}
+
+// load reads the pin file; a missing one holds no pins.
+func (p *Pins) load() (map[string]pin, error) {
+	pins := map[string]pin{}
+	data, err := os.ReadFile(p.File)
+	if os.IsNotExist(err) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pin file: %v", err)
+	}
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("invalid pin file %s: %v", p.File, err)
+	}
+	return pins, nil
+}
+
+// fail returns err if p refuses, and warns about it otherwise.
+func (p *Pins) fail(err error) error {
+	if p.Refuse {
+		return err
+	}
+	p.warn("warning: %v\n", err)
+	return nil
+}
+
+func (p *Pins) warn(format string, a ...any) {
+	if p.Warn != nil {
+		fmt.Fprintf(p.Warn, format, a...)
+	}
+}
+
+func parsePublicKey(b64 string) (*mlkem.EncapsulationKey768, error) {
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+	return mlkem.NewEncapsulationKey768(b)
+}
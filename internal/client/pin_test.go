@@ -0,0 +1,175 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/mlkem"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
+)
+
+// fingerprintHeader is a reply header advertising fingerprint, if any.
+func fingerprintHeader(fingerprint string) http.Header {
+	h := http.Header{}
+	if fingerprint != "" {
+		h.Set(protocol.KeyFingerprintHeader, fingerprint)
+	}
+	return h
+}
+
+func TestPins_Check(t *testing.T) {
+	var warn bytes.Buffer
+	p := &Pins{File: filepath.Join(t.TempDir(), "state", "pins.json"), Warn: &warn}
+
+	// A server without a fingerprint is not pinned.
+	if err := p.check("unix:/a.sock/", fingerprintHeader(""), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.File); !os.IsNotExist(err) {
+		t.Fatalf("pin file written for an empty fingerprint: %v", err)
+	}
+
+	// First contact pins, the same fingerprint passes.
+	for range 2 {
+		if err := p.check("unix:/a.sock/", fingerprintHeader("SHA256:one"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.check("unix:/b.sock/", fingerprintHeader("SHA256:two"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(p.File); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("pin file: %v", err)
+	}
+	if warn.Len() != 0 {
+		t.Fatalf("unexpected warning %q", warn.String())
+	}
+
+	// A change warns and keeps the pin, or is refused.
+	if err := p.check("unix:/a.sock/", fingerprintHeader("SHA256:evil"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(warn.String(), "SHA256:one and now advertises SHA256:evil") {
+		t.Fatalf("unexpected warning %q", warn.String())
+	}
+	p.Refuse = true
+	for _, fp := range []string{"SHA256:evil", ""} {
+		if err := p.check("unix:/a.sock/", fingerprintHeader(fp), nil); !errors.Is(err, ErrKeyChanged) {
+			t.Fatalf("check(%q) = %v, want ErrKeyChanged", fp, err)
+		}
+	}
+	if err := p.check("unix:/b.sock/", fingerprintHeader("SHA256:two"), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPins_LegacyFile(t *testing.T) {
+	p := &Pins{File: filepath.Join(t.TempDir(), "pins.json"), Refuse: true}
+	if err := os.WriteFile(p.File, []byte(`{"unix:/a.sock/": "SHA256:one"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.check("unix:/a.sock/", fingerprintHeader("SHA256:two"), nil); !errors.Is(err, ErrKeyChanged) {
+		t.Fatalf("expected ErrKeyChanged, got %v", err)
+	}
+	if kp, err := p.challenge("unix:/a.sock/"); err != nil || kp.challenge != "" {
+		t.Fatalf("challenge without a pinned public key = %+v, %v", kp, err)
+	}
+}
+
+// startKeyServer serves the key headers of dk, as the server does, on every
+// reply; with prove unset it advertises dk without answering challenges.
+func startKeyServer(t *testing.T, dk *mlkem.DecapsulationKey768, prove bool) string {
+	t.Helper()
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ek := dk.EncapsulationKey()
+		w.Header().Set(protocol.KeyFingerprintHeader, pqc.Fingerprint(ek))
+		if r.Header.Get(protocol.PublicKeyHeader) != "" {
+			w.Header().Set(protocol.PublicKeyHeader, base64.StdEncoding.EncodeToString(ek.Bytes()))
+		}
+		if ct, err := base64.StdEncoding.DecodeString(r.Header.Get(protocol.KeyProofHeader)); prove && err == nil && len(ct) > 0 {
+			proof, _ := pqc.KeyProof(dk, ct)
+			w.Header().Set(protocol.KeyProofHeader, base64.StdEncoding.EncodeToString(proof))
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(closeSrv)
+	return socketPath
+}
+
+func TestPostMap_KeyProof(t *testing.T) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := startKeyServer(t, dk, true)
+	pins := &Pins{File: filepath.Join(t.TempDir(), "pins.json"), Refuse: true}
+	ep := Endpoint{SocketPath: socketPath, Route: "/", Pins: pins}
+
+	// First contact pins the public key, later requests prove it.
+	for range 2 {
+		if _, _, err := postMapToServerJSON(context.Background(), ep, map[string]string{"A": "1"}, "req-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if kp, err := pins.challenge(ep.serverID()); err != nil || kp.challenge == "" {
+		t.Fatalf("public key not pinned: %+v, %v", kp, err)
+	}
+
+	// A server in its place that only advertises the key is refused.
+	all, err := pins.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := all[ep.serverID()]
+	ep.SocketPath = startKeyServer(t, dk, false)
+	all[ep.serverID()] = pinned
+	data, _ := json.Marshal(all)
+	if err := os.WriteFile(pins.File, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = postMapToServerJSON(context.Background(), ep, map[string]string{"A": "1"}, "req-2")
+	if !errors.Is(err, ErrKeyProof) {
+		t.Fatalf("expected ErrKeyProof, got %v", err)
+	}
+}
+
+func TestPostMap_RefusesChangedKey(t *testing.T) {
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocol.KeyFingerprintHeader, "SHA256:new")
+		w.Write([]byte(`{}`))
+	}))
+	defer closeSrv()
+
+	pins := &Pins{File: filepath.Join(t.TempDir(), "pins.json"), Refuse: true}
+	ep := Endpoint{SocketPath: socketPath, Route: "/", Pins: pins}
+	if err := pins.check(ep.serverID(), fingerprintHeader("SHA256:old"), nil); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := postMapToServerJSON(context.Background(), ep, map[string]string{"A": "1"}, "req-1")
+	if !errors.Is(err, ErrKeyChanged) {
+		t.Fatalf("expected ErrKeyChanged, got %v", err)
+	}
+}
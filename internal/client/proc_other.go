@@ -0,0 +1,46 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package client
+
+import (
+	"os"
+	"syscall"
+)
+
+// The process primitives behind the function vars of this package.
+var (
+	sysExec      = syscall.Exec
+	sysUmask     = syscall.Umask
+	sysKill      = syscall.Kill
+	sysSetgroups = syscall.Setgroups
+	sysSetgid    = syscall.Setgid
+	sysSetuid    = syscall.Setuid
+)
+
+// platformSignals are forwarded in wait mode besides the portable ones.
+var platformSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH}
+
+// credentialAttr starts a child as c.
+func credentialAttr(c *Credential) (*syscall.SysProcAttr, error) {
+	groups := make([]uint32, len(c.Groups))
+	for i, g := range c.Groups {
+		groups[i] = uint32(g)
+	}
+	return &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(c.UID), Gid: uint32(c.GID), Groups: groups},
+	}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package client
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// errNoUser is returned when --user is asked for; Windows has no setuid.
+var errNoUser = errors.New("switching users is not supported on Windows")
+
+// Windows has no exec, umask, signals to other processes or setuid.
+var (
+	sysExec      = execChild
+	sysUmask     = func(int) int { return 0 }
+	sysKill      = func(int, syscall.Signal) error { return syscall.EWINDOWS }
+	sysSetgroups = func([]int) error { return errNoUser }
+	sysSetgid    = func(int) error { return errNoUser }
+	sysSetuid    = func(int) error { return errNoUser }
+)
+
+var platformSignals []os.Signal
+
+func credentialAttr(*Credential) (*syscall.SysProcAttr, error) {
+	return nil, errNoUser
+}
+
+// execChild stands in for exec: it runs the command with our stdio and
+// exits with its status, so to the caller ojster is replaced by it.
+func execChild(argv0 string, argv []string, envv []string) error {
+	cmd := exec.Command(argv0)
+	cmd.Args = argv
+	cmd.Env = envv
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	os.Exit(cmd.ProcessState.ExitCode())
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ojster/ojster/internal/protocol"
+)
+
+// FetchPublicKey returns the public key file the server at ep decrypts
+// ep.Route with, from its "pubkey" endpoint. The key is checked against
+// ep.Pins. The request is abandoned once ctx is done.
+func FetchPublicKey(ctx context.Context, ep Endpoint) ([]byte, error) {
+	ep.Route = "/" + strings.TrimPrefix(ep.Route, "/")
+	tr, base := ep.transport()
+	client := &http.Client{Timeout: cmp.Or(ep.Timeout, DefaultTimeout), Transport: tr}
+
+	kp, err := ep.keyProof()
+	if err != nil {
+		return nil, err
+	}
+	requestID := newRequestID()
+	resp, err := doNegotiated(client, func(version int) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", base+strings.TrimSuffix(ep.Route, "/")+"/pubkey", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		kp.setHeader(req.Header)
+		req.Header.Set("X-Request-ID", requestID)
+		req.Header.Set(protocol.Header, strconv.Itoa(version))
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status=%d body=%q", resp.StatusCode, body)
+	}
+	if ep.Pins != nil {
+		if err := ep.Pins.check(ep.serverID(), resp.Header, kp); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/ojster/ojster/internal/protocol"
+)
+
+func TestFetchPublicKey(t *testing.T) {
+	socketPath, closeSrv := startUnixHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("method %s", r.Method)
+		}
+		if r.URL.Path != "/pubkey" && r.URL.Path != "/projectA/pubkey" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(protocol.KeyFingerprintHeader, "SHA256:"+r.URL.Path)
+		w.Write([]byte("KEY " + r.URL.Path + "\n"))
+	}))
+	defer closeSrv()
+
+	pins := &Pins{File: filepath.Join(t.TempDir(), "pins.json")}
+	for route, want := range map[string]string{"": "KEY /pubkey\n", "/": "KEY /pubkey\n", "projectA": "KEY /projectA/pubkey\n"} {
+		got, err := FetchPublicKey(context.Background(), Endpoint{SocketPath: socketPath, Route: route, Pins: pins})
+		if err != nil || string(got) != want {
+			t.Fatalf("FetchPublicKey(%q) = %q, %v; want %q", route, got, err, want)
+		}
+	}
+	if _, err := FetchPublicKey(context.Background(), Endpoint{SocketPath: socketPath, Route: "/projectB"}); err == nil {
+		t.Fatal("expected error for a 404")
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// Assign functions to vars so tests can override them
+var (
+	killFunc    = sysKill
+	getppidFunc = os.Getppid
+)
+
+// signalReady announces that the secrets were obtained, as asked by opts: it
+// writes opts.ReadyFile and sends opts.ReadySignal to the parent process. The
+// file holds the PID, which the exec'd command keeps, so a healthcheck can
+// tell a stale file from a previous run.
+func signalReady(opts RunOptions) error {
+	if opts.ReadyFile != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.ReadyFile), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory of ready file: %v", err)
+		}
+		data := fmt.Appendf(nil, "%d\n", os.Getpid())
+		if err := file.WriteFileAtomic(opts.ReadyFile, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write ready file: %v", err)
+		}
+	}
+	if opts.ReadySignal != 0 {
+		// Without a parent of its own (PID 1, or reparented to init) there
+		// is nobody to tell, and signalling init could stop the container.
+		ppid := getppidFunc()
+		if ppid <= 1 {
+			return fmt.Errorf("cannot send ready signal: no parent process")
+		}
+		if err := killFunc(ppid, opts.ReadySignal); err != nil {
+			return fmt.Errorf("failed to send ready signal to parent process %d: %v", ppid, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func stubKill(t *testing.T, ppid int) *[]syscall.Signal {
+	t.Helper()
+	var sent []syscall.Signal
+	oldKill, oldPpid := killFunc, getppidFunc
+	t.Cleanup(func() { killFunc, getppidFunc = oldKill, oldPpid })
+	getppidFunc = func() int { return ppid }
+	killFunc = func(pid int, sig syscall.Signal) error {
+		if pid != ppid {
+			t.Fatalf("signal sent to %d, want parent %d", pid, ppid)
+		}
+		sent = append(sent, sig)
+		return nil
+	}
+	return &sent
+}
+
+func TestRun_SignalsReady(t *testing.T) {
+	stubExec(t)
+	sent := stubKill(t, 4242)
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		return []byte(`{"SECRET":"ok"}`), 200, nil
+	}
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	readyFile := filepath.Join(t.TempDir(), "sub", "ready")
+	opts := RunOptions{ReadyFile: readyFile, ReadySignal: syscall.SIGUSR1}
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, opts, []string{"sh"}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+
+	data, err := os.ReadFile(readyFile)
+	if err != nil {
+		t.Fatalf("ready file not written: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != strconv.Itoa(os.Getpid()) {
+		t.Fatalf("ready file holds %q, want the PID", got)
+	}
+	if len(*sent) != 1 || (*sent)[0] != syscall.SIGUSR1 {
+		t.Fatalf("signals sent = %v, want [SIGUSR1]", *sent)
+	}
+}
+
+func TestRun_NotReadyOnFailure(t *testing.T) {
+	stubSleep(t)
+	stubExec(t)
+	sent := stubKill(t, 4242)
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		return nil, 503, nil
+	}
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+
+	readyFile := filepath.Join(t.TempDir(), "ready")
+	opts := RunOptions{Optional: 2, ReadyFile: readyFile, ReadySignal: syscall.SIGUSR1}
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, opts, []string{"sh"}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("expected degraded start with exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+	if _, err := os.Stat(readyFile); !os.IsNotExist(err) {
+		t.Fatalf("ready file must not be written without secrets, stat err=%v", err)
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("no signal expected, got %v", *sent)
+	}
+}
+
+func TestSignalReady_NoParent(t *testing.T) {
+	stubKill(t, 1)
+	err := signalReady(RunOptions{ReadySignal: syscall.SIGUSR1})
+	if err == nil || !strings.Contains(err.Error(), "no parent process") {
+		t.Fatalf("expected no-parent error, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// forwardedSignals are passed on to the command in wait mode.
+var forwardedSignals = append([]os.Signal{
+	syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT,
+}, platformSignals...)
+
+// notifyFunc is a var so tests can deliver signals without raising them.
+var notifyFunc = func(c chan<- os.Signal) func() {
+	signal.Notify(c, forwardedSignals...)
+	return func() { signal.Stop(c) }
+}
+
+// restartAfterFunc is a var so tests can skip the restart backoff.
+var restartAfterFunc = time.After
+
+const (
+	maxRestartBackoff = 30 * time.Second
+	// A command that ran at least this long resets the restart backoff.
+	restartBackoffReset = time.Minute
+)
+
+// superviseRun is Run in wait mode: it gets the command's environment from
+// obtain, supervises the command, and with opts.Restart does both again,
+// with exponential backoff, while the command fails. A SIGTERM or SIGINT
+// ends the cycle.
+func superviseRun(nextArgs []string, opts RunOptions, obtain func() ([]string, int, bool), errw io.Writer) int {
+	if opts.Umask != nil {
+		umaskFunc(*opts.Umask)
+	}
+	backoff := time.Second
+	for restarts := 0; ; restarts++ {
+		mergedEnv, code, ok := obtain()
+		if !ok {
+			return code
+		}
+		started := nowFunc()
+		code, stopped := superviseNext(nextArgs, mergedEnv, opts, errw)
+		if stopped || code == 0 || !opts.Restart {
+			return code
+		}
+		if opts.MaxRestarts > 0 && restarts == opts.MaxRestarts {
+			fmt.Fprintf(errw, "%s failed after %d restarts; giving up\n", nextArgs[0], restarts)
+			return code
+		}
+		if nowFunc().Sub(started) >= restartBackoffReset {
+			backoff = time.Second
+		}
+		fmt.Fprintf(errw, "restarting %s in %s\n", nextArgs[0], backoff)
+		if waitOrStop(backoff) {
+			return code
+		}
+		backoff = min(backoff*2, maxRestartBackoff)
+	}
+}
+
+// waitOrStop waits for d and reports whether a SIGTERM or SIGINT came first.
+func waitOrStop(d time.Duration) bool {
+	sigs := make(chan os.Signal, 8)
+	stop := notifyFunc(sigs)
+	defer stop()
+	timer := restartAfterFunc(d)
+	for {
+		select {
+		case <-timer:
+			return false
+		case sig := <-sigs:
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				return true
+			}
+		}
+	}
+}
+
+// superviseNext runs nextArgs as a child instead of exec'ing it, forwards
+// signals to it and returns its exit status, as Docker expects of an init
+// process. After a SIGTERM or SIGINT the child has opts.KillTimeout to exit
+// before it gets SIGKILL; zero waits for it indefinitely, and stopped is set.
+// A child killed by a signal yields 128 plus the signal number, as in shells.
+// The child gets opts.User and opts.Dir, so the supervisor keeps its own.
+func superviseNext(nextArgs, mergedEnv []string, opts RunOptions, errw io.Writer) (code int, stopped bool) {
+	mergedEnv, dir, err := processEnv(mergedEnv, opts)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1, false
+	}
+	nextBin := nextArgs[0]
+	nextBinPath, err := lookPathFunc(nextBin)
+	if err != nil {
+		fmt.Fprintf(errw, "executable not found %q: %v\n", nextBin, err)
+		return 2, false
+	}
+
+	sigs := make(chan os.Signal, 8)
+	stop := notifyFunc(sigs)
+	defer stop()
+
+	cmd := exec.Command(nextBinPath, nextArgs[1:]...)
+	cmd.Args[0] = nextBin
+	cmd.Env = mergedEnv
+	cmd.Dir = dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if opts.User != nil {
+		if cmd.SysProcAttr, err = credentialAttr(opts.User); err != nil {
+			fmt.Fprintf(errw, "failed to start %s: %v\n", nextBinPath, err)
+			return 1, false
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(errw, "failed to start %s: %v\n", nextBinPath, err)
+		return 1, false
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var kill <-chan time.Time
+	for {
+		select {
+		case sig := <-sigs:
+			_ = cmd.Process.Signal(sig)
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				stopped = true
+				if kill == nil && opts.KillTimeout > 0 {
+					kill = time.After(opts.KillTimeout)
+				}
+			}
+		case <-kill:
+			fmt.Fprintf(errw, "%s did not exit within %s; sending SIGKILL\n", nextBin, opts.KillTimeout)
+			_ = cmd.Process.Kill()
+		case err := <-done:
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				fmt.Fprintf(errw, "failed to wait for %s: %v\n", nextBin, err)
+				return 1, stopped
+			}
+			return exitStatus(nextBin, cmd.ProcessState, errw), stopped
+		}
+	}
+}
+
+// exitStatus reports how the child ended, unless it exited 0, and returns the
+// matching exit code.
+func exitStatus(name string, ps *os.ProcessState, errw io.Writer) int {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		fmt.Fprintf(errw, "%s was killed by signal %s\n", name, ws.Signal())
+		return 128 + int(ws.Signal())
+	}
+	if code := ps.ExitCode(); code != 0 {
+		fmt.Fprintf(errw, "%s exited with status %d\n", name, code)
+		return code
+	}
+	return 0
+}
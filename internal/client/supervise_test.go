@@ -0,0 +1,189 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubNotify returns a channel that delivers "signals" to the latest caller
+// of notifyFunc.
+func stubNotify(t *testing.T) chan<- os.Signal {
+	t.Helper()
+	var mu sync.Mutex
+	var current chan<- os.Signal
+	old := notifyFunc
+	t.Cleanup(func() { notifyFunc = old })
+	notifyFunc = func(c chan<- os.Signal) func() {
+		mu.Lock()
+		defer mu.Unlock()
+		current = c
+		return func() {}
+	}
+	relay := make(chan os.Signal, 1)
+	go func() {
+		for sig := range relay {
+			mu.Lock()
+			c := current
+			mu.Unlock()
+			c <- sig
+		}
+	}()
+	t.Cleanup(func() { close(relay) })
+	return relay
+}
+
+// waitForFile waits until the child signals it is ready by creating path. It
+// runs on its own goroutine, so it reports with Errorf.
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("child did not create %s", path)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSuperviseNext_ExitStatus(t *testing.T) {
+	stubNotify(t)
+	var errBuf bytes.Buffer
+	code, stopped := superviseNext([]string{"sh", "-c", "exit 3"}, os.Environ(), RunOptions{KillTimeout: time.Second}, &errBuf)
+	if code != 3 || stopped {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "sh exited with status 3") {
+		t.Fatalf("exit status not reported: %q", errBuf.String())
+	}
+}
+
+func TestSuperviseNext_ForwardsSignal(t *testing.T) {
+	sigs := stubNotify(t)
+	ready := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		waitForFile(t, ready)
+		sigs <- syscall.SIGTERM
+	}()
+	var errBuf bytes.Buffer
+	code, stopped := superviseNext([]string{"sh", "-c", "trap 'exit 42' TERM; touch " + ready + "; while :; do sleep 0.05; done"}, os.Environ(), RunOptions{KillTimeout: 5 * time.Second}, &errBuf)
+	if code != 42 || !stopped {
+		t.Fatalf("expected the child's own exit code 42, got %d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestSuperviseNext_KillsAfterTimeout(t *testing.T) {
+	sigs := stubNotify(t)
+	ready := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		waitForFile(t, ready)
+		sigs <- syscall.SIGTERM
+	}()
+	var errBuf bytes.Buffer
+	// The ignored SIGTERM is inherited by the exec'd sleep.
+	code, _ := superviseNext([]string{"sh", "-c", "trap '' TERM; touch " + ready + "; exec sleep 30"}, os.Environ(), RunOptions{KillTimeout: 100 * time.Millisecond}, &errBuf)
+	if code != 128+int(syscall.SIGKILL) {
+		t.Fatalf("expected exit code %d, got %d stderr=%q", 128+int(syscall.SIGKILL), code, errBuf.String())
+	}
+	for _, want := range []string{"did not exit within 100ms; sending SIGKILL", "killed by signal killed"} {
+		if !strings.Contains(errBuf.String(), want) {
+			t.Fatalf("stderr %q lacks %q", errBuf.String(), want)
+		}
+	}
+}
+
+func TestSuperviseRun_Restarts(t *testing.T) {
+	stubNotify(t)
+	oldAfter := restartAfterFunc
+	t.Cleanup(func() { restartAfterFunc = oldAfter })
+	var backoffs []time.Duration
+	restartAfterFunc = func(d time.Duration) <-chan time.Time {
+		backoffs = append(backoffs, d)
+		c := make(chan time.Time, 1)
+		c <- time.Time{}
+		return c
+	}
+
+	// The command fails until the third run; every run gets fresh values.
+	count := filepath.Join(t.TempDir(), "count")
+	script := `n=$(cat ` + count + ` 2>/dev/null || echo 0); n=$((n+1)); echo $n > ` + count + `; [ "$RUN" = "fresh-$n" ] && [ $n -ge 3 ]`
+	obtains := 0
+	obtain := func() ([]string, int, bool) {
+		obtains++
+		return append(os.Environ(), "RUN=fresh-"+strconv.Itoa(obtains)), 0, true
+	}
+
+	var errBuf bytes.Buffer
+	code := superviseRun([]string{"sh", "-c", script}, RunOptions{Wait: true, Restart: true}, obtain, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected exit code 0 after restarts, got %d stderr=%q", code, errBuf.String())
+	}
+	if obtains != 3 {
+		t.Fatalf("expected values to be obtained 3 times, got %d", obtains)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; !slices.Equal(backoffs, want) {
+		t.Fatalf("backoffs = %v, want %v", backoffs, want)
+	}
+}
+
+func TestSuperviseRun_MaxRestarts(t *testing.T) {
+	stubNotify(t)
+	oldAfter := restartAfterFunc
+	t.Cleanup(func() { restartAfterFunc = oldAfter })
+	restartAfterFunc = func(time.Duration) <-chan time.Time {
+		c := make(chan time.Time, 1)
+		c <- time.Time{}
+		return c
+	}
+	runs := 0
+	obtain := func() ([]string, int, bool) {
+		runs++
+		return os.Environ(), 0, true
+	}
+
+	var errBuf bytes.Buffer
+	code := superviseRun([]string{"sh", "-c", "exit 7"}, RunOptions{Wait: true, Restart: true, MaxRestarts: 2}, obtain, &errBuf)
+	if code != 7 || runs != 3 {
+		t.Fatalf("expected exit code 7 after 3 runs, got %d after %d; stderr=%q", code, runs, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "failed after 2 restarts; giving up") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	// Without Restart the failure is final.
+	runs = 0
+	if code := superviseRun([]string{"sh", "-c", "exit 7"}, RunOptions{Wait: true}, obtain, &errBuf); code != 7 || runs != 1 {
+		t.Fatalf("expected a single run, got code %d after %d runs", code, runs)
+	}
+
+	// A failure to obtain the values is final too.
+	failing := func() ([]string, int, bool) { return nil, ExitStartupTimeout, false }
+	if code := superviseRun([]string{"sh", "-c", "exit 7"}, RunOptions{Wait: true, Restart: true}, failing, &errBuf); code != ExitStartupTimeout {
+		t.Fatalf("expected exit code %d, got %d", ExitStartupTimeout, code)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Assign functions to vars so tests can override them
+var (
+	setgroupsFunc = sysSetgroups
+	setgidFunc    = sysSetgid
+	setuidFunc    = sysSetuid
+)
+
+// Credential is the user the command runs as after the secrets exchange.
+type Credential struct {
+	UID, GID int
+	// Groups are the supplementary groups; empty drops them all.
+	Groups []int
+	// Home, when set, replaces HOME in the command's environment.
+	Home string
+}
+
+// ParseUser parses "user[:group]" the way gosu and su-exec do. User and group
+// are names or numeric IDs. A user found in the passwd database brings its
+// primary group, supplementary groups and home directory; an explicit group
+// replaces the first two. A numeric user that is not in the database needs
+// an explicit group.
+func ParseUser(spec string) (*Credential, error) {
+	userPart, groupPart, hasGroup := strings.Cut(spec, ":")
+	if userPart == "" || (hasGroup && groupPart == "") {
+		return nil, fmt.Errorf("invalid user %q: want user[:group]", spec)
+	}
+
+	c := &Credential{GID: -1}
+	u, err := lookupUser(userPart)
+	switch {
+	case err == nil:
+		c.UID, _ = strconv.Atoi(u.Uid)
+		c.GID, _ = strconv.Atoi(u.Gid)
+		c.Home = u.HomeDir
+		if !hasGroup {
+			ids, _ := u.GroupIds()
+			for _, id := range ids {
+				if n, err := strconv.Atoi(id); err == nil && n != c.GID {
+					c.Groups = append(c.Groups, n)
+				}
+			}
+		}
+	case isID(userPart):
+		c.UID, _ = strconv.Atoi(userPart)
+	default:
+		return nil, fmt.Errorf("unknown user %q: %v", userPart, err)
+	}
+
+	if hasGroup {
+		if isID(groupPart) {
+			c.GID, _ = strconv.Atoi(groupPart)
+		} else {
+			g, err := user.LookupGroup(groupPart)
+			if err != nil {
+				return nil, fmt.Errorf("unknown group %q: %v", groupPart, err)
+			}
+			c.GID, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	if c.GID < 0 {
+		return nil, fmt.Errorf("user %q is not in the passwd database; give a group as %s:GID", userPart, userPart)
+	}
+	return c, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if isID(name) {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+func isID(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0
+}
+
+// drop switches the process to c: supplementary groups first, then the group,
+// then the user, after which the others can no longer be changed.
+func (c *Credential) drop() error {
+	if err := setgroupsFunc(c.Groups); err != nil {
+		return fmt.Errorf("failed to set supplementary groups: %v", err)
+	}
+	if err := setgidFunc(c.GID); err != nil {
+		return fmt.Errorf("failed to set group %d: %v", c.GID, err)
+	}
+	if err := setuidFunc(c.UID); err != nil {
+		return fmt.Errorf("failed to set user %d: %v", c.UID, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestParseUser(t *testing.T) {
+	cases := []struct {
+		spec     string
+		uid, gid int
+	}{
+		{"12345:54321", 12345, 54321},
+		{"root", 0, 0},
+		{"0:12", 0, 12},
+	}
+	for _, c := range cases {
+		got, err := ParseUser(c.spec)
+		if err != nil {
+			t.Fatalf("ParseUser(%q): %v", c.spec, err)
+		}
+		if got.UID != c.uid || got.GID != c.gid {
+			t.Fatalf("ParseUser(%q) = %d:%d, want %d:%d", c.spec, got.UID, got.GID, c.uid, c.gid)
+		}
+	}
+
+	for _, spec := range []string{"", ":1", "1:", "12345", "no-such-user-ojster", "0:no-such-group-ojster"} {
+		if _, err := ParseUser(spec); err == nil {
+			t.Fatalf("ParseUser(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestRun_DropsPrivileges(t *testing.T) {
+	var calls []string
+	oldGroups, oldGid, oldUid := setgroupsFunc, setgidFunc, setuidFunc
+	t.Cleanup(func() { setgroupsFunc, setgidFunc, setuidFunc = oldGroups, oldGid, oldUid })
+	setgroupsFunc = func(gids []int) error { calls = append(calls, fmt.Sprint("groups", gids)); return nil }
+	setgidFunc = func(gid int) error { calls = append(calls, fmt.Sprint("gid ", gid)); return nil }
+	setuidFunc = func(uid int) error { calls = append(calls, fmt.Sprint("uid ", uid)); return nil }
+
+	_, _, execEnv := stubExec(t)
+	oldPost := postMapToServerJSONFunc
+	t.Cleanup(func() { postMapToServerJSONFunc = oldPost })
+	postMapToServerJSONFunc = func(context.Context, Endpoint, map[string]string, string) ([]byte, int, error) {
+		return []byte(`{"SECRET":"ok"}`), 200, nil
+	}
+	t.Setenv("SECRET", pqc.BuildSealed([]byte{0x01}, []byte{0x02}))
+	t.Setenv("HOME", "/root")
+
+	opts := RunOptions{User: &Credential{UID: 1000, GID: 100, Groups: []int{27}, Home: "/home/app"}}
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, opts, []string{"sh"}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+	if want := []string{"groups[27]", "gid 100", "uid 1000"}; !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	if envSliceToMap(*execEnv)["HOME"] != "/home/app" || envSliceToMap(*execEnv)["SECRET"] != "ok" {
+		t.Fatalf("unexpected exec env: %v", *execEnv)
+	}
+}
+
+func TestRun_DropFailureStops(t *testing.T) {
+	oldUid := setuidFunc
+	t.Cleanup(func() { setuidFunc = oldUid })
+	setuidFunc = func(int) error { return fmt.Errorf("operation not permitted") }
+	oldGroups, oldGid := setgroupsFunc, setgidFunc
+	t.Cleanup(func() { setgroupsFunc, setgidFunc = oldGroups, oldGid })
+	setgroupsFunc = func([]int) error { return nil }
+	setgidFunc = func(int) error { return nil }
+
+	execPath, _, _ := stubExec(t)
+	t.Setenv("SECRET", "")
+	var outBuf, errBuf bytes.Buffer
+	opts := RunOptions{Passthrough: true, User: &Credential{UID: 1000, GID: 1000}}
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, opts, []string{"sh"}, &outBuf, &errBuf); code != 1 {
+		t.Fatalf("expected exit code 1, got %d stderr=%q", code, errBuf.String())
+	}
+	if *execPath != "" {
+		t.Fatalf("command must not run after a failed drop, exec'd %s", *execPath)
+	}
+}
+
+func TestRun_ChdirAndUmask(t *testing.T) {
+	var calls []string
+	oldChdir, oldUmask := chdirFunc, umaskFunc
+	t.Cleanup(func() { chdirFunc, umaskFunc = oldChdir, oldUmask })
+	chdirFunc = func(dir string) error { calls = append(calls, "chdir "+dir); return nil }
+	umaskFunc = func(mask int) int { calls = append(calls, fmt.Sprintf("umask %03o", mask)); return 0o022 }
+
+	_, _, execEnv := stubExec(t)
+	t.Setenv("SECRET", "")
+	mask := 0o027
+	opts := RunOptions{Passthrough: true, Dir: "/srv/app", Umask: &mask}
+	var outBuf, errBuf bytes.Buffer
+	if code := Run(context.Background(), pqc.DefaultValueRegex(), Endpoint{SocketPath: "unused-socket"}, opts, []string{"sh"}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%q", code, errBuf.String())
+	}
+	if want := []string{"chdir /srv/app", "umask 027"}; !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	if envSliceToMap(*execEnv)["PWD"] != "/srv/app" {
+		t.Fatalf("PWD not updated: %v", *execEnv)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ojster/ojster/internal/scan"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// Service is the part of a compose service definition that determines its
+// environment.
+type Service struct {
+	Name        string
+	EnvFiles    []string
+	Environment []Variable
+}
+
+// Variable is one environment entry. Set is false for pass-through entries
+// ("- KEY" or "KEY:"), which take their value from the project environment.
+type Variable struct {
+	Key   string
+	Value string
+	Set   bool
+}
+
+// Services returns the services of a compose file with their env_file and
+// environment entries, in file order.
+func Services(data []byte) ([]Service, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	servicesAt := findServices(lines)
+	if servicesAt < 0 {
+		return nil, errors.New("no top-level services: section")
+	}
+
+	var services []Service
+	end, _ := blockEnd(lines, servicesAt, 0)
+	svcIndent := firstChildIndent(lines, servicesAt, end, 2)
+	for i := servicesAt + 1; i < end; i++ {
+		if skippable(lines[i]) || indentOf(lines[i]) != svcIndent || mapKey(lines[i]) == "" {
+			continue
+		}
+		svc := Service{Name: mapKey(lines[i])}
+		svcEnd, _ := blockEnd(lines, i, svcIndent)
+		bodyIndent := firstChildIndent(lines, i, svcEnd, svcIndent+2)
+		for j := i + 1; j < svcEnd; j++ {
+			if skippable(lines[j]) || indentOf(lines[j]) != bodyIndent {
+				continue
+			}
+			var err error
+			switch mapKey(lines[j]) {
+			case "env_file":
+				svc.EnvFiles, err = envFiles(lines, j, bodyIndent)
+			case "environment":
+				svc.Environment, err = environment(lines, j, bodyIndent)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("service %q: %w", svc.Name, err)
+			}
+		}
+		services = append(services, svc)
+		i = svcEnd - 1
+	}
+	return services, nil
+}
+
+func findServices(lines []string) int {
+	for i, l := range lines {
+		if !skippable(l) && indentOf(l) == 0 && mapKey(l) == "services" {
+			return i
+		}
+	}
+	return -1
+}
+
+// envFiles reads an env_file value: a scalar, a flow list or a block list of
+// paths or of {path: ..., required: ...} mappings.
+func envFiles(lines []string, at, indent int) ([]string, error) {
+	if rest := scalar(inlineValue(lines[at])); rest != "" {
+		if inner, ok := strings.CutPrefix(rest, "["); ok {
+			var out []string
+			for _, p := range strings.Split(strings.TrimSuffix(inner, "]"), ",") {
+				if p = scalar(p); p != "" {
+					out = append(out, p)
+				}
+			}
+			return out, nil
+		}
+		return []string{rest}, nil
+	}
+
+	var out []string
+	end, _ := blockEnd(lines, at, indent)
+	for i := at + 1; i < end; i++ {
+		item, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "- ")
+		if skippable(lines[i]) || !ok {
+			continue
+		}
+		if mapKey(item) == "" {
+			out = append(out, scalar(item))
+			continue
+		}
+		// A mapping item: find its path member on this or a following line.
+		itemEnd, _ := blockEnd(lines, i, indentOf(lines[i]))
+		for j := i; j < itemEnd; j++ {
+			l := strings.TrimPrefix(strings.TrimSpace(lines[j]), "- ")
+			if mapKey(l) == "path" {
+				out = append(out, scalar(inlineValue(l)))
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// environment reads a block mapping or block list environment section.
+func environment(lines []string, at, indent int) ([]Variable, error) {
+	if rest := inlineValue(lines[at]); rest != "" {
+		return nil, fmt.Errorf("%w: environment must be a block mapping or list, not %q", ErrUnsupported, rest)
+	}
+
+	var out []Variable
+	end, _ := blockEnd(lines, at, indent)
+	entryIndent := firstChildIndent(lines, at, end, indent+2)
+	for i := at + 1; i < end; i++ {
+		l := lines[i]
+		if skippable(l) || indentOf(l) != entryIndent {
+			continue
+		}
+		if item, ok := strings.CutPrefix(strings.TrimSpace(l), "- "); ok {
+			k, v, set := strings.Cut(scalar(item), "=")
+			out = append(out, Variable{Key: strings.TrimSpace(k), Value: v, Set: set})
+			continue
+		}
+		k := mapKey(l)
+		if k == "" {
+			continue
+		}
+		raw := inlineValue(l)
+		switch {
+		case raw == "" || raw == "~" || raw == "null":
+			out = append(out, Variable{Key: k})
+		case strings.HasPrefix(raw, "|") || strings.HasPrefix(raw, ">"):
+			// Block scalar: the value is the more deeply indented lines.
+			valEnd, _ := blockEnd(lines, i, entryIndent)
+			var parts []string
+			for j := i + 1; j < valEnd; j++ {
+				parts = append(parts, strings.TrimSpace(lines[j]))
+			}
+			out = append(out, Variable{Key: k, Value: strings.Join(parts, "\n"), Set: true})
+		default:
+			out = append(out, Variable{Key: k, Value: scalar(raw), Set: true})
+		}
+	}
+	return out, nil
+}
+
+// scalar decodes a plain, single- or double-quoted YAML scalar, dropping a
+// trailing comment.
+func scalar(s string) string {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == '"' {
+				if v, err := strconv.Unquote(s[:i+1]); err == nil {
+					return v
+				}
+				return s[1:i]
+			}
+		}
+	case strings.HasPrefix(s, "'"):
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return strings.ReplaceAll(s[1:i], "''", "'")
+		}
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// Check cross-references the compose file at composePath with its env files
+// and writes one line per finding to outw:
+//
+//   - values that look like plaintext secrets, inline or in any env file;
+//   - secret-looking keys a service references that are not sealed in the
+//     project env file (envFile, or .env next to the compose file);
+//   - sealed keys in the project env file that no service uses.
+//
+// It returns 0 if nothing was found, 1 for findings or errors.
+func Check(composePath, envFile string, outw io.Writer, errw io.Writer) int {
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read compose file %s: %w", composePath, err))
+		return 1
+	}
+	services, err := Services(data)
+	if err != nil {
+		fmt.Fprintf(errw, "%s: %v\n", composePath, err)
+		return 1
+	}
+	dir := filepath.Dir(composePath)
+	if envFile == "" {
+		envFile = filepath.Join(dir, ".env")
+	}
+	project, err := env.ParseEnvFile(envFile)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", envFile, err))
+		return 1
+	}
+
+	var findings []string
+	report := func(format string, args ...any) {
+		findings = append(findings, fmt.Sprintf(format, args...))
+	}
+	checkFile := func(path string, m map[string]string) {
+		for _, k := range sortedKeys(m) {
+			if scan.Plaintext(k, m[k]) {
+				report("%s: %s looks like a plaintext secret", path, k)
+			}
+		}
+	}
+	checkFile(envFile, project)
+
+	used := make(map[string]bool)
+	projectLoaded := false
+	checked := map[string]bool{filepath.Clean(envFile): true}
+	for _, svc := range services {
+		for _, f := range svc.EnvFiles {
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(dir, f)
+			}
+			f = filepath.Clean(f)
+			if f == filepath.Clean(envFile) {
+				projectLoaded = true
+			}
+			if checked[f] {
+				continue
+			}
+			checked[f] = true
+			if _, err := os.Stat(f); err != nil {
+				report("service %s: env_file %s: %v", svc.Name, f, err)
+				continue
+			}
+			m, err := env.ParseEnvFile(f)
+			if err != nil {
+				report("service %s: env_file %s: %v", svc.Name, f, err)
+				continue
+			}
+			checkFile(f, m)
+		}
+
+		for _, v := range svc.Environment {
+			refs := []string{v.Key}
+			if v.Set {
+				refs = env.References(v.Value)
+				if scan.Plaintext(v.Key, v.Value) {
+					report("%s: service %s: environment %s looks like a plaintext secret", composePath, svc.Name, v.Key)
+				}
+			}
+			for _, r := range refs {
+				used[r] = true
+				if !scan.SecretName(r) && !scan.SecretName(v.Key) {
+					continue
+				}
+				val, ok := project[r]
+				switch {
+				case !ok:
+					report("service %s: %s references %s, which is not defined (let alone sealed) in %s", svc.Name, v.Key, r, envFile)
+				case !scan.Sealed(val) && !scan.Plaintext(r, val):
+					// Plaintext values with secret names were reported above.
+					report("service %s: %s references %s, which is not sealed in %s", svc.Name, v.Key, r, envFile)
+				}
+			}
+		}
+	}
+
+	if !projectLoaded {
+		for _, k := range sortedKeys(project) {
+			if scan.Sealed(project[k]) && !used[k] {
+				report("%s: sealed key %s is not used by any service", envFile, k)
+			}
+		}
+	}
+
+	for _, f := range findings {
+		fmt.Fprintln(outw, f)
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(errw, "%d problem(s) found in %s\n", len(findings), composePath)
+		return 1
+	}
+	fmt.Fprintf(outw, "No problems found in %s\n", composePath)
+	return 0
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestServices(t *testing.T) {
+	data := lines(
+		"version: '3'",
+		"services:",
+		"  api:",
+		"    image: api",
+		"    env_file: api.env # main",
+		"    environment:",
+		"      DB_URL: \"postgres://u:${DB_PASSWORD}@db\"",
+		"      TOKEN:",
+		"      LEVEL: 'it''s' # note",
+		"      CERT: |",
+		"        line1",
+		"        line2",
+		"  worker:",
+		"    env_file:",
+		"      - a.env",
+		"      - path: b.env",
+		"        required: false",
+		"      - required: true",
+		"        path: \"c.env\"",
+		"    environment:",
+		"      - PLAIN=x # c",
+		"      - PASS_THROUGH",
+		"  cron:",
+		"    env_file: [x.env, 'y.env']",
+		"volumes:",
+		"  data:",
+	)
+	got, err := Services([]byte(data))
+	if err != nil {
+		t.Fatalf("Services: %v", err)
+	}
+	want := []Service{
+		{Name: "api", EnvFiles: []string{"api.env"}, Environment: []Variable{
+			{Key: "DB_URL", Value: "postgres://u:${DB_PASSWORD}@db", Set: true},
+			{Key: "TOKEN"},
+			{Key: "LEVEL", Value: "it's", Set: true},
+			{Key: "CERT", Value: "line1\nline2", Set: true},
+		}},
+		{Name: "worker", EnvFiles: []string{"a.env", "b.env", "c.env"}, Environment: []Variable{
+			{Key: "PLAIN", Value: "x", Set: true},
+			{Key: "PASS_THROUGH"},
+		}},
+		{Name: "cron", EnvFiles: []string{"x.env", "y.env"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Services:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestServices_Errors(t *testing.T) {
+	if _, err := Services([]byte("volumes:\n  a:\n")); err == nil {
+		t.Fatalf("expected error without services")
+	}
+	_, err := Services([]byte(lines("services:", "  api:", "    environment: {A: b}")))
+	if !errors.Is(err, ErrUnsupported) || !strings.Contains(err.Error(), `service "api"`) {
+		t.Fatalf("expected ErrUnsupported for flow mapping, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	sealed := pqc.BuildSealed([]byte{1}, []byte{2})
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return p
+	}
+	write(".env", lines(
+		"DB_PASSWORD="+sealed,
+		"UNUSED_TOKEN="+sealed,
+		"ADMIN_PASSWORD=hunter2",
+		"DB_HOST=db",
+	))
+	write("api.env", lines("API_SECRET=plain", "API_KEY="+sealed))
+	composePath := write("docker-compose.yml", lines(
+		"services:",
+		"  api:",
+		"    env_file: api.env",
+		"    environment:",
+		"      DB_PASSWORD: ${DB_PASSWORD}",
+		"      ADMIN_PASSWORD: ${ADMIN_PASSWORD}",
+		"      SESSION_SECRET: literal-secret",
+		"      STRIPE_TOKEN: ${STRIPE_TOKEN}",
+		"      HOST: ${DB_HOST}",
+		"  worker:",
+		"    env_file: missing.env",
+	))
+
+	var outBuf, errBuf bytes.Buffer
+	if code := Check(composePath, "", &outBuf, &errBuf); code != 1 {
+		t.Fatalf("expected findings, got code=%d stdout=%q stderr=%q", code, outBuf.String(), errBuf.String())
+	}
+	out := outBuf.String()
+	for _, want := range []string{
+		".env: ADMIN_PASSWORD looks like a plaintext secret",
+		"api.env: API_SECRET looks like a plaintext secret",
+		"service api: environment SESSION_SECRET looks like a plaintext secret",
+		"STRIPE_TOKEN references STRIPE_TOKEN, which is not defined",
+		"service worker: env_file " + filepath.Join(dir, "missing.env"),
+		"sealed key UNUSED_TOKEN is not used by any service",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected finding %q in:\n%s", want, out)
+		}
+	}
+	for _, notWant := range []string{"API_KEY", "DB_HOST", "sealed key DB_PASSWORD", "ADMIN_PASSWORD, which is not sealed"} {
+		if strings.Contains(out, notWant) {
+			t.Fatalf("unexpected finding %q in:\n%s", notWant, out)
+		}
+	}
+	if len(strings.Split(strings.TrimSpace(out), "\n")) != 6 || !strings.Contains(errBuf.String(), "6 problem(s) found") {
+		t.Fatalf("expected exactly 6 findings, got:\n%s\nstderr=%q", out, errBuf.String())
+	}
+}
+
+func TestCheck_CleanAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	sealed := pqc.BuildSealed([]byte{1}, []byte{2})
+	envPath := filepath.Join(dir, "secrets.env")
+	composePath := filepath.Join(dir, "compose.yml")
+	if err := os.WriteFile(envPath, []byte("DB_PASSWORD="+sealed+"\nOTHER_TOKEN="+sealed+"\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// env_file loads every key of the project file, so none are unused.
+	if err := os.WriteFile(composePath, []byte(lines("services:", "  api:", "    env_file: secrets.env", "    environment:", "      - DB_PASSWORD")), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	if code := Check(composePath, envPath, &outBuf, &errBuf); code != 0 || !strings.Contains(outBuf.String(), "No problems found") {
+		t.Fatalf("expected clean result, got code=%d stdout=%q stderr=%q", code, outBuf.String(), errBuf.String())
+	}
+
+	errBuf.Reset()
+	if code := Check(filepath.Join(dir, "nope.yml"), "", &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "failed to read compose file") {
+		t.Fatalf("expected read error, got code=%d stderr=%q", code, errBuf.String())
+	}
+	errBuf.Reset()
+	if code := Check(envPath, "", &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "no top-level services") {
+		t.Fatalf("expected parse error, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compose edits the environment section of a service in a
+// docker-compose.yml in place. It works line by line so comments, ordering
+// and formatting of everything else are preserved; only block-style YAML
+// (the form compose files are written in) is supported.
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+var ErrUnsupported = errors.New("compose: unsupported YAML layout")
+
+// UpdateFile sets key to value in the environment of service in the compose
+// file at path.
+func UpdateFile(path, service, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := SetEnvironment(data, service, key, value)
+	if err != nil {
+		return err
+	}
+	return file.WriteFileAtomic(path, out, 0o644)
+}
+
+// SetEnvironment returns data with key set to value in the environment of
+// service. An existing entry is replaced, otherwise one is appended in the
+// style (mapping or list) already used; a missing environment section is
+// created. The value is always written double-quoted.
+func SetEnvironment(data []byte, service, key, value string) ([]byte, error) {
+	s := string(data)
+	finalNewline := strings.HasSuffix(s, "\n")
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+
+	servicesAt := findServices(lines)
+	if servicesAt < 0 {
+		return nil, errors.New("no top-level services: section")
+	}
+	svcAt, svcIndent := findChild(lines, servicesAt, 0, service)
+	if svcAt < 0 {
+		return nil, fmt.Errorf("service %q not found", service)
+	}
+
+	svcEnd, svcLast := blockEnd(lines, svcAt, svcIndent)
+	bodyIndent := firstChildIndent(lines, svcAt, svcEnd, svcIndent+2)
+	envAt := -1
+	for i := svcAt + 1; i < svcEnd; i++ {
+		if !skippable(lines[i]) && indentOf(lines[i]) == bodyIndent && mapKey(lines[i]) == "environment" {
+			envAt = i
+			break
+		}
+	}
+
+	pad := func(n int) string { return strings.Repeat(" ", n) }
+	mapEntry := func(indent int) string { return pad(indent) + key + ": " + quote(value) }
+
+	if envAt < 0 {
+		lines = insert(lines, svcLast+1, pad(bodyIndent)+"environment:", mapEntry(bodyIndent+2))
+		return join(lines, finalNewline), nil
+	}
+	if rest := inlineValue(lines[envAt]); rest != "" {
+		return nil, fmt.Errorf("%w: environment of %q must be a block mapping or list, not %q", ErrUnsupported, service, rest)
+	}
+
+	envEnd, envLast := blockEnd(lines, envAt, bodyIndent)
+	entryIndent := firstChildIndent(lines, envAt, envEnd, bodyIndent+2)
+	list := false
+	for i := envAt + 1; i < envEnd; i++ {
+		if !skippable(lines[i]) {
+			list = strings.HasPrefix(strings.TrimSpace(lines[i]), "- ")
+			break
+		}
+	}
+
+	newLine := mapEntry(entryIndent)
+	if list {
+		newLine = pad(entryIndent) + "- " + quote(key+"="+value)
+	}
+	for i := envAt + 1; i < envEnd; i++ {
+		l := lines[i]
+		if skippable(l) || indentOf(l) != entryIndent {
+			continue
+		}
+		var k string
+		if list {
+			k = listKey(l)
+		} else {
+			k = mapKey(l)
+		}
+		if k != key {
+			continue
+		}
+		// Drop continuation lines of a multi-line value.
+		end, _ := blockEnd(lines, i, entryIndent)
+		lines = append(lines[:i], append([]string{newLine}, lines[end:]...)...)
+		return join(lines, finalNewline), nil
+	}
+	lines = insert(lines, envLast+1, newLine)
+	return join(lines, finalNewline), nil
+}
+
+// findChild finds the mapping key name directly below the line at parent.
+func findChild(lines []string, parent, parentIndent int, name string) (at, indent int) {
+	end, _ := blockEnd(lines, parent, parentIndent)
+	indent = firstChildIndent(lines, parent, end, parentIndent+2)
+	for i := parent + 1; i < end; i++ {
+		if !skippable(lines[i]) && indentOf(lines[i]) == indent && mapKey(lines[i]) == name {
+			return i, indent
+		}
+	}
+	return -1, 0
+}
+
+// blockEnd returns the index after the block owned by the line at start (all
+// following lines indented deeper than indent, plus "- " items at the same
+// indent below a mapping key, which YAML allows) and the index of its last
+// non-blank, non-comment line.
+func blockEnd(lines []string, start, indent int) (end, last int) {
+	last = start
+	isItem := func(l string) bool { return strings.HasPrefix(strings.TrimSpace(l), "- ") }
+	startIsItem := isItem(lines[start])
+	for end = start + 1; end < len(lines); end++ {
+		l := lines[end]
+		if skippable(l) {
+			continue
+		}
+		if in := indentOf(l); in < indent || in == indent && (startIsItem || !isItem(l)) {
+			break
+		}
+		last = end
+	}
+	return end, last
+}
+
+func firstChildIndent(lines []string, parent, end, fallback int) int {
+	for i := parent + 1; i < end; i++ {
+		if !skippable(lines[i]) {
+			return indentOf(lines[i])
+		}
+	}
+	return fallback
+}
+
+func skippable(l string) bool {
+	t := strings.TrimSpace(l)
+	return t == "" || strings.HasPrefix(t, "#")
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+// mapKey returns the key of a "key:" or "key: value" line, or "".
+func mapKey(l string) string {
+	t := strings.TrimSpace(l)
+	if strings.HasPrefix(t, "- ") {
+		return ""
+	}
+	if k, _, ok := strings.Cut(t, ": "); ok {
+		return unquote(strings.TrimSpace(k))
+	}
+	if k, ok := strings.CutSuffix(t, ":"); ok {
+		return unquote(strings.TrimSpace(k))
+	}
+	return ""
+}
+
+// inlineValue returns what follows "key:" on the line, ignoring comments.
+func inlineValue(l string) string {
+	t := strings.TrimSpace(l)
+	_, rest, _ := strings.Cut(t, ":")
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "#") {
+		return ""
+	}
+	return rest
+}
+
+// listKey returns KEY for a "- KEY=value" or "- KEY" list item.
+func listKey(l string) string {
+	t, ok := strings.CutPrefix(strings.TrimSpace(l), "- ")
+	if !ok {
+		return ""
+	}
+	t = unquote(strings.TrimSpace(t))
+	k, _, _ := strings.Cut(t, "=")
+	return strings.TrimSpace(k)
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// quote renders s as a YAML double-quoted scalar (a JSON string is one).
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func insert(lines []string, at int, add ...string) []string {
+	out := make([]string, 0, len(lines)+len(add))
+	out = append(out, lines[:at]...)
+	out = append(out, add...)
+	return append(out, lines[at:]...)
+}
+
+func join(lines []string, finalNewline bool) []byte {
+	s := strings.Join(lines, "\n")
+	if finalNewline {
+		s += "\n"
+	}
+	return []byte(s)
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lines(l ...string) string { return strings.Join(l, "\n") + "\n" }
+
+func TestSetEnvironment(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "replace in mapping",
+			in: lines(
+				"# stack",
+				"services:",
+				"  api:",
+				"    image: nginx:latest # pinned later",
+				"    environment:",
+				"      A: one",
+				"      SECRET: old",
+				"      B: \"two\"",
+				"  db:",
+				"    environment:",
+				"      SECRET: keep",
+			),
+			want: lines(
+				"# stack",
+				"services:",
+				"  api:",
+				"    image: nginx:latest # pinned later",
+				"    environment:",
+				"      A: one",
+				"      SECRET: \"OJSTER-1:x\"",
+				"      B: \"two\"",
+				"  db:",
+				"    environment:",
+				"      SECRET: keep",
+			),
+		},
+		{
+			name: "append to mapping and drop block scalar",
+			in: lines(
+				"services:",
+				"    api:",
+				"        environment:",
+				"            SECRET: |",
+				"                multi",
+				"                line",
+				"            A: one",
+				"",
+				"        ports: [\"80:80\"]",
+			),
+			want: lines(
+				"services:",
+				"    api:",
+				"        environment:",
+				"            SECRET: \"OJSTER-1:x\"",
+				"            A: one",
+				"",
+				"        ports: [\"80:80\"]",
+			),
+		},
+		{
+			name: "append to list at same indent",
+			in: lines(
+				"services:",
+				"  api:",
+				"    environment:",
+				"    - A=1",
+				"    # trailing comment",
+				"    restart: always",
+			),
+			want: lines(
+				"services:",
+				"  api:",
+				"    environment:",
+				"    - A=1",
+				"    - \"SECRET=OJSTER-1:x\"",
+				"    # trailing comment",
+				"    restart: always",
+			),
+		},
+		{
+			name: "replace in list",
+			in: lines(
+				"services:",
+				"  \"api\":",
+				"    environment:",
+				"      - \"SECRET=old\"",
+				"      - SECRET2=keep",
+			),
+			want: lines(
+				"services:",
+				"  \"api\":",
+				"    environment:",
+				"      - \"SECRET=OJSTER-1:x\"",
+				"      - SECRET2=keep",
+			),
+		},
+		{
+			name: "create environment",
+			in:   "version: \"3\"\nservices:\n  web:\n    image: x\n  api:\n    image: y\nvolumes:\n  data:",
+			want: "version: \"3\"\nservices:\n  web:\n    image: x\n  api:\n    image: y\n    environment:\n      SECRET: \"OJSTER-1:x\"\nvolumes:\n  data:",
+		},
+		{
+			name: "empty environment",
+			in:   lines("services:", "  api:", "    environment: # none yet", "    image: y"),
+			want: lines("services:", "  api:", "    environment: # none yet", "      SECRET: \"OJSTER-1:x\"", "    image: y"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := SetEnvironment([]byte(c.in), "api", "SECRET", "OJSTER-1:x")
+			if err != nil {
+				t.Fatalf("SetEnvironment: %v", err)
+			}
+			if string(got) != c.want {
+				t.Fatalf("unexpected result:\nwant:\n%s\ngot:\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSetEnvironment_Errors(t *testing.T) {
+	if _, err := SetEnvironment([]byte("version: 3\n"), "api", "K", "v"); err == nil || !strings.Contains(err.Error(), "services") {
+		t.Fatalf("expected missing services error, got %v", err)
+	}
+	if _, err := SetEnvironment([]byte(lines("services:", "  web:", "    image: x")), "api", "K", "v"); err == nil || !strings.Contains(err.Error(), `"api" not found`) {
+		t.Fatalf("expected missing service error, got %v", err)
+	}
+	flow := lines("services:", "  api:", "    environment: {A: 1}")
+	if _, err := SetEnvironment([]byte(flow), "api", "K", "v"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported for flow mapping, got %v", err)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := UpdateFile(p, "api", "K", "v"); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+	if err := os.WriteFile(p, []byte(lines("services:", "  api:", "    image: x")), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := UpdateFile(p, "api", "K", "v"); err != nil {
+		t.Fatalf("UpdateFile: %v", err)
+	}
+	b, _ := os.ReadFile(p)
+	if !strings.HasSuffix(string(b), "    environment:\n      K: \"v\"\n") {
+		t.Fatalf("unexpected file:\n%s", b)
+	}
+	if err := UpdateFile(p, "nope", "K", "v"); err == nil {
+		t.Fatalf("expected error for missing service")
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doctor checks an ojster deployment for the problems users most
+// often run into and suggests a fix for each one it finds.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ojster/ojster/internal/client"
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/file"
+	"github.com/ojster/ojster/internal/util/ipc"
+)
+
+// Options selects what Run checks. An empty PrivateKeyFile skips the key
+// checks; an empty EnvFile makes the regex check look at the environment
+// of the current process instead.
+type Options struct {
+	SocketPath     string
+	PrivateKeyFile string
+	EnvFile        string
+	Regex          string
+}
+
+// checkTmpfs and fetchPublicKey are vars so tests can substitute them.
+var (
+	checkTmpfs     = file.CheckTmpfs
+	fetchPublicKey = client.FetchPublicKey
+)
+
+// report collects the outcome of each check.
+type report struct {
+	outw     io.Writer
+	problems int
+}
+
+func (r *report) ok(format string, args ...any) {
+	fmt.Fprintf(r.outw, "ok: "+format+"\n", args...)
+}
+
+func (r *report) skip(format string, args ...any) {
+	fmt.Fprintf(r.outw, "skipped: "+format+"\n", args...)
+}
+
+func (r *report) problem(hint, format string, args ...any) {
+	r.problems++
+	fmt.Fprintf(r.outw, "problem: "+format+"\n", args...)
+	if hint != "" {
+		fmt.Fprintf(r.outw, "  fix: %s\n", hint)
+	}
+}
+
+// Run performs every check, printing one line per check to outw. It returns
+// 1 if a problem was found, else 0.
+func Run(opts Options, outw io.Writer, errw io.Writer) int {
+	r := &report{outw: outw}
+	if r.socket(opts.SocketPath) {
+		r.server(opts.SocketPath)
+	}
+	r.tmpfs(os.TempDir())
+	r.privateKey(opts.PrivateKeyFile)
+	r.values(opts.EnvFile, opts.Regex)
+
+	if r.problems > 0 {
+		fmt.Fprintf(errw, "%d problem(s) found\n", r.problems)
+		return 1
+	}
+	fmt.Fprintln(outw, "No problems found")
+	return 0
+}
+
+// socket checks that path is a socket in a safe directory that accepts
+// connections, and reports whether it does. A named pipe has no directory,
+// so it is only connected to.
+func (r *report) socket(path string) bool {
+	if ipc.IsPipe(path) {
+		return r.connect(path)
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		r.problem("start the server with ojster serve; in containers, mount the directory of the socket rather than the socket itself",
+			"socket %s does not exist", path)
+		return false
+	case err != nil:
+		r.problem("this user needs search permission on every directory above the socket, for example through the group of its directory",
+			"socket %s: %v", path, err)
+		return false
+	case fi.IsDir():
+		r.problem("Docker creates a directory when a bind-mounted path does not exist yet; remove it, start the server first and mount the parent directory instead",
+			"socket %s is a directory", path)
+		return false
+	case fi.Mode()&os.ModeSocket == 0:
+		r.problem("point OJSTER_SOCKET_PATH at the socket ojster serve listens on", "%s is not a socket", path)
+		return false
+	}
+
+	dir := filepath.Dir(path)
+	if di, err := os.Stat(dir); err == nil && di.Mode().Perm()&0o002 != 0 && di.Mode()&os.ModeSticky == 0 {
+		r.problem("run chmod o-w "+dir, "socket directory %s is world-writable, so anyone could replace the socket", dir)
+	}
+	return r.connect(path)
+}
+
+func (r *report) connect(path string) bool {
+	conn, err := ipc.DialTimeout(path, 2*time.Second)
+	switch {
+	case errors.Is(err, syscall.EACCES):
+		r.problem("run as a user with write access to the socket, for example a member of the group of its directory",
+			"permission denied connecting to %s", path)
+		return false
+	case errors.Is(err, syscall.ECONNREFUSED):
+		r.problem("the server stopped and left its socket behind; restart ojster serve",
+			"nothing is listening on %s", path)
+		return false
+	case err != nil:
+		r.problem("", "cannot connect to %s: %v", path, err)
+		return false
+	}
+	conn.Close()
+	r.ok("%s accepts connections", ipc.Describe(path))
+	return true
+}
+
+// server checks that the server behind the socket answers a request.
+func (r *report) server(path string) {
+	if _, err := fetchPublicKey(context.Background(), client.Endpoint{SocketPath: path}); err != nil {
+		r.problem("check the server's log; client and server must speak the same protocol version",
+			"server at %s did not answer: %v", path, err)
+		return
+	}
+	r.ok("server at %s answered", path)
+}
+
+func (r *report) tmpfs(dir string) {
+	err := checkTmpfs(dir)
+	if errors.Is(err, file.ErrNoTmpfs) {
+		r.skip("%v", err)
+		return
+	}
+	if err != nil {
+		r.problem("ojster serve refuses to start without a memory-backed temp directory; use docker run --tmpfs /tmp or a tmpfs mount in compose",
+			"%v", err)
+		return
+	}
+	r.ok("%s is on tmpfs", dir)
+}
+
+func (r *report) privateKey(path string) {
+	if path == "" {
+		r.skip("no private key file here (--priv-file)")
+		return
+	}
+	info, err := pqc.InspectKeyFile(path)
+	if err != nil {
+		r.problem("create a key with ojster keypair", "%v", err)
+		return
+	}
+	if info.Kind == pqc.KindPublic {
+		r.problem("point OJSTER_PRIVATE_KEY_FILE at the private key, not the public one", "%s is a public key", path)
+		return
+	}
+	if err := pqc.CheckKeyPerms(path); err != nil {
+		r.problem("chmod 600 the key and make it owned by the user running ojster serve", "%v", err)
+		return
+	}
+	if info.Fingerprint != "" {
+		r.ok("%s is a %s, fingerprint %s", path, info.Kind, info.Fingerprint)
+		return
+	}
+	r.ok("%s is a %s", path, info.Kind)
+}
+
+// values checks that the env file parses and that regex selects at least one
+// of its values, or of the process environment without an env file.
+func (r *report) values(envFile, regex string) {
+	source := "the environment"
+	var values []string
+	if envFile == "" {
+		for _, kv := range os.Environ() {
+			if _, v, ok := strings.Cut(kv, "="); ok {
+				values = append(values, v)
+			}
+		}
+	} else {
+		m, err := env.ParseEnvFile(envFile)
+		if err != nil {
+			r.problem("fix the line shown; quote values that contain spaces or #", "%v", err)
+			return
+		}
+		r.ok("%s parses (%d entries)", envFile, len(m))
+		for _, v := range m {
+			values = append(values, v)
+		}
+		source = envFile
+	}
+
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		r.problem("fix OJSTER_REGEX or unset it to use the default", "invalid OJSTER_REGEX: %v", err)
+		return
+	}
+	n := 0
+	for _, v := range values {
+		if re.MatchString(v) {
+			n++
+		}
+	}
+	if n == 0 {
+		r.problem("seal values with ojster seal, or adjust OJSTER_REGEX to the values you sealed",
+			"no value in %s matches OJSTER_REGEX %s", source, regex)
+		return
+	}
+	r.ok("%d value(s) in %s match OJSTER_REGEX", n, source)
+}
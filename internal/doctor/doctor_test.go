@@ -0,0 +1,166 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/client"
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func stubChecks(t *testing.T, tmpfsErr, fetchErr error) {
+	t.Helper()
+	origTmpfs, origFetch := checkTmpfs, fetchPublicKey
+	t.Cleanup(func() { checkTmpfs, fetchPublicKey = origTmpfs, origFetch })
+	checkTmpfs = func(string) error { return tmpfsErr }
+	fetchPublicKey = func(context.Context, client.Endpoint) ([]byte, error) { return []byte("pub"), fetchErr }
+}
+
+func listen(t *testing.T, path string) *net.UnixListener {
+	t.Helper()
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func TestRun_Healthy(t *testing.T) {
+	stubChecks(t, nil, nil)
+	td := t.TempDir()
+	sock := filepath.Join(td, "ipc.sock")
+	listen(t, sock)
+	priv, pub, envFile := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key"), filepath.Join(td, ".env")
+	if code := pqc.KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	if err := os.WriteFile(envFile, []byte("HOST=db\nPASSWORD="+pqc.Prefix+"abc:def\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errb bytes.Buffer
+	code := Run(Options{SocketPath: sock, PrivateKeyFile: priv, EnvFile: envFile, Regex: pqc.DefaultValueRegex()}, &out, &errb)
+	if code != 0 {
+		t.Fatalf("expected no problems, got code=%d out=%q stderr=%q", code, out.String(), errb.String())
+	}
+	for _, want := range []string{"ok: unix socket " + sock + " accepts connections", "ok: server at", "is a private key, fingerprint", "parses (2 entries)", "ok: 1 value(s) in " + envFile, "No problems found"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_Problems(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	if code := pqc.KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair failed: code=%d", code)
+	}
+	badEnv := filepath.Join(td, "bad.env")
+	if err := os.WriteFile(badEnv, []byte("A=1\n\xff=2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	plainEnv := filepath.Join(td, "plain.env")
+	if err := os.WriteFile(plainEnv, []byte("PASSWORD=hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	live := filepath.Join(td, "live.sock")
+	listen(t, live)
+	staleLn := listen(t, filepath.Join(td, "stale.sock"))
+	staleLn.SetUnlinkOnClose(false)
+	staleLn.Close()
+	if err := os.Mkdir(filepath.Join(td, "dir.sock"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	openKey := filepath.Join(td, "open.key")
+	if data, err := os.ReadFile(priv); err != nil || os.WriteFile(openKey, data, 0o644) != nil {
+		t.Fatal("copying the private key failed")
+	}
+
+	for name, tc := range map[string]struct {
+		opts     Options
+		tmpfsErr error
+		fetchErr error
+		want     []string
+	}{
+		"missing socket": {
+			opts: Options{SocketPath: filepath.Join(td, "missing.sock"), Regex: "x"},
+			want: []string{"problem: socket " + filepath.Join(td, "missing.sock") + " does not exist", "fix: start the server"},
+		},
+		"socket is a directory": {
+			opts: Options{SocketPath: filepath.Join(td, "dir.sock"), Regex: "x"},
+			want: []string{"is a directory", "Docker creates a directory"},
+		},
+		"stale socket": {
+			opts: Options{SocketPath: filepath.Join(td, "stale.sock"), Regex: "x"},
+			want: []string{"nothing is listening on", "restart ojster serve"},
+		},
+		"server error": {
+			opts:     Options{SocketPath: live, Regex: "x"},
+			fetchErr: errors.New("server returned status=500"),
+			want:     []string{"accepts connections", "did not answer: server returned status=500"},
+		},
+		"no tmpfs": {
+			opts:     Options{SocketPath: live, Regex: "x"},
+			tmpfsErr: errors.New("path /tmp is not on tmpfs (statfs type 0xef53)"),
+			want:     []string{"problem: path /tmp is not on tmpfs", "--tmpfs /tmp"},
+		},
+		"public key as private key": {
+			opts: Options{SocketPath: live, PrivateKeyFile: pub, Regex: "x"},
+			want: []string{"is a public key"},
+		},
+		"key perms": {
+			opts: Options{SocketPath: live, PrivateKeyFile: openKey, Regex: "x"},
+			want: []string{"are too open", "fix: chmod 600"},
+		},
+		"missing key": {
+			opts: Options{SocketPath: live, PrivateKeyFile: pub + ".missing", Regex: "x"},
+			want: []string{"failed to read key file", "fix: create a key with ojster keypair"},
+		},
+		"unparseable env file": {
+			opts: Options{SocketPath: live, PrivateKeyFile: priv, EnvFile: badEnv, Regex: "x"},
+			want: []string{"line 2, column 1: invalid UTF-8"},
+		},
+		"nothing sealed": {
+			opts: Options{SocketPath: live, PrivateKeyFile: priv, EnvFile: plainEnv, Regex: pqc.DefaultValueRegex()},
+			want: []string{"no value in " + plainEnv + " matches OJSTER_REGEX", "seal values with ojster seal"},
+		},
+		"invalid regex": {
+			opts: Options{SocketPath: live, EnvFile: plainEnv, Regex: "("},
+			want: []string{"invalid OJSTER_REGEX", "skipped: no private key file"},
+		},
+	} {
+		stubChecks(t, tc.tmpfsErr, tc.fetchErr)
+		var out, errb bytes.Buffer
+		if code := Run(tc.opts, &out, &errb); code != 1 || !strings.Contains(errb.String(), "problem(s) found") {
+			t.Errorf("%s: expected problems, got code=%d stderr=%q", name, code, errb.String())
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: output lacks %q:\n%s", name, want, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envdiff compares env files by key and, given the private key, by
+// decrypted value.
+package envdiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/scan"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// Change kinds.
+const (
+	Added   = '+'
+	Removed = '-'
+	Changed = '~'
+)
+
+// Change is one differing key. Old is unset for Added, New for Removed.
+type Change struct {
+	Key  string
+	Kind byte
+	Old  string
+	New  string
+}
+
+// Diff compares a with b and returns the differences sorted by key.
+func Diff(a, b map[string]string) []Change {
+	var changes []Change
+	for k, av := range a {
+		bv, ok := b[k]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: k, Kind: Removed, Old: av})
+		case av != bv:
+			changes = append(changes, Change{Key: k, Kind: Changed, Old: av, New: bv})
+		}
+	}
+	for k, bv := range b {
+		if _, ok := a[k]; !ok {
+			changes = append(changes, Change{Key: k, Kind: Added, New: bv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// Files compares the env files at aPath and bPath and writes one line per
+// differing key to outw: "+ KEY" (only in b), "- KEY" (only in a) or
+// "~ KEY" (changed). Sealed values are re-encrypted on every seal, so they are
+// only compared when privPath names the private key to decrypt them with;
+// otherwise keys sealed in both files are counted as not compared. Values are
+// printed only with showValues. Returns 0 if the files match, 1 if they
+// differ and 2 on errors.
+func Files(aPath, bPath, privPath string, showValues bool, outw io.Writer, errw io.Writer) int {
+	a, code := load(aPath, privPath, errw)
+	if code != 0 {
+		return code
+	}
+	b, code := load(bPath, privPath, errw)
+	if code != 0 {
+		return code
+	}
+
+	var changes []Change
+	uncompared := 0
+	for _, c := range Diff(a, b) {
+		if c.Kind == Changed && scan.Sealed(c.Old) && scan.Sealed(c.New) {
+			uncompared++
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	for _, c := range changes {
+		if !showValues {
+			fmt.Fprintf(outw, "%c %s\n", c.Kind, c.Key)
+			continue
+		}
+		switch c.Kind {
+		case Added:
+			fmt.Fprintf(outw, "+ %s\n", env.FormatEnvEntry(c.Key, c.New))
+		case Removed:
+			fmt.Fprintf(outw, "- %s\n", env.FormatEnvEntry(c.Key, c.Old))
+		default:
+			fmt.Fprintf(outw, "~ %s\n    - %s\n    + %s\n", c.Key, env.FormatEnvEntry(c.Key, c.Old), env.FormatEnvEntry(c.Key, c.New))
+		}
+	}
+	if uncompared > 0 {
+		fmt.Fprintf(errw, "%d key(s) sealed in both files not compared; pass --priv-file to compare decrypted values\n", uncompared)
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// load parses the env file at path, replacing pqc-sealed values with their
+// plaintext if privPath is set.
+func load(path, privPath string, errw io.Writer) (map[string]string, int) {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", path, err))
+		return nil, 2
+	}
+	m, err := env.ParseEnvFile(path)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", path, err))
+		return nil, 2
+	}
+	if privPath == "" {
+		return m, 0
+	}
+	decrypted, err := pqc.UnsealMap(context.Background(), m, privPath, nil)
+	if err != nil {
+		fmt.Fprintf(errw, "%s: %v\n", path, err)
+		return nil, 2
+	}
+	for k, v := range decrypted {
+		m[k] = v
+	}
+	return m, 0
+}
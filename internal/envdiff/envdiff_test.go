@@ -0,0 +1,126 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envdiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestDiff(t *testing.T) {
+	got := Diff(map[string]string{"A": "1", "B": "2", "C": "3"}, map[string]string{"B": "2", "C": "x", "D": "4"})
+	want := []Change{
+		{Key: "A", Kind: Removed, Old: "1"},
+		{Key: "C", Kind: Changed, Old: "3", New: "x"},
+		{Key: "D", Kind: Added, New: "4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff = %+v, want %+v", got, want)
+	}
+	if Diff(map[string]string{"A": "1"}, map[string]string{"A": "1"}) != nil {
+		t.Fatalf("expected no changes")
+	}
+}
+
+// fixture writes two env files; SAME is sealed separately in each with the
+// same plaintext, PW with different plaintexts.
+func fixture(t *testing.T) (priv, a, b string) {
+	t.Helper()
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	seal := func(v string) string {
+		s, err := pqc.Seal(pub, []byte(v))
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		return s
+	}
+	a, b = filepath.Join(td, "a.env"), filepath.Join(td, "b.env")
+	if err := os.WriteFile(a, []byte("SAME="+seal("s")+"\nPW="+seal("old")+"\nHOST=a\nGONE=x\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(b, []byte("SAME="+seal("s")+"\nPW="+seal("new")+"\nHOST=b\nNEW=y\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return priv, a, b
+}
+
+func TestFiles_WithoutKey(t *testing.T) {
+	_, a, b := fixture(t)
+	var out, errb bytes.Buffer
+	if code := Files(a, b, "", false, &out, &errb); code != 1 {
+		t.Fatalf("expected differences, got code=%d stderr=%q", code, errb.String())
+	}
+	if out.String() != "- GONE\n~ HOST\n+ NEW\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if !strings.Contains(errb.String(), "2 key(s) sealed in both files not compared") {
+		t.Fatalf("expected not-compared note, got %q", errb.String())
+	}
+}
+
+func TestFiles_WithKey(t *testing.T) {
+	priv, a, b := fixture(t)
+	var out, errb bytes.Buffer
+	if code := Files(a, b, priv, false, &out, &errb); code != 1 {
+		t.Fatalf("expected differences, got code=%d stderr=%q", code, errb.String())
+	}
+	if out.String() != "- GONE\n~ HOST\n+ NEW\n~ PW\n" || errb.Len() != 0 {
+		t.Fatalf("unexpected output %q stderr=%q", out.String(), errb.String())
+	}
+	if strings.Contains(out.String(), "old") {
+		t.Fatalf("plaintext leaked without --show-values: %q", out.String())
+	}
+
+	out.Reset()
+	if code := Files(a, b, priv, true, &out, &errb); code != 1 {
+		t.Fatalf("expected differences, got code=%d", code)
+	}
+	want := "- GONE=x\n~ HOST\n    - HOST=a\n    + HOST=b\n+ NEW=y\n~ PW\n    - PW=old\n    + PW=new\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if code := Files(a, a, priv, true, &out, &errb); code != 0 || out.Len() != 0 {
+		t.Fatalf("expected identical files, got code=%d stdout=%q", code, out.String())
+	}
+}
+
+func TestFiles_Errors(t *testing.T) {
+	_, a, b := fixture(t)
+	var out, errb bytes.Buffer
+	if code := Files(a, filepath.Join(t.TempDir(), "nope.env"), "", false, &out, &errb); code != 2 || !strings.Contains(errb.String(), "failed to read env file") {
+		t.Fatalf("expected read error, got code=%d stderr=%q", code, errb.String())
+	}
+	errb.Reset()
+	if code := Files(filepath.Join(t.TempDir(), "nope.env"), b, "", false, &out, &errb); code != 2 {
+		t.Fatalf("expected read error, got code=%d", code)
+	}
+	errb.Reset()
+	if code := Files(a, b, filepath.Join(t.TempDir(), "nope.key"), false, &out, &errb); code != 2 || !strings.Contains(errb.String(), "a.env") {
+		t.Fatalf("expected key error, got code=%d stderr=%q", code, errb.String())
+	}
+}
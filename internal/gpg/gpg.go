@@ -0,0 +1,149 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gpg seals and unseals env values with OpenPGP keys by delegating to
+// the gpg binary (and therefore gpg-agent). No OpenPGP code is linked in.
+package gpg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// Prefix marks values sealed to OpenPGP recipients.
+const Prefix = "OJSTER-GPG-1:"
+
+var (
+	ErrGPG         = errors.New("gpg: command failed")
+	ErrMissingKeys = errors.New("gpg: missing keys")
+)
+
+// gpgBinary is a var so tests can substitute a fake gpg.
+var gpgBinary = "gpg"
+
+// IsSealed reports whether val is a GPG-sealed value.
+func IsSealed(val string) bool {
+	return strings.HasPrefix(val, Prefix) && len(val) > len(Prefix)
+}
+
+// run executes gpg with args, feeding stdin and returning stdout.
+// gpg's stderr is folded into the returned error.
+func run(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(gpgBinary, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("%w: %v", ErrGPG, err)
+		}
+		return nil, fmt.Errorf("%w: %v: %s", ErrGPG, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Seal encrypts plaintext to all recipients (key IDs, fingerprints or user IDs
+// known to the local keyring) and returns the sealed env value.
+// gpg's trust model applies, so a recipient key that is not valid in the
+// keyring is refused.
+func Seal(recipients []string, plaintext []byte) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one gpg recipient is required")
+	}
+	args := []string{"--batch", "--yes", "--quiet", "--encrypt"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+	args = append(args, "--output", "-")
+
+	ct, err := run(plaintext, args...)
+	if err != nil {
+		return "", err
+	}
+	if len(ct) == 0 {
+		return "", fmt.Errorf("%w: empty ciphertext", ErrGPG)
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// Open decrypts a single sealed value. Secret key access (and any passphrase
+// prompt) is handled by gpg-agent.
+func Open(sealed string) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("value does not start with %s", Prefix)
+	}
+	ct, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 gpg ciphertext: %v", err)
+	}
+	return run(ct, "--quiet", "--decrypt")
+}
+
+// UnsealMap decrypts the requested keys of envMap (all GPG-sealed keys if keys
+// is empty). It returns the decrypted map and the resolved keys in output order.
+func UnsealMap(envMap map[string]string, keys []string) (map[string]string, []string, error) {
+	keys, missing := env.SelectKeys(envMap, keys, IsSealed)
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMissingKeys, strings.Join(missing, ", "))
+	}
+
+	decrypted := make(map[string]string, len(keys))
+	for _, k := range keys {
+		pt, err := Open(envMap[k])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unseal %s: %w", k, err)
+		}
+		decrypted[k] = string(pt)
+	}
+	return decrypted, keys, nil
+}
+
+// UnsealFromFile mirrors pqc.UnsealFromFiles for GPG-sealed values: it decrypts
+// the requested keys from the env file at inPath and writes JSON or env lines
+// to outw. Returns an exit code and writes errors to errw.
+func UnsealFromFile(inPath string, keys []string, jsonOut bool, outw io.Writer, errw io.Writer) int {
+	envMap, err := env.ParseEnvFile(inPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", inPath, err))
+		return 1
+	}
+
+	decrypted, resolvedKeys, err := UnsealMap(envMap, keys)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		if errors.Is(err, ErrMissingKeys) {
+			return 2
+		}
+		return 1
+	}
+
+	if jsonOut {
+		js, _ := json.Marshal(decrypted)
+		_, _ = outw.Write(js)
+		return 0
+	}
+
+	_, _ = io.WriteString(outw, env.FormatEnvEntries(decrypted, resolvedKeys))
+	return 0
+}
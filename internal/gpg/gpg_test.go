@@ -0,0 +1,203 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// fakeGPG installs a shell script standing in for gpg. Encryption prefixes the
+// input with "CT:" and records the args; decryption strips the prefix again.
+func fakeGPG(t *testing.T) (argsFile string) {
+	t.Helper()
+	td := t.TempDir()
+	argsFile = filepath.Join(td, "args")
+	script := `#!/bin/sh
+echo "$@" >> "` + argsFile + `"
+for a in "$@"; do
+  case "$a" in
+    --encrypt) printf 'CT:'; cat; exit 0 ;;
+    --decrypt) input=$(cat); case "$input" in CT:*) printf '%s' "${input#CT:}"; exit 0 ;; esac
+               echo "gpg: decryption failed: No secret key" >&2; exit 2 ;;
+  esac
+done
+exit 1
+`
+	bin := filepath.Join(td, "gpg")
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake gpg: %v", err)
+	}
+	old := gpgBinary
+	gpgBinary = bin
+	t.Cleanup(func() { gpgBinary = old })
+	return argsFile
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Seal(), Open() and UnsealFromFile()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestSealOpen_RoundtripWithFake(t *testing.T) {
+	argsFile := fakeGPG(t)
+
+	sealed, err := Seal([]string{"alice@example.com", "0xBEEF"}, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) {
+		t.Fatalf("expected sealed value with prefix %s, got %q", Prefix, sealed)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "--recipient alice@example.com --recipient 0xBEEF") {
+		t.Fatalf("expected recipients passed to gpg, got %q", args)
+	}
+	if strings.Contains(string(args), "--trust-model") {
+		t.Fatalf("gpg's trust model was overridden: %q", args)
+	}
+
+	pt, err := Open(sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if string(pt) != "s3cr3t" {
+		t.Fatalf("unexpected plaintext: %q", pt)
+	}
+}
+
+func TestSeal_Errors(t *testing.T) {
+	if _, err := Seal(nil, []byte("x")); err == nil {
+		t.Fatalf("expected error without recipients")
+	}
+
+	old := gpgBinary
+	gpgBinary = filepath.Join(t.TempDir(), "no-such-gpg")
+	defer func() { gpgBinary = old }()
+	_, err := Seal([]string{"r"}, []byte("x"))
+	if !errors.Is(err, ErrGPG) {
+		t.Fatalf("expected ErrGPG for missing binary, got %v", err)
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	fakeGPG(t)
+
+	if _, err := Open("OJSTER-1:abc:def"); err == nil {
+		t.Fatalf("expected error for non-gpg value")
+	}
+	if _, err := Open(Prefix + "!!!"); err == nil || !strings.Contains(err.Error(), "invalid base64") {
+		t.Fatalf("expected base64 error, got %v", err)
+	}
+	// valid base64 that the fake refuses to decrypt; stderr must be surfaced
+	_, err := Open(Prefix + "Z2FyYmFnZQ==")
+	if !errors.Is(err, ErrGPG) || !strings.Contains(err.Error(), "No secret key") {
+		t.Fatalf("expected gpg stderr in error, got %v", err)
+	}
+}
+
+func TestUnsealFromFile(t *testing.T) {
+	fakeGPG(t)
+
+	a, _ := Seal([]string{"r"}, []byte("one"))
+	b, _ := Seal([]string{"r"}, []byte("two words"))
+	envPath := filepath.Join(t.TempDir(), ".env")
+	content := "PLAIN=x\nB=" + b + "\nA=" + a + "\n"
+	if err := os.WriteFile(envPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	var out, errb bytes.Buffer
+	if code := UnsealFromFile(envPath, nil, false, &out, &errb); code != 0 {
+		t.Fatalf("UnsealFromFile failed: code=%d stderr=%q", code, errb.String())
+	}
+	if want := "A=one\nB=\"two words\""; out.String() != want {
+		t.Fatalf("unexpected output: want=%q got=%q", want, out.String())
+	}
+
+	out.Reset()
+	if code := UnsealFromFile(envPath, []string{"A"}, true, &out, &errb); code != 0 {
+		t.Fatalf("UnsealFromFile json failed: code=%d stderr=%q", code, errb.String())
+	}
+	var m map[string]string
+	if err := json.Unmarshal(out.Bytes(), &m); err != nil || len(m) != 1 || m["A"] != "one" {
+		t.Fatalf("unexpected json output %q (err=%v)", out.String(), err)
+	}
+
+	errb.Reset()
+	if code := UnsealFromFile(envPath, []string{"MISSING"}, false, &out, &errb); code != 2 {
+		t.Fatalf("expected code 2 for missing key, got %d", code)
+	}
+	if !strings.Contains(errb.String(), "MISSING") {
+		t.Fatalf("expected missing key in stderr, got %q", errb.String())
+	}
+
+	errb.Reset()
+	if code := UnsealFromFile(envPath, []string{"PLAIN"}, false, &out, &errb); code != 1 {
+		t.Fatalf("expected code 1 for non-gpg value, got %d", code)
+	}
+
+	errb.Reset()
+	if code := UnsealFromFile(t.TempDir(), nil, false, &out, &errb); code != 1 {
+		t.Fatalf("expected code 1 for unreadable env file, got %d", code)
+	}
+}
+
+// TestSealOpen_RealGPG exercises the real gpg binary with a throwaway keyring.
+func TestSealOpen_RealGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+	home, err := os.MkdirTemp("", "gpg")
+	if err != nil {
+		t.Fatalf("mkdtemp: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		_ = os.RemoveAll(home)
+	})
+	t.Setenv("GNUPGHOME", home)
+
+	gen := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "ojster-test@example.com", "future-default", "default", "never")
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Skipf("gpg key generation unavailable: %v: %s", err, out)
+	}
+
+	sealed, err := Seal([]string{"ojster-test@example.com"}, []byte("real secret"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	pt, err := Open(sealed)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if string(pt) != "real secret" {
+		t.Fatalf("unexpected plaintext: %q", pt)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// Credentials identify the unprivileged user a server switches to.
+type Credentials struct {
+	UID int
+	GID int
+}
+
+// LookupCredentials resolves userName and groupName, which may be names or
+// numeric IDs. Numeric IDs need no passwd or group entry, which minimal
+// container images often lack. Without groupName the user's primary group is
+// used. Root is rejected, since dropping to it would do nothing.
+func LookupCredentials(userName, groupName string) (Credentials, error) {
+	var c Credentials
+	var primary string
+	if u, err := user.Lookup(userName); err == nil {
+		c.UID, _ = strconv.Atoi(u.Uid)
+		primary = u.Gid
+	} else if id, convErr := strconv.Atoi(userName); convErr == nil && id >= 0 {
+		c.UID = id
+		if u, err := user.LookupId(userName); err == nil {
+			primary = u.Gid
+		}
+	} else {
+		return Credentials{}, fmt.Errorf("unknown user %q", userName)
+	}
+
+	switch {
+	case groupName != "":
+		if g, err := user.LookupGroup(groupName); err == nil {
+			c.GID, _ = strconv.Atoi(g.Gid)
+		} else if id, convErr := strconv.Atoi(groupName); convErr == nil && id >= 0 {
+			c.GID = id
+		} else {
+			return Credentials{}, fmt.Errorf("unknown group %q", groupName)
+		}
+	case primary != "":
+		c.GID, _ = strconv.Atoi(primary)
+	default:
+		return Credentials{}, fmt.Errorf("user %q has no passwd entry; specify a group", userName)
+	}
+
+	if c.UID == 0 || c.GID == 0 {
+		return Credentials{}, errors.New("refusing to drop privileges to root")
+	}
+	return c, nil
+}
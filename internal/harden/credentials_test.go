@@ -0,0 +1,48 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harden
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupCredentials(t *testing.T) {
+	tests := []struct {
+		user, group string
+		want        Credentials
+		wantErr     string
+	}{
+		{"12345", "23456", Credentials{UID: 12345, GID: 23456}, ""},
+		{"12345", "", Credentials{}, "has no passwd entry"},
+		{"root", "", Credentials{}, "refusing to drop privileges to root"},
+		{"12345", "root", Credentials{}, "refusing to drop privileges to root"},
+		{"no-such-user-ojster", "", Credentials{}, "unknown user"},
+		{"-1", "", Credentials{}, "unknown user"},
+		{"12345", "no-such-group-ojster", Credentials{}, "unknown group"},
+	}
+	for _, tc := range tests {
+		got, err := LookupCredentials(tc.user, tc.group)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("LookupCredentials(%q, %q) error = %v, want %q", tc.user, tc.group, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("LookupCredentials(%q, %q) = %+v, %v; want %+v", tc.user, tc.group, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+// Package harden reduces what a compromised or crashing server process can
+// leak, by applying Linux process restrictions at startup.
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// prSetNoNewPrivs is missing from the syscall package.
+const prSetNoNewPrivs = 38
+
+// DisableCoreDumps sets RLIMIT_CORE to zero and clears the dumpable flag, so
+// a crash writes no core file and processes of the same user cannot ptrace
+// the server or read its memory through /proc.
+func DisableCoreDumps() error {
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		return fmt.Errorf("failed to set RLIMIT_CORE: %w", err)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to clear dumpable flag: %w", errno)
+	}
+	return nil
+}
+
+// Drop switches every thread of the process to c, clearing supplementary
+// groups first. It fails if the process could switch back to root afterwards.
+func Drop(c Credentials) error {
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("failed to clear supplementary groups: %w", err)
+	}
+	if err := syscall.Setgid(c.GID); err != nil {
+		return fmt.Errorf("failed to set group %d: %w", c.GID, err)
+	}
+	if err := syscall.Setuid(c.UID); err != nil {
+		return fmt.Errorf("failed to set user %d: %w", c.UID, err)
+	}
+	if c.UID != 0 && syscall.Setuid(0) == nil {
+		return errors.New("privileges could be regained after dropping them")
+	}
+	return nil
+}
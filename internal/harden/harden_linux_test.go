@@ -0,0 +1,68 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package harden
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestDisableCoreDumps(t *testing.T) {
+	if err := DisableCoreDumps(); err != nil {
+		t.Fatalf("DisableCoreDumps failed: %v", err)
+	}
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &lim); err != nil {
+		t.Fatalf("getrlimit: %v", err)
+	}
+	if lim.Cur != 0 || lim.Max != 0 {
+		t.Fatalf("RLIMIT_CORE = %+v, want 0/0", lim)
+	}
+	dumpable, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_GET_DUMPABLE, 0, 0)
+	if errno != 0 {
+		t.Fatalf("prctl: %v", errno)
+	}
+	if dumpable != 0 {
+		t.Fatalf("dumpable = %d, want 0", dumpable)
+	}
+}
+
+// TestDrop re-runs the test binary so the dropped credentials don't leak into
+// other tests.
+func TestDrop(t *testing.T) {
+	if os.Getenv("OJSTER_TEST_DROP") == "1" {
+		if err := Drop(Credentials{UID: 65534, GID: 65534}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d:%d\n", syscall.Getuid(), syscall.Getgid())
+		os.Exit(0)
+	}
+	if os.Getuid() != 0 {
+		t.Skip("dropping privileges requires root")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDrop$")
+	cmd.Env = append(os.Environ(), "OJSTER_TEST_DROP=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil || !strings.HasPrefix(string(out), "65534:65534\n") {
+		t.Fatalf("drop failed: %v output=%q", err, out)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package harden
+
+import "errors"
+
+// DisableCoreDumps is a no-op outside Linux, where the server is not deployed.
+func DisableCoreDumps() error {
+	return nil
+}
+
+// Drop is only implemented on Linux.
+func Drop(c Credentials) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,32 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harden
+
+// Access rights for FSRule, translated to Landlock rights for the path's type.
+const (
+	// FSRead allows reading files and listing directories.
+	FSRead = 1 << iota
+	// FSWrite allows writing and truncating files and, below a directory,
+	// creating and removing entries.
+	FSWrite
+	// FSExec allows executing files.
+	FSExec
+)
+
+// FSRule grants Access to Path and, if it is a directory, everything below it.
+type FSRule struct {
+	Path   string
+	Access int
+}
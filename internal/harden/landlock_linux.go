@@ -0,0 +1,137 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package harden
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock syscalls share their numbers across architectures.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+	oPath                        = 0x200000 // missing from the syscall package on amd64
+)
+
+// Landlock filesystem rights (LANDLOCK_ACCESS_FS_*).
+const (
+	llExecute = 1 << iota
+	llWriteFile
+	llReadFile
+	llReadDir
+	llRemoveDir
+	llRemoveFile
+	llMakeChar
+	llMakeDir
+	llMakeReg
+	llMakeSock
+	llMakeFifo
+	llMakeBlock
+	llMakeSym
+	llRefer    // ABI 2
+	llTruncate // ABI 3
+	llIoctlDev // ABI 5
+
+	llFileRights = llExecute | llWriteFile | llReadFile | llTruncate | llIoctlDev
+)
+
+// Landlock denies all filesystem access except what rules grant, for every
+// thread of the process and its future children. Landlock needs Linux 5.13
+// and a pure Go build: with cgo the syscall cannot reach all threads and an
+// error is returned.
+func Landlock(rules []FSRule) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return fmt.Errorf("Landlock is not available: %w", errno)
+	}
+	handled := uint64(llMakeSym<<1 - 1)
+	if abi >= 2 {
+		handled |= llRefer
+	}
+	if abi >= 3 {
+		handled |= llTruncate
+	}
+	if abi >= 5 {
+		handled |= llIoctlDev
+	}
+
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&handled)), unsafe.Sizeof(handled), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create Landlock ruleset: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+	for _, r := range rules {
+		if err := addLandlockRule(int(fd), r, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to set no_new_privs: %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+func addLandlockRule(rulesetFD int, r FSRule, handled uint64) error {
+	fd, err := syscall.Open(r.Path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for Landlock: %w", r.Path, err)
+	}
+	defer syscall.Close(fd)
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", r.Path, err)
+	}
+	isDir := st.Mode&syscall.S_IFMT == syscall.S_IFDIR
+
+	var access uint64
+	if r.Access&FSRead != 0 {
+		access |= llReadFile | llReadDir
+	}
+	if r.Access&FSWrite != 0 {
+		access |= llWriteFile | llTruncate | llRemoveDir | llRemoveFile | llMakeDir | llMakeReg | llMakeSym | llMakeSock | llMakeFifo
+	}
+	if r.Access&FSExec != 0 {
+		access |= llExecute
+	}
+	if !isDir {
+		access &= llFileRights
+	}
+	access &= handled
+	if access == 0 {
+		return nil
+	}
+
+	// struct landlock_path_beneath_attr is packed: u64 allowed_access, s32 parent_fd.
+	var attr [12]byte
+	binary.NativeEndian.PutUint64(attr[:8], access)
+	binary.NativeEndian.PutUint32(attr[8:], uint32(fd))
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFD), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add Landlock rule for %s: %w", r.Path, errno)
+	}
+	return nil
+}
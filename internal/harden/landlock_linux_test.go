@@ -0,0 +1,75 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestLandlock re-runs the test binary because the ruleset cannot be lifted.
+func TestLandlock(t *testing.T) {
+	if dir := os.Getenv("OJSTER_TEST_LANDLOCK"); dir != "" {
+		key := filepath.Join(dir, "key")
+		err := Landlock([]FSRule{{Path: filepath.Join(dir, "rw"), Access: FSRead | FSWrite}, {Path: key, Access: FSRead}})
+		if err != nil {
+			fmt.Println("install:", err)
+			os.Exit(0)
+		}
+		if _, err := os.ReadFile(key); err != nil {
+			fmt.Println("read key:", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(key, []byte("x"), 0o600); !errors.Is(err, syscall.EACCES) {
+			fmt.Println("write key:", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "rw", "f"), []byte("x"), 0o600); err != nil {
+			fmt.Println("write rw:", err)
+			os.Exit(1)
+		}
+		if _, err := os.ReadFile("/etc/passwd"); !errors.Is(err, syscall.EACCES) {
+			fmt.Println("read outside:", err)
+			os.Exit(1)
+		}
+		fmt.Println("ok")
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "rw"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key"), []byte("key"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLandlock$")
+	cmd.Env = append(os.Environ(), "OJSTER_TEST_LANDLOCK="+dir)
+	out, err := cmd.CombinedOutput()
+	if strings.HasPrefix(string(out), "install:") {
+		t.Skipf("Landlock unavailable: %s", out)
+	}
+	if err != nil || !strings.HasPrefix(string(out), "ok\n") {
+		t.Fatalf("ruleset not effective: %v output=%q", err, out)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package harden
+
+// Syscall numbers for Seccomp on amd64. The syscall package's table predates
+// several of these, so they are listed here.
+const (
+	sysSeccomp    = 317
+	auditArch     = 0xc000003e // AUDIT_ARCH_X86_64
+	x32SyscallBit = 0x40000000
+)
+
+var deniedSyscalls = []uint32{
+	41,  // socket
+	53,  // socketpair
+	101, // ptrace
+	310, // process_vm_readv
+	311, // process_vm_writev
+	312, // kcmp
+	175, // init_module
+	313, // finit_module
+	176, // delete_module
+	246, // kexec_load
+	320, // kexec_file_load
+	165, // mount
+	166, // umount2
+	155, // pivot_root
+	161, // chroot
+	428, // open_tree
+	429, // move_mount
+	430, // fsopen
+	432, // fsmount
+	442, // mount_setattr
+	272, // unshare
+	308, // setns
+	167, // swapon
+	168, // swapoff
+	169, // reboot
+	172, // iopl
+	173, // ioperm
+	163, // acct
+	321, // bpf
+	298, // perf_event_open
+	323, // userfaultfd
+	248, // add_key
+	249, // request_key
+	250, // keyctl
+	304, // open_by_handle_at
+	425, // io_uring_setup, whose requests bypass seccomp
+	426, // io_uring_enter
+	427, // io_uring_register
+}
+
+var execSyscalls = []uint32{
+	59,  // execve
+	322, // execveat
+	57,  // fork
+	58,  // vfork
+}
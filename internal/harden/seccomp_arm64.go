@@ -0,0 +1,68 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package harden
+
+// Syscall numbers for Seccomp on arm64, which uses the generic table.
+const (
+	sysSeccomp    = 277
+	auditArch     = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	x32SyscallBit = 0
+)
+
+var deniedSyscalls = []uint32{
+	198, // socket
+	199, // socketpair
+	117, // ptrace
+	270, // process_vm_readv
+	271, // process_vm_writev
+	272, // kcmp
+	105, // init_module
+	273, // finit_module
+	106, // delete_module
+	104, // kexec_load
+	294, // kexec_file_load
+	40,  // mount
+	39,  // umount2
+	41,  // pivot_root
+	51,  // chroot
+	428, // open_tree
+	429, // move_mount
+	430, // fsopen
+	432, // fsmount
+	442, // mount_setattr
+	97,  // unshare
+	268, // setns
+	224, // swapon
+	225, // swapoff
+	142, // reboot
+	89,  // acct
+	280, // bpf
+	241, // perf_event_open
+	282, // userfaultfd
+	217, // add_key
+	218, // request_key
+	219, // keyctl
+	265, // open_by_handle_at
+	425, // io_uring_setup, whose requests bypass seccomp
+	426, // io_uring_enter
+	427, // io_uring_register
+}
+
+var execSyscalls = []uint32{
+	221, // execve
+	281, // execveat
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (amd64 || arm64)
+
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Seccomp return actions and the seccomp(2) operation used by Seccomp.
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	// offsets into struct seccomp_data
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// Seccomp installs a filter on every thread that makes the syscalls in
+// deniedSyscalls fail with EPERM: creating sockets (the listener already
+// exists), ptrace and reading other processes' memory, and kernel, mount and
+// namespace administration. Unless allowExec is set, which the subprocess
+// unseal path needs, starting programs is denied too. The filter is inherited
+// by subprocesses and cannot be removed.
+func Seccomp(allowExec bool) error {
+	denied := deniedSyscalls
+	if !allowExec {
+		denied = append(denied[:len(denied):len(denied)], execSyscalls...)
+	}
+	filter := seccompProgram(denied)
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to set no_new_privs: %w", errno)
+	}
+	r, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %w", errno)
+	}
+	if r != 0 {
+		return errors.New("failed to install seccomp filter: a thread could not be synchronized")
+	}
+	return nil
+}
+
+// seccompProgram assembles the BPF filter: kill the process on a foreign
+// architecture, return EPERM for denied (and on amd64, x32) syscalls, and
+// allow everything else.
+func seccompProgram(denied []uint32) []syscall.SockFilter {
+	const (
+		ldAbs = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+		jeq   = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+		jge   = syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K
+		ret   = syscall.BPF_RET | syscall.BPF_K
+	)
+	n := len(denied)
+	prog := []syscall.SockFilter{
+		{Code: ldAbs, K: seccompDataArch},
+		{Code: jeq, Jt: 1, K: auditArch},
+		{Code: ret, K: seccompRetKillProcess},
+		{Code: ldAbs, K: seccompDataNr},
+	}
+	if x32SyscallBit != 0 {
+		prog = append(prog, syscall.SockFilter{Code: jge, Jt: uint8(n + 1), K: x32SyscallBit})
+	}
+	for i, nr := range denied {
+		// Jump over the remaining checks and the allow to the EPERM return.
+		prog = append(prog, syscall.SockFilter{Code: jeq, Jt: uint8(n - i), K: nr})
+	}
+	return append(prog,
+		syscall.SockFilter{Code: ret, K: seccompRetAllow},
+		syscall.SockFilter{Code: ret, K: seccompRetErrno | uint32(syscall.EPERM)},
+	)
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (amd64 || arm64)
+
+package harden
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// runFilter interprets the subset of classic BPF seccompProgram emits.
+func runFilter(t *testing.T, prog []syscall.SockFilter, nr, arch uint32) uint32 {
+	t.Helper()
+	var acc uint32
+	for pc := 0; pc < len(prog); pc++ {
+		ins := prog[pc]
+		switch ins.Code {
+		case syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS:
+			acc = map[uint32]uint32{seccompDataNr: nr, seccompDataArch: arch}[ins.K]
+		case syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K:
+			if acc == ins.K {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K:
+			if acc >= ins.K {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		case syscall.BPF_RET | syscall.BPF_K:
+			return ins.K
+		default:
+			t.Fatalf("unexpected instruction %#v", ins)
+		}
+	}
+	t.Fatalf("filter fell off the end")
+	return 0
+}
+
+func TestSeccompProgram(t *testing.T) {
+	denied := append(deniedSyscalls[:len(deniedSyscalls):len(deniedSyscalls)], execSyscalls...)
+	prog := seccompProgram(denied)
+	eperm := seccompRetErrno | uint32(syscall.EPERM)
+	for _, nr := range denied {
+		if got := runFilter(t, prog, nr, auditArch); got != eperm {
+			t.Fatalf("syscall %d: got action %#x, want EPERM", nr, got)
+		}
+	}
+	for _, nr := range []uint32{uint32(syscall.SYS_READ), uint32(syscall.SYS_WRITE), uint32(syscall.SYS_FUTEX), uint32(syscall.SYS_ACCEPT4)} {
+		if got := runFilter(t, prog, nr, auditArch); got != seccompRetAllow {
+			t.Fatalf("syscall %d: got action %#x, want allow", nr, got)
+		}
+	}
+	if got := runFilter(t, prog, uint32(syscall.SYS_READ), 0x40000003); got != seccompRetKillProcess {
+		t.Fatalf("foreign arch: got action %#x, want kill", got)
+	}
+	if x32SyscallBit != 0 {
+		if got := runFilter(t, prog, x32SyscallBit|uint32(syscall.SYS_READ), auditArch); got != eperm {
+			t.Fatalf("x32 syscall: got action %#x, want EPERM", got)
+		}
+	}
+}
+
+// TestSeccomp re-runs the test binary because the filter cannot be removed.
+func TestSeccomp(t *testing.T) {
+	if os.Getenv("OJSTER_TEST_SECCOMP") == "1" {
+		if err := Seccomp(false); err != nil {
+			fmt.Println("install:", err)
+			os.Exit(1)
+		}
+		if _, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0); !errors.Is(err, syscall.EPERM) {
+			fmt.Println("socket:", err)
+			os.Exit(1)
+		}
+		if err := exec.Command("/bin/true").Run(); !errors.Is(err, syscall.EPERM) {
+			fmt.Println("exec:", err)
+			os.Exit(1)
+		}
+		if _, err := os.ReadFile("/proc/self/status"); err != nil {
+			fmt.Println("read:", err)
+			os.Exit(1)
+		}
+		fmt.Println("ok")
+		os.Exit(0)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeccomp$")
+	cmd.Env = append(os.Environ(), "OJSTER_TEST_SECCOMP=1")
+	out, err := cmd.CombinedOutput()
+	if strings.Contains(string(out), "install:") {
+		t.Skipf("seccomp unavailable: %s", out)
+	}
+	if err != nil || !strings.HasPrefix(string(out), "ok\n") {
+		t.Fatalf("filter not effective: %v output=%q", err, out)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importenv seals an existing plaintext env file in one step, for
+// projects adopting ojster.
+package importenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/scan"
+	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// File seals every non-empty value of the env file at fromPath with the public
+// key at pubPath and writes the result to outPath. Values that are already
+// sealed are kept. If outPath exists (and is not fromPath) the sealed entries
+// are merged into it; otherwise the source's comments and layout are kept.
+// With shred the plaintext source is overwritten with zeros and, unless it is
+// also outPath, removed. Returns an exit code and writes errors to errw.
+func File(fromPath, outPath, pubPath string, shred bool, outw io.Writer, errw io.Writer) int {
+	data, err := os.ReadFile(fromPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", fromPath, err))
+		return 1
+	}
+	ek, err := pqc.LoadEncapsulationKey(pubPath)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+
+	src, err := env.ParseDocument(data)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", fromPath, err))
+		return 1
+	}
+	inPlace := samePath(fromPath, outPath)
+	target := src
+	if !inPlace {
+		if _, err := os.Stat(outPath); err == nil {
+			if target, err = env.LoadDocument(outPath); err != nil {
+				fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", outPath, err))
+				return 1
+			}
+		}
+	}
+
+	sealed, kept := 0, 0
+	done := make(map[string]bool)
+	for _, k := range src.Keys() {
+		if done[k] {
+			continue
+		}
+		done[k] = true
+		v, _, err := src.Get(k)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", fromPath, err))
+			return 1
+		}
+		if v == "" || scan.Sealed(v) {
+			kept++
+			target.Set(k, v)
+			continue
+		}
+		mlkemCT, gcmBlob, err := pqc.SealBytes(ek, []byte(v))
+		if err != nil {
+			fmt.Fprintf(errw, "failed to seal %s: %v\n", k, err)
+			return 1
+		}
+		target.Set(k, pqc.BuildSealed(mlkemCT, gcmBlob))
+		sealed++
+	}
+
+	// Open the source before it may be replaced, so its original inode can
+	// still be overwritten after an in-place import.
+	var plain *os.File
+	if shred {
+		if plain, err = os.OpenFile(fromPath, os.O_WRONLY, 0); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to open %s for shredding: %w", fromPath, err))
+			return 1
+		}
+		defer plain.Close()
+	}
+
+	if err := target.WriteFile(outPath, 0o644); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to write %s: %w", outPath, err))
+		return 1
+	}
+	fmt.Fprintf(outw, "Sealed %d values from %s into %s (%d kept as-is)\n", sealed, fromPath, outPath, kept)
+
+	if shred {
+		err := file.Overwrite(plain)
+		if err == nil && !inPlace {
+			err = os.Remove(fromPath)
+		}
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to shred %s: %w", fromPath, err))
+			return 1
+		}
+		fmt.Fprintf(outw, "Shredded %s\n", fromPath)
+	}
+	return 0
+}
+
+// samePath reports whether a and b name the same existing file.
+func samePath(a, b string) bool {
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ai, bi)
+}
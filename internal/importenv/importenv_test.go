@@ -0,0 +1,163 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importenv
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+func keypair(t *testing.T) (priv, pub string) {
+	t.Helper()
+	td := t.TempDir()
+	priv, pub = filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var out, errb bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	return priv, pub
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func unsealAll(t *testing.T, path, priv string) map[string]string {
+	t.Helper()
+	m, err := env.ParseEnvFile(path)
+	if err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	dec, err := pqc.UnsealMap(context.Background(), m, priv, nil)
+	if err != nil {
+		t.Fatalf("UnsealMap: %v", err)
+	}
+	for k, v := range dec {
+		m[k] = v
+	}
+	return m
+}
+
+func TestFile_NewOutput(t *testing.T) {
+	priv, pub := keypair(t)
+	already, err := pqc.Seal(pub, []byte("kept"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	td := t.TempDir()
+	from, out := filepath.Join(td, "plain.env"), filepath.Join(td, "sealed.env")
+	writeFile(t, from, "# database\nDB_PASSWORD=\"hunter 2\"\nEMPTY=\nTOKEN="+already+"\n")
+
+	var outBuf, errBuf bytes.Buffer
+	if code := File(from, out, pub, false, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("File returned %d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(outBuf.String(), "Sealed 1 values from "+from+" into "+out+" (2 kept as-is)") {
+		t.Fatalf("unexpected stdout %q", outBuf.String())
+	}
+	b, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(b), "# database\nDB_PASSWORD=OJSTER-1:") || strings.Contains(string(b), "hunter") {
+		t.Fatalf("unexpected sealed file:\n%s", b)
+	}
+	got := unsealAll(t, out, priv)
+	if got["DB_PASSWORD"] != "hunter 2" || got["TOKEN"] != "kept" || got["EMPTY"] != "" {
+		t.Fatalf("unexpected round trip: %#v", got)
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Fatalf("source should be left alone without shred: %v", err)
+	}
+}
+
+func TestFile_MergeAndShred(t *testing.T) {
+	priv, pub := keypair(t)
+	td := t.TempDir()
+	from, out := filepath.Join(td, "plain.env"), filepath.Join(td, ".env")
+	writeFile(t, from, "API_KEY=abc\n")
+	writeFile(t, out, "# existing\nHOST=db\n")
+
+	var outBuf, errBuf bytes.Buffer
+	if code := File(from, out, pub, true, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("File returned %d stderr=%q", code, errBuf.String())
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Fatalf("expected source to be removed, stat err=%v", err)
+	}
+	if !strings.Contains(outBuf.String(), "Shredded "+from) {
+		t.Fatalf("unexpected stdout %q", outBuf.String())
+	}
+	b, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(b), "# existing\nHOST=db\nAPI_KEY=OJSTER-1:") {
+		t.Fatalf("expected merge into existing file, got:\n%s", b)
+	}
+	if got := unsealAll(t, out, priv); got["API_KEY"] != "abc" {
+		t.Fatalf("unexpected round trip: %#v", got)
+	}
+}
+
+func TestFile_InPlaceShred(t *testing.T) {
+	priv, pub := keypair(t)
+	path := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, path, "SECRET=plain\n")
+	// A hard link keeps the original inode reachable so the overwrite can be observed.
+	orig := path + ".orig"
+	if err := os.Link(path, orig); err != nil {
+		t.Skipf("hard links unsupported: %v", err)
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	if code := File(path, path, pub, true, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("File returned %d stderr=%q", code, errBuf.String())
+	}
+	if got := unsealAll(t, path, priv); got["SECRET"] != "plain" {
+		t.Fatalf("unexpected round trip: %#v", got)
+	}
+	b, _ := os.ReadFile(orig)
+	if strings.Trim(string(b), "\x00") != "" {
+		t.Fatalf("expected original inode zeroed, got %q", b)
+	}
+}
+
+func TestFile_Errors(t *testing.T) {
+	_, pub := keypair(t)
+	td := t.TempDir()
+	from := filepath.Join(td, "plain.env")
+	writeFile(t, from, "A=1\n")
+
+	cases := []struct {
+		name, from, out, pub, want string
+	}{
+		{"missing source", filepath.Join(td, "nope.env"), filepath.Join(td, "o.env"), pub, "failed to read env file"},
+		{"missing public key", from, filepath.Join(td, "o.env"), filepath.Join(td, "nope.key"), "nope.key"},
+		{"unwritable output", from, filepath.Join(td, "plain.env", "sub", "o.env"), pub, "failed to write"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var outBuf, errBuf bytes.Buffer
+			if code := File(tc.from, tc.out, tc.pub, false, &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), tc.want) {
+				t.Fatalf("expected code 1 with %q, got code=%d stderr=%q", tc.want, code, errBuf.String())
+			}
+		})
+	}
+}
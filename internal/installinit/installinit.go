@@ -0,0 +1,87 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package installinit copies the running ojster binary into a shared volume,
+// so other services can use it as their init without a COPY step in each
+// project's Dockerfile.
+package installinit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// BinaryName is the name of the installed binary. Invoked under this name,
+// ojster behaves like ojster run, which is what Docker's init does.
+const BinaryName = "docker-init"
+
+// executable is a var so tests can substitute the binary to install.
+var executable = os.Executable
+
+// snippet is the compose fragment that mounts the installed binary over the
+// docker-init that init: true runs.
+const snippet = `volumes:
+  %[1]s:
+    external: true
+
+services:
+  app:
+    init: true
+    volumes:
+      - type: volume
+        source: %[1]s
+        target: /sbin/docker-init
+        read_only: true
+        volume:
+          subpath: %[2]s
+`
+
+// Install copies the running binary to target/docker-init, mode 0755, and
+// prints a compose snippet that uses it from the volume named volume. The
+// copy replaces an existing binary atomically, so containers already running
+// the old one are not disturbed. Returns 0 on success, 1 on failure.
+func Install(target, volume string, outw io.Writer, errw io.Writer) int {
+	self, err := executable()
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to locate the running binary: %w", err))
+		return 1
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to locate the running binary: %w", err))
+		return 1
+	}
+	data, err := os.ReadFile(self)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read %s: %w", self, err))
+		return 1
+	}
+
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to create %s: %w", target, err))
+		return 1
+	}
+	dst := filepath.Join(target, BinaryName)
+	if err := file.WriteFileAtomic(dst, data, 0o755); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to write %s: %w", dst, err))
+		return 1
+	}
+
+	fmt.Fprintf(errw, "Installed %s (%d bytes). Add to each service that needs secrets:\n\n", dst, len(data))
+	fmt.Fprintf(outw, snippet, volume, BinaryName)
+	return 0
+}
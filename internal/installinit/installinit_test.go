@@ -0,0 +1,101 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installinit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func stubExecutable(t *testing.T, path string, err error) {
+	t.Helper()
+	orig := executable
+	t.Cleanup(func() { executable = orig })
+	executable = func() (string, error) { return path, err }
+}
+
+func TestInstall(t *testing.T) {
+	td := t.TempDir()
+	self := filepath.Join(td, "ojster")
+	if err := os.WriteFile(self, []byte("binary v1"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(td, "link")
+	if err := os.Symlink(self, link); err != nil {
+		t.Fatal(err)
+	}
+	stubExecutable(t, link, nil)
+	target := filepath.Join(td, "vol", "bin")
+
+	var out, errb bytes.Buffer
+	if code := Install(target, "ojster-bin", &out, &errb); code != 0 {
+		t.Fatalf("install failed: code=%d stderr=%q", code, errb.String())
+	}
+	dst := filepath.Join(target, BinaryName)
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o755 {
+		t.Errorf("mode %v, want 0755", fi.Mode().Perm())
+	}
+	for _, want := range []string{"  ojster-bin:\n    external: true", "init: true", "source: ojster-bin", "target: /sbin/docker-init", "subpath: docker-init"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("snippet lacks %q:\n%s", want, out.String())
+		}
+	}
+	if !strings.Contains(errb.String(), "Installed "+dst) {
+		t.Errorf("stderr lacks install note: %q", errb.String())
+	}
+
+	// A reinstall replaces the binary.
+	if err := os.WriteFile(self, []byte("binary v2"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if code := Install(target, "ojster-bin", io.Discard, io.Discard); code != 0 {
+		t.Fatalf("reinstall failed: code=%d", code)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "binary v2" {
+		t.Fatalf("reinstall left %q", data)
+	}
+}
+
+func TestInstall_Errors(t *testing.T) {
+	td := t.TempDir()
+	stubExecutable(t, "", errors.New("no proc"))
+	var errb bytes.Buffer
+	if code := Install(td, "v", io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "no proc") {
+		t.Fatalf("expected locate error, got code=%d stderr=%q", code, errb.String())
+	}
+
+	self := filepath.Join(td, "ojster")
+	if err := os.WriteFile(self, []byte("bin"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	stubExecutable(t, self, nil)
+	notDir := filepath.Join(td, "file")
+	if err := os.WriteFile(notDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	errb.Reset()
+	if code := Install(notDir, "v", io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "failed to create") {
+		t.Fatalf("expected target error, got code=%d stderr=%q", code, errb.String())
+	}
+}
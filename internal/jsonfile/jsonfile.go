@@ -0,0 +1,318 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonfile seals and unseals string values inside JSON documents,
+// addressed by dot-separated paths such as "db.password" or "servers.0.token".
+// Member order is preserved; documents are written with two-space indentation.
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/env"
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// IsJSONPath reports whether path names a JSON document (by extension).
+func IsJSONPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// node is an order-preserving JSON value.
+type node struct {
+	kind byte // '{', '[' or 0 for scalars
+	keys []string
+	vals []*node
+	raw  []byte // encoded scalar
+}
+
+// Document is a parsed JSON document.
+type Document struct {
+	root *node
+}
+
+// Parse parses JSON data into a Document.
+func Parse(data []byte) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := parseValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: trailing data after document")
+	}
+	return &Document{root: root}, nil
+}
+
+func parseValue(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := &node{kind: byte(t)}
+		for dec.More() {
+			if n.kind == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, k.(string))
+			}
+			v, err := parseValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.vals = append(n.vals, v)
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return stringNode(t), nil
+	case json.Number:
+		return &node{raw: []byte(t)}, nil
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		return &node{raw: b}, nil
+	}
+}
+
+func stringNode(s string) *node {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return &node{raw: bytes.TrimRight(buf.Bytes(), "\n")}
+}
+
+// stringValue returns the decoded string if n is a JSON string.
+func (n *node) stringValue() (string, bool) {
+	if n.kind != 0 || len(n.raw) == 0 || n.raw[0] != '"' {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(n.raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+func (n *node) encode(buf *bytes.Buffer) {
+	switch n.kind {
+	case 0:
+		buf.Write(n.raw)
+	case '{':
+		buf.WriteByte('{')
+		for i, k := range n.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(stringNode(k).raw)
+			buf.WriteByte(':')
+			n.vals[i].encode(buf)
+		}
+		buf.WriteByte('}')
+	case '[':
+		buf.WriteByte('[')
+		for i, v := range n.vals {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			v.encode(buf)
+		}
+		buf.WriteByte(']')
+	}
+}
+
+// Bytes serializes the document with two-space indentation and a final newline.
+func (d *Document) Bytes() []byte {
+	var compact, out bytes.Buffer
+	d.root.encode(&compact)
+	_ = json.Indent(&out, compact.Bytes(), "", "  ")
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// Strings returns every string value in the document keyed by its path.
+func (d *Document) Strings() map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, n *node)
+	walk = func(prefix string, n *node) {
+		switch n.kind {
+		case 0:
+			if s, ok := n.stringValue(); ok && prefix != "" {
+				out[prefix] = s
+			}
+		case '{':
+			for i, k := range n.keys {
+				walk(joinPath(prefix, k), n.vals[i])
+			}
+		case '[':
+			for i, v := range n.vals {
+				walk(joinPath(prefix, strconv.Itoa(i)), v)
+			}
+		}
+	}
+	walk("", d.root)
+	return out
+}
+
+func joinPath(prefix, elem string) string {
+	if prefix == "" {
+		return elem
+	}
+	return prefix + "." + elem
+}
+
+// Set stores value as a string at path. Missing object members along the
+// path are created; array elements must already exist.
+func (d *Document) Set(path, value string) error {
+	if path == "" {
+		return errors.New("empty JSON path")
+	}
+	elems := strings.Split(path, ".")
+	n := d.root
+	for i, elem := range elems {
+		last := i == len(elems)-1
+		switch n.kind {
+		case '{':
+			idx := -1
+			for j, k := range n.keys {
+				if k == elem {
+					idx = j
+				}
+			}
+			if idx < 0 {
+				n.keys = append(n.keys, elem)
+				n.vals = append(n.vals, &node{kind: '{'})
+				idx = len(n.vals) - 1
+			}
+			if last {
+				n.vals[idx] = stringNode(value)
+				return nil
+			}
+			n = n.vals[idx]
+		case '[':
+			idx, err := strconv.Atoi(elem)
+			if err != nil || idx < 0 || idx >= len(n.vals) {
+				return fmt.Errorf("%s: no array element %q", path, elem)
+			}
+			if last {
+				n.vals[idx] = stringNode(value)
+				return nil
+			}
+			n = n.vals[idx]
+		default:
+			parent := strings.Join(elems[:i], ".")
+			if parent == "" {
+				parent = "document root"
+			}
+			return fmt.Errorf("%s: %s is not an object or array", path, parent)
+		}
+	}
+	return nil
+}
+
+// UpdateFile sets path to value in the JSON document at filePath, creating
+// the document if it does not exist.
+func UpdateFile(filePath, path, value string) error {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) || err == nil && len(bytes.TrimSpace(data)) == 0 {
+		data, err = []byte("{}"), nil
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	if err := doc.Set(path, value); err != nil {
+		return err
+	}
+	return file.WriteFileAtomic(filePath, doc.Bytes(), 0o644)
+}
+
+// UnsealFunc decrypts the selected values (all sealed ones if keys is empty)
+// and returns them keyed like the input. The pqc and gpg UnsealMap
+// functions all fit this shape once their key material is bound.
+type UnsealFunc func(values map[string]string, keys []string) (map[string]string, error)
+
+// UnsealFromFile decrypts sealed string values in the JSON document at inPath.
+// If paths is empty all sealed values are decrypted. With jsonOut the whole
+// document is written with the decrypted values substituted; otherwise the
+// decrypted values are written as "path=value" lines. Returns an exit code
+// (2 for missing paths) and writes errors to errw.
+func UnsealFromFile(inPath string, paths []string, unseal UnsealFunc, jsonOut bool, outw io.Writer, errw io.Writer) int {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read JSON file %s: %w", inPath, err))
+		return 1
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("%s: %w", inPath, err))
+		return 1
+	}
+
+	values := doc.Strings()
+	if len(paths) > 0 {
+		if _, missing := env.SelectKeys(values, paths, nil); len(missing) > 0 {
+			fmt.Fprintf(errw, "missing paths in %s: %s\n", inPath, strings.Join(missing, ", "))
+			return 2
+		}
+	}
+	decrypted, err := unseal(values, paths)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+
+	keys := make([]string, 0, len(decrypted))
+	for k := range decrypted {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if jsonOut {
+		for _, k := range keys {
+			if err := doc.Set(k, decrypted[k]); err != nil {
+				fmt.Fprintln(errw, err)
+				return 1
+			}
+		}
+		_, _ = outw.Write(doc.Bytes())
+		return 0
+	}
+	if result := env.FormatEnvEntries(decrypted, keys); result != "" {
+		_, _ = io.WriteString(outw, result)
+	}
+	return 0
+}
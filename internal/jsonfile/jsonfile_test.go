@@ -0,0 +1,172 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// fakeUnseal "decrypts" values prefixed with SEALED: by stripping the prefix.
+func fakeUnseal(values map[string]string, keys []string) (map[string]string, error) {
+	out := make(map[string]string)
+	if len(keys) == 0 {
+		for k, v := range values {
+			if strings.HasPrefix(v, "SEALED:") {
+				keys = append(keys, k)
+			}
+		}
+	}
+	for _, k := range keys {
+		v, ok := strings.CutPrefix(values[k], "SEALED:")
+		if !ok {
+			return nil, errors.New(k + " is not sealed")
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Document and UnsealFromFile()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestIsJSONPath(t *testing.T) {
+	for p, want := range map[string]bool{"a.json": true, "dir/A.JSON": true, ".env": false, "json": false, "x.json.bak": false} {
+		if got := IsJSONPath(p); got != want {
+			t.Fatalf("IsJSONPath(%q) = %v", p, got)
+		}
+	}
+}
+
+func TestDocument_PreservesOrderAndTypes(t *testing.T) {
+	src := `{"z":1,"a":{"pw":"x<y>","n":1.50},"list":["a",{"t":"v"}],"b":true,"nil":null}`
+	doc, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := "{\n  \"z\": 1,\n  \"a\": {\n    \"pw\": \"x<y>\",\n    \"n\": 1.50\n  },\n  \"list\": [\n    \"a\",\n    {\n      \"t\": \"v\"\n    }\n  ],\n  \"b\": true,\n  \"nil\": null\n}\n"
+	if got := string(doc.Bytes()); got != want {
+		t.Fatalf("unexpected serialization:\n%s", got)
+	}
+	strs := doc.Strings()
+	if len(strs) != 3 || strs["a.pw"] != "x<y>" || strs["list.0"] != "a" || strs["list.1.t"] != "v" {
+		t.Fatalf("unexpected strings %v", strs)
+	}
+}
+
+func TestDocument_Set(t *testing.T) {
+	doc, _ := Parse([]byte(`{"a":{"b":"old"},"arr":["x"],"s":"str"}`))
+	for path, v := range map[string]string{"a.b": "new", "a.c.d": "deep", "arr.0": "y", "top": "t"} {
+		if err := doc.Set(path, v); err != nil {
+			t.Fatalf("Set(%s): %v", path, err)
+		}
+	}
+	strs := doc.Strings()
+	if strs["a.b"] != "new" || strs["a.c.d"] != "deep" || strs["arr.0"] != "y" || strs["top"] != "t" {
+		t.Fatalf("unexpected strings %v", strs)
+	}
+	for path, want := range map[string]string{"": "empty", "arr.1": "no array element", "arr.x": "no array element", "s.x": "not an object"} {
+		if err := doc.Set(path, "v"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Set(%q) error = %v; want %q", path, err, want)
+		}
+	}
+	scalar, _ := Parse([]byte(`"x"`))
+	if err := scalar.Set("a", "v"); err == nil || !strings.Contains(err.Error(), "document root") {
+		t.Fatalf("expected root error, got %v", err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, src := range []string{"", "{", `{"a":}`, `{} {}`} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Fatalf("expected error for %q", src)
+		}
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "config.json")
+	if err := UpdateFile(p, "db.password", "SEALED:pw"); err != nil {
+		t.Fatalf("UpdateFile new: %v", err)
+	}
+	if err := UpdateFile(p, "api_key", "SEALED:k"); err != nil {
+		t.Fatalf("UpdateFile existing: %v", err)
+	}
+	b, _ := os.ReadFile(p)
+	if string(b) != "{\n  \"db\": {\n    \"password\": \"SEALED:pw\"\n  },\n  \"api_key\": \"SEALED:k\"\n}\n" {
+		t.Fatalf("unexpected file %q", b)
+	}
+	writeFile(t, p, "[")
+	if err := UpdateFile(p, "x", "v"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestUnsealFromFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, p, `{"name":"app","db":{"password":"SEALED:pw","host":"db"},"keys":["SEALED:k0"]}`)
+
+	var out, errb bytes.Buffer
+	if code := UnsealFromFile(p, nil, fakeUnseal, true, &out, &errb); code != 0 {
+		t.Fatalf("unseal json failed: %s", errb.String())
+	}
+	doc, err := Parse(out.Bytes())
+	if err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if s := doc.Strings(); s["db.password"] != "pw" || s["keys.0"] != "k0" || s["name"] != "app" {
+		t.Fatalf("unexpected document %s", out.String())
+	}
+
+	out.Reset()
+	if code := UnsealFromFile(p, []string{"db.password"}, fakeUnseal, false, &out, &errb); code != 0 || out.String() != "db.password=pw" {
+		t.Fatalf("unexpected env output %q (code %d, %s)", out.String(), code, errb.String())
+	}
+
+	errb.Reset()
+	if code := UnsealFromFile(p, []string{"db.nope"}, fakeUnseal, false, &out, &errb); code != 2 || !strings.Contains(errb.String(), "db.nope") {
+		t.Fatalf("expected missing path exit 2, got %d %q", code, errb.String())
+	}
+	if code := UnsealFromFile(p, []string{"name"}, fakeUnseal, false, &out, &errb); code != 1 {
+		t.Fatalf("expected unseal error exit 1, got %d", code)
+	}
+	if code := UnsealFromFile(filepath.Join(t.TempDir(), "missing.json"), nil, fakeUnseal, false, &out, &errb); code != 1 {
+		t.Fatalf("expected read error")
+	}
+	writeFile(t, p, "{")
+	if code := UnsealFromFile(p, nil, fakeUnseal, false, &out, &errb); code != 1 {
+		t.Fatalf("expected parse error")
+	}
+}
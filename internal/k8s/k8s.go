@@ -0,0 +1,199 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8s renders Kubernetes manifests from env files, to ease moving a
+// compose deployment that uses ojster to a cluster.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// Manifest kinds accepted by ExportFromFile.
+const (
+	// KindSecret is a v1 Secret holding the decrypted values.
+	KindSecret = "secret"
+	// KindExternalSecret is an external-secrets.io ExternalSecret that maps
+	// each key to the same key in a secret store; no values are included.
+	KindExternalSecret = "external-secret"
+	// KindSealed is a v1 Secret without data whose annotations carry the
+	// sealed values, for an in-cluster ojster decrypter to fill in.
+	KindSealed = "sealed"
+)
+
+// AnnotationPrefix prefixes the per-key annotations of a KindSealed Secret.
+const AnnotationPrefix = "ojster/"
+
+var (
+	// dnsNameRe is the RFC 1123 subdomain form Kubernetes requires for object names.
+	dnsNameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+	// dataKeyRe is the form Kubernetes requires for Secret data keys.
+	dataKeyRe = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	// annotationNameRe is the form of the name part of an annotation key.
+	annotationNameRe = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+)
+
+// ExportOptions controls ExportFromFile.
+type ExportOptions struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Store names the SecretStore an ExternalSecret reads from.
+	Store string
+}
+
+func (o ExportOptions) validate() error {
+	if !dnsNameRe.MatchString(o.Name) {
+		return fmt.Errorf("invalid name %q: must be a lowercase RFC 1123 subdomain", o.Name)
+	}
+	if o.Namespace != "" && !dnsNameRe.MatchString(o.Namespace) {
+		return fmt.Errorf("invalid namespace %q", o.Namespace)
+	}
+	switch o.Kind {
+	case KindSecret, KindSealed:
+	case KindExternalSecret:
+		if o.Store == "" {
+			return errors.New("an external-secret requires a secret store name")
+		}
+		if !dnsNameRe.MatchString(o.Store) {
+			return fmt.Errorf("invalid secret store name %q", o.Store)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q (want %s, %s or %s)", o.Kind, KindSecret, KindExternalSecret, KindSealed)
+	}
+	return nil
+}
+
+// ExportFromFile renders a manifest of opts.Kind for the given keys of the env
+// file at inPath (all sealed keys if keys is empty) and writes it to outw.
+// Only KindSecret decrypts, using the private key at privPath. Returns an exit
+// code (2 for missing keys) and writes errors to errw.
+func ExportFromFile(inPath, privPath string, keys []string, opts ExportOptions, outw io.Writer, errw io.Writer) int {
+	if err := opts.validate(); err != nil {
+		fmt.Fprintln(errw, err)
+		return 2
+	}
+	envMap, err := env.ParseEnvFile(inPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", inPath, err))
+		return 1
+	}
+	keys, missing := env.SelectKeys(envMap, keys, pqc.IsSealed)
+	if len(missing) > 0 {
+		fmt.Fprintf(errw, "missing keys in %s: %s\n", inPath, strings.Join(missing, ", "))
+		return 2
+	}
+	if len(keys) == 0 {
+		fmt.Fprintf(errw, "no sealed keys found in %s\n", inPath)
+		return 1
+	}
+	for _, k := range keys {
+		if !dataKeyRe.MatchString(k) {
+			fmt.Fprintf(errw, "key %q is not a valid Kubernetes Secret key\n", k)
+			return 1
+		}
+	}
+
+	var manifest string
+	switch opts.Kind {
+	case KindSecret:
+		decrypted, err := pqc.UnsealMap(context.Background(), envMap, privPath, keys)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		manifest = Secret(opts.Name, opts.Namespace, decrypted, keys)
+	case KindSealed:
+		for _, k := range keys {
+			if !pqc.IsSealed(envMap[k]) {
+				fmt.Fprintf(errw, "value for %s does not appear to be sealed\n", k)
+				return 1
+			}
+			if !annotationNameRe.MatchString(k) {
+				fmt.Fprintf(errw, "key %q cannot be used in an annotation name\n", k)
+				return 1
+			}
+		}
+		manifest = SealedSecret(opts.Name, opts.Namespace, envMap, keys)
+	case KindExternalSecret:
+		manifest = ExternalSecret(opts.Name, opts.Namespace, opts.Store, keys)
+	}
+	_, _ = io.WriteString(outw, manifest)
+	return 0
+}
+
+// Secret renders an Opaque v1 Secret with the given keys of values.
+func Secret(name, namespace string, values map[string]string, keys []string) string {
+	var b strings.Builder
+	writeHeader(&b, "v1", "Secret", name, namespace)
+	b.WriteString("type: Opaque\ndata:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  %s: %s\n", k, base64.StdEncoding.EncodeToString([]byte(values[k])))
+	}
+	return b.String()
+}
+
+// SealedSecret renders a v1 Secret without data whose annotations hold the
+// sealed values of keys, named AnnotationPrefix+KEY.
+func SealedSecret(name, namespace string, sealed map[string]string, keys []string) string {
+	var b strings.Builder
+	writeHeader(&b, "v1", "Secret", name, namespace)
+	// writeHeader ends inside metadata, so annotations nest there.
+	b.WriteString("  annotations:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "    %s: %s\n", AnnotationPrefix+k, quote(sealed[k]))
+	}
+	b.WriteString("type: Opaque\n")
+	return b.String()
+}
+
+// ExternalSecret renders an external-secrets.io ExternalSecret reading each
+// key from the same key in the named SecretStore into a Secret called name.
+func ExternalSecret(name, namespace, store string, keys []string) string {
+	var b strings.Builder
+	writeHeader(&b, "external-secrets.io/v1beta1", "ExternalSecret", name, namespace)
+	b.WriteString("spec:\n  refreshInterval: 1h\n  secretStoreRef:\n")
+	fmt.Fprintf(&b, "    name: %s\n    kind: SecretStore\n  target:\n    name: %s\n  data:\n", store, name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "    - secretKey: %s\n      remoteRef:\n        key: %s\n", k, k)
+	}
+	return b.String()
+}
+
+func writeHeader(b *strings.Builder, apiVersion, kind, name, namespace string) {
+	fmt.Fprintf(b, "apiVersion: %s\nkind: %s\nmetadata:\n  name: %s\n", apiVersion, kind, name)
+	if namespace != "" {
+		fmt.Fprintf(b, "  namespace: %s\n", namespace)
+	}
+}
+
+// quote renders s as a YAML double-quoted scalar.
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
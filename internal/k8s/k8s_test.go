@@ -0,0 +1,140 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// sealedEnv creates a keypair and an env file with DB_PASSWORD sealed and PLAIN unsealed.
+func sealedEnv(t *testing.T) (envPath, priv string) {
+	t.Helper()
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	envPath = filepath.Join(td, ".env")
+	var out, errb bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &out, &errb); code != 0 {
+		t.Fatalf("keypair failed: %s", errb.String())
+	}
+	if err := os.WriteFile(envPath, []byte("PLAIN=x\n"), 0o600); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+	if code := pqc.SealWithPlaintext(pub, envPath, "DB_PASSWORD", []byte("hunter2"), &out, &errb); code != 0 {
+		t.Fatalf("seal failed: %s", errb.String())
+	}
+	return envPath, priv
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   ExportFromFile()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestExportFromFile_Secret(t *testing.T) {
+	envPath, priv := sealedEnv(t)
+	var out, errb bytes.Buffer
+	opts := ExportOptions{Kind: KindSecret, Name: "api", Namespace: "prod"}
+	if code := ExportFromFile(envPath, priv, nil, opts, &out, &errb); code != 0 {
+		t.Fatalf("export failed: %s", errb.String())
+	}
+	want := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: api\n  namespace: prod\ntype: Opaque\ndata:\n" +
+		"  DB_PASSWORD: " + base64.StdEncoding.EncodeToString([]byte("hunter2")) + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected manifest:\n%s", out.String())
+	}
+}
+
+func TestExportFromFile_SealedAndExternal(t *testing.T) {
+	envPath, priv := sealedEnv(t)
+	var out, errb bytes.Buffer
+	// sealed needs no private key
+	if code := ExportFromFile(envPath, filepath.Join(t.TempDir(), "none"), nil, ExportOptions{Kind: KindSealed, Name: "api"}, &out, &errb); code != 0 {
+		t.Fatalf("sealed export failed: %s", errb.String())
+	}
+	if !strings.Contains(out.String(), "  annotations:\n    ojster/DB_PASSWORD: \"OJSTER-1:") || strings.Contains(out.String(), "\ndata:") {
+		t.Fatalf("unexpected sealed manifest:\n%s", out.String())
+	}
+
+	out.Reset()
+	opts := ExportOptions{Kind: KindExternalSecret, Name: "api", Store: "vault"}
+	if code := ExportFromFile(envPath, priv, []string{"DB_PASSWORD"}, opts, &out, &errb); code != 0 {
+		t.Fatalf("external export failed: %s", errb.String())
+	}
+	for _, want := range []string{"kind: ExternalSecret", "    name: vault\n", "    - secretKey: DB_PASSWORD\n      remoteRef:\n        key: DB_PASSWORD\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "OJSTER-1:") {
+		t.Fatalf("external secret must not carry values")
+	}
+}
+
+func TestExportFromFile_Errors(t *testing.T) {
+	envPath, priv := sealedEnv(t)
+	cases := []struct {
+		name string
+		keys []string
+		opts ExportOptions
+		code int
+		want string
+	}{
+		{"bad name", nil, ExportOptions{Kind: KindSecret, Name: "Bad_Name"}, 2, "invalid name"},
+		{"bad namespace", nil, ExportOptions{Kind: KindSecret, Name: "a", Namespace: "-x"}, 2, "invalid namespace"},
+		{"bad kind", nil, ExportOptions{Kind: "configmap", Name: "a"}, 2, "unknown kind"},
+		{"no store", nil, ExportOptions{Kind: KindExternalSecret, Name: "a"}, 2, "secret store"},
+		{"bad store", nil, ExportOptions{Kind: KindExternalSecret, Name: "a", Store: "A B"}, 2, "invalid secret store"},
+		{"missing key", []string{"NOPE"}, ExportOptions{Kind: KindSecret, Name: "a"}, 2, "NOPE"},
+		{"plain as sealed", []string{"PLAIN"}, ExportOptions{Kind: KindSealed, Name: "a"}, 1, "does not appear to be sealed"},
+		{"plain as secret", []string{"PLAIN"}, ExportOptions{Kind: KindSecret, Name: "a"}, 1, "sealed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var out, errb bytes.Buffer
+			if code := ExportFromFile(envPath, priv, c.keys, c.opts, &out, &errb); code != c.code || !strings.Contains(errb.String(), c.want) {
+				t.Fatalf("expected code %d with %q, got %d %q", c.code, c.want, code, errb.String())
+			}
+		})
+	}
+
+	var out, errb bytes.Buffer
+	empty := filepath.Join(t.TempDir(), "empty.env")
+	_ = os.WriteFile(empty, []byte("A=1\n"), 0o600)
+	if code := ExportFromFile(empty, priv, nil, ExportOptions{Kind: KindSecret, Name: "a"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "no sealed keys") {
+		t.Fatalf("expected no sealed keys error, got %q", errb.String())
+	}
+	_ = os.WriteFile(empty, []byte("_X=OJSTER-1:a:b\n"), 0o600)
+	errb.Reset()
+	if code := ExportFromFile(empty, priv, nil, ExportOptions{Kind: KindSealed, Name: "a"}, &out, &errb); code != 1 || !strings.Contains(errb.String(), "annotation") {
+		t.Fatalf("expected annotation name error, got %q", errb.String())
+	}
+	if code := ExportFromFile(t.TempDir(), priv, nil, ExportOptions{Kind: KindSecret, Name: "a"}, &out, &errb); code != 1 {
+		t.Fatalf("expected read error for directory")
+	}
+}
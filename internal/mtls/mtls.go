@@ -0,0 +1,73 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mtls builds mutual TLS configurations from PEM files for the
+// optional TCP transport between client and server.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ServerConfig presents certFile/keyFile and requires clients to present a
+// certificate signed by a CA in caFile.
+func ServerConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, pool, err := load(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// ClientConfig presents certFile/keyFile and only trusts servers whose
+// certificate is signed by a CA in caFile.
+func ClientConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, pool, err := load(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
+
+func load(certFile, keyFile, caFile string) (tls.Certificate, *x509.CertPool, error) {
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return tls.Certificate{}, nil, errors.New("mutual TLS requires a certificate, a key and a CA file")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to load TLS key pair %s/%s: %w", certFile, keyFile, err)
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to read CA file %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return tls.Certificate{}, nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+	}
+	return cert, pool, nil
+}
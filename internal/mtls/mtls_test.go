@@ -0,0 +1,202 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+type pki struct {
+	ca, serverCert, serverKey, clientCert, clientKey string
+}
+
+// writePKI creates a CA and a server and client certificate signed by it.
+func writePKI(t *testing.T) pki {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(ca): %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	write := func(name, typ string, der []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return p
+	}
+	leaf := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate(%s): %v", name, err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalECPrivateKey: %v", err)
+		}
+		return write(name+".crt", "CERTIFICATE", der), write(name+".key", "EC PRIVATE KEY", keyDER)
+	}
+
+	p := pki{ca: write("ca.crt", "CERTIFICATE", caDER)}
+	p.serverCert, p.serverKey = leaf("server", 2, x509.ExtKeyUsageServerAuth)
+	p.clientCert, p.clientKey = leaf("client", 3, x509.ExtKeyUsageClientAuth)
+	return p
+}
+
+// handshake accepts one connection on a listener using server and dials it
+// with client, returning the error seen by the client after the handshake.
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, _ = c.Write([]byte("ok"))
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// With TLS 1.3 a rejected client certificate surfaces on the first read.
+	_, err = io.ReadAll(conn)
+	return err
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   ServerConfig / ClientConfig
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestConfigs_MutualHandshake(t *testing.T) {
+	p := writePKI(t)
+	srv, err := ServerConfig(p.serverCert, p.serverKey, p.ca)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	if srv.ClientAuth != tls.RequireAndVerifyClientCert || srv.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("unexpected server config: auth=%v min=%x", srv.ClientAuth, srv.MinVersion)
+	}
+	cli, err := ClientConfig(p.clientCert, p.clientKey, p.ca)
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	if err := handshake(t, srv, cli); err != nil {
+		t.Fatalf("expected handshake to succeed, got %v", err)
+	}
+}
+
+func TestConfigs_ClientWithoutCertificateRejected(t *testing.T) {
+	p := writePKI(t)
+	srv, err := ServerConfig(p.serverCert, p.serverKey, p.ca)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+	cli, err := ClientConfig(p.clientCert, p.clientKey, p.ca)
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	cli.Certificates = nil
+	if err := handshake(t, srv, cli); err == nil {
+		t.Fatalf("expected handshake without client certificate to fail")
+	}
+}
+
+func TestConfigs_Errors(t *testing.T) {
+	p := writePKI(t)
+	notPEM := filepath.Join(t.TempDir(), "bogus.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cases := []struct {
+		name            string
+		cert, key, ca   string
+		wantErrContains string
+	}{
+		{"missing files", "", p.clientKey, p.ca, "requires a certificate"},
+		{"bad key pair", p.clientCert, p.serverKey, p.ca, "failed to load TLS key pair"},
+		{"missing ca", p.clientCert, p.clientKey, filepath.Join(t.TempDir(), "nope"), "failed to read CA file"},
+		{"empty ca", p.clientCert, p.clientKey, notPEM, "no certificates found"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ServerConfig(tc.cert, tc.key, tc.ca); err == nil || !strings.Contains(err.Error(), tc.wantErrContains) {
+				t.Fatalf("ServerConfig: expected error containing %q, got %v", tc.wantErrContains, err)
+			}
+			if _, err := ClientConfig(tc.cert, tc.key, tc.ca); err == nil || !strings.Contains(err.Error(), tc.wantErrContains) {
+				t.Fatalf("ClientConfig: expected error containing %q, got %v", tc.wantErrContains, err)
+			}
+		})
+	}
+}
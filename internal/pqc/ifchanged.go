@@ -0,0 +1,141 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// ML-KEM encapsulation is randomized, so sealing the same plaintext twice
+// never yields the same value. SealIfChanged keeps the existing value when it
+// already holds the plaintext, so re-running a seal script leaves git clean.
+//
+// With the private key at hand the old value is simply decrypted and
+// compared. Without it, the comparison uses a commitment stored in a comment
+// directly above the entry:
+//
+//	# ojster-commitment: hmac-sha256:<salt>:<hash>
+//	KEY=OJSTER-1:...
+//
+// hash is HMAC-SHA256 keyed with salt over SHA-256(sealed value) || plaintext.
+// Binding it to the sealed value makes a commitment left behind by a plain
+// seal harmless: it no longer verifies and the value is re-sealed. The hash
+// is fast, so it only hides high-entropy secrets; a slow KDF would not save
+// a guessable one either, and made every `seal --if-changed` run pay for it.
+// Commitments in the older pbkdf2-sha256:i=N:<salt>:<hash> format, where hash
+// is PBKDF2-HMAC-SHA256(plaintext, salt || SHA-256(sealed value)), still
+// verify and are replaced on the next seal.
+const CommitmentPrefix = "# ojster-commitment: "
+
+const (
+	commitAlgorithm       = "hmac-sha256"
+	legacyCommitAlgorithm = "pbkdf2-sha256"
+	commitSaltSize        = 16
+	commitHashSize        = 32
+)
+
+// SealIfChanged seals plaintext under keyName in the env file at outPath
+// unless the entry already decrypts (with the private key at privPath, when
+// that file exists) or commits to the same plaintext.
+func SealIfChanged(pubPath, privPath, outPath, keyName string, plaintext []byte, outw io.Writer, errw io.Writer) int {
+	opts := SealOptions{IfChanged: true, PrivPath: privPath}
+	return SealEntries(pubPath, outPath, []SealEntry{{Key: keyName, Plaintext: plaintext}}, opts, outw, errw)
+}
+
+// unchanged reports whether the existing entry for key already holds plaintext.
+func unchanged(doc *env.Document, dk *mlkem.DecapsulationKey768, key string, plaintext []byte) (bool, error) {
+	old, ok, err := doc.Get(key)
+	if err != nil || !ok || !IsSealed(old) {
+		return false, err
+	}
+	if dk != nil {
+		return sealedEquals(dk, old, plaintext), nil
+	}
+	c, ok := doc.Annotation(key, CommitmentPrefix)
+	return ok && verifyCommitment(c, old, plaintext), nil
+}
+
+// sealedEquals reports whether sealed decrypts to plaintext. A value sealed
+// to another key simply counts as different.
+func sealedEquals(dk *mlkem.DecapsulationKey768, sealed string, plaintext []byte) bool {
+	ct, blob, err := DecodeSealed(sealed)
+	if err != nil {
+		return false
+	}
+	got, err := OpenBytes(dk, ct, blob)
+	if err != nil {
+		return false
+	}
+	defer clear(got)
+	return subtle.ConstantTimeCompare(got, plaintext) == 1
+}
+
+func newCommitment(sealed string, plaintext []byte) (string, error) {
+	salt := make([]byte, commitSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	hash := commitHash(salt, sealed, plaintext)
+	return fmt.Sprintf("%s:%s:%s", commitAlgorithm, enc.EncodeToString(salt), enc.EncodeToString(hash)), nil
+}
+
+// verifyCommitment reports whether c commits to plaintext for this sealed
+// value. Malformed commitments never verify.
+func verifyCommitment(c, sealed string, plaintext []byte) bool {
+	parts := strings.Split(strings.TrimSpace(c), ":")
+	n := len(parts)
+	if n < 3 {
+		return false
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[n-2])
+	want, err2 := base64.RawStdEncoding.DecodeString(parts[n-1])
+	if err := errors.Join(err1, err2); err != nil || len(salt) != commitSaltSize || len(want) != commitHashSize {
+		return false
+	}
+	switch {
+	case n == 3 && parts[0] == commitAlgorithm:
+		return hmac.Equal(commitHash(salt, sealed, plaintext), want)
+	case n == 4 && parts[0] == legacyCommitAlgorithm:
+		params, err := parseKDFParams(parts[1])
+		if err != nil {
+			return false
+		}
+		bound := sha256.Sum256([]byte(sealed))
+		got, err := deriveKey(plaintext, bytes.Join([][]byte{salt, bound[:]}, nil), params, commitHashSize)
+		return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+	}
+	return false
+}
+
+func commitHash(salt []byte, sealed string, plaintext []byte) []byte {
+	bound := sha256.Sum256([]byte(sealed))
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(bound[:])
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+func runSealIfChanged(t *testing.T, pubPath, privPath, outPath, key string, plaintext []byte) string {
+	t.Helper()
+	var outBuf, errBuf bytes.Buffer
+	if code := SealIfChanged(pubPath, privPath, outPath, key, plaintext, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("SealIfChanged exit %d: %s", code, errBuf.String())
+	}
+	return outBuf.String()
+}
+
+func unsealOne(t *testing.T, envPath, privPath, key string) string {
+	t.Helper()
+	m, err := env.ParseEnvFile(envPath)
+	if err != nil {
+		t.Fatalf("parse %s: %v", envPath, err)
+	}
+	out, err := UnsealMap(context.Background(), m, privPath, []string{key})
+	if err != nil {
+		t.Fatalf("unseal: %v", err)
+	}
+	return out[key]
+}
+
+func TestSealIfChanged_DecryptCompare(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	writeFile(t, envPath, []byte("# keep\nOTHER=1\n"), 0o644)
+
+	if out := runSealIfChanged(t, pub, priv, envPath, "K", []byte("v1")); !strings.HasPrefix(out, "Wrote K") {
+		t.Fatalf("first seal should write, got %q", out)
+	}
+	before := readTrim(t, envPath)
+	if strings.Contains(before, CommitmentPrefix) {
+		t.Fatalf("no commitment expected when the private key is available:\n%s", before)
+	}
+	if out := runSealIfChanged(t, pub, priv, envPath, "K", []byte("v1")); out != "K unchanged in "+envPath+"\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if got := readTrim(t, envPath); got != before {
+		t.Fatalf("unchanged secret rewrote the file:\n%s\n---\n%s", before, got)
+	}
+	runSealIfChanged(t, pub, priv, envPath, "K", []byte("v2"))
+	if got := readTrim(t, envPath); got == before {
+		t.Fatalf("changed secret was not re-sealed")
+	}
+	if got := unsealOne(t, envPath, priv, "K"); got != "v2" {
+		t.Fatalf("unsealed %q, want v2", got)
+	}
+}
+
+func TestSealIfChanged_Commitment(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	noPriv := priv + ".absent"
+
+	runSealIfChanged(t, pub, noPriv, envPath, "K", []byte("v1"))
+	before := readTrim(t, envPath)
+	if !strings.HasPrefix(before, CommitmentPrefix+"hmac-sha256:") {
+		t.Fatalf("expected a commitment above the entry:\n%s", before)
+	}
+	if out := runSealIfChanged(t, pub, noPriv, envPath, "K", []byte("v1")); !strings.Contains(out, "unchanged") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if got := readTrim(t, envPath); got != before {
+		t.Fatalf("unchanged secret rewrote the file")
+	}
+
+	// A plain seal leaves the commitment stale; it must not verify.
+	if code, errOut := runSeal(t, pub, envPath, "K", []byte("v2")); code != 0 {
+		t.Fatalf("seal exit %d: %s", code, errOut)
+	}
+	if out := runSealIfChanged(t, pub, noPriv, envPath, "K", []byte("v1")); !strings.HasPrefix(out, "Wrote K") {
+		t.Fatalf("stale commitment was trusted: %q", out)
+	}
+	if got := unsealOne(t, envPath, priv, "K"); got != "v1" {
+		t.Fatalf("unsealed %q, want v1", got)
+	}
+
+	// With the private key the commitment is dropped again.
+	runSealIfChanged(t, pub, priv, envPath, "K", []byte("v3"))
+	if b, _ := os.ReadFile(envPath); bytes.Contains(b, []byte(CommitmentPrefix)) {
+		t.Fatalf("commitment should be removed:\n%s", b)
+	}
+}
+
+func TestVerifyCommitment_Legacy(t *testing.T) {
+	// A pbkdf2-sha256 commitment written by an earlier release.
+	sealed := "OJSTER-1:a:b"
+	salt := bytes.Repeat([]byte{7}, commitSaltSize)
+	bound := sha256.Sum256([]byte(sealed))
+	hash, err := deriveKey([]byte("x"), append(salt, bound[:]...), testKDF, commitHashSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawStdEncoding
+	c := "pbkdf2-sha256:" + testKDF.String() + ":" + enc.EncodeToString(salt) + ":" + enc.EncodeToString(hash)
+	if !verifyCommitment(c, sealed, []byte("x")) {
+		t.Fatalf("legacy commitment %q did not verify", c)
+	}
+	if verifyCommitment(c, sealed, []byte("y")) || verifyCommitment(c, sealed+"c", []byte("x")) {
+		t.Fatal("legacy commitment verified for another value")
+	}
+}
+
+func TestVerifyCommitment_Malformed(t *testing.T) {
+	for _, c := range []string{"", "pbkdf2-sha256", "hmac-sha256:AAAA", "hmac-sha256:i=1:AAAAAAAAAAAAAAAAAAAAAA:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "scrypt:i=1:AAAA:AAAA", "pbkdf2-sha256:i=1:!!:AAAA", "pbkdf2-sha256:i=0:AAAAAAAAAAAAAAAAAAAAAA:AAAA"} {
+		if verifyCommitment(c, "OJSTER-1:a:b", []byte("x")) {
+			t.Fatalf("malformed commitment %q verified", c)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"crypto/mlkem"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// KeyCache keeps parsed private keys by path, so a server does not read and
+// parse the key file for every request. A key is loaded again when its file
+// changes: a different file (as after an atomic rename or a Kubernetes
+// secret update), size or modification time. Checking that costs one stat.
+type KeyCache struct {
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	dk *mlkem.DecapsulationKey768
+	fi os.FileInfo
+}
+
+// NewKeyCache returns an empty KeyCache.
+func NewKeyCache() *KeyCache {
+	return &KeyCache{keys: map[string]cachedKey{}}
+}
+
+// Load returns the private key at privPath, from the cache when the file has
+// not changed since it was read. Errors are those of LoadDecapsulationKey;
+// a key that fails to load is dropped from the cache.
+func (c *KeyCache) Load(privPath string) (*mlkem.DecapsulationKey768, error) {
+	fi, err := os.Stat(privPath)
+	if err != nil {
+		c.forget(privPath)
+		return nil, fmt.Errorf("failed to read private key file %s: %w", privPath, err)
+	}
+	c.mu.Lock()
+	cached, ok := c.keys[privPath]
+	c.mu.Unlock()
+	if ok && os.SameFile(cached.fi, fi) && cached.fi.Size() == fi.Size() && cached.fi.ModTime().Equal(fi.ModTime()) {
+		return cached.dk, nil
+	}
+
+	dk, err := LoadDecapsulationKey(privPath)
+	if err != nil {
+		c.forget(privPath)
+		return nil, err
+	}
+	c.mu.Lock()
+	c.keys[privPath] = cachedKey{dk: dk, fi: fi}
+	c.mu.Unlock()
+	return dk, nil
+}
+
+func (c *KeyCache) forget(privPath string) {
+	c.mu.Lock()
+	delete(c.keys, privPath)
+	c.mu.Unlock()
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyCache_ReloadsChangedFile(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: code=%d", code)
+	}
+	c := NewKeyCache()
+	first, err := c.Load(priv)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if again, err := c.Load(priv); err != nil || again != first {
+		t.Fatalf("unchanged key file was loaded again (%v)", err)
+	}
+
+	// Replace the key the way secret mounts do: a new file renamed over it.
+	newPriv, newPub := filepath.Join(td, "new.key"), filepath.Join(td, "new.pub")
+	if code := KeypairWithPaths(newPriv, newPub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: code=%d", code)
+	}
+	if err := os.Rename(newPriv, priv); err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Load(priv)
+	if err != nil || second == first {
+		t.Fatalf("replaced key file was not reloaded (%v)", err)
+	}
+	sealed, err := Seal(newPub, []byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := UnsealMapWithKey(context.Background(), map[string]string{"K": sealed}, second, nil); err != nil || got["K"] != "v" {
+		t.Fatalf("reloaded key does not decrypt: %v %v", got, err)
+	}
+
+	if err := os.Remove(priv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Load(priv); err == nil {
+		t.Fatal("expected an error for a removed key file")
+	}
+}
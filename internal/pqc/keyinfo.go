@@ -0,0 +1,238 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/mlkem"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/memlock"
+)
+
+// Bare base64 keys carry metadata as leading "# Name: value" lines, the
+// same names PEM keys use as headers.
+
+// splitKeyComments separates the leading comment lines of a key file from
+// the key itself; body is a subslice of data.
+func splitKeyComments(data []byte) (headers map[string]string, body []byte) {
+	body = data
+	for {
+		trimmed := bytes.TrimLeft(body, " \t\r\n")
+		if !bytes.HasPrefix(trimmed, []byte("#")) {
+			return headers, body
+		}
+		line, rest, _ := bytes.Cut(trimmed, []byte("\n"))
+		if k, v, ok := strings.Cut(string(line[1:]), ":"); ok {
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		body = rest
+	}
+}
+
+// commentLines renders headers as the comment lines of a bare key file.
+func commentLines(headers map[string]string) []byte {
+	var b bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		fmt.Fprintf(&b, "# %s: %s\n", k, headers[k])
+	}
+	return b.Bytes()
+}
+
+// encodePublicKey renders a public key file: a PEM block with headers, or
+// bare base64 preceded by the headers as comments when there is a Comment.
+func encodePublicKey(pub []byte, headers map[string]string, pemOut bool) []byte {
+	if pemOut {
+		return pem.EncodeToMemory(&pem.Block{Type: pemPublicType, Headers: headers, Bytes: pub})
+	}
+	b64 := []byte(base64.StdEncoding.EncodeToString(pub) + "\n")
+	if headers["Comment"] == "" {
+		return b64
+	}
+	return slices.Concat(commentLines(headers), b64)
+}
+
+// PublicKeyFromPrivate re-derives the public key file for the private key at
+// privPath, in the encoding of that file and with its Created and Comment
+// metadata, so a lost public key file can be restored as it was.
+func PublicKeyFromPrivate(privPath string) (pubFile []byte, fingerprint string, err error) {
+	info, err := InspectKeyFile(privPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Kind == KindPublic {
+		return nil, "", fmt.Errorf("%s is a public key, not a private key", privPath)
+	}
+	dk, err := LoadDecapsulationKey(privPath)
+	if err != nil {
+		return nil, "", err
+	}
+	ek := dk.EncapsulationKey()
+	headers := map[string]string{"Algorithm": Algorithm}
+	for k, v := range map[string]string{"Created": info.Created, "Comment": info.Comment} {
+		if v != "" {
+			headers[k] = v
+		}
+	}
+	return encodePublicKey(ek.Bytes(), headers, info.Encoding == "PEM"), Fingerprint(ek), nil
+}
+
+// Fingerprint identifies a public key in the style of OpenSSH:
+// "SHA256:" and the unpadded base64 SHA-256 of the encapsulation key.
+func Fingerprint(ek *mlkem.EncapsulationKey768) string {
+	sum := sha256.Sum256(ek.Bytes())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// keyProofLabel keeps key proofs apart from any other MAC of a shared key.
+const keyProofLabel = "ojster key proof v1"
+
+// KeyChallenge encapsulates a fresh shared key to ek. Only the holder of the
+// private key can answer ct with KeyProof; want is the answer to expect.
+func KeyChallenge(ek *mlkem.EncapsulationKey768) (ct, want []byte) {
+	shared, ct := ek.Encapsulate()
+	defer clear(shared)
+	return ct, keyProofMAC(shared, ct)
+}
+
+// KeyProof answers the KeyChallenge ciphertext ct with dk: an HMAC-SHA256 of
+// ct keyed with the decapsulated shared key. ML-KEM decapsulation does not
+// fail for a wrong key; it yields a shared key that gives a wrong answer.
+func KeyProof(dk *mlkem.DecapsulationKey768, ct []byte) ([]byte, error) {
+	shared, err := dk.Decapsulate(ct)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(shared)
+	return keyProofMAC(shared, ct), nil
+}
+
+func keyProofMAC(shared, ct []byte) []byte {
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(keyProofLabel))
+	mac.Write(ct)
+	return mac.Sum(nil)
+}
+
+// Key kinds reported by InspectKeyFile.
+const (
+	KindPrivate          = "private key"
+	KindEncryptedPrivate = "encrypted private key"
+	KindPublic           = "public key"
+)
+
+// KeyInfo describes a key file without unlocking it.
+type KeyInfo struct {
+	Kind      string
+	Encoding  string // "PEM" or "base64"
+	Algorithm string
+	// Fingerprint is that of the public key; it is left empty for an
+	// encrypted private key, whose public half needs the passphrase.
+	Fingerprint string
+	// KDF names the key derivation of an encrypted private key.
+	KDF     string
+	Created string
+	Comment string
+}
+
+// InspectKeyFile reads a private or public key file of any supported
+// encoding and returns its metadata.
+func InspectKeyFile(path string) (KeyInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KeyInfo{}, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+	_ = memlock.Lock(data)
+	defer memlock.Wipe(data)
+
+	headers, body := splitKeyComments(data)
+	info := KeyInfo{Encoding: "base64", Algorithm: Algorithm}
+	block, err := decodeKeyPEM(body, path, "")
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	var raw []byte
+	switch {
+	case block != nil:
+		_ = memlock.Lock(block.Bytes)
+		defer memlock.Wipe(block.Bytes)
+		info.Encoding, headers, raw = "PEM", block.Headers, block.Bytes
+		switch block.Type {
+		case pemPrivateType:
+			info.Kind = KindPrivate
+		case pemPublicType:
+			info.Kind = KindPublic
+		case pemEncryptedType:
+			info.Kind = KindEncryptedPrivate
+			info.KDF = block.Headers["KDF"] + " " + block.Headers["KDF-Params"]
+		}
+	case bytes.HasPrefix(bytes.TrimSpace(body), []byte(WrappedKeyPrefix)):
+		params, _, _, err := splitWrapped(body)
+		if err != nil {
+			return KeyInfo{}, fmt.Errorf("%s: %w", path, err)
+		}
+		info.Kind, info.KDF = KindEncryptedPrivate, KDF+" "+params
+	default:
+		raw = make([]byte, base64.StdEncoding.DecodedLen(len(body)))
+		_ = memlock.Lock(raw)
+		defer memlock.Wipe(raw)
+		n, err := base64.StdEncoding.Decode(raw, bytes.TrimSpace(body))
+		if err != nil {
+			return KeyInfo{}, fmt.Errorf("invalid base64 key in %s: %w", path, err)
+		}
+		raw = raw[:n]
+		switch n {
+		case mlkem.SeedSize:
+			info.Kind = KindPrivate
+		case mlkem.EncapsulationKeySize768:
+			info.Kind = KindPublic
+		case expandedDecapsulationKeySize768:
+			return KeyInfo{}, expandedKeyError(path)
+		default:
+			return KeyInfo{}, fmt.Errorf("%s is not an ojster key (%d bytes)", path, n)
+		}
+	}
+
+	switch info.Kind {
+	case KindPrivate:
+		dk, err := mlkem.NewDecapsulationKey768(raw)
+		if err != nil {
+			return KeyInfo{}, fmt.Errorf("invalid private key in %s: %w", path, err)
+		}
+		info.Fingerprint = Fingerprint(dk.EncapsulationKey())
+	case KindPublic:
+		ek, err := mlkem.NewEncapsulationKey768(raw)
+		if err != nil {
+			return KeyInfo{}, fmt.Errorf("invalid public key in %s: %w", path, err)
+		}
+		info.Fingerprint = Fingerprint(ek)
+	}
+	if alg := headers["Algorithm"]; alg != "" {
+		info.Algorithm = alg
+	}
+	info.Created, info.Comment = headers["Created"], headers["Comment"]
+	return info, nil
+}
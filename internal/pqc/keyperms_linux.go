@@ -0,0 +1,40 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package pqc
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// CheckKeyPerms mirrors OpenSSH's rule for private keys: the file at privPath
+// must not be accessible by group or others, and must be owned by the current
+// user or root.
+func CheckKeyPerms(privPath string) error {
+	fi, err := os.Stat(privPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat private key file %s: %w", privPath, err)
+	}
+	if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+		return fmt.Errorf("permissions %04o for private key file %s are too open; it must not be accessible by group or others (chmod 600)", perm, privPath)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Uid != 0 && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("private key file %s is owned by uid %d, not by the current user or root", privPath, st.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package pqc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckKeyPerms(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.key"), filepath.Join(td, "pub.key")
+	var outBuf, errBuf bytes.Buffer
+	if code := KeypairWithPaths(priv, pub, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("keypair failed: %s", errBuf.String())
+	}
+	if err := CheckKeyPerms(priv); err != nil {
+		t.Fatalf("0600 key rejected: %v", err)
+	}
+	if _, err := LoadDecapsulationKey(priv); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if err := os.Chmod(priv, 0o640); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := CheckKeyPerms(priv); err == nil || !strings.Contains(err.Error(), "permissions 0640") {
+		t.Fatalf("expected permissions error, got %v", err)
+	}
+	if _, err := LoadDecapsulationKey(priv); err == nil || !strings.Contains(err.Error(), "too open") {
+		t.Fatalf("expected load to refuse the key, got %v", err)
+	}
+	InsecureKeyPerms = true
+	_, err := LoadDecapsulationKey(priv)
+	InsecureKeyPerms = false
+	if err != nil {
+		t.Fatalf("load with InsecureKeyPerms failed: %v", err)
+	}
+
+	if err := CheckKeyPerms(filepath.Join(td, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+
+	if os.Geteuid() == 0 {
+		if err := os.Chmod(priv, 0o600); err != nil {
+			t.Fatalf("chmod: %v", err)
+		}
+		if err := os.Chown(priv, 65534, 65534); err != nil {
+			t.Fatalf("chown: %v", err)
+		}
+		if err := CheckKeyPerms(priv); err == nil || !strings.Contains(err.Error(), "owned by uid 65534") {
+			t.Fatalf("expected owner error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// MigrateOptions controls Migrate.
+type MigrateOptions struct {
+	// DryRun reports the values that would be rewritten without writing.
+	DryRun bool
+}
+
+// migrateTo is the prefix of the format Migrate writes. It must be a
+// version no earlier release reads as another format.
+const migrateTo = FramedPrefix
+
+// Migrate rewrites the OJSTER-1 values of the env file at envPath in the
+// format of migrateTo, keeping their encoding. Each is decrypted with the
+// private key at privPath and sealed again to its public half. Other
+// entries, comments and the layout of the file are kept. A commitment above
+// a migrated entry is replaced, as it is bound to the old value. A value in
+// a format this build does not know is refused, and nothing is written
+// unless every value could be migrated.
+func Migrate(privPath, envPath string, opts MigrateOptions, outw io.Writer, errw io.Writer) int {
+	fi, err := os.Stat(envPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", envPath, err))
+		return 1
+	}
+	doc, err := env.LoadDocument(envPath)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", envPath, err))
+		return 1
+	}
+	dk, code := loadDecapsulationKey(privPath, errw)
+	if code != 0 {
+		return code
+	}
+	ek := dk.EncapsulationKey()
+
+	var report strings.Builder
+	migrated := 0
+	seen := make(map[string]bool)
+	for _, k := range doc.Keys() {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		old, _, err := doc.Get(k)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", envPath, err))
+			return 1
+		}
+		if !IsSealed(old) || framed(old) {
+			continue
+		}
+		prefix := sealedPrefix(old)
+		if err := checkFormat(prefix); err != nil {
+			fmt.Fprintf(errw, "cannot migrate %s: %v\n", k, err)
+			return 1
+		}
+		plaintext, msg := decryptValue(k, old, dk)
+		if msg != "" {
+			fmt.Fprintln(errw, msg)
+			return 1
+		}
+		migrated++
+		if opts.DryRun {
+			fmt.Fprintf(&report, "Would migrate %s in %s\n", k, envPath)
+			continue
+		}
+
+		ct, blob, err := SealBytes(ek, []byte(plaintext))
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		mlkemB64, _, _ := ParseSealed(old)
+		encoding := EncodingBase64
+		if sealedEncoding(mlkemB64) == base64.RawURLEncoding {
+			encoding = EncodingBase64URL
+		}
+		sealed := BuildSealedWithOptions(ct, blob, SealOptions{Frame: true, Encoding: encoding})
+		if !strings.HasPrefix(sealed, migrateTo) {
+			fmt.Fprintf(errw, "cannot migrate %s: the new value is not in the %s format\n", k, strings.TrimSuffix(migrateTo, ":"))
+			return 1
+		}
+		if _, ok := doc.Annotation(k, CommitmentPrefix); ok {
+			commitment, err := newCommitment(sealed, []byte(plaintext))
+			if err != nil {
+				fmt.Fprintln(errw, err)
+				return 1
+			}
+			doc.SetAnnotation(k, CommitmentPrefix, commitment)
+		}
+		doc.Set(k, sealed)
+		fmt.Fprintf(&report, "Migrated %s in %s\n", k, envPath)
+	}
+
+	if migrated == 0 {
+		fmt.Fprintf(outw, "Nothing to migrate in %s\n", envPath)
+		return 0
+	}
+	if !opts.DryRun {
+		if err := doc.WriteFile(envPath, fi.Mode().Perm()); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to update env file %s: %w", envPath, err))
+			return 1
+		}
+	}
+	_, _ = io.WriteString(outw, report.String())
+	return 0
+}
+
+// framed reports whether a sealed value is in the format Migrate writes.
+func framed(val string) bool {
+	return strings.HasPrefix(val, migrateTo)
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// legacySealed seals plaintext in the format without a frame.
+func legacySealed(t *testing.T, pubPath, plaintext string) string {
+	t.Helper()
+	ek, err := LoadEncapsulationKey(pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, blob, err := SealBytes(ek, []byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(ct) + sep + base64.StdEncoding.EncodeToString(blob)
+}
+
+func TestMigrate(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	current, err := SealWithOptions(pub, []byte("current"), SealOptions{Frame: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "# database\nDB_PASSWORD=" + legacySealed(t, pub, "db") + "\n" +
+		"PLAIN=1\n" +
+		CommitmentPrefix + "stale\n" +
+		"API_KEY='" + legacySealed(t, pub, "api") + "'\n" +
+		"CURRENT=" + current + "\n"
+	writeFile(t, envPath, []byte(content), 0o640)
+
+	var out, errb bytes.Buffer
+	if code := Migrate(priv, envPath, MigrateOptions{DryRun: true}, &out, &errb); code != 0 {
+		t.Fatalf("dry run exit %d: %s", code, errb.String())
+	}
+	if got := readTrim(t, envPath); got != strings.TrimSpace(content) {
+		t.Fatal("dry run changed the file")
+	}
+	if want := "Would migrate DB_PASSWORD in " + envPath + "\nWould migrate API_KEY in " + envPath + "\n"; out.String() != want {
+		t.Fatalf("dry run output %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if code := Migrate(priv, envPath, MigrateOptions{}, &out, &errb); code != 0 {
+		t.Fatalf("migrate exit %d: %s", code, errb.String())
+	}
+	doc, err := env.LoadDocument(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"DB_PASSWORD", "API_KEY", "CURRENT"} {
+		if v, _, _ := doc.Get(k); !framed(v) {
+			t.Errorf("%s was not migrated: %q", k, v)
+		}
+	}
+	if v, _, _ := doc.Get("CURRENT"); v != current {
+		t.Error("a value in the current format was rewritten")
+	}
+	if c, ok := doc.Annotation("API_KEY", CommitmentPrefix); !ok || c == "stale" {
+		t.Errorf("commitment was not replaced: %q", c)
+	}
+	for k, want := range map[string]string{"DB_PASSWORD": "db", "API_KEY": "api", "CURRENT": "current"} {
+		if got := unsealOne(t, envPath, priv, k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if b, _ := os.ReadFile(envPath); !bytes.HasPrefix(b, []byte("# database\nDB_PASSWORD=")) || !bytes.Contains(b, []byte("\nPLAIN=1\n")) {
+		t.Fatalf("layout was not kept:\n%s", b)
+	}
+	if fi, _ := os.Stat(envPath); fi.Mode().Perm() != 0o640 {
+		t.Errorf("mode changed to %04o", fi.Mode().Perm())
+	}
+
+	out.Reset()
+	if code := Migrate(priv, envPath, MigrateOptions{}, &out, &errb); code != 0 || !strings.HasPrefix(out.String(), "Nothing to migrate") {
+		t.Fatalf("second run: exit %d, output %q", code, out.String())
+	}
+}
+
+func TestMigrate_WrongKeyWritesNothing(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	otherPriv, otherPub := filepath.Join(t.TempDir(), "priv"), filepath.Join(t.TempDir(), "pub")
+	if code := KeypairWithPaths(otherPriv, otherPub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	content := "A=" + legacySealed(t, pub, "a") + "\nB=" + legacySealed(t, otherPub, "b") + "\n"
+	writeFile(t, envPath, []byte(content), 0o644)
+
+	var errb bytes.Buffer
+	if code := Migrate(priv, envPath, MigrateOptions{}, io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "for B") {
+		t.Fatalf("expected failure for B, got exit %d: %s", code, errb.String())
+	}
+	if got := readTrim(t, envPath); got != strings.TrimSpace(content) {
+		t.Fatal("a failed migration changed the file")
+	}
+}
+
+func TestMigrate_UnknownFormatWritesNothing(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	later := "OJSTER-3:" + strings.TrimPrefix(legacySealed(t, pub, "b"), Prefix)
+	content := "A=" + legacySealed(t, pub, "a") + "\nB=" + later + "\n"
+	writeFile(t, envPath, []byte(content), 0o644)
+
+	var errb bytes.Buffer
+	if code := Migrate(priv, envPath, MigrateOptions{}, io.Discard, &errb); code != 1 || !strings.Contains(errb.String(), "cannot migrate B: unsupported format OJSTER-3") {
+		t.Fatalf("expected refusal for B, got exit %d: %s", code, errb.String())
+	}
+	if got := readTrim(t, envPath); got != strings.TrimSpace(content) {
+		t.Fatal("a refused migration changed the file")
+	}
+}
+
+func TestMigrate_KeepsEncoding(t *testing.T) {
+	priv, pub, envPath := tmpPaths(t)
+	if code := KeypairWithPaths(priv, pub, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("keypair exit %d", code)
+	}
+	ek, err := LoadEncapsulationKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, blob, err := SealBytes(ek, []byte("url"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, envPath, []byte("URL="+BuildSealedWithOptions(ct, blob, SealOptions{Encoding: EncodingBase64URL})+"\n"), 0o644)
+
+	if code := Migrate(priv, envPath, MigrateOptions{}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("migrate exit %d", code)
+	}
+	doc, err := env.LoadDocument(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, _ := doc.Get("URL")
+	if !strings.HasPrefix(v, FramedPrefix) || strings.ContainsAny(v, "+/=") {
+		t.Fatalf("URL was not migrated to base64url OJSTER-2: %q", v)
+	}
+	if got := unsealOne(t, envPath, priv, "URL"); got != "url" {
+		t.Fatalf("URL = %q", got)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keys can also be written as PEM blocks, which carry their algorithm and
+// creation date and survive being pasted into tickets and chat. Readers
+// accept either form.
+const (
+	pemPrivateType   = "OJSTER PRIVATE KEY"
+	pemEncryptedType = "OJSTER ENCRYPTED PRIVATE KEY"
+	pemPublicType    = "OJSTER PUBLIC KEY"
+
+	// Algorithm is the Algorithm header of PEM-armored keys.
+	Algorithm = "ML-KEM-768"
+)
+
+// keyHeaders are the PEM headers written with both halves of a keypair.
+func keyHeaders(created time.Time, comment string) map[string]string {
+	h := map[string]string{
+		"Algorithm": Algorithm,
+		"Created":   created.UTC().Format(time.RFC3339),
+	}
+	if comment != "" {
+		h["Comment"] = comment
+	}
+	return h
+}
+
+// decodeKeyPEM returns the PEM block in a key file, or nil if the file holds
+// a bare key. The block must be of type want (or pemEncryptedType for a
+// private key; any ojster key type if want is empty) and of the ML-KEM-768
+// algorithm.
+func decodeKeyPEM(data []byte, path, want string) (*pem.Block, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("-----BEGIN ")) {
+		return nil, nil
+	}
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("malformed PEM block in %s", path)
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("trailing data after PEM block in %s", path)
+	}
+	switch {
+	case want == "" && block.Type != pemPrivateType && block.Type != pemEncryptedType && block.Type != pemPublicType:
+		return nil, fmt.Errorf("%s holds a %q PEM block, not an ojster key", path, block.Type)
+	case want != "" && block.Type != want && !(want == pemPrivateType && block.Type == pemEncryptedType):
+		return nil, fmt.Errorf("%s holds a %q PEM block, want %q", path, block.Type, want)
+	}
+	if alg, ok := block.Headers["Algorithm"]; ok && alg != Algorithm {
+		return nil, fmt.Errorf("unsupported key algorithm %q in %s", alg, path)
+	}
+	return block, nil
+}
+
+// wrappedToPEM armors a WrapPrivateKey result, moving the KDF parameters and
+// salt into headers.
+func wrappedToPEM(wrapped []byte, headers map[string]string) ([]byte, error) {
+	params, salt, blobB64, err := splitWrapped(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := base64.StdEncoding.DecodeString(blobB64)
+	if err != nil {
+		return nil, err
+	}
+	h := map[string]string{"KDF": KDF, "KDF-Params": params, "Salt": salt}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemEncryptedType, Headers: h, Bytes: blob}), nil
+}
+
+// wrappedFromPEM reverses wrappedToPEM.
+func wrappedFromPEM(block *pem.Block, path string) ([]byte, error) {
+	if kdf := block.Headers["KDF"]; !strings.EqualFold(kdf, KDF) {
+		return nil, fmt.Errorf("unsupported KDF %q in %s", kdf, path)
+	}
+	params, salt := block.Headers["KDF-Params"], block.Headers["Salt"]
+	if params == "" || salt == "" {
+		return nil, fmt.Errorf("missing KDF-Params or Salt header in %s", path)
+	}
+	return []byte(WrappedKeyPrefix + params + sep + salt + sep + base64.StdEncoding.EncodeToString(block.Bytes)), nil
+}
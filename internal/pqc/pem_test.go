@@ -0,0 +1,96 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeypairWithOptions_PEM(t *testing.T) {
+	t.Cleanup(func() { PassphraseFunc = nil })
+	PassphraseFunc = func(string) ([]byte, error) { return []byte("pw"), nil }
+
+	for _, passphrase := range [][]byte{nil, []byte("pw")} {
+		priv, pub, envFile := tmpPaths(t)
+		var outBuf, errBuf bytes.Buffer
+		opts := KeypairOptions{PEM: true, Passphrase: passphrase, KDF: testKDF}
+		if code := KeypairWithOptions(priv, pub, opts, &outBuf, &errBuf); code != 0 {
+			t.Fatalf("KeypairWithOptions failed: code=%d stderr=%q", code, errBuf.String())
+		}
+		wantType := pemPrivateType
+		if passphrase != nil {
+			wantType = pemEncryptedType
+		}
+		privPEM, pubPEM := readTrim(t, priv), readTrim(t, pub)
+		if !strings.HasPrefix(privPEM, "-----BEGIN "+wantType+"-----\nAlgorithm: ML-KEM-768\nCreated: ") {
+			t.Fatalf("unexpected private key file:\n%s", privPEM)
+		}
+		if !strings.HasPrefix(pubPEM, "-----BEGIN "+pemPublicType+"-----\nAlgorithm: ML-KEM-768\n") {
+			t.Fatalf("unexpected public key file:\n%s", pubPEM)
+		}
+		if !strings.Contains(outBuf.String(), "PUBLIC (PEM):\n"+pubPEM) {
+			t.Fatalf("summary does not show the PEM public key: %q", outBuf.String())
+		}
+		if passphrase != nil && (!IsWrappedKey([]byte(privPEM)) || !strings.Contains(privPEM, "KDF-Params: i=1")) {
+			t.Fatalf("expected KDF headers:\n%s", privPEM)
+		}
+
+		if code, stderr := runSeal(t, pub, envFile, "K", []byte("v")); code != 0 {
+			t.Fatalf("seal failed: %s", stderr)
+		}
+		outBuf.Reset()
+		if code := UnsealFromFiles(context.Background(), envFile, priv, nil, false, &outBuf, &errBuf); code != 0 || outBuf.String() != "K=v" {
+			t.Fatalf("unseal: code=%d out=%q stderr=%q", code, outBuf.String(), errBuf.String())
+		}
+	}
+}
+
+func TestDecodeKeyPEM_Errors(t *testing.T) {
+	priv, pub, _ := tmpPaths(t)
+	var outBuf, errBuf bytes.Buffer
+	if code := KeypairWithOptions(priv, pub, KeypairOptions{PEM: true}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithOptions failed: %s", errBuf.String())
+	}
+	pubPEM, _ := os.ReadFile(pub)
+	privPEM, _ := os.ReadFile(priv)
+
+	// A public key where a private key is expected, and the other way round.
+	if err := os.Chmod(pub, 0o600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if _, err := LoadDecapsulationKey(pub); err == nil || !strings.Contains(err.Error(), `"OJSTER PUBLIC KEY" PEM block`) {
+		t.Fatalf("expected block type error, got %v", err)
+	}
+	if _, err := LoadEncapsulationKey(priv); err == nil || !strings.Contains(err.Error(), `"OJSTER PRIVATE KEY" PEM block`) {
+		t.Fatalf("expected block type error, got %v", err)
+	}
+
+	writeFile(t, pub, bytes.Replace(pubPEM, []byte("ML-KEM-768"), []byte("ML-KEM-1024"), 1), 0o644)
+	if _, err := LoadEncapsulationKey(pub); err == nil || !strings.Contains(err.Error(), "unsupported key algorithm") {
+		t.Fatalf("expected algorithm error, got %v", err)
+	}
+	writeFile(t, pub, pubPEM[:len(pubPEM)/2], 0o644)
+	if _, err := LoadEncapsulationKey(pub); err == nil || !strings.Contains(err.Error(), "malformed PEM block") {
+		t.Fatalf("expected malformed error, got %v", err)
+	}
+	writeFile(t, priv, append(privPEM, privPEM...), 0o600)
+	if _, err := LoadDecapsulationKey(priv); err == nil || !strings.Contains(err.Error(), "trailing data") {
+		t.Fatalf("expected trailing data error, got %v", err)
+	}
+}
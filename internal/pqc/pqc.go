@@ -16,23 +16,32 @@ package pqc
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/mlkem"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ojster/ojster/internal/util/env"
 	"github.com/ojster/ojster/internal/util/file"
+	"github.com/ojster/ojster/internal/util/memlock"
 )
 
 const (
@@ -40,29 +49,133 @@ const (
 	defaultPrivFile = "ojster_priv.key"
 	defaultPubFile  = "ojster_pub.key"
 	Prefix          = "OJSTER-1:"
+	FramedPrefix    = "OJSTER-2:"
 	sep             = ":" // separator between mlkem ciphertext and gcm blob
 )
 
+// KEM is the key encapsulation mechanism of key pairs and sealed values.
+const KEM = "ML-KEM-768"
+
+// Cipher is the data cipher that encrypts a value under the shared key.
+const Cipher = "AES-256-GCM"
+
+// Formats lists the sealed-value formats this build opens, the one new
+// values are sealed in by default first.
+var Formats = []string{
+	strings.TrimSuffix(Prefix, ":"),
+	strings.TrimSuffix(FramedPrefix, ":") + " (with frame, seal --frame)",
+}
+
+// versionRe matches the prefix of every sealed-value format, including
+// formats of later releases that this build cannot open.
+var versionRe = regexp.MustCompile(`^OJSTER-[0-9]+:`)
+
 var (
 	ErrConfig      = errors.New("pqc: config error")
 	ErrUnseal      = errors.New("pqc: unseal error")
 	ErrMissingKeys = errors.New("pqc: missing keys")
 )
 
-// BuildSealed builds the canonical sealed string from the two binary parts.
+// In the OJSTER-2 format (FramedPrefix) the ML-KEM-768 ciphertext is
+// followed by a frame:
+//
+//	cipher ID (1 byte) || gcm blob length (4 bytes) || CRC-32C (4 bytes)
+//
+// The big-endian length tells how long the second part must be, so a value
+// cut short (for example by a line wrap when copying it) is reported with
+// the byte it ends at. The Castagnoli checksum covers the ciphertext, the
+// first two frame fields and the gcm blob, so other damage is found before
+// decapsulation. The cipher ID is 0, for AES-256-GCM; it leaves room for
+// another data cipher. OJSTER-1 values have no frame; the prefix tells
+// which is which, so releases that predate the frame report OJSTER-2 values
+// as not sealed instead of misreading them.
+const (
+	frameSize            = 1 + 4 + 4
+	framedCiphertextSize = mlkem.CiphertextSize768 + frameSize
+)
+
+var frameCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Encodings of the two parts of a sealed value.
+const (
+	// EncodingBase64 is standard base64 with padding, the default.
+	EncodingBase64 = "base64"
+	// EncodingBase64URL is unpadded URL-safe base64 (RFC 4648 section 5),
+	// for values embedded in URLs, YAML flow scalars and other places that
+	// mangle '+', '/' or '='.
+	EncodingBase64URL = "base64url"
+)
+
+// Encodings lists the encodings SealOptions.Encoding accepts. Decoding
+// detects the encoding of each value.
+var Encodings = []string{EncodingBase64, EncodingBase64URL}
+
+// sealedEncoding detects the encoding of a sealed value from its first
+// part. Unpadded URL-safe base64 of an ML-KEM ciphertext never has a length
+// that is a multiple of 4, so even a part without '-' or '_' is recognized.
+func sealedEncoding(mlkemB64 string) *base64.Encoding {
+	if strings.ContainsAny(mlkemB64, "-_") || len(mlkemB64)%4 != 0 {
+		return base64.RawURLEncoding
+	}
+	return base64.StdEncoding
+}
+
+// BuildSealed builds the canonical sealed string from the two binary parts,
+// in the OJSTER-1 format.
 func BuildSealed(mlkemCiphertext, gcmBlob []byte) string {
-	mlkemB64 := base64.StdEncoding.EncodeToString(mlkemCiphertext)
-	gcmB64 := base64.StdEncoding.EncodeToString(gcmBlob)
-	return Prefix + mlkemB64 + sep + gcmB64
+	return BuildSealedWithOptions(mlkemCiphertext, gcmBlob, SealOptions{})
+}
+
+// BuildSealedWithOptions is BuildSealed with options. With opts.Frame an
+// ML-KEM-768 ciphertext gets a frame and the value the FramedPrefix, and
+// opts.Encoding selects one of the Encodings.
+func BuildSealedWithOptions(mlkemCiphertext, gcmBlob []byte, opts SealOptions) string {
+	prefix := Prefix
+	if opts.Frame && len(mlkemCiphertext) == mlkem.CiphertextSize768 {
+		framed := make([]byte, 0, framedCiphertextSize)
+		framed = append(framed, mlkemCiphertext...)
+		framed = append(framed, 0) // AES-256-GCM
+		framed = binary.BigEndian.AppendUint32(framed, uint32(len(gcmBlob)))
+		sum := crc32.Update(crc32.Checksum(framed, frameCRCTable), frameCRCTable, gcmBlob)
+		mlkemCiphertext = binary.BigEndian.AppendUint32(framed, sum)
+		prefix = FramedPrefix
+	}
+	enc := base64.StdEncoding
+	if opts.Encoding == EncodingBase64URL {
+		enc = base64.RawURLEncoding
+	}
+	mlkemB64 := enc.EncodeToString(mlkemCiphertext)
+	gcmB64 := enc.EncodeToString(gcmBlob)
+	return prefix + mlkemB64 + sep + gcmB64
+}
+
+// sealedPrefix returns the format prefix of val, such as Prefix, or "" if
+// val does not start with one. It also returns prefixes of formats this
+// build cannot open; see checkFormat.
+func sealedPrefix(val string) string {
+	return versionRe.FindString(val)
+}
+
+// checkFormat returns an error for a format prefix this build cannot open.
+func checkFormat(prefix string) error {
+	if prefix == Prefix || prefix == FramedPrefix {
+		return nil
+	}
+	return fmt.Errorf("unsupported format %s; this version of ojster opens %s and %s", strings.TrimSuffix(prefix, ":"), strings.TrimSuffix(Prefix, ":"), strings.TrimSuffix(FramedPrefix, ":"))
 }
 
 // ParseSealed splits a sealed value into the two base64 parts and returns them.
-// It returns an error if the value doesn't start with Prefix or doesn't contain Sep.
+// It returns an error if the value doesn't start with Prefix or FramedPrefix
+// or doesn't contain Sep.
 func ParseSealed(val string) (mlkemB64 string, gcmB64 string, err error) {
-	if !strings.HasPrefix(val, Prefix) {
-		return "", "", fmt.Errorf("value does not start with %s", Prefix)
+	prefix := sealedPrefix(val)
+	if prefix == "" {
+		return "", "", fmt.Errorf("value does not start with %s or %s", Prefix, FramedPrefix)
+	}
+	if err := checkFormat(prefix); err != nil {
+		return "", "", err
 	}
-	payload := strings.TrimPrefix(val, Prefix)
+	payload := strings.TrimPrefix(val, prefix)
 	parts := strings.SplitN(payload, sep, 2)
 	if len(parts) != 2 {
 		return "", "", fmt.Errorf("sealed value malformed")
@@ -70,6 +183,58 @@ func ParseSealed(val string) (mlkemB64 string, gcmB64 string, err error) {
 	return parts[0], parts[1], nil
 }
 
+// DecodeSealed parses a sealed value, decodes both parts in either of the
+// Encodings and checks the frame of an OJSTER-2 value. It returns the parts
+// OpenBytes takes. Errors say where a truncated or line-wrapped value ends.
+func DecodeSealed(val string) (mlkemCiphertext, gcmBlob []byte, err error) {
+	if i := strings.IndexAny(val, " \t\r\n"); i >= 0 {
+		return nil, nil, fmt.Errorf("line-wrapped at byte %d", i)
+	}
+	mlkemB64, gcmB64, err := ParseSealed(val)
+	if err != nil {
+		if prefix := sealedPrefix(val); prefix != "" && checkFormat(prefix) == nil {
+			return nil, nil, fmt.Errorf("no %q separator; truncated at byte %d or not a sealed value", sep, len(val))
+		}
+		return nil, nil, err
+	}
+	enc := sealedEncoding(mlkemB64)
+	mlkemCiphertext, err = enc.DecodeString(mlkemB64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid base64 mlkem ciphertext: %v", err)
+	}
+	if !strings.HasPrefix(val, FramedPrefix) {
+		gcmBlob, err = enc.DecodeString(gcmB64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid base64 gcm blob: %v", err)
+		}
+		return mlkemCiphertext, gcmBlob, nil
+	}
+
+	if len(mlkemCiphertext) != framedCiphertextSize {
+		return nil, nil, fmt.Errorf("first part is %d bytes, want %d", len(mlkemCiphertext), framedCiphertextSize)
+	}
+	frame := mlkemCiphertext[mlkem.CiphertextSize768:]
+	want := len(val) - len(gcmB64) + enc.EncodedLen(int(binary.BigEndian.Uint32(frame[1:5])))
+	switch {
+	case len(val) < want:
+		return nil, nil, fmt.Errorf("truncated at byte %d of %d", len(val), want)
+	case len(val) > want:
+		return nil, nil, fmt.Errorf("%d unexpected bytes after byte %d", len(val)-want, want)
+	}
+	gcmBlob, err = enc.DecodeString(gcmB64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid base64 gcm blob: %v", err)
+	}
+	sum := crc32.Update(crc32.Checksum(mlkemCiphertext[:framedCiphertextSize-4], frameCRCTable), frameCRCTable, gcmBlob)
+	if sum != binary.BigEndian.Uint32(frame[5:]) {
+		return nil, nil, errors.New("checksum mismatch; the value was altered")
+	}
+	if frame[0] != 0 {
+		return nil, nil, fmt.Errorf("unknown data cipher 0x%02x", frame[0])
+	}
+	return mlkemCiphertext[:mlkem.CiphertextSize768], gcmBlob, nil
+}
+
 // IsSealed reports whether the logical (unquoted) value looks like a sealed value.
 func IsSealed(val string) bool {
 	// Accept optional single or double quotes around the whole value.
@@ -78,27 +243,36 @@ func IsSealed(val string) bool {
 			val = val[1 : len(val)-1]
 		}
 	}
-	if !strings.HasPrefix(val, Prefix) {
+	prefix := sealedPrefix(val)
+	if prefix == "" {
 		return false
 	}
-	payload := strings.TrimPrefix(val, Prefix)
+	payload := strings.TrimPrefix(val, prefix)
 	// quick check for separator presence
 	return strings.Contains(payload, sep)
 }
 
 // DefaultValueRegex returns the canonical regex string the client should use to detect sealed values.
-// It mirrors the previous defaultValueRegex but is generated from Prefix and a base64 character class.
+// It mirrors the previous defaultValueRegex but is generated from versionRe and a base64 character class,
+// so values in a format of a later release reach the server and fail there with a clear error.
 func DefaultValueRegex() string {
-	// base64 chars plus optional trailing '=' padding and optional surrounding single quotes
+	// base64 and base64url chars plus optional trailing '=' padding and optional surrounding single quotes
 	// allow the whole value optionally quoted with single quotes (client previously used ^'?(OJSTER-1:... )'?$)
 	// We keep the capturing group for backward compatibility with existing client code.
-	base64Class := `[A-Za-z0-9+/=]+`
-	return fmt.Sprintf(`^'?(%s%s%s)'?$`, regexp.QuoteMeta(Prefix), base64Class, regexp.QuoteMeta(sep)+base64Class)
+	base64Class := `[A-Za-z0-9+/=_-]+`
+	return fmt.Sprintf(`^'?(%s%s%s)'?$`, strings.TrimPrefix(versionRe.String(), "^"), base64Class, regexp.QuoteMeta(sep)+base64Class)
 }
 
 // DefaultValueRegexp compiles DefaultValueRegex.
 func DefaultValueRegexp() (*regexp.Regexp, error) { return regexp.Compile(DefaultValueRegex()) }
 
+// sealedValueRe is DefaultValueRegex without the anchors and quotes.
+var sealedValueRe = regexp.MustCompile(strings.TrimPrefix(versionRe.String(), "^") + `[A-Za-z0-9+/=_-]+` + regexp.QuoteMeta(sep) + `[A-Za-z0-9+/=_-]+`)
+
+// SealedValueRegexp matches sealed values anywhere in a string, such as a
+// log line, where DefaultValueRegex only matches a whole value.
+func SealedValueRegexp() *regexp.Regexp { return sealedValueRe }
+
 func DefaultPrivFile() string { return defaultPrivFile }
 func DefaultPubFile() string  { return defaultPubFile }
 
@@ -166,48 +340,154 @@ func decryptAESGCM(key, blob []byte) ([]byte, error) {
 // On success it writes a short summary to outw and returns 0.
 // On failure it writes an error message to errw and returns a non-zero exit code.
 func KeypairWithPaths(privPath, pubPath string, outw io.Writer, errw io.Writer) int {
-	dk, err := mlkem.GenerateKey768()
+	return KeypairWithOptions(privPath, pubPath, KeypairOptions{}, outw, errw)
+}
+
+// KeypairOptions controls optional KeypairWithOptions behaviour.
+type KeypairOptions struct {
+	// PrivOut receives the private key instead of a file at privPath, so it
+	// can be piped into a secret store without touching the filesystem.
+	// PrivOutName describes it in the summary.
+	PrivOut     io.Writer
+	PrivOutName string
+	// PubOut receives the public key instead of a file at pubPath.
+	PubOut io.Writer
+	// Passphrase, if set, wraps the private key with a key derived from it
+	// using KDF (DefaultKDFParams when zero); see WrapPrivateKey.
+	Passphrase []byte
+	KDF        KDFParams
+	// Comment labels both key files, together with the creation time.
+	Comment string
+	// PEM writes both keys as PEM blocks with Algorithm and Created headers
+	// instead of bare base64.
+	PEM bool
+	// Mnemonic also prints the seed as recovery words (see SeedFromMnemonic).
+	Mnemonic bool
+	// Seed restores the keypair with this 64-byte seed instead of
+	// generating a new one.
+	Seed []byte
+}
+
+// KeypairWithOptions is KeypairWithPaths with the outputs selected by opts.
+// The summary omits the base64 public key when it went to opts.PubOut.
+func KeypairWithOptions(privPath, pubPath string, opts KeypairOptions, outw io.Writer, errw io.Writer) int {
+	var dk *mlkem.DecapsulationKey768
+	var err error
+	if opts.Seed != nil {
+		dk, err = mlkem.NewDecapsulationKey768(opts.Seed)
+	} else {
+		dk, err = mlkem.GenerateKey768()
+	}
 	if err != nil {
 		fmt.Fprintln(errw, fmt.Errorf("failed to generate key: %w", err))
 		return 1
 	}
 	priv := dk.Bytes() // 64 bytes seed form (private)
-	ek := dk.EncapsulationKey()
-	pub := ek.Bytes() // public encapsulation key bytes
+	_ = memlock.Lock(priv)
+	defer memlock.Wipe(priv)
+	pub := dk.EncapsulationKey().Bytes() // public encapsulation key bytes
 
 	// Encode to base64 text
 	privB64 := []byte(base64.StdEncoding.EncodeToString(priv) + "\n")
-	pubB64Bytes := []byte(base64.StdEncoding.EncodeToString(pub) + "\n")
-
-	// Write private key atomically with 0600 permissions
-	if err := file.WriteFileAtomic(privPath, privB64, 0o600); err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("failed to write private key: %w", err))
+	_ = memlock.Lock(privB64)
+	defer memlock.Wipe(privB64)
+	if opts.Passphrase != nil {
+		kdf := opts.KDF
+		if kdf == (KDFParams{}) {
+			kdf = DefaultKDFParams
+		}
+		if privB64, err = WrapPrivateKey(priv, opts.Passphrase, kdf); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to wrap private key: %w", err))
+			return 1
+		}
+		opts.KDF = kdf
+	}
+	headers := keyHeaders(time.Now(), opts.Comment)
+	pubB64Bytes := encodePublicKey(pub, headers, opts.PEM)
+	if opts.PEM {
+		var armored []byte
+		if opts.Passphrase != nil {
+			if armored, err = wrappedToPEM(privB64, headers); err != nil {
+				fmt.Fprintln(errw, fmt.Errorf("failed to encode private key: %w", err))
+				return 1
+			}
+		} else {
+			armored = pem.EncodeToMemory(&pem.Block{Type: pemPrivateType, Headers: headers, Bytes: priv})
+			_ = memlock.Lock(armored)
+			defer memlock.Wipe(armored)
+		}
+		privB64 = armored
+	} else if opts.Comment != "" {
+		privB64 = slices.Concat(commentLines(headers), privB64)
+		_ = memlock.Lock(privB64)
+		defer memlock.Wipe(privB64)
+	}
+
+	// Files are written first: they can be removed again if a later step
+	// fails, while data written to a stream cannot be taken back.
+	var written []string
+	fail := func(err error) int {
+		for _, p := range written {
+			_ = os.Remove(p)
+		}
+		fmt.Fprintln(errw, err)
 		return 1
 	}
-
-	// Write public key atomically with 0644 permissions
-	if err := file.WriteFileAtomic(pubPath, pubB64Bytes, 0o644); err != nil {
-		_ = os.Remove(privPath)
-		fmt.Fprintln(errw, fmt.Errorf("failed to write public key: %w", err))
-		return 1
+	if opts.PrivOut == nil {
+		// Write private key atomically with 0600 permissions
+		if err := file.WriteFileAtomic(privPath, privB64, 0o600); err != nil {
+			return fail(fmt.Errorf("failed to write private key: %w", err))
+		}
+		written = append(written, privPath)
+	}
+	if opts.PubOut == nil {
+		// Write public key atomically with 0644 permissions
+		if err := file.WriteFileAtomic(pubPath, pubB64Bytes, 0o644); err != nil {
+			return fail(fmt.Errorf("failed to write public key: %w", err))
+		}
+		written = append(written, pubPath)
+	}
+	if opts.PrivOut != nil {
+		if _, err := opts.PrivOut.Write(privB64); err != nil {
+			return fail(fmt.Errorf("failed to write private key to %s: %w", opts.PrivOutName, err))
+		}
+	}
+	if opts.PubOut != nil {
+		if _, err := opts.PubOut.Write(pubB64Bytes); err != nil {
+			return fail(fmt.Errorf("failed to write public key: %w", err))
+		}
 	}
 
-	absPriv, _ := filepath.Abs(privPath)
-	absPub, _ := filepath.Abs(pubPath)
-
-	outMsg := fmt.Sprintf(
-		"Wrote private key to %s (mode 0600)\nWrote public key to %s (mode 0644)\n\nPUBLIC (base64):\n%s\n",
-		absPriv, absPub, strings.TrimSpace(string(pubB64Bytes)),
-	)
+	var summary strings.Builder
+	if opts.PrivOut != nil {
+		fmt.Fprintf(&summary, "Wrote private key to %s\n", opts.PrivOutName)
+	} else {
+		absPriv, _ := filepath.Abs(privPath)
+		fmt.Fprintf(&summary, "Wrote private key to %s (mode 0600)\n", absPriv)
+	}
+	if opts.Passphrase != nil {
+		fmt.Fprintf(&summary, "Private key is wrapped with a passphrase (%s %s)\n", KDF, opts.KDF)
+	}
+	if opts.PubOut != nil {
+		summary.WriteString("Wrote public key to standard output\n")
+	} else {
+		absPub, _ := filepath.Abs(pubPath)
+		encoding := "base64"
+		if opts.PEM {
+			encoding = "PEM"
+		}
+		fmt.Fprintf(&summary, "Wrote public key to %s (mode 0644)\n\nPUBLIC (%s):\n%s\n", absPub, encoding, strings.TrimSpace(string(pubB64Bytes)))
+	}
 
 	if outw != nil {
-		_, _ = io.WriteString(outw, outMsg)
+		_, _ = io.WriteString(outw, summary.String())
+		if opts.Mnemonic {
+			if err := writeMnemonic(outw, priv); err != nil {
+				fmt.Fprintln(errw, err)
+				return 1
+			}
+		}
 	}
-
-	// avoid unused var warnings in some build contexts
-	_ = ek
-	_ = pub
-
 	return 0
 }
 
@@ -215,81 +495,279 @@ func KeypairWithPaths(privPath, pubPath string, outw io.Writer, errw io.Writer)
 // writes the sealed value into outPath under keyName (via env.UpdateEnvFile), and
 // writes a short success message to outw. Returns an exit code and writes errors to errw.
 func SealWithPlaintext(pubPath, outPath, keyName string, plaintext []byte, outw io.Writer, errw io.Writer) int {
-	pubBytesRaw, err := os.ReadFile(pubPath)
+	sealed, err := Seal(pubPath, plaintext)
 	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("failed to read public key file %s: %w", pubPath, err))
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 
-	pubBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(pubBytesRaw)))
-	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("invalid base64 public key in %s: %w", pubPath, err))
+	if err := env.UpdateEnvFile(outPath, keyName, sealed); err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("failed to update env file %s: %w", outPath, err))
 		return 1
 	}
 
-	ek, err := mlkem.NewEncapsulationKey768(pubBytes)
-	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("invalid public key in %s: %w", pubPath, err))
-		return 1
+	if outw != nil {
+		_, _ = io.WriteString(outw, fmt.Sprintf("Wrote %s to %s\n", keyName, outPath))
 	}
+	return 0
+}
+
+// SealEntry is one key and its plaintext for SealEntries.
+type SealEntry struct {
+	Key       string
+	Plaintext []byte
+}
 
-	pt := make([]byte, len(plaintext))
-	copy(pt, plaintext)
+// SealOptions controls SealEntries.
+type SealOptions struct {
+	// IfChanged keeps entries that already hold the same plaintext (see
+	// SealIfChanged).
+	IfChanged bool
+	// PrivPath is the private key IfChanged compares with; when the file does
+	// not exist a commitment is stored instead.
+	PrivPath string
+	// Frame seals in the OJSTER-2 format, which reports truncated and
+	// altered values. Releases before it cannot open such values.
+	Frame bool
+	// Encoding is one of the Encodings; empty means EncodingBase64.
+	Encoding string
+}
 
-	sharedKey, mlkemCiphertext := ek.Encapsulate()
-	if len(sharedKey) != mlkem.SharedKeySize {
-		fmt.Fprintln(errw, fmt.Errorf("unexpected shared key size: %d", len(sharedKey)))
+// SealEntries seals every entry into the env file at outPath and writes the
+// file once, so a failure part-way leaves it untouched.
+func SealEntries(pubPath, outPath string, entries []SealEntry, opts SealOptions, outw io.Writer, errw io.Writer) int {
+	ek, err := LoadEncapsulationKey(pubPath)
+	if err != nil {
+		fmt.Fprintln(errw, err)
 		return 1
 	}
-
-	gcmBlob, err := encryptAESGCM(sharedKey, pt)
+	doc, err := env.LoadDocument(outPath)
 	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("encryption failed: %w", err))
+		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", outPath, err))
 		return 1
 	}
-
-	mlkemB64 := base64.StdEncoding.EncodeToString(mlkemCiphertext)
-	gcmB64 := base64.StdEncoding.EncodeToString(gcmBlob)
-	sealed := Prefix + mlkemB64 + sep + gcmB64
-
-	if err := env.UpdateEnvFile(outPath, keyName, sealed); err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("failed to update env file %s: %w", outPath, err))
-		return 1
+	var dk *mlkem.DecapsulationKey768
+	if opts.IfChanged {
+		if _, err := os.Stat(opts.PrivPath); err == nil {
+			if dk, err = LoadDecapsulationKey(opts.PrivPath); err != nil {
+				fmt.Fprintln(errw, err)
+				return 1
+			}
+		}
 	}
 
+	var report strings.Builder
+	written := 0
+	for _, e := range entries {
+		if opts.IfChanged {
+			same, err := unchanged(doc, dk, e.Key, e.Plaintext)
+			if err != nil {
+				fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", outPath, err))
+				return 1
+			}
+			if same {
+				fmt.Fprintf(&report, "%s unchanged in %s\n", e.Key, outPath)
+				continue
+			}
+		}
+		ct, blob, err := SealBytes(ek, e.Plaintext)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		sealed := BuildSealedWithOptions(ct, blob, opts)
+		doc.Set(e.Key, sealed)
+		if opts.IfChanged {
+			commitment := ""
+			if dk == nil {
+				if commitment, err = newCommitment(sealed, e.Plaintext); err != nil {
+					fmt.Fprintln(errw, err)
+					return 1
+				}
+			}
+			doc.SetAnnotation(e.Key, CommitmentPrefix, commitment)
+		}
+		fmt.Fprintf(&report, "Wrote %s to %s\n", e.Key, outPath)
+		written++
+	}
+	if written > 0 {
+		if err := doc.WriteFile(outPath, 0o644); err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to update env file %s: %w", outPath, err))
+			return 1
+		}
+	}
 	if outw != nil {
-		_, _ = io.WriteString(outw, fmt.Sprintf("Wrote %s to %s\n", keyName, outPath))
+		_, _ = io.WriteString(outw, report.String())
 	}
-	_ = ek
 	return 0
 }
 
-// loadDecapsulationKey reads privPath, base64-decodes it and returns a DecapsulationKey.
-// On error it writes the same error messages as before to errw and returns a non-zero exit code.
-func loadDecapsulationKey(privPath string, errw io.Writer) (*mlkem.DecapsulationKey768, int) {
+// Seal encrypts plaintext for the public key file at pubPath and returns the
+// sealed value string.
+func Seal(pubPath string, plaintext []byte) (string, error) {
+	return SealWithOptions(pubPath, plaintext, SealOptions{})
+}
+
+// SealWithOptions is Seal with the format options of opts.
+func SealWithOptions(pubPath string, plaintext []byte, opts SealOptions) (string, error) {
+	ek, err := LoadEncapsulationKey(pubPath)
+	if err != nil {
+		return "", err
+	}
+	mlkemCiphertext, gcmBlob, err := SealBytes(ek, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return BuildSealedWithOptions(mlkemCiphertext, gcmBlob, opts), nil
+}
+
+// LoadEncapsulationKey reads pubPath, base64-decodes it (or its PEM block) and
+// returns an EncapsulationKey.
+func LoadEncapsulationKey(pubPath string) (*mlkem.EncapsulationKey768, error) {
+	pubBytesRaw, err := os.ReadFile(pubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file %s: %w", pubPath, err)
+	}
+
+	_, pubBytesRaw = splitKeyComments(pubBytesRaw)
+	var pubBytes []byte
+	if block, err := decodeKeyPEM(pubBytesRaw, pubPath, pemPublicType); err != nil {
+		return nil, err
+	} else if block != nil {
+		pubBytes = block.Bytes
+	} else if pubBytes, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(pubBytesRaw))); err != nil {
+		return nil, fmt.Errorf("invalid base64 public key in %s: %w", pubPath, err)
+	}
+
+	ek, err := mlkem.NewEncapsulationKey768(pubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in %s: %w", pubPath, err)
+	}
+	return ek, nil
+}
+
+// InsecureKeyPerms skips CheckKeyPerms in LoadDecapsulationKey. Commands set
+// it for --insecure-key-perms after warning about the permissions once.
+var InsecureKeyPerms bool
+
+// LoadDecapsulationKey reads privPath, base64-decodes it (or its PEM block) and
+// returns a DecapsulationKey.
+// A passphrase-wrapped key is unlocked via PassphraseFunc. Unless InsecureKeyPerms is set, a key file that fails CheckKeyPerms is refused.
+func LoadDecapsulationKey(privPath string) (*mlkem.DecapsulationKey768, error) {
+	if !InsecureKeyPerms {
+		if err := CheckKeyPerms(privPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
 	privFileBytes, err := os.ReadFile(privPath)
 	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("failed to read private key file %s: %w", privPath, err))
-		return nil, 1
+		return nil, fmt.Errorf("failed to read private key file %s: %w", privPath, err)
 	}
-	privText := strings.TrimSpace(string(privFileBytes))
-	privBytes, err := base64.StdEncoding.DecodeString(privText)
+	_ = memlock.Lock(privFileBytes)
+	defer memlock.Wipe(privFileBytes)
+	_, privFileBytes = splitKeyComments(privFileBytes)
+	if bytes.HasPrefix(bytes.TrimSpace(privFileBytes), []byte(WrappedKeyPrefix)) {
+		return loadWrappedKey(privPath, privFileBytes)
+	}
+
+	var seed []byte
+	block, err := decodeKeyPEM(privFileBytes, privPath, pemPrivateType)
 	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("invalid base64 private key in %s: %w", privPath, err))
-		return nil, 1
+		return nil, err
+	}
+	if block != nil {
+		_ = memlock.Lock(block.Bytes)
+		defer memlock.Wipe(block.Bytes)
+		if block.Type == pemEncryptedType {
+			wrapped, err := wrappedFromPEM(block, privPath)
+			if err != nil {
+				return nil, err
+			}
+			return loadWrappedKey(privPath, wrapped)
+		}
+		seed = block.Bytes
+	} else {
+		privBytes := make([]byte, base64.StdEncoding.DecodedLen(len(privFileBytes)))
+		_ = memlock.Lock(privBytes)
+		defer memlock.Wipe(privBytes)
+		n, err := base64.StdEncoding.Decode(privBytes, bytes.TrimSpace(privFileBytes))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 private key in %s: %w", privPath, err)
+		}
+		seed = privBytes[:n]
+	}
+
+	if len(seed) == expandedDecapsulationKeySize768 {
+		return nil, expandedKeyError(privPath)
+	}
+	// The expanded key lives inside crypto/mlkem; only the buffers above are ours to lock.
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in %s: %w", privPath, err)
 	}
+	return dk, nil
+}
 
-	dk, err := mlkem.NewDecapsulationKey768(privBytes)
+// expandedDecapsulationKeySize768 is the length of the expanded ML-KEM-768
+// decapsulation key encoding of FIPS 203, which other ML-KEM tooling writes
+// instead of the 64-byte seed.
+const expandedDecapsulationKeySize768 = 2400
+
+// expandedKeyError explains why an expanded private key cannot be used:
+// crypto/mlkem only constructs keys from the seed, and the expanded encoding
+// does not contain the seed, so it cannot be converted either.
+func expandedKeyError(privPath string) error {
+	return fmt.Errorf("private key in %s is an expanded ML-KEM-768 decapsulation key (%d bytes); ojster needs the %d-byte seed it was generated from, and the seed cannot be recovered from the expanded form", privPath, expandedDecapsulationKeySize768, mlkem.SeedSize)
+}
+
+// loadDecapsulationKey wraps LoadDecapsulationKey in the writer/exit-code pattern.
+// On error it writes the error message to errw and returns a non-zero exit code.
+func loadDecapsulationKey(privPath string, errw io.Writer) (*mlkem.DecapsulationKey768, int) {
+	dk, err := LoadDecapsulationKey(privPath)
 	if err != nil {
-		fmt.Fprintln(errw, fmt.Errorf("invalid private key in %s: %w", privPath, err))
+		fmt.Fprintln(errw, err)
 		return nil, 1
 	}
 	return dk, 0
 }
 
+// SealBytes encapsulates a fresh shared key to ek and encrypts plaintext with it.
+// It returns the two binary parts that BuildSealed encodes.
+func SealBytes(ek *mlkem.EncapsulationKey768, plaintext []byte) (mlkemCiphertext, gcmBlob []byte, err error) {
+	sharedKey, mlkemCiphertext := ek.Encapsulate()
+	_ = memlock.Lock(sharedKey)
+	defer memlock.Wipe(sharedKey)
+	if len(sharedKey) != mlkem.SharedKeySize {
+		return nil, nil, fmt.Errorf("unexpected shared key size: %d", len(sharedKey))
+	}
+
+	gcmBlob, err = encryptAESGCM(sharedKey, plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	return mlkemCiphertext, gcmBlob, nil
+}
+
+// OpenBytes reverses SealBytes using dk.
+func OpenBytes(dk *mlkem.DecapsulationKey768, mlkemCiphertext, gcmBlob []byte) ([]byte, error) {
+	sharedKey, err := dk.Decapsulate(mlkemCiphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decapsulation failed: %v", err)
+	}
+	_ = memlock.Lock(sharedKey)
+	defer memlock.Wipe(sharedKey)
+	plaintext, err := decryptAESGCM(sharedKey, gcmBlob)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %v", err)
+	}
+	return plaintext, nil
+}
+
 // UnsealMap decrypts the provided envMap using the private key at privPath.
 // It returns the decrypted map or a sentinel error (ErrConfig, ErrUnseal, ErrMissingKeys).
-func UnsealMap(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+// Once ctx is done no further values are decrypted and the error wraps
+// ErrUnseal and the context's error.
+func UnsealMap(ctx context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
 	// capture stderr from loadDecapsulationKey
 	var errBuf bytes.Buffer
 	dk, code := loadDecapsulationKey(privPath, &errBuf)
@@ -298,11 +776,19 @@ func UnsealMap(envMap map[string]string, privPath string, keys []string) (map[st
 		return nil, fmt.Errorf("%w: %s", ErrConfig, strings.TrimSpace(errBuf.String()))
 	}
 
-	decrypted, _, code, msg := decryptCore(envMap, dk, keys, "<map input>")
+	return UnsealMapWithKey(ctx, envMap, dk, keys)
+}
+
+// UnsealMapWithKey is UnsealMap with an already loaded private key, such as
+// one from a KeyCache. It returns ErrUnseal or ErrMissingKeys on failure.
+func UnsealMapWithKey(ctx context.Context, envMap map[string]string, dk *mlkem.DecapsulationKey768, keys []string) (map[string]string, error) {
+	decrypted, _, code, msg := decryptCore(ctx, envMap, dk, keys, "<map input>")
 	if code != 0 {
-		switch code {
-		case 2:
+		switch {
+		case code == 2:
 			return nil, fmt.Errorf("%w: %s", ErrMissingKeys, msg)
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("%w: %w", ErrUnseal, context.Cause(ctx))
 		default:
 			return nil, fmt.Errorf("%w: %s", ErrUnseal, msg)
 		}
@@ -313,126 +799,218 @@ func UnsealMap(envMap map[string]string, privPath string, keys []string) (map[st
 // UnsealFromFiles reads the env file at inPath and the private key at privPath,
 // decapsulates and decrypts the requested keys (if keys is empty, all sealed keys).
 // On success it writes either JSON (if jsonOut) or newline-separated env entries to outw.
-// Returns an exit code and writes errors to errw.
-func UnsealFromFiles(inPath, privPath string, keys []string, jsonOut bool, outw io.Writer, errw io.Writer) int {
+// Returns an exit code and writes errors to errw. Decryption stops early,
+// with exit code 1, once ctx is done.
+func UnsealFromFiles(ctx context.Context, inPath, privPath string, keys []string, jsonOut bool, outw io.Writer, errw io.Writer) int {
+	return UnsealFromFilesWithOptions(ctx, inPath, privPath, keys, UnsealOptions{JSON: jsonOut}, outw, errw)
+}
+
+// UnsealOptions controls optional UnsealFromFilesWithOptions behaviour.
+type UnsealOptions struct {
+	// JSON writes a JSON object instead of env entries.
+	JSON bool
+	// Interpolate expands ${VAR} references after unsealing (docker compose
+	// semantics, see env.Interpolate). Decrypted values are never expanded
+	// themselves. Without explicit keys, entries that reference other
+	// variables are written alongside the sealed ones.
+	Interpolate bool
+	// Lookup resolves variables from outside the file when interpolating.
+	// Defaults to os.LookupEnv.
+	Lookup env.LookupFunc
+}
+
+// UnsealFromFilesWithOptions is UnsealFromFiles with additional options.
+func UnsealFromFilesWithOptions(ctx context.Context, inPath, privPath string, keys []string, opts UnsealOptions, outw io.Writer, errw io.Writer) int {
 	dk, code := loadDecapsulationKey(privPath, errw)
 	if code != 0 {
 		return code
 	}
 
-	// Parse env file into map of key->rawValue (logical unquoted value)
-	envMap, err := env.ParseEnvFile(inPath)
+	// Parse env file into ordered entries of key->rawValue (logical unquoted value)
+	entries, err := env.ParseEnvFileEntries(inPath)
 	if err != nil {
 		fmt.Fprintln(errw, fmt.Errorf("failed to read env file %s: %w", inPath, err))
 		return 1
 	}
+	envMap := env.EntriesMap(entries)
 
-	return unsealCore(envMap, dk, keys, jsonOut, outw, errw, inPath)
-}
+	if !opts.Interpolate {
+		return unsealCore(ctx, envMap, dk, keys, opts.JSON, outw, errw, inPath)
+	}
 
-// decryptCore performs the core selection/validation/decapsulation/decryption.
-// It returns the decrypted map, the resolved keys slice (in deterministic order),
-// an exit code, and an error message string (if non-zero code).
-func decryptCore(envMap map[string]string, dk *mlkem.DecapsulationKey768, keys []string, sourceDesc string) (map[string]string, []string, int, string) {
-	// If no keys provided, select all keys whose stored value starts with the sealed Prefix
+	// Interpolation needs every sealed value decrypted, since any entry may reference one.
+	decrypted, sealedKeys, code, msg := decryptCore(ctx, envMap, dk, nil, inPath)
+	if code != 0 {
+		fmt.Fprintln(errw, msg)
+		return code
+	}
+	var outKeys, missing []string
+	if len(keys) > 0 {
+		outKeys, missing = env.SelectKeys(envMap, keys, nil)
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(errw, "missing keys in %s: %s\n", inPath, strings.Join(missing, ", "))
+		return 2
+	}
 	if len(keys) == 0 {
-		for k, v := range envMap {
-			if strings.HasPrefix(v, Prefix) {
-				keys = append(keys, k)
-			}
+		seen := make(map[string]bool, len(sealedKeys))
+		outKeys = append(outKeys, sealedKeys...)
+		for _, k := range sealedKeys {
+			seen[k] = true
 		}
-		sort.Strings(keys)
-		if len(keys) == 0 {
-			// No sealed entries is not an error; return empty map and success.
-			return map[string]string{}, keys, 0, ""
+		for _, e := range entries {
+			if !seen[e.Key] && !e.Literal && env.HasReference(e.Value) {
+				seen[e.Key] = true
+				outKeys = append(outKeys, e.Key)
+			}
 		}
 	}
 
-	// Validate requested keys exist
-	missing := make([]string, 0)
-	for _, k := range keys {
-		if _, ok := envMap[k]; !ok {
-			missing = append(missing, k)
+	for i, e := range entries {
+		if v, ok := decrypted[e.Key]; ok && e.Value == envMap[e.Key] {
+			entries[i] = env.Entry{Key: e.Key, Value: v, Literal: true}
 		}
 	}
+	lookup := opts.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	resolved, err := env.Interpolate(entries, lookup)
+	if err != nil {
+		fmt.Fprintln(errw, fmt.Errorf("interpolation failed in %s: %w", inPath, err))
+		return 1
+	}
+
+	result := make(map[string]string, len(outKeys))
+	for _, k := range outKeys {
+		result[k] = resolved[k]
+	}
+	return writeUnsealed(result, outKeys, opts.JSON, outw)
+}
+
+// decryptCore performs the core selection/validation/decapsulation/decryption.
+// It returns the decrypted map, the resolved keys slice (in deterministic order),
+// an exit code, and an error message string (if non-zero code). Workers stop
+// taking keys once ctx is done, which fails the call with exit code 1.
+func decryptCore(ctx context.Context, envMap map[string]string, dk *mlkem.DecapsulationKey768, keys []string, sourceDesc string) (map[string]string, []string, int, string) {
+	// If no keys provided, select all keys whose stored value starts with a sealed-value prefix.
+	// Requested keys are validated to exist.
+	keys, missing := env.SelectKeys(envMap, keys, func(v string) bool { return sealedPrefix(v) != "" })
 	if len(missing) > 0 {
 		msg := fmt.Sprintf("missing keys in %s: %s", sourceDesc, strings.Join(missing, ", "))
 		return nil, nil, 2, msg
 	}
+	if len(keys) == 0 {
+		// No sealed entries is not an error; return empty map and success.
+		return map[string]string{}, keys, 0, ""
+	}
+
+	// Keys are decrypted by a bounded pool of workers, as decapsulation
+	// dominates for files with many sealed values. Results and the error
+	// reported (the first failing key in keys order) do not depend on
+	// scheduling.
+	plaintexts := make([]string, len(keys))
+	msgs := make([]string, len(keys))
+	var next atomic.Int64
+	var failedAt atomic.Int64
+	failedAt.Store(int64(len(keys)))
+	var wg sync.WaitGroup
+	for range min(unsealWorkers(), len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := next.Add(1) - 1
+				// Keys after a failure are skipped; earlier ones still run,
+				// so the first failure in order is the one reported.
+				if i >= failedAt.Load() || ctx.Err() != nil {
+					return
+				}
+				plaintexts[i], msgs[i] = decryptValue(keys[i], envMap[keys[i]], dk)
+				if msgs[i] == "" {
+					continue
+				}
+				for cur := failedAt.Load(); i < cur && !failedAt.CompareAndSwap(cur, i); {
+					cur = failedAt.Load()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if i := failedAt.Load(); i < int64(len(keys)) {
+		return nil, nil, 1, msgs[i]
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, 1, fmt.Sprintf("unsealing %s stopped: %v", sourceDesc, context.Cause(ctx))
+	}
 
-	// Collect decrypted values
 	decrypted := make(map[string]string, len(keys))
+	for i, k := range keys {
+		decrypted[k] = plaintexts[i]
+	}
+	// Return decrypted map and the resolved keys (in the order we processed them)
+	return decrypted, keys, 0, ""
+}
 
-	for _, k := range keys {
-		stored := envMap[k]
-		if !strings.HasPrefix(stored, Prefix) {
-			msg := fmt.Sprintf("value for %s does not appear to be sealed (missing Prefix)", k)
-			return nil, nil, 1, msg
-		}
-
-		mlkemB64, gcmB64, perr := ParseSealed(stored)
-		if perr != nil {
-			msg := fmt.Sprintf("sealed value for %s malformed", k)
-			return nil, nil, 1, msg
-		}
+// unsealWorkers returns how many keys decryptCore decrypts at once. It is a
+// var so tests can force the serial and parallel paths.
+var unsealWorkers = func() int { return runtime.GOMAXPROCS(0) }
 
-		mlkemCiphertext, err := base64.StdEncoding.DecodeString(mlkemB64)
-		if err != nil {
-			msg := fmt.Sprintf("invalid base64 mlkem ciphertext for %s: %v", k, err)
-			return nil, nil, 1, msg
-		}
-		gcmBlob, err := base64.StdEncoding.DecodeString(gcmB64)
-		if err != nil {
-			msg := fmt.Sprintf("invalid base64 gcm blob for %s: %v", k, err)
-			return nil, nil, 1, msg
-		}
-
-		sharedKey, err := dk.Decapsulate(mlkemCiphertext)
-		if err != nil {
-			msg := fmt.Sprintf("decapsulation failed for %s: %v", k, err)
-			return nil, nil, 1, msg
-		}
-		if len(sharedKey) != mlkem.SharedKeySize {
-			msg := fmt.Sprintf("unexpected shared key size for %s: %d", k, len(sharedKey))
-			return nil, nil, 1, msg
-		}
+// decryptValue decrypts the sealed value stored for k, returning the
+// plaintext or an error message naming k.
+func decryptValue(k, stored string, dk *mlkem.DecapsulationKey768) (string, string) {
+	prefix := sealedPrefix(stored)
+	if prefix == "" {
+		return "", fmt.Sprintf("value for %s does not appear to be sealed (missing Prefix)", k)
+	}
+	if err := checkFormat(prefix); err != nil {
+		return "", fmt.Sprintf("sealed value for %s: %v", k, err)
+	}
 
-		plaintext, err := decryptAESGCM(sharedKey, gcmBlob)
-		if err != nil {
-			msg := fmt.Sprintf("decryption failed for %s: %v", k, err)
-			return nil, nil, 1, msg
-		}
+	mlkemCiphertext, gcmBlob, err := DecodeSealed(stored)
+	if err != nil {
+		return "", fmt.Sprintf("sealed value for %s malformed: %v", k, err)
+	}
 
-		decrypted[k] = string(plaintext)
+	sharedKey, err := dk.Decapsulate(mlkemCiphertext)
+	if err != nil {
+		return "", fmt.Sprintf("decapsulation failed for %s: %v", k, err)
+	}
+	if len(sharedKey) != mlkem.SharedKeySize {
+		return "", fmt.Sprintf("unexpected shared key size for %s: %d", k, len(sharedKey))
 	}
 
-	// Return decrypted map and the resolved keys (in the order we processed them)
-	return decrypted, keys, 0, ""
+	_ = memlock.Lock(sharedKey)
+	plaintext, err := decryptAESGCM(sharedKey, gcmBlob)
+	memlock.Wipe(sharedKey)
+	if err != nil {
+		return "", fmt.Sprintf("decryption failed for %s: %v", k, err)
+	}
+	return string(plaintext), ""
 }
 
-func unsealCore(envMap map[string]string, dk *mlkem.DecapsulationKey768, keys []string, jsonOut bool, outw io.Writer, errw io.Writer, sourceDesc string) int {
-	decrypted, resolvedKeys, code, msg := decryptCore(envMap, dk, keys, sourceDesc)
+func unsealCore(ctx context.Context, envMap map[string]string, dk *mlkem.DecapsulationKey768, keys []string, jsonOut bool, outw io.Writer, errw io.Writer, sourceDesc string) int {
+	decrypted, resolvedKeys, code, msg := decryptCore(ctx, envMap, dk, keys, sourceDesc)
 	if code != 0 {
 		fmt.Fprintln(errw, msg)
 		return code
 	}
 
-	// Build output
+	return writeUnsealed(decrypted, resolvedKeys, jsonOut, outw)
+}
+
+// writeUnsealed writes values as JSON or as .env-safe lines in keys order.
+func writeUnsealed(values map[string]string, keys []string, jsonOut bool, outw io.Writer) int {
 	if jsonOut {
-		js, _ := json.Marshal(decrypted)
+		js, _ := json.Marshal(values)
 		if outw != nil {
 			_, _ = outw.Write(js)
 		}
 		return 0
 	}
 
-	// .env-safe lines: preserve the resolvedKeys ordering (matches original behavior)
-	var b strings.Builder
-	for _, k := range resolvedKeys {
-		b.WriteString(env.FormatEnvEntry(k, decrypted[k]))
-		b.WriteByte('\n')
-	}
-	result := strings.TrimRight(b.String(), "\n")
+	// .env-safe lines: preserve the keys ordering (matches original behavior)
+	result := env.FormatEnvEntries(values, keys)
 	if outw != nil && result != "" {
 		_, _ = io.WriteString(outw, result)
 	}
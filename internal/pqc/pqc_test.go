@@ -16,10 +16,15 @@ package pqc
 
 import (
 	"bytes"
+	"context"
+	"crypto/mlkem"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"sort"
@@ -47,7 +52,7 @@ func tmpPaths(t *testing.T) (priv, pub, envFile string) {
 func runUnseal(t *testing.T, envPath, privPath string, keys []string, jsonOut bool) (int, string) {
 	t.Helper()
 	var outBuf, errBuf bytes.Buffer
-	code := UnsealFromFiles(envPath, privPath, keys, jsonOut, &outBuf, &errBuf)
+	code := UnsealFromFiles(context.Background(), envPath, privPath, keys, jsonOut, &outBuf, &errBuf)
 	return code, errBuf.String()
 }
 
@@ -155,6 +160,42 @@ func TestKeypairWithPaths_PublicWriteFail(t *testing.T) {
 	}
 }
 
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestKeypairWithOptions_Streams(t *testing.T) {
+	priv, pub, _ := tmpPaths(t)
+
+	var privBuf, pubBuf, outBuf, errBuf bytes.Buffer
+	opts := KeypairOptions{PrivOut: &privBuf, PrivOutName: "fd 3", PubOut: &pubBuf}
+	if code := KeypairWithOptions(priv, pub, opts, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithOptions failed: code=%d stderr=%q", code, errBuf.String())
+	}
+	for _, p := range []string{priv, pub} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected no file at %s, stat err=%v", p, err)
+		}
+	}
+	if privBuf.Len() == 0 || pubBuf.Len() == 0 {
+		t.Fatalf("expected both keys streamed, got priv=%d pub=%d bytes", privBuf.Len(), pubBuf.Len())
+	}
+	want := "Wrote private key to fd 3\nWrote public key to standard output\n"
+	if outBuf.String() != want {
+		t.Fatalf("unexpected summary %q", outBuf.String())
+	}
+
+	// A failing stream removes the files already written.
+	errBuf.Reset()
+	opts = KeypairOptions{PrivOut: failingWriter{}, PrivOutName: "fd 3"}
+	if code := KeypairWithOptions(priv, pub, opts, &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "failed to write private key to fd 3") {
+		t.Fatalf("expected stream error, got code=%d stderr=%q", code, errBuf.String())
+	}
+	if _, err := os.Stat(pub); !os.IsNotExist(err) {
+		t.Fatalf("expected public key removed after stream failure, stat err=%v", err)
+	}
+}
+
 // ----------------------------- seal tests ---------------------------------
 
 func TestSealWithPlaintext_PubFileMissing(t *testing.T) {
@@ -248,7 +289,7 @@ func TestUnsealMap_HappyPath(t *testing.T) {
 	}
 
 	// Call UnsealMap and expect a decrypted map directly
-	decrypted, err := UnsealMap(envMap, priv, []string{keyName})
+	decrypted, err := UnsealMap(context.Background(), envMap, priv, []string{keyName})
 	if err != nil {
 		t.Fatalf("UnsealMap failed: err=%v", err)
 	}
@@ -259,6 +300,68 @@ func TestUnsealMap_HappyPath(t *testing.T) {
 	}
 }
 
+func TestUnsealMap_ParallelDeterministic(t *testing.T) {
+	priv, pub, _ := tmpPaths(t)
+	var errBuf bytes.Buffer
+	if code := KeypairWithPaths(priv, pub, io.Discard, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: code=%d stderr=%q", code, errBuf.String())
+	}
+	envMap := map[string]string{}
+	for i := range 40 {
+		sealed, err := Seal(pub, []byte(fmt.Sprintf("value-%d", i)))
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		envMap[fmt.Sprintf("K%02d", i)] = sealed
+	}
+
+	orig := unsealWorkers
+	t.Cleanup(func() { unsealWorkers = orig })
+	for _, workers := range []int{1, 8} {
+		unsealWorkers = func() int { return workers }
+		got, err := UnsealMap(context.Background(), envMap, priv, nil)
+		if err != nil || len(got) != 40 || got["K07"] != "value-7" || got["K39"] != "value-39" {
+			t.Fatalf("workers=%d: unexpected result %d values (%v)", workers, len(got), err)
+		}
+	}
+
+	// With several broken values, the first one in key order is reported.
+	broken := maps.Clone(envMap)
+	broken["K05"], broken["K30"] = Prefix+"x", Prefix+"y"
+	for range 20 {
+		if _, err := UnsealMap(context.Background(), broken, priv, nil); err == nil || !strings.Contains(err.Error(), "sealed value for K05 malformed") {
+			t.Fatalf("expected K05 to be reported, got %v", err)
+		}
+	}
+}
+
+func TestUnsealMap_Canceled(t *testing.T) {
+	priv, pub, _ := tmpPaths(t)
+	var errBuf bytes.Buffer
+	if code := KeypairWithPaths(priv, pub, io.Discard, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: code=%d stderr=%q", code, errBuf.String())
+	}
+	sealed, err := Seal(pub, []byte("v"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := UnsealMap(ctx, map[string]string{"K": sealed}, priv, nil)
+	if !errors.Is(err, ErrUnseal) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrUnseal wrapping context.Canceled, got %v (%v)", err, got)
+	}
+
+	envFile := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, envFile, []byte("K="+sealed+"\n"), 0o600)
+	var out bytes.Buffer
+	errBuf.Reset()
+	if code := UnsealFromFiles(ctx, envFile, priv, nil, false, &out, &errBuf); code != 1 || out.Len() != 0 || !strings.Contains(errBuf.String(), "context canceled") {
+		t.Fatalf("expected code 1 and no output, got code=%d out=%q stderr=%q", code, out.String(), errBuf.String())
+	}
+}
+
 func TestUnsealMap_PrivFileMissing(t *testing.T) {
 	td := t.TempDir()
 	priv := filepath.Join(td, "no-such-priv.b64")
@@ -274,7 +377,7 @@ func TestUnsealMap_PrivFileMissing(t *testing.T) {
 		t.Fatalf("ParseEnvFile failed: %v", err)
 	}
 
-	decrypted, err := UnsealMap(envMap, priv, nil)
+	decrypted, err := UnsealMap(context.Background(), envMap, priv, nil)
 	if err == nil {
 		t.Fatalf("expected error when private key file missing; got decrypted=%v", decrypted)
 	}
@@ -304,7 +407,7 @@ func TestUnsealMap_MalformedSealedValue(t *testing.T) {
 	// Replace the sealed value with a malformed payload (no separator)
 	envMap["BAD"] = Prefix + "onlyonepart"
 
-	decrypted, err := UnsealMap(envMap, priv, []string{"BAD"})
+	decrypted, err := UnsealMap(context.Background(), envMap, priv, []string{"BAD"})
 	if err == nil {
 		t.Fatalf("expected error for malformed sealed value; got decrypted=%v", decrypted)
 	}
@@ -591,7 +694,7 @@ func TestUnseal_NoSealedEntries_NoOutput(t *testing.T) {
 
 	// Request all sealed entries (nil keys) — there are none.
 	var stdout, stderr bytes.Buffer
-	code := UnsealFromFiles(envFile, priv, nil, false, &stdout, &stderr)
+	code := UnsealFromFiles(context.Background(), envFile, priv, nil, false, &stdout, &stderr)
 	if code != 0 {
 		t.Fatalf("expected success when no sealed entries exist, got code=%d stderr=%q", code, stderr.String())
 	}
@@ -625,7 +728,7 @@ func TestUnsealFromFiles_MissingKey_Message(t *testing.T) {
 	errBuf.Reset()
 
 	// request a missing key and assert the specific error message and exit code
-	code := UnsealFromFiles(envFile, priv, []string{"MISSING"}, false, &outBuf, &errBuf)
+	code := UnsealFromFiles(context.Background(), envFile, priv, []string{"MISSING"}, false, &outBuf, &errBuf)
 	if code != 2 {
 		t.Fatalf("expected exit code 2 for missing keys, got %d stderr=%q", code, errBuf.String())
 	}
@@ -693,8 +796,8 @@ func TestSealAndUnseal_HappyPath(t *testing.T) {
 	}
 
 	var jsonOut bytes.Buffer
-	if code := UnsealFromFiles(envFile, priv, []string{keyName}, true, &jsonOut, &errBuf); code != 0 {
-		t.Fatalf("UnsealFromFiles(json) failed: code=%d stderr=%q", code, errBuf.String())
+	if code := UnsealFromFiles(context.Background(), envFile, priv, []string{keyName}, true, &jsonOut, &errBuf); code != 0 {
+		t.Fatalf("UnsealFromFiles(context.Background(), json) failed: code=%d stderr=%q", code, errBuf.String())
 	}
 	var got map[string]string
 	if err := json.Unmarshal(jsonOut.Bytes(), &got); err != nil {
@@ -705,8 +808,8 @@ func TestSealAndUnseal_HappyPath(t *testing.T) {
 	}
 
 	var envOut bytes.Buffer
-	if code := UnsealFromFiles(envFile, priv, []string{keyName}, false, &envOut, &errBuf); code != 0 {
-		t.Fatalf("UnsealFromFiles(env) failed: code=%d stderr=%q", code, errBuf.String())
+	if code := UnsealFromFiles(context.Background(), envFile, priv, []string{keyName}, false, &envOut, &errBuf); code != 0 {
+		t.Fatalf("UnsealFromFiles(context.Background(), env) failed: code=%d stderr=%q", code, errBuf.String())
 	}
 	parsed, err := env.ParseEnvReader(strings.NewReader(envOut.String()))
 	if err != nil {
@@ -744,7 +847,7 @@ func TestRoundtripMultipleKeys(t *testing.T) {
 	}
 
 	var outJSON bytes.Buffer
-	if code := UnsealFromFiles(envFile, priv, nil, true, &outJSON, &errBuf); code != 0 {
+	if code := UnsealFromFiles(context.Background(), envFile, priv, nil, true, &outJSON, &errBuf); code != 0 {
 		t.Fatalf("UnsealFromFiles failed: code=%d stderr=%q", code, errBuf.String())
 	}
 	var got map[string]string
@@ -759,7 +862,7 @@ func TestRoundtripMultipleKeys(t *testing.T) {
 
 	sort.Strings(keys)
 	var envOut bytes.Buffer
-	if code := UnsealFromFiles(envFile, priv, keys, false, &envOut, &errBuf); code != 0 {
+	if code := UnsealFromFiles(context.Background(), envFile, priv, keys, false, &envOut, &errBuf); code != 0 {
 		t.Fatalf("UnsealFromFiles failed: code=%d stderr=%q", code, errBuf.String())
 	}
 	parsed, err := env.ParseEnvReader(strings.NewReader(envOut.String()))
@@ -773,6 +876,70 @@ func TestRoundtripMultipleKeys(t *testing.T) {
 	}
 }
 
+func TestDecodeSealed_Frame(t *testing.T) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, blob, err := SealBytes(dk.EncapsulationKey(), []byte("a secret value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := BuildSealedWithOptions(ct, blob, SealOptions{Frame: true})
+	if !strings.HasPrefix(sealed, FramedPrefix) {
+		t.Fatalf("framed value lacks %s: %q", FramedPrefix, sealed)
+	}
+
+	gotCT, gotBlob, err := DecodeSealed(sealed)
+	if err != nil {
+		t.Fatalf("DecodeSealed: %v", err)
+	}
+	if got, err := OpenBytes(dk, gotCT, gotBlob); err != nil || string(got) != "a secret value" {
+		t.Fatalf("OpenBytes = %q, %v", got, err)
+	}
+
+	// OJSTER-1 values have no frame and open too.
+	legacy := Prefix + base64.StdEncoding.EncodeToString(ct) + sep + base64.StdEncoding.EncodeToString(blob)
+	if BuildSealed(ct, blob) != legacy {
+		t.Fatal("BuildSealed does not default to OJSTER-1")
+	}
+	if got, msg := decryptValue("K", legacy, dk); msg != "" || got != "a secret value" {
+		t.Fatalf("legacy value: got %q, %s", got, msg)
+	}
+
+	// A frame under the OJSTER-1 prefix is not taken for one.
+	if _, msg := decryptValue("K", Prefix+strings.TrimPrefix(sealed, FramedPrefix), dk); msg == "" {
+		t.Fatal("framed value opened under the OJSTER-1 prefix")
+	}
+	// Formats of later releases are reported as such.
+	later := "OJSTER-3:" + strings.TrimPrefix(sealed, FramedPrefix)
+	if !IsSealed(later) {
+		t.Fatalf("IsSealed rejects %q", later)
+	}
+	if _, msg := decryptValue("K", later, dk); !strings.Contains(msg, "unsupported format OJSTER-3") {
+		t.Fatalf("expected unsupported format, got %q", msg)
+	}
+
+	mid := strings.Index(sealed, sep) + 1
+	flipped := []byte(sealed)
+	if flipped[mid+3] == 'A' {
+		flipped[mid+3] = 'B'
+	} else {
+		flipped[mid+3] = 'A'
+	}
+	for name, tc := range map[string]struct{ val, want string }{
+		"cut in second part": {sealed[:len(sealed)-10], fmt.Sprintf("truncated at byte %d of %d", len(sealed)-10, len(sealed))},
+		"cut in first part":  {sealed[:100], `no ":" separator; truncated at byte 100`},
+		"line-wrapped":       {sealed[:76] + "\n" + sealed[76:], "line-wrapped at byte 76"},
+		"trailing data":      {sealed + "AAAA", fmt.Sprintf("4 unexpected bytes after byte %d", len(sealed))},
+		"altered":            {string(flipped), "checksum mismatch"},
+	} {
+		if _, _, err := DecodeSealed(tc.val); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", name, tc.want, err)
+		}
+	}
+}
+
 // ----------------------------- AES helpers tests --------------------------
 
 func TestEncryptDecrypt_Errors(t *testing.T) {
@@ -835,4 +1002,97 @@ func TestBuildParseRegexRoundtrip(t *testing.T) {
 	if !re.MatchString(quoted) {
 		t.Fatalf("DefaultValueRegexp did not match quoted sealed value: %q", quoted)
 	}
+
+	// SealedValueRegexp finds the value inside surrounding text
+	if got := SealedValueRegexp().FindString("body=" + quoted + " rest"); got != sealed {
+		t.Fatalf("SealedValueRegexp found %q, want %q", got, sealed)
+	}
+}
+
+func TestSealEncoding_Base64URL(t *testing.T) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, blob, err := SealBytes(dk.EncapsulationKey(), []byte("url safe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := BuildSealedWithOptions(ct, blob, SealOptions{Frame: true, Encoding: EncodingBase64URL})
+	if strings.ContainsAny(strings.TrimPrefix(sealed, FramedPrefix), "+/=") {
+		t.Fatalf("base64url value contains '+', '/' or '=': %q", sealed)
+	}
+	re, _ := DefaultValueRegexp()
+	if !re.MatchString(sealed) || SealedValueRegexp().FindString(" "+sealed+" ") != sealed {
+		t.Fatalf("regexps do not match %q", sealed)
+	}
+
+	// Decoding detects the encoding, with or without a frame.
+	if got, msg := decryptValue("K", sealed, dk); msg != "" || got != "url safe" {
+		t.Fatalf("decryptValue: got %q, %s", got, msg)
+	}
+	unframed := BuildSealedWithOptions(ct, blob, SealOptions{Encoding: EncodingBase64URL})
+	if got, msg := decryptValue("K", unframed, dk); msg != "" || got != "url safe" {
+		t.Fatalf("decryptValue(OJSTER-1): got %q, %s", got, msg)
+	}
+	if _, _, err := DecodeSealed(sealed[:len(sealed)-2]); err == nil || !strings.Contains(err.Error(), "truncated at byte") {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+	mixed := strings.Replace(sealed, "-", "+", 1)
+	if mixed != sealed {
+		if _, _, err := DecodeSealed(mixed); err == nil {
+			t.Fatal("value mixing both alphabets was accepted")
+		}
+	}
+}
+
+func TestUnsealFromFilesWithOptions_Interpolate(t *testing.T) {
+	priv, pub, envFile := tmpPaths(t)
+	var outBuf, errBuf bytes.Buffer
+	if code := KeypairWithPaths(priv, pub, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: %s", errBuf.String())
+	}
+	// references resolve in file order, so the sealed part comes first
+	if code, stderr := runSeal(t, pub, envFile, "DB_PASSWORD", []byte("pa$$word")); code != 0 {
+		t.Fatalf("seal failed: %s", stderr)
+	}
+	sealed, _ := os.ReadFile(envFile)
+	writeFile(t, envFile, append(sealed, "PLAIN=x\nDATABASE_URL=postgres://user:${DB_PASSWORD}@db\nLIT='$DB_PASSWORD'\n"...), 0o600)
+	noEnv := func(string) (string, bool) { return "", false }
+
+	var out bytes.Buffer
+	opts := UnsealOptions{JSON: true, Interpolate: true, Lookup: noEnv}
+	if code := UnsealFromFilesWithOptions(context.Background(), envFile, priv, nil, opts, &out, &errBuf); code != 0 {
+		t.Fatalf("unseal failed: %s", errBuf.String())
+	}
+	var got map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	// decrypted values are not expanded themselves; literal and plain entries are not printed
+	want := map[string]string{"DB_PASSWORD": "pa$$word", "DATABASE_URL": "postgres://user:pa$$word@db"}
+	if len(got) != len(want) || got["DB_PASSWORD"] != want["DB_PASSWORD"] || got["DATABASE_URL"] != want["DATABASE_URL"] {
+		t.Fatalf("unexpected output %v", got)
+	}
+
+	out.Reset()
+	opts = UnsealOptions{Interpolate: true, Lookup: noEnv}
+	if code := UnsealFromFilesWithOptions(context.Background(), envFile, priv, []string{"DATABASE_URL", "LIT"}, opts, &out, &errBuf); code != 0 {
+		t.Fatalf("unseal with keys failed: %s", errBuf.String())
+	}
+	parsed, _ := env.ParseEnvString(out.String())
+	if parsed["DATABASE_URL"] != want["DATABASE_URL"] || parsed["LIT"] != "$DB_PASSWORD" || len(parsed) != 2 {
+		t.Fatalf("unexpected env output %q", out.String())
+	}
+
+	errBuf.Reset()
+	if code := UnsealFromFilesWithOptions(context.Background(), envFile, priv, []string{"NOPE"}, opts, &out, &errBuf); code != 2 || !strings.Contains(errBuf.String(), "NOPE") {
+		t.Fatalf("expected missing key exit 2, got %d %q", code, errBuf.String())
+	}
+
+	writeFile(t, envFile, []byte("BAD=${REQUIRED:?set me}\n"), 0o600)
+	errBuf.Reset()
+	if code := UnsealFromFilesWithOptions(context.Background(), envFile, priv, nil, opts, &out, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "set me") {
+		t.Fatalf("expected interpolation failure, got %d %q", code, errBuf.String())
+	}
 }
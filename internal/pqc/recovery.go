@@ -0,0 +1,62 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/mnemonic"
+)
+
+// recoveryWords is the length of the mnemonic for a 64-byte seed.
+const recoveryWords = 48
+
+// writeMnemonic prints the recovery words for seed, numbered, six per line.
+func writeMnemonic(w io.Writer, seed []byte) error {
+	list, err := mnemonic.Encode(seed)
+	if err != nil {
+		return fmt.Errorf("failed to encode recovery words: %w", err)
+	}
+	var b strings.Builder
+	b.WriteString("\nRECOVERY WORDS (anyone holding them can decrypt; keep them offline):\n")
+	for i := 0; i < len(list); i += 6 {
+		var line strings.Builder
+		for j, word := range list[i:min(i+6, len(list))] {
+			fmt.Fprintf(&line, "%2d. %-10s", i+j+1, word)
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// SeedFromMnemonic decodes recovery words printed by keypair --mnemonic back
+// into the private key seed for KeypairOptions.Seed. The numbers in front of
+// each word may be included.
+func SeedFromMnemonic(text string) ([]byte, error) {
+	var list []string
+	for _, f := range strings.Fields(text) {
+		if len(f) > 1 && strings.TrimLeft(f, "0123456789") == "." {
+			continue
+		}
+		list = append(list, f)
+	}
+	if len(list) != recoveryWords {
+		return nil, fmt.Errorf("expected %d recovery words, got %d", recoveryWords, len(list))
+	}
+	return mnemonic.Decode(list)
+}
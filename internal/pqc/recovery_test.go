@@ -0,0 +1,51 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeypairWithOptions_MnemonicRestore(t *testing.T) {
+	priv, pub, _ := tmpPaths(t)
+	var outBuf, errBuf bytes.Buffer
+	if code := KeypairWithOptions(priv, pub, KeypairOptions{Mnemonic: true}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithOptions failed: %s", errBuf.String())
+	}
+	_, words, ok := strings.Cut(outBuf.String(), "RECOVERY WORDS")
+	if !ok || strings.Count(words, "\n") != 9 || !strings.Contains(words, "48. ") {
+		t.Fatalf("unexpected recovery words output:\n%s", outBuf.String())
+	}
+	_, words, _ = strings.Cut(words, "\n")
+
+	// The printed form, numbers included, restores the same keypair.
+	seed, err := SeedFromMnemonic(words)
+	if err != nil {
+		t.Fatalf("SeedFromMnemonic: %v", err)
+	}
+	priv2, pub2, _ := tmpPaths(t)
+	if code := KeypairWithOptions(priv2, pub2, KeypairOptions{Seed: seed}, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("restore failed: %s", errBuf.String())
+	}
+	if readTrim(t, priv) != readTrim(t, priv2) || readTrim(t, pub) != readTrim(t, pub2) {
+		t.Fatal("restored keypair differs from the original")
+	}
+
+	if _, err := SeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"); err == nil || !strings.Contains(err.Error(), "expected 48 recovery words, got 12") {
+		t.Fatalf("expected a length error, got %v", err)
+	}
+}
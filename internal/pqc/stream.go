@@ -0,0 +1,205 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/mlkem"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ojster/ojster/internal/util/memlock"
+)
+
+// A sealed stream is binary, unlike the sealed values of env files:
+//
+//	"OJSTER-STREAM-1\n" || ML-KEM-768 ciphertext || chunk...
+//
+// Each chunk is up to streamChunkSize bytes of plaintext sealed with
+// AES-256-GCM under the encapsulated shared key, which is fresh for every
+// stream, so nonces can be a counter. The last byte of the nonce marks the
+// final chunk, so a stream cut at a chunk boundary fails to open instead of
+// looking complete.
+const (
+	streamMagic     = "OJSTER-STREAM-1\n"
+	streamChunkSize = 64 * 1024
+	streamTagSize   = 16
+)
+
+// NewSealer writes the header of a sealed stream for ek to w and returns a
+// writer that seals what is written to it. Close must be called to write
+// the final chunk; it does not close w.
+func NewSealer(w io.Writer, ek *mlkem.EncapsulationKey768) (io.WriteCloser, error) {
+	sharedKey, mlkemCiphertext := ek.Encapsulate()
+	aead, err := streamAEAD(sharedKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, streamMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(mlkemCiphertext); err != nil {
+		return nil, err
+	}
+	return &sealer{w: w, aead: aead, buf: make([]byte, 0, streamChunkSize)}, nil
+}
+
+type sealer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	err     error
+}
+
+func (s *sealer) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, as it may
+		// turn out to be the final chunk.
+		if len(s.buf) == streamChunkSize {
+			if s.err = s.flush(false); s.err != nil {
+				return n, s.err
+			}
+		}
+		c := copy(s.buf[len(s.buf):streamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close seals the final chunk. Later writes fail.
+func (s *sealer) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.flush(true)
+	if s.err == nil {
+		s.err = errors.New("pqc: write to closed sealer")
+		return nil
+	}
+	return s.err
+}
+
+func (s *sealer) flush(last bool) error {
+	ct := s.aead.Seal(s.buf[:0:0], streamNonce(s.counter, last), s.buf, nil)
+	memlock.Wipe(s.buf)
+	s.buf = s.buf[:0]
+	s.counter++
+	_, err := s.w.Write(ct)
+	return err
+}
+
+// NewOpener reads the header of a sealed stream from r and returns a reader
+// of its plaintext. Reading fails with ErrUnseal when the stream was
+// tampered with or truncated; data returned before that is authentic.
+func NewOpener(r io.Reader, dk *mlkem.DecapsulationKey768) (io.Reader, error) {
+	header := make([]byte, len(streamMagic)+mlkem.CiphertextSize768)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: not a sealed stream: %v", ErrUnseal, err)
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, fmt.Errorf("%w: not a sealed stream", ErrUnseal)
+	}
+	sharedKey, err := dk.Decapsulate(header[len(streamMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decapsulation failed: %v", ErrUnseal, err)
+	}
+	aead, err := streamAEAD(sharedKey)
+	if err != nil {
+		return nil, err
+	}
+	return &opener{r: bufio.NewReaderSize(r, streamChunkSize+streamTagSize+1), aead: aead, chunk: make([]byte, streamChunkSize+streamTagSize)}, nil
+}
+
+type opener struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+func (o *opener) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		if o.done {
+			return 0, io.EOF
+		}
+		o.plain, o.err = o.next()
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+// next opens the next chunk. A chunk is the final one when the stream ends
+// after it.
+func (o *opener) next() ([]byte, error) {
+	n, err := io.ReadFull(o.r, o.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return nil, err
+	default:
+		_, perr := o.r.Peek(1)
+		last = perr == io.EOF
+	}
+	if n < streamTagSize {
+		return nil, fmt.Errorf("%w: sealed stream is truncated", ErrUnseal)
+	}
+	plain, err := o.aead.Open(o.chunk[:0], streamNonce(o.counter, last), o.chunk[:n], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sealed stream is corrupted or truncated", ErrUnseal)
+	}
+	o.counter++
+	o.done = last
+	return plain, nil
+}
+
+func streamAEAD(sharedKey []byte) (cipher.AEAD, error) {
+	_ = memlock.Lock(sharedKey)
+	defer memlock.Wipe(sharedKey)
+	block, err := aes.NewCipher(sharedKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamNonce is the big-endian chunk counter followed by the final-chunk flag.
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, nonceSizeGCM)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
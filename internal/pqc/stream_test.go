@@ -0,0 +1,109 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"crypto/mlkem"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func sealStream(t *testing.T, ek *mlkem.EncapsulationKey768, plaintext []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	s, err := NewSealer(&out, ek)
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	// Odd-sized writes cross chunk boundaries at arbitrary points.
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 1000)
+		if _, err := s.Write(p[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		p = p[n:]
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := s.Write([]byte("x")); err == nil {
+		t.Fatal("write after Close succeeded")
+	}
+	return out.Bytes()
+}
+
+func TestStream_Roundtrip(t *testing.T) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize + 5} {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+		sealed := sealStream(t, dk.EncapsulationKey(), plaintext)
+
+		o, err := NewOpener(iotest.HalfReader(bytes.NewReader(sealed)), dk)
+		if err != nil {
+			t.Fatalf("size %d: NewOpener: %v", size, err)
+		}
+		got, err := io.ReadAll(o)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: roundtrip failed (%d bytes, %v)", size, len(got), err)
+		}
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := bytes.Repeat([]byte("a"), 2*streamChunkSize+10)
+	sealed := sealStream(t, dk.EncapsulationKey(), plaintext)
+	header := len(streamMagic) + mlkem.CiphertextSize768
+	chunk := streamChunkSize + streamTagSize
+
+	flipped := bytes.Clone(sealed)
+	flipped[header+chunk+5] ^= 1
+	for name, data := range map[string][]byte{
+		"cut at chunk boundary": sealed[:header+2*chunk],
+		"cut inside chunk":      sealed[:len(sealed)-3],
+		"trailing data":         append(bytes.Clone(sealed), 0),
+		"flipped bit":           flipped,
+		"header only":           sealed[:header],
+	} {
+		o, err := NewOpener(bytes.NewReader(data), dk)
+		if err != nil {
+			t.Fatalf("%s: NewOpener: %v", name, err)
+		}
+		if _, err := io.ReadAll(o); !errors.Is(err, ErrUnseal) {
+			t.Errorf("%s: expected ErrUnseal, got %v", name, err)
+		}
+	}
+
+	other, _ := mlkem.GenerateKey768()
+	if o, err := NewOpener(bytes.NewReader(sealed), other); err == nil {
+		if _, err := io.ReadAll(o); !errors.Is(err, ErrUnseal) {
+			t.Errorf("wrong key: expected ErrUnseal, got %v", err)
+		}
+	}
+	if _, err := NewOpener(bytes.NewReader([]byte("OJSTER-1:abc")), dk); !errors.Is(err, ErrUnseal) {
+		t.Errorf("expected ErrUnseal for a non-stream, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/mlkem"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ojster/ojster/internal/util/memlock"
+)
+
+// WrappedKeyPrefix starts a private key file whose seed is encrypted with a
+// passphrase. The full form is
+//
+//	OJSTER-KEY-PBKDF2-1:i=600000:<salt>:<nonce||ciphertext>
+//
+// with base64 salt and blob. The KEK is PBKDF2-HMAC-SHA256(passphrase, salt)
+// with the stated iterations; AES-256-GCM authenticates everything before
+// the blob, so the parameters cannot be altered unnoticed.
+const WrappedKeyPrefix = "OJSTER-KEY-PBKDF2-1:"
+
+// KDF is the key derivation of wrapped keys. It comes from
+// the standard library: memory-hard KDFs such as Argon2id are not in it,
+// and the project carries neither third-party nor hand-written primitives.
+const KDF = "PBKDF2-SHA256"
+
+const (
+	wrapSaltSize = 16
+	// Unwrapping refuses parameters beyond this, so a crafted key file
+	// cannot make ojster spin without bound.
+	maxKDFIterations = 10_000_000
+)
+
+// ErrPassphrase is returned when a wrapped key does not open with the given
+// passphrase (or has been tampered with).
+var ErrPassphrase = errors.New("pqc: wrong passphrase or corrupted private key")
+
+// KDFParams are the PBKDF2 cost parameters of a wrapped key.
+type KDFParams struct {
+	Iterations uint32
+}
+
+// DefaultKDFParams is the OWASP recommendation for PBKDF2-HMAC-SHA256.
+var DefaultKDFParams = KDFParams{Iterations: 600_000}
+
+func (p KDFParams) String() string {
+	return fmt.Sprintf("i=%d", p.Iterations)
+}
+
+func (p KDFParams) validate() error {
+	if p.Iterations < 1 || p.Iterations > maxKDFIterations {
+		return fmt.Errorf("unsupported %s parameters %s", KDF, p)
+	}
+	return nil
+}
+
+func parseKDFParams(s string) (KDFParams, error) {
+	var p KDFParams
+	v, ok := strings.CutPrefix(s, "i=")
+	n, err := strconv.ParseUint(v, 10, 32)
+	if !ok || err != nil {
+		return p, fmt.Errorf("malformed %s parameters %q", KDF, s)
+	}
+	p.Iterations = uint32(n)
+	return p, p.validate()
+}
+
+// deriveKey runs PBKDF2-HMAC-SHA256 with params.
+func deriveKey(secret, salt []byte, params KDFParams, size int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, string(secret), salt, int(params.Iterations), size)
+}
+
+// IsWrappedKey reports whether a private key file holds a passphrase-wrapped
+// key, bare or PEM-armored.
+func IsWrappedKey(data []byte) bool {
+	_, data = splitKeyComments(data)
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte(WrappedKeyPrefix)) || bytes.HasPrefix(data, []byte("-----BEGIN "+pemEncryptedType+"-----"))
+}
+
+// WrapPrivateKey encrypts the private key seed under passphrase and returns
+// the WrappedKeyPrefix line (with a trailing newline) to store in the key file.
+func WrapPrivateKey(seed, passphrase []byte, params KDFParams) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	salt := make([]byte, wrapSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header := WrappedKeyPrefix + params.String() + sep + base64.StdEncoding.EncodeToString(salt)
+	gcm, err := kekCipher(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSizeGCM)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	blob := gcm.Seal(nonce, nonce, seed, []byte(header))
+	return []byte(header + sep + base64.StdEncoding.EncodeToString(blob) + "\n"), nil
+}
+
+// UnwrapPrivateKey reverses WrapPrivateKey and returns the seed, which the
+// caller should memlock.Wipe after use.
+func UnwrapPrivateKey(data, passphrase []byte) ([]byte, error) {
+	paramStr, saltB64, blobB64, err := splitWrapped(data)
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseKDFParams(paramStr)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 salt: %w", err)
+	}
+	blob, err := base64.StdEncoding.DecodeString(blobB64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 wrapped key: %w", err)
+	}
+	if len(blob) < nonceSizeGCM {
+		return nil, errors.New("wrapped key too short")
+	}
+	gcm, err := kekCipher(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	header := WrappedKeyPrefix + paramStr + sep + saltB64
+	seed, err := gcm.Open(nil, blob[:nonceSizeGCM], blob[nonceSizeGCM:], []byte(header))
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	_ = memlock.Lock(seed)
+	return seed, nil
+}
+
+// splitWrapped returns the parameter, salt and blob fields of a wrapped key.
+func splitWrapped(data []byte) (params, salt, blob string, err error) {
+	s, ok := strings.CutPrefix(string(bytes.TrimSpace(data)), WrappedKeyPrefix)
+	if !ok {
+		return "", "", "", errors.New("not a passphrase-wrapped private key")
+	}
+	parts := strings.Split(s, sep)
+	if len(parts) != 3 {
+		return "", "", "", errors.New("malformed passphrase-wrapped private key")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+func kekCipher(passphrase, salt []byte, params KDFParams) (cipher.AEAD, error) {
+	kek, err := deriveKey(passphrase, salt, params, 32)
+	if err != nil {
+		return nil, err
+	}
+	_ = memlock.Lock(kek)
+	defer memlock.Wipe(kek)
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PassphraseFunc supplies the passphrase for a wrapped private key file.
+// Commands install one; without it wrapped keys cannot be loaded. The
+// returned slice is wiped after use.
+var PassphraseFunc func(privPath string) ([]byte, error)
+
+// unlocked caches decapsulation keys of wrapped key files by a hash of the
+// file content, so a server pays for the KDF (and needs the passphrase)
+// only once, even when it reads the key file again for every request.
+var unlocked struct {
+	sync.Mutex
+	keys map[[sha256.Size]byte]*mlkem.DecapsulationKey768
+}
+
+func loadWrappedKey(privPath string, data []byte) (*mlkem.DecapsulationKey768, error) {
+	id := sha256.Sum256(bytes.TrimSpace(data))
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	if dk, ok := unlocked.keys[id]; ok {
+		return dk, nil
+	}
+	if PassphraseFunc == nil {
+		return nil, fmt.Errorf("private key %s is passphrase-protected and no passphrase was provided", privPath)
+	}
+	passphrase, err := PassphraseFunc(privPath)
+	if err != nil {
+		return nil, err
+	}
+	defer memlock.Wipe(passphrase)
+	seed, err := UnwrapPrivateKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock private key %s: %w", privPath, err)
+	}
+	defer memlock.Wipe(seed)
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in %s: %w", privPath, err)
+	}
+	if unlocked.keys == nil {
+		unlocked.keys = make(map[[sha256.Size]byte]*mlkem.DecapsulationKey768)
+	}
+	unlocked.keys[id] = dk
+	return dk, nil
+}
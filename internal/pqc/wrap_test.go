@@ -0,0 +1,109 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testKDF keeps the tests fast; the format does not depend on the cost.
+var testKDF = KDFParams{Iterations: 1}
+
+func TestWrapUnwrapPrivateKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 64)
+	wrapped, err := WrapPrivateKey(seed, []byte("hunter2"), testKDF)
+	if err != nil {
+		t.Fatalf("WrapPrivateKey: %v", err)
+	}
+	if !IsWrappedKey(wrapped) || !strings.HasPrefix(string(wrapped), WrappedKeyPrefix+"i=1:") {
+		t.Fatalf("unexpected wrapped key %q", wrapped)
+	}
+	got, err := UnwrapPrivateKey(wrapped, []byte("hunter2"))
+	if err != nil || !bytes.Equal(got, seed) {
+		t.Fatalf("UnwrapPrivateKey = %x, %v", got, err)
+	}
+
+	if _, err := UnwrapPrivateKey(wrapped, []byte("hunter3")); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("wrong passphrase: err = %v", err)
+	}
+	// The parameters are authenticated: cheaper ones cannot be swapped in.
+	tampered := bytes.Replace(wrapped, []byte("i=1:"), []byte("i=2:"), 1)
+	if _, err := UnwrapPrivateKey(tampered, []byte("hunter2")); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("tampered params: err = %v", err)
+	}
+	for _, bad := range []string{
+		WrappedKeyPrefix + "t=1,m=64,p=1:AAAA:AAAA",
+		WrappedKeyPrefix + "i=1:AAAA",
+		WrappedKeyPrefix + "i=99999999:AAAA:AAAA",
+		WrappedKeyPrefix + "i=0:AAAA:AAAA",
+		WrappedKeyPrefix + "i=1:!!:AAAA",
+	} {
+		if _, err := UnwrapPrivateKey([]byte(bad), []byte("x")); err == nil || errors.Is(err, ErrPassphrase) {
+			t.Errorf("UnwrapPrivateKey(%q): expected a format error, got %v", bad, err)
+		}
+	}
+	if _, err := WrapPrivateKey(seed, nil, testKDF); err == nil {
+		t.Fatal("expected an error for an empty passphrase")
+	}
+}
+
+func TestKeypairWithOptions_Passphrase(t *testing.T) {
+	priv, pub, envFile := tmpPaths(t)
+	var outBuf, errBuf bytes.Buffer
+	opts := KeypairOptions{Passphrase: []byte("correct horse"), KDF: testKDF}
+	if code := KeypairWithOptions(priv, pub, opts, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithOptions failed: code=%d stderr=%q", code, errBuf.String())
+	}
+	if !strings.Contains(outBuf.String(), "wrapped with a passphrase (PBKDF2-SHA256 i=1)") {
+		t.Fatalf("summary does not mention the passphrase: %q", outBuf.String())
+	}
+	data, err := os.ReadFile(priv)
+	if err != nil || !IsWrappedKey(data) {
+		t.Fatalf("expected a wrapped key file, got %q (%v)", data, err)
+	}
+
+	if code, _ := runSeal(t, pub, envFile, "K", []byte("v")); code != 0 {
+		t.Fatal("seal failed")
+	}
+
+	// Without a passphrase source the key cannot be loaded.
+	t.Cleanup(func() { PassphraseFunc = nil })
+	PassphraseFunc = nil
+	if _, err := LoadDecapsulationKey(priv); err == nil || !strings.Contains(err.Error(), "passphrase-protected") {
+		t.Fatalf("expected passphrase error, got %v", err)
+	}
+	PassphraseFunc = func(string) ([]byte, error) { return []byte("wrong"), nil }
+	if _, err := LoadDecapsulationKey(priv); !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("expected ErrPassphrase, got %v", err)
+	}
+
+	calls := 0
+	PassphraseFunc = func(string) ([]byte, error) { calls++; return []byte("correct horse"), nil }
+	for range 2 {
+		outBuf.Reset()
+		if code := UnsealFromFiles(context.Background(), envFile, priv, nil, false, &outBuf, &errBuf); code != 0 || outBuf.String() != "K=v" {
+			t.Fatalf("unseal: code=%d out=%q stderr=%q", code, outBuf.String(), errBuf.String())
+		}
+	}
+	// The unlocked key is cached, so the passphrase is only asked for once.
+	if calls != 1 {
+		t.Fatalf("PassphraseFunc called %d times, want 1", calls)
+	}
+}
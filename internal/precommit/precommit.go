@@ -0,0 +1,93 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package precommit implements a git pre-commit check that refuses env files
+// holding secrets in the clear.
+package precommit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ojster/ojster/internal/scan"
+)
+
+// gitBinary is a var so tests can substitute a fake git.
+var gitBinary = "git"
+
+func git(args ...string) ([]byte, error) {
+	cmd := exec.Command(gitBinary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Check scans env files for plaintext secrets and staged ojster private keys.
+// Without paths it checks the files staged in the git index, reading their
+// staged content rather than the working tree; otherwise it checks the given
+// paths on disk (as hook frameworks pass them). Files not named like env
+// files are ignored. Returns 1 if anything was found, else 0.
+func Check(paths []string, outw io.Writer, errw io.Writer) int {
+	read := os.ReadFile
+	if len(paths) == 0 {
+		out, err := git("diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		for _, p := range strings.Split(string(out), "\x00") {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		read = func(p string) ([]byte, error) { return git("show", ":"+p) }
+	}
+
+	var findings []scan.Finding
+	for _, p := range paths {
+		if scan.IsPrivateKeyFile(p) {
+			findings = append(findings, scan.Finding{Path: p, Reason: "is an ojster private key and must not be committed"})
+			continue
+		}
+		if !scan.IsEnvFile(p) {
+			continue
+		}
+		data, err := read(p)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to read %s: %w", p, err))
+			return 1
+		}
+		findings = append(findings, scan.EnvData(p, data)...)
+	}
+
+	for _, f := range findings {
+		fmt.Fprintln(outw, f)
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(errw, "%d problem(s) found; seal secrets with 'ojster seal' before committing\n", len(findings))
+		return 1
+	}
+	return 0
+}
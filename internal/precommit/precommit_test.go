@@ -0,0 +1,137 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package precommit
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// gitRepo creates a repository in a temp dir and changes into it.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	runGit(t, "init", "-q")
+	return dir
+}
+
+func runGit(t *testing.T, args ...string) {
+	t.Helper()
+	if _, err := git(args...); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func write(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Check()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestCheck_Staged(t *testing.T) {
+	gitRepo(t)
+	sealed := pqc.BuildSealed([]byte{1}, []byte{2})
+	write(t, ".env", "DB_PASSWORD=hunter2\nAPI_TOKEN="+sealed+"\n")
+	write(t, "config/app.env", "LOG_LEVEL=debug\n")
+	write(t, "main.go", "package main // DB_PASSWORD=hunter2\n")
+	write(t, pqc.DefaultPrivFile(), "key material\n")
+	runGit(t, "add", ".env", "config/app.env", "main.go", pqc.DefaultPrivFile())
+	// The working tree no longer matters once staged.
+	write(t, ".env", "DB_PASSWORD="+sealed+"\n")
+
+	var outBuf, errBuf bytes.Buffer
+	if code := Check(nil, &outBuf, &errBuf); code != 1 {
+		t.Fatalf("expected findings, got code=%d stderr=%q", code, errBuf.String())
+	}
+	want := ".env:1: DB_PASSWORD looks like a plaintext secret\n" +
+		pqc.DefaultPrivFile() + ": is an ojster private key and must not be committed\n"
+	if outBuf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", outBuf.String(), want)
+	}
+	if !strings.Contains(errBuf.String(), "2 problem(s) found") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	// Once the sealed version is staged and the key unstaged, the check passes.
+	runGit(t, "add", ".env")
+	runGit(t, "rm", "-q", "--cached", pqc.DefaultPrivFile())
+	outBuf.Reset()
+	errBuf.Reset()
+	if code := Check(nil, &outBuf, &errBuf); code != 0 || outBuf.Len() != 0 {
+		t.Fatalf("expected clean check, got code=%d stdout=%q stderr=%q", code, outBuf.String(), errBuf.String())
+	}
+}
+
+func TestCheck_Paths(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, ".env.prod")
+	write(t, envPath, "# prod\nSTRIPE_SECRET_KEY=sk_live_123\n")
+	write(t, filepath.Join(dir, "README.md"), "PASSWORD=x\n")
+
+	var outBuf, errBuf bytes.Buffer
+	code := Check([]string{envPath, filepath.Join(dir, "README.md")}, &outBuf, &errBuf)
+	if code != 1 || outBuf.String() != envPath+":2: STRIPE_SECRET_KEY looks like a plaintext secret\n" {
+		t.Fatalf("unexpected result code=%d stdout=%q", code, outBuf.String())
+	}
+
+	errBuf.Reset()
+	if code := Check([]string{filepath.Join(dir, "missing.env")}, &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "failed to read") {
+		t.Fatalf("expected read error, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestCheck_GitErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	old := gitBinary
+	t.Cleanup(func() { gitBinary = old })
+
+	gitBinary = "false"
+	var outBuf, errBuf bytes.Buffer
+	if code := Check(nil, &outBuf, &errBuf); code != 1 || !strings.Contains(errBuf.String(), "git diff") {
+		t.Fatalf("expected git failure, got code=%d stderr=%q", code, errBuf.String())
+	}
+
+	gitBinary = "sh"
+	errBuf.Reset()
+	if _, err := git("-c", "echo oops >&2; exit 3"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
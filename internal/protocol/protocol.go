@@ -0,0 +1,121 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package protocol holds the version of the JSON request/response exchange
+// between the ojster client and server. Clients send the version they use in
+// Header. Servers answer with it and list every version they speak in
+// VersionsHeader, from which a client picks the highest one both sides speak
+// (see Negotiate). A peer that omits the headers predates versioning and
+// speaks version 1.
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Header carries the protocol version of a request and of its response.
+const Header = "X-Ojster-Protocol"
+
+// VersionsHeader lists, comma-separated, the protocol versions a server
+// speaks. It is sent on every response, including rejections.
+const VersionsHeader = "X-Ojster-Protocol-Versions"
+
+// KeyFingerprintHeader carries the fingerprint of the server's key (see
+// pqc.Fingerprint) on responses, for clients that pin it on first use. It is
+// self-reported; KeyProofHeader proves it.
+const KeyFingerprintHeader = "X-Ojster-Key-Fingerprint"
+
+// PublicKeyHeader asks, on requests, for the server's public key, which the
+// response carries in the same header as the base64 encapsulation key. A
+// client fetches it on first contact to challenge the server later.
+const PublicKeyHeader = "X-Ojster-Public-Key"
+
+// KeyProofHeader carries, on requests, a base64 ML-KEM ciphertext to the
+// server's pinned public key (see pqc.KeyChallenge), and on responses the
+// server's answer (see pqc.KeyProof). Only a server that holds the private
+// key can answer it.
+const KeyProofHeader = "X-Ojster-Key-Proof"
+
+// SingleDeliveryHeader lists, comma-separated, the keys of a reply that the
+// server releases only once. Whoever relays the reply, such as the agent,
+// must not cache them, or they would be delivered again.
+const SingleDeliveryHeader = "X-Ojster-Single-Delivery"
+
+// MinVersion and Version are the oldest and newest protocol versions this
+// build speaks. Bump Version when the wire format changes in a way an older
+// peer would misread, and MinVersion when support for old peers is dropped.
+const (
+	MinVersion = 1
+	Version    = 1
+)
+
+// ErrMismatch is returned by Check and Negotiate for a peer that shares no
+// version with this build. Retrying cannot fix it, so clients give up instead.
+var ErrMismatch = errors.New("protocol version mismatch")
+
+// Versions is the VersionsHeader value of this build.
+func Versions() string {
+	vs := make([]string, 0, Version-MinVersion+1)
+	for v := MinVersion; v <= Version; v++ {
+		vs = append(vs, strconv.Itoa(v))
+	}
+	return strings.Join(vs, ",")
+}
+
+// Check validates the Header value of a request and returns the version it
+// names. An empty value is accepted as version 1.
+func Check(value string) (int, error) {
+	if value == "" {
+		value = "1"
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("%w: invalid version %q", ErrMismatch, value)
+	}
+	if v < MinVersion || v > Version {
+		return 0, fmt.Errorf("%w: peer speaks version %d, this ojster speaks versions %s; upgrade the older of client and server", ErrMismatch, v, Versions())
+	}
+	return v, nil
+}
+
+// Negotiate returns the highest version that both this build and the server
+// behind the response headers h speak. A server that predates VersionsHeader
+// speaks only the version in its Header, and one without either version 1.
+func Negotiate(h http.Header) (int, error) {
+	advertised := h.Get(VersionsHeader)
+	if advertised == "" {
+		advertised = h.Get(Header)
+	}
+	if advertised == "" {
+		advertised = "1"
+	}
+	best := 0
+	for _, field := range strings.Split(advertised, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || v < 1 {
+			return 0, fmt.Errorf("%w: invalid versions %q", ErrMismatch, advertised)
+		}
+		if v >= MinVersion && v <= Version && v > best {
+			best = v
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("%w: peer speaks versions %s, this ojster speaks versions %s; upgrade the older of client and server", ErrMismatch, advertised, Versions())
+	}
+	return best, nil
+}
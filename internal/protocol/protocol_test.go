@@ -0,0 +1,76 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	for _, ok := range []string{"", strconv.Itoa(Version)} {
+		if v, err := Check(ok); err != nil || v != Version {
+			t.Errorf("Check(%q) = %d, %v", ok, v, err)
+		}
+	}
+	for _, bad := range []string{strconv.Itoa(Version + 1), "0", "abc"} {
+		_, err := Check(bad)
+		if !errors.Is(err, ErrMismatch) {
+			t.Errorf("Check(%q) = %v, want ErrMismatch", bad, err)
+		}
+	}
+	if _, err := Check(strconv.Itoa(Version + 1)); !strings.Contains(err.Error(), "peer speaks version 2") {
+		t.Fatalf("unclear error: %v", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		versions, header string
+		want             int
+	}{
+		{"", "", 1},
+		{"", "1", 1},
+		{"1", "", 1},
+		{"1, 2, 3", "3", Version},
+		{Versions(), "", Version},
+	} {
+		h := http.Header{}
+		if tc.versions != "" {
+			h.Set(VersionsHeader, tc.versions)
+		}
+		if tc.header != "" {
+			h.Set(Header, tc.header)
+		}
+		if v, err := Negotiate(h); err != nil || v != tc.want {
+			t.Errorf("Negotiate(%v) = %d, %v, want %d", h, v, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"2,3", "abc", "1,x"} {
+		h := http.Header{}
+		h.Set(VersionsHeader, bad)
+		if _, err := Negotiate(h); !errors.Is(err, ErrMismatch) {
+			t.Errorf("Negotiate(%q) = %v, want ErrMismatch", bad, err)
+		}
+	}
+	h := http.Header{}
+	h.Set(Header, "2")
+	if _, err := Negotiate(h); err == nil || !strings.Contains(err.Error(), "peer speaks versions 2") {
+		t.Fatalf("unclear error: %v", err)
+	}
+}
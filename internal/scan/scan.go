@@ -0,0 +1,257 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scan holds the heuristics ojster uses to spot values that should be
+// sealed but are not.
+package scan
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ojster/ojster/internal/gpg"
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// secretNameRe matches key names that conventionally hold secrets.
+var secretNameRe = regexp.MustCompile(`(?i)(passw(or)?d|passphrase|secret|token|api_?key|private_?key|access_?key|credential|auth|pwd)`)
+
+// SecretName reports whether an env key name suggests it holds a secret,
+// e.g. DB_PASSWORD, API_TOKEN or STRIPE_SECRET_KEY.
+func SecretName(key string) bool {
+	return secretNameRe.MatchString(key)
+}
+
+// Sealed reports whether v is sealed in any of the formats ojster produces.
+func Sealed(v string) bool {
+	return pqc.IsSealed(v) || gpg.IsSealed(v)
+}
+
+// Plaintext reports whether key=value looks like a secret stored in the
+// clear: the key name suggests a secret and the value is non-empty, not
+// sealed and not merely a reference to another variable.
+func Plaintext(key, value string) bool {
+	return value != "" && SecretName(key) && !Sealed(value) && !env.HasReference(value)
+}
+
+// patterns are well-known credential formats, checked regardless of key name.
+var patterns = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), "contains an AWS access key ID"},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), "contains a JWT"},
+	{regexp.MustCompile(`-----BEGIN ([A-Z]+ )*PRIVATE KEY( BLOCK)?-----`), "contains a private key block"},
+}
+
+var hexRe = regexp.MustCompile(`^[0-9a-fA-F-]+$`)
+
+// Entropy returns the Shannon entropy of s in bits per byte.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// HighEntropy reports whether v looks like random key material: a single
+// token of at least 20 bytes whose entropy is close to that of random hex or
+// base64. Non-hex tokens must mix letters and digits, which keeps long
+// identifiers and host names out. Values with whitespace and URLs are never
+// considered.
+func HighEntropy(v string) bool {
+	if len(v) < 20 || strings.ContainsAny(v, " \t\n") || strings.Contains(v, "://") {
+		return false
+	}
+	if hexRe.MatchString(v) {
+		return len(v) >= 32 && Entropy(v) >= 3.0
+	}
+	return strings.ContainsAny(v, "0123456789") && strings.IndexFunc(v, unicode.IsLetter) >= 0 && Entropy(v) >= 4.0
+}
+
+// Value returns why key=value should probably be sealed, or "" if it looks
+// fine. Sealed values and variable references are never reported.
+func Value(key, value string) string {
+	if value == "" || Sealed(value) || env.HasReference(value) {
+		return ""
+	}
+	for _, p := range patterns {
+		if p.re.MatchString(value) {
+			return p.reason
+		}
+	}
+	if SecretName(key) {
+		return "looks like a plaintext secret"
+	}
+	if HighEntropy(value) {
+		return "is a high-entropy value"
+	}
+	return ""
+}
+
+// Finding is a value that should probably be sealed.
+type Finding struct {
+	Path   string
+	Line   int
+	Key    string
+	Reason string
+}
+
+// String renders "path:line: KEY reason"; line and key are omitted when
+// unset, for findings about a whole file.
+func (f Finding) String() string {
+	loc := f.Path
+	if f.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, f.Line)
+	}
+	if f.Key == "" {
+		return loc + ": " + f.Reason
+	}
+	return loc + ": " + f.Key + " " + f.Reason
+}
+
+// IsEnvFile reports whether path is named like an env file: ".env",
+// ".env.<anything>" or "<anything>.env".
+func IsEnvFile(path string) bool {
+	base := filepath.Base(path)
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// IsPrivateKeyFile reports whether path is named like an ojster private key.
+func IsPrivateKeyFile(path string) bool {
+	return filepath.Base(path) == pqc.DefaultPrivFile()
+}
+
+// EnvData returns a Finding for each value in the env file content data that
+// should probably be sealed (see Value), in file order. Content that cannot
+// be parsed, such as a file beyond env.DefaultLimits, is one Finding for the
+// whole file, since its values go unchecked.
+func EnvData(path string, data []byte) []Finding {
+	doc, err := env.ParseDocument(data)
+	if err != nil {
+		return []Finding{{Path: path, Reason: "cannot be checked: " + err.Error()}}
+	}
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, k := range doc.Keys() {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		v, _, _ := doc.Get(k)
+		if reason := Value(k, v); reason != "" {
+			findings = append(findings, Finding{Path: path, Line: doc.Line(k), Key: k, Reason: reason})
+		}
+	}
+	return findings
+}
+
+// IsComposeFile reports whether path is named like a compose file.
+func IsComposeFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(base)
+	if ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	return strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose")
+}
+
+var (
+	// yamlEnvEntryRe matches "KEY: value" and "- KEY=value" lines whose key
+	// is named like an environment variable (upper case), as used in
+	// environment sections; other compose keys are lower case.
+	yamlEnvEntryRe = regexp.MustCompile(`^\s*(?:-\s+["']?([A-Z_][A-Z0-9_]*)=(.*?)["']?|["']?([A-Z_][A-Z0-9_]*)["']?:\s+(.*))\s*$`)
+	yamlCommentRe  = regexp.MustCompile(`\s+#.*$`)
+)
+
+// ComposeData returns findings for a compose file: environment-style entries
+// are judged by Value, and credential patterns are reported on any line.
+func ComposeData(path string, data []byte) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if m := yamlEnvEntryRe.FindStringSubmatch(line); m != nil {
+			key, value := m[1], m[2]
+			if key == "" {
+				key, value = m[3], strings.Trim(yamlCommentRe.ReplaceAllString(m[4], ""), `"'`)
+			}
+			if reason := Value(key, value); reason != "" {
+				findings = append(findings, Finding{Path: path, Line: i + 1, Key: key, Reason: reason})
+			}
+			continue
+		}
+		for _, p := range patterns {
+			if p.re.MatchString(line) {
+				findings = append(findings, Finding{Path: path, Line: i + 1, Reason: p.reason})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// Dir walks root, skipping hidden directories, and returns the findings for
+// every env and compose file below it.
+func Dir(root string) ([]Finding, error) {
+	var findings []Finding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		env, compose := IsEnvFile(path), IsComposeFile(path)
+		if !env && !compose {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if env {
+			findings = append(findings, EnvData(path, data)...)
+		} else {
+			findings = append(findings, ComposeData(path, data)...)
+		}
+		return nil
+	})
+	return findings, err
+}
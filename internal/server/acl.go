@@ -0,0 +1,155 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ACL is the file given to "serve --acl". It lists which key names (path.Match
+// patterns) each client may decrypt, for example:
+//
+//	{"clients": [
+//	  {"name": "app1-web-1", "allow": ["DB_*", "API_KEY"]},
+//	  {"container_id": "4f2a9c1e7b3d", "allow": ["CACHE_*"]},
+//	  {"uid": 1000, "allow": ["*"]}
+//	]}
+//
+// A client matches a peer when all of its set fields do, and a peer may
+// decrypt the keys allowed by any client it matches. Peers that match no
+// client may decrypt nothing.
+type ACL struct {
+	Clients []ACLClient `json:"clients"`
+}
+
+// ACLClient identifies a client by container name (which needs a Docker
+// socket), container ID or its prefix, and/or UID.
+type ACLClient struct {
+	Name        string   `json:"name,omitempty"`
+	ContainerID string   `json:"container_id,omitempty"`
+	UID         *int     `json:"uid,omitempty"`
+	Allow       []string `json:"allow"`
+}
+
+// LoadACL reads and validates the ACL file at path. Unknown fields are
+// rejected so a typo cannot silently widen a client's access.
+func LoadACL(path string) (*ACL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACL file %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var acl ACL
+	if err := dec.Decode(&acl); err != nil {
+		return nil, fmt.Errorf("invalid ACL file %s: %w", path, err)
+	}
+	if err := acl.validate(); err != nil {
+		return nil, fmt.Errorf("invalid ACL file %s: %w", path, err)
+	}
+	return &acl, nil
+}
+
+// containerIDPrefixRegex requires the 12 characters Docker shows at least, so
+// a short prefix cannot match unrelated containers.
+var containerIDPrefixRegex = regexp.MustCompile(`^[0-9a-f]{12,64}$`)
+
+func (a *ACL) validate() error {
+	if len(a.Clients) == 0 {
+		return errors.New("no clients configured")
+	}
+	for i, c := range a.Clients {
+		switch {
+		case c.Name == "" && c.ContainerID == "" && c.UID == nil:
+			return fmt.Errorf("client %d: name, container_id or uid is required", i+1)
+		case c.ContainerID != "" && !containerIDPrefixRegex.MatchString(c.ContainerID):
+			return fmt.Errorf("client %d: container_id must be 12 to 64 lowercase hex characters", i+1)
+		}
+		if err := validatePatterns(c.Allow); err != nil {
+			return fmt.Errorf("client %d: invalid allow pattern %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// needsNames reports whether a client is identified by container name.
+func (a *ACL) needsNames() bool {
+	for _, c := range a.Clients {
+		if c.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c ACLClient) matches(peer Peer) bool {
+	return (c.Name == "" || c.Name == peer.Name) &&
+		(c.ContainerID == "" || (peer.ContainerID != "" && strings.HasPrefix(peer.ContainerID, c.ContainerID))) &&
+		(c.UID == nil || *c.UID == peer.UID)
+}
+
+// allowed reports whether peer may decrypt key.
+func (a *ACL) allowed(peer Peer, key string) bool {
+	for _, c := range a.Clients {
+		if c.matches(peer) && keyAllowed(c.Allow, key) {
+			return true
+		}
+	}
+	return false
+}
+
+type aclKey struct{}
+
+// aclHandler makes acl available to the handlers of next.
+func aclHandler(next http.Handler, acl *ACL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), aclKey{}, acl)))
+	})
+}
+
+// checkACL refuses keys the request's peer may not decrypt with 403 and
+// writes the refusal to the request log. Without an ACL every key passes.
+func checkACL(w http.ResponseWriter, r *http.Request, incoming map[string]string) *statusError {
+	acl, _ := r.Context().Value(aclKey{}).(*ACL)
+	if acl == nil {
+		return nil
+	}
+	peer, ok := peerFromContext(r.Context())
+	var denied []string
+	for k := range incoming {
+		if !ok || !acl.allowed(peer, k) {
+			denied = append(denied, k)
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	slices.Sort(denied)
+	who := "unidentified peer"
+	if ok {
+		who = peer.String()
+	}
+	fmt.Fprintf(logw, "acl: denied %s on %s to %s request_id=%s\n", strings.Join(denied, ","), r.URL.Path, who, w.Header().Get(RequestIDHeader))
+	return &statusError{"key not allowed for this client: " + denied[0], http.StatusForbidden}
+}
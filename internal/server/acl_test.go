@@ -0,0 +1,133 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadACL(t *testing.T) {
+	write := func(content string) string {
+		p := filepath.Join(t.TempDir(), "acl.json")
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	acl, err := LoadACL(write(`{"clients": [
+		{"name": "app1-web-1", "allow": ["DB_*"]},
+		{"container_id": "0123456789ab", "uid": 1000, "allow": ["*"]}]}`))
+	if err != nil {
+		t.Fatalf("LoadACL: %v", err)
+	}
+	if len(acl.Clients) != 2 || *acl.Clients[1].UID != 1000 || !acl.needsNames() {
+		t.Fatalf("unexpected ACL %+v", acl)
+	}
+
+	for name, tc := range map[string]struct{ content, want string }{
+		"empty":         {`{"clients": []}`, "no clients"},
+		"no identity":   {`{"clients": [{"allow": ["*"]}]}`, "name, container_id or uid is required"},
+		"short id":      {`{"clients": [{"container_id": "0123", "allow": ["*"]}]}`, "12 to 64 lowercase hex"},
+		"bad pattern":   {`{"clients": [{"uid": 0, "allow": ["["]}]}`, "client 1: invalid allow pattern"},
+		"unknown field": {`{"clients": [{"uid": 0, "keys": ["*"]}]}`, "unknown field"},
+	} {
+		if _, err := LoadACL(write(tc.content)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+	if _, err := LoadACL(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
+
+func TestACL_Allowed(t *testing.T) {
+	uid := 1000
+	acl := &ACL{Clients: []ACLClient{
+		{Name: "web", Allow: []string{"DB_*"}},
+		{ContainerID: testContainerID[:12], UID: &uid, Allow: []string{"CACHE_URL"}},
+	}}
+	web := Peer{PID: 1, UID: 0, ContainerID: testContainerID, Name: "web"}
+	for _, tc := range []struct {
+		peer Peer
+		key  string
+		want bool
+	}{
+		{web, "DB_PASSWORD", true},
+		{web, "CACHE_URL", false}, // the container matches, the UID does not
+		{Peer{UID: 1000, ContainerID: testContainerID}, "CACHE_URL", true},
+		{Peer{UID: 1000}, "CACHE_URL", false},
+		{Peer{UID: 1000, Name: "other"}, "DB_PASSWORD", false},
+	} {
+		if got := acl.allowed(tc.peer, tc.key); got != tc.want {
+			t.Errorf("allowed(%v, %s) = %v, want %v", tc.peer, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestHandlePost_ACL(t *testing.T) {
+	origUnseal, origLogw := unsealMapFunc, logw
+	t.Cleanup(func() { unsealMapFunc, logw = origUnseal, origLogw })
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		return envMap, nil
+	}
+	var logBuf bytes.Buffer
+	logw = &logBuf
+
+	acl := &ACL{Clients: []ACLClient{{Name: "web", Allow: []string{"DB_*"}}}}
+	h := aclHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlePost(w, r, testConfig(), nil, "/tmp/key", nil, singleDelivery{})
+	}), acl)
+	post := func(peer *Peer, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if peer != nil {
+			req = req.WithContext(context.WithValue(req.Context(), peerKey{}, &peerConn{peer: *peer}))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	web := &Peer{PID: 42, UID: 1000, ContainerID: testContainerID, Name: "web"}
+	ExpectStatus(t, post(web, `{"DB_PASSWORD":"x"}`), http.StatusOK)
+
+	rec := post(web, `{"DB_PASSWORD":"x","API_KEY":"y"}`)
+	ExpectStatus(t, rec, http.StatusForbidden)
+	expectBodyContains(t, rec, "key not allowed for this client: API_KEY")
+	if want := "acl: denied API_KEY on / to pid=42 uid=1000 container=0123456789ab name=web"; !strings.Contains(logBuf.String(), want) {
+		t.Fatalf("log %q lacks %q", logBuf.String(), want)
+	}
+
+	ExpectStatus(t, post(nil, `{"DB_PASSWORD":"x"}`), http.StatusForbidden)
+	if !strings.Contains(logBuf.String(), "to unidentified peer") {
+		t.Fatalf("log %q lacks the unidentified peer", logBuf.String())
+	}
+}
+
+func TestServeSockets_ACLNeedsDockerSocketForNames(t *testing.T) {
+	var errBuf bytes.Buffer
+	acl := &ACL{Clients: []ACLClient{{Name: "web", Allow: []string{"*"}}}}
+	code := serveSockets([]Socket{{Path: filepath.Join(t.TempDir(), "s"), PrivateKeyFile: "/k"}}, context.Background(), ServeOptions{ACL: acl}, &bytes.Buffer{}, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "--docker-socket") {
+		t.Fatalf("expected refusal, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// batchPath is appended to a route path (or used alone for "/") to decrypt
+// several env maps in one request.
+const batchPath = "/batch"
+
+// BatchEntry is one named env map of a batch request, and its result in the
+// reply. A reply entry has either Env or Status and Error set.
+type BatchEntry struct {
+	Name   string            `json:"name"`
+	Env    map[string]string `json:"env,omitempty"`
+	Status int               `json:"status,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// handleBatch decrypts a JSON array of BatchEntry, one at a time and with the
+// same key, command, allow list, ACL and single-delivery keys as handlePost.
+// A malformed batch, or one holding more keys in all than a single request
+// may, is rejected as a whole; otherwise the reply is 200 with one result
+// per entry, in request order, so one failing entry does not hold back the
+// others.
+func handleBatch(w http.ResponseWriter, r *http.Request, cfg *unsealConfig, cmdArgs []string, privateKeyFile string, allow []string, single singleDelivery) {
+	defer r.Body.Close()
+
+	if isCBOR(r.Header.Get("Content-Type")) {
+		httpError(w, "batch requests must be JSON", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var entries []BatchEntry
+	{
+		buf, err := readBody(r)
+		if err != nil {
+			httpError(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer putBuf(buf)
+		if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+			httpError(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	seen := make(map[string]bool, len(entries))
+	keys := 0
+	for i, e := range entries {
+		switch {
+		case e.Name == "":
+			httpError(w, "batch entry "+strconv.Itoa(i+1)+": name is required", http.StatusBadRequest)
+			return
+		case seen[e.Name]:
+			httpError(w, "batch entry "+e.Name+" is listed twice", http.StatusBadRequest)
+			return
+		}
+		seen[e.Name] = true
+		keys += len(e.Env)
+	}
+	if keys > cfg.maxKeys {
+		httpError(w, fmt.Sprintf("batch has %d keys, more than the limit of %d", keys, cfg.maxKeys), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	results := make([]BatchEntry, len(entries))
+	for i, e := range entries {
+		results[i].Name = e.Name
+		serr := checkACL(w, r, e.Env)
+		if serr != nil {
+			results[i].Status, results[i].Error = serr.code, scrubber.String(serr.msg)
+			continue
+		}
+		out, serr := unsealRequest(r.Context(), cfg, e.Env, r.URL.Path, cmdArgs, privateKeyFile, allow, single)
+		if serr != nil {
+			results[i].Status, results[i].Error = serr.code, scrubber.String(serr.msg)
+			continue
+		}
+		results[i].Env = out
+	}
+
+	buf := getBuf()
+	defer putBuf(buf)
+	_ = json.NewEncoder(buf).Encode(results)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+func TestHandleBatch(t *testing.T) {
+	orig := unsealMapFunc
+	defer func() { unsealMapFunc = orig }()
+	sealed := pqc.BuildSealed([]byte{0x01}, []byte{0x02})
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		if _, ok := envMap["BROKEN"]; ok {
+			return nil, errors.New("failed to decrypt BROKEN=" + sealed)
+		}
+		out := make(map[string]string, len(envMap))
+		for k := range envMap {
+			out[k] = privPath
+		}
+		return out, nil
+	}
+
+	mux := newMux(Socket{Routes: []Route{{Path: "/projectA", PrivateKeyFile: "/keys/a", Allow: []string{"DB_*"}}}}, testConfig())
+	body := `[{"name": "web", "env": {"DB_PASS": "x"}},
+		{"name": "db", "env": {"DB_USER": "y", "API_KEY": "z"}},
+		{"name": "worker", "env": {"DB_BROKEN": "w", "BROKEN": "w"}}]`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/projectA/batch", strings.NewReader(body)))
+	ExpectStatus(t, rec, http.StatusOK)
+
+	var got []BatchEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	want := []BatchEntry{
+		{Name: "web", Env: map[string]string{"DB_PASS": "/keys/a"}},
+		{Name: "db", Status: http.StatusForbidden, Error: "key not allowed on /projectA/batch: API_KEY"},
+		{Name: "worker", Status: http.StatusForbidden, Error: "key not allowed on /projectA/batch: BROKEN"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("batch reply = %+v, want %+v", got, want)
+	}
+
+	// Without routes "/batch" uses the socket key, and errors are scrubbed.
+	mux = newMux(Socket{PrivateKeyFile: "/keys/root"}, testConfig())
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(`[{"name": "a", "env": {"K": "v"}}, {"name": "b", "env": {"BROKEN": "v"}}]`)))
+	ExpectStatus(t, rec, http.StatusOK)
+	got = nil
+	_ = json.Unmarshal(rec.Body.Bytes(), &got)
+	if len(got) != 2 || got[0].Env["K"] != "/keys/root" || got[1].Status != http.StatusBadGateway || strings.Contains(got[1].Error, sealed) {
+		t.Fatalf("unexpected batch reply %s", rec.Body.String())
+	}
+}
+
+func TestHandleBatch_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, contentType string
+		code                    int
+		want                    string
+	}{
+		{"invalid_json", `{"name": "a"}`, "", http.StatusBadRequest, "invalid JSON"},
+		{"no_name", `[{"env": {"K": "v"}}]`, "", http.StatusBadRequest, "batch entry 1: name is required"},
+		{"duplicate", `[{"name": "a"}, {"name": "a"}]`, "", http.StatusBadRequest, "batch entry a is listed twice"},
+		{"cbor", `[]`, "application/cbor", http.StatusUnsupportedMediaType, "must be JSON"},
+		{"too_many_keys", `[{"name": "a", "env": {"A": "x", "B": "y"}}, {"name": "b", "env": {"C": "z"}}]`, "", http.StatusRequestEntityTooLarge, "batch has 3 keys, more than the limit of 2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := unsealMapFunc
+			defer func() { unsealMapFunc = orig }()
+			unsealMapFunc = func(context.Context, map[string]string, string, []string) (map[string]string, error) {
+				t.Fatal("a rejected batch was unsealed")
+				return nil, nil
+			}
+			cfg := testConfig()
+			cfg.maxKeys = 2
+			req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			handleBatch(rec, req, cfg, nil, "/x", nil, singleDelivery{})
+			ExpectStatus(t, rec, tc.code)
+			expectBodyContains(t, rec, tc.want)
+		})
+	}
+}
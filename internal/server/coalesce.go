@@ -0,0 +1,119 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// flightGroup runs one unseal for identical requests that arrive while it is
+// in progress and hands its result to all of them, so replicas that start
+// together cost one subprocess or set of decapsulations instead of one each.
+// Nothing is cached: a request that arrives afterwards unseals again.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[[sha256.Size]byte]*flight
+}
+
+// flight is one unseal in progress; done is closed once out and serr are set.
+// ctx is cancelled once none of the callers waits for the result any more.
+type flight struct {
+	done    chan struct{}
+	out     map[string]string
+	serr    *statusError
+	callers int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+var unsealFlights = &flightGroup{calls: map[[sha256.Size]byte]*flight{}}
+
+// do runs fn, unless an identical call is already running, in which case it
+// waits for that call and returns a copy of its result. fn gets a context
+// that outlives ctx while other callers still wait for it, so one client
+// hanging up does not fail the requests that joined its unseal. A waiter
+// whose ctx is done returns errCanceled at once.
+func (g *flightGroup) do(ctx context.Context, key [sha256.Size]byte, fn func(context.Context) (map[string]string, *statusError)) (map[string]string, *statusError) {
+	g.mu.Lock()
+	f, joined := g.calls[key]
+	if !joined {
+		f = &flight{done: make(chan struct{})}
+		f.ctx, f.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		g.calls[key] = f
+	}
+	f.callers++
+	g.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() { g.leave(key, f) })
+	defer stop()
+
+	if joined {
+		select {
+		case <-f.done:
+			return maps.Clone(f.out), f.serr
+		case <-ctx.Done():
+			return nil, errCanceled
+		}
+	}
+	f.out, f.serr = fn(f.ctx)
+	f.cancel()
+	g.mu.Lock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(f.done)
+	return f.out, f.serr
+}
+
+// leave drops a caller of f whose context is done. When it was the last one,
+// the unseal is cancelled and later identical requests start their own.
+func (g *flightGroup) leave(key [sha256.Size]byte, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f.callers--; f.callers > 0 {
+		return
+	}
+	f.cancel()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
+
+// flightKey identifies an unseal by everything its result depends on: the
+// incoming pairs, the command and the private key file.
+func flightKey(incoming map[string]string, cmd []string, privateKeyFile string) [sha256.Size]byte {
+	h := sha256.New()
+	field := func(s string) {
+		_ = binary.Write(h, binary.BigEndian, uint64(len(s)))
+		h.Write([]byte(s))
+	}
+	field(privateKeyFile)
+	_ = binary.Write(h, binary.BigEndian, uint64(len(cmd)))
+	for _, arg := range cmd {
+		field(arg)
+	}
+	for _, k := range slices.Sorted(maps.Keys(incoming)) {
+		field(k)
+		field(incoming[k])
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
@@ -0,0 +1,163 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUnsealRequest_Coalesces(t *testing.T) {
+	origUnseal := unsealMapFunc
+	t.Cleanup(func() { unsealMapFunc = origUnseal })
+	var calls atomic.Int32
+	release := make(chan struct{})
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		calls.Add(1)
+		<-release
+		return envMap, nil
+	}
+
+	incoming := map[string]string{"FOO": "sealed-foo", "BAR": "sealed-bar"}
+	key := flightKey(incoming, nil, "/k")
+	const replicas = 5
+	var wg sync.WaitGroup
+	outs := make([]map[string]string, replicas)
+	for i := range replicas {
+		wg.Go(func() {
+			out, serr := unsealRequest(context.Background(), testConfig(), incoming, "/", nil, "/k", nil, singleDelivery{})
+			if serr != nil {
+				t.Errorf("replica %d: %s", i, serr.msg)
+			}
+			outs[i] = out
+		})
+	}
+	// Wait until every replica has joined the first one's unseal.
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+		unsealFlights.mu.Lock()
+		f := unsealFlights.calls[key]
+		joined := f != nil && f.callers == replicas
+		unsealFlights.mu.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replicas did not join one unseal")
+		}
+	}
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("unsealed %d times, want 1", n)
+	}
+	for i, out := range outs {
+		if out["FOO"] != "sealed-foo" || out["BAR"] != "sealed-bar" {
+			t.Errorf("replica %d got %v", i, out)
+		}
+	}
+
+	// Only concurrent requests share a result.
+	if _, serr := unsealRequest(context.Background(), testConfig(), incoming, "/", nil, "/k", nil, singleDelivery{}); serr != nil {
+		t.Fatal(serr.msg)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("unsealed %d times after a later request, want 2", n)
+	}
+}
+
+func TestUnsealRequest_CanceledWhenAllLeave(t *testing.T) {
+	origUnseal := unsealMapFunc
+	t.Cleanup(func() { unsealMapFunc = origUnseal })
+	started := make(chan struct{})
+	unsealMapFunc = func(ctx context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	incoming := map[string]string{"FOO": "sealed-foo"}
+	key := flightKey(incoming, nil, "/k")
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	results := make(chan *statusError, 2)
+	go func() {
+		_, serr := unsealRequest(first, testConfig(), incoming, "/", nil, "/k", nil, singleDelivery{})
+		results <- serr
+	}()
+	<-started
+	go func() {
+		_, serr := unsealRequest(second, testConfig(), incoming, "/", nil, "/k", nil, singleDelivery{})
+		results <- serr
+	}()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+		unsealFlights.mu.Lock()
+		f := unsealFlights.calls[key]
+		joined := f != nil && f.callers == 2
+		unsealFlights.mu.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second request did not join the unseal")
+		}
+	}
+
+	// The first client leaving does not stop the unseal the second waits for.
+	cancelFirst()
+	select {
+	case serr := <-results:
+		t.Fatalf("a request returned while one still waited: %v", serr)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelSecond()
+	for range 2 {
+		select {
+		case serr := <-results:
+			if serr != errCanceled {
+				t.Fatalf("expected errCanceled, got %v", serr)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("unseal was not cancelled after every client left")
+		}
+	}
+	unsealFlights.mu.Lock()
+	defer unsealFlights.mu.Unlock()
+	if _, ok := unsealFlights.calls[key]; ok {
+		t.Fatal("cancelled unseal is still joinable")
+	}
+}
+
+func TestFlightKey(t *testing.T) {
+	base := flightKey(map[string]string{"A": "1", "B": "2"}, []string{"cmd"}, "/k")
+	if flightKey(map[string]string{"B": "2", "A": "1"}, []string{"cmd"}, "/k") != base {
+		t.Error("key depends on map order")
+	}
+	for name, other := range map[string][32]byte{
+		"value":   flightKey(map[string]string{"A": "1", "B": "3"}, []string{"cmd"}, "/k"),
+		"subset":  flightKey(map[string]string{"A": "1"}, []string{"cmd"}, "/k"),
+		"command": flightKey(map[string]string{"A": "1", "B": "2"}, []string{"cmd", "x"}, "/k"),
+		"key":     flightKey(map[string]string{"A": "1", "B": "2"}, []string{"cmd"}, "/other"),
+		"framing": flightKey(map[string]string{"A": "1B2"}, []string{"cmd"}, "/k"),
+	} {
+		if other == base {
+			t.Errorf("%s does not change the key", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+)
+
+// Config is the file given to "serve --config". It lists the sockets to
+// serve, for example:
+//
+//	{"sockets": [
+//	  {"path": "/mnt/app1/ipc.sock", "private_key_file": "/run/secrets/app1_key"},
+//	  {"path": "/mnt/app2/ipc.sock", "private_key_file": "/run/secrets/app2_key",
+//	   "command": ["/dotenvx", "get", "--format", "json"]},
+//	  {"path": "/mnt/shared/ipc.sock", "routes": [
+//	    {"path": "/projectA", "private_key_file": "/run/secrets/a_key", "allow": ["DB_*"]},
+//	    {"path": "/projectB", "private_key_file": "/run/secrets/b_key"}
+//	  ]}
+//	]}
+type Config struct {
+	Sockets []Socket `json:"sockets"`
+}
+
+// LoadConfig reads and validates the config file at path. Unknown fields are
+// rejected so a typo cannot silently drop a setting.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Sockets) == 0 {
+		return errors.New("no sockets configured")
+	}
+	seen := make(map[string]bool, len(c.Sockets))
+	for i, s := range c.Sockets {
+		switch {
+		case s.Path == "":
+			return fmt.Errorf("socket %d: path is required", i+1)
+		case s.PrivateKeyFile == "" && len(s.Routes) == 0:
+			return fmt.Errorf("socket %s: private_key_file or routes is required", s.Path)
+		case seen[s.Path]:
+			return fmt.Errorf("socket %s is listed twice", s.Path)
+		}
+		seen[s.Path] = true
+		if err := validatePatterns(s.SingleDelivery); err != nil {
+			return fmt.Errorf("socket %s: invalid single_delivery pattern %w", s.Path, err)
+		}
+		if err := validateRoutes(s.Routes); err != nil {
+			return fmt.Errorf("socket %s: %w", s.Path, err)
+		}
+	}
+	return nil
+}
+
+// routePathRegex keeps route paths literal, so they are matched exactly and
+// cannot be read as http.ServeMux wildcards.
+var routePathRegex = regexp.MustCompile(`^(/[A-Za-z0-9._-]+)+$`)
+
+func validateRoutes(routes []Route) error {
+	seen := make(map[string]bool, len(routes))
+	for i, r := range routes {
+		switch {
+		case r.Path == "":
+			return fmt.Errorf("route %d: path is required", i+1)
+		case !routePathRegex.MatchString(r.Path):
+			return fmt.Errorf("route %s: path must look like /name and have no trailing slash", r.Path)
+		case path.Base(r.Path) == path.Base(batchPath), path.Base(r.Path) == path.Base(pubkeyPath):
+			return fmt.Errorf("route %s: paths ending in %s or %s are reserved", r.Path, batchPath, pubkeyPath)
+		case r.PrivateKeyFile == "":
+			return fmt.Errorf("route %s: private_key_file is required", r.Path)
+		case seen[r.Path]:
+			return fmt.Errorf("route %s is listed twice", r.Path)
+		}
+		seen[r.Path] = true
+		if err := validatePatterns(r.Allow); err != nil {
+			return fmt.Errorf("route %s: invalid allow pattern %w", r.Path, err)
+		}
+		if err := validatePatterns(r.SingleDelivery); err != nil {
+			return fmt.Errorf("route %s: invalid single_delivery pattern %w", r.Path, err)
+		}
+	}
+	return nil
+}
+
+// validatePatterns returns the first pattern that path.Match rejects, quoted,
+// as an error.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q", pattern)
+		}
+	}
+	return nil
+}
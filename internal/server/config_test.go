@@ -0,0 +1,76 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	write := func(content string) string {
+		p := filepath.Join(t.TempDir(), "serve.json")
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	cfg, err := LoadConfig(write(`{"sockets": [
+		{"path": "/mnt/a/ipc.sock", "private_key_file": "/k/a"},
+		{"path": "/mnt/b/ipc.sock", "private_key_file": "/k/b", "command": ["/dotenvx", "get"]}]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Sockets) != 2 || cfg.Sockets[1].Command[0] != "/dotenvx" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	cfg, err = LoadConfig(write(`{"sockets": [{"path": "/mnt/s/ipc.sock", "routes": [
+		{"path": "/projectA", "private_key_file": "/k/a", "allow": ["DB_*"]},
+		{"path": "/team/projectB", "private_key_file": "/k/b"}]}]}`))
+	if err != nil {
+		t.Fatalf("LoadConfig with routes: %v", err)
+	}
+	if r := cfg.Sockets[0].Routes; len(r) != 2 || r[0].Allow[0] != "DB_*" || r[1].Path != "/team/projectB" {
+		t.Fatalf("unexpected routes %+v", r)
+	}
+
+	for name, tc := range map[string]struct{ content, want string }{
+		"empty":         {`{"sockets": []}`, "no sockets"},
+		"no path":       {`{"sockets": [{"private_key_file": "/k"}]}`, "path is required"},
+		"no key":        {`{"sockets": [{"path": "/s"}]}`, "private_key_file or routes is required"},
+		"route path":    {`{"sockets": [{"path": "/s", "routes": [{"path": "/a/", "private_key_file": "/k"}]}]}`, "must look like /name"},
+		"route wild":    {`{"sockets": [{"path": "/s", "routes": [{"path": "/{x}", "private_key_file": "/k"}]}]}`, "must look like /name"},
+		"route batch":   {`{"sockets": [{"path": "/s", "routes": [{"path": "/a/batch", "private_key_file": "/k"}]}]}`, "are reserved"},
+		"route pubkey":  {`{"sockets": [{"path": "/s", "routes": [{"path": "/pubkey", "private_key_file": "/k"}]}]}`, "are reserved"},
+		"route key":     {`{"sockets": [{"path": "/s", "routes": [{"path": "/a"}]}]}`, "route /a: private_key_file is required"},
+		"route twice":   {`{"sockets": [{"path": "/s", "routes": [{"path": "/a", "private_key_file": "/k"}, {"path": "/a", "private_key_file": "/j"}]}]}`, "route /a is listed twice"},
+		"route allow":   {`{"sockets": [{"path": "/s", "routes": [{"path": "/a", "private_key_file": "/k", "allow": ["["]}]}]}`, "invalid allow pattern"},
+		"route single":  {`{"sockets": [{"path": "/s", "routes": [{"path": "/a", "private_key_file": "/k", "single_delivery": ["["]}]}]}`, "route /a: invalid single_delivery pattern"},
+		"socket single": {`{"sockets": [{"path": "/s", "private_key_file": "/k", "single_delivery": ["["]}]}`, "socket /s: invalid single_delivery pattern"},
+		"duplicate":     {`{"sockets": [{"path": "/s", "private_key_file": "/k"}, {"path": "/s", "private_key_file": "/j"}]}`, "listed twice"},
+		"unknown field": {`{"sockets": [{"path": "/s", "private_key": "/k"}]}`, "unknown field"},
+	} {
+		if _, err := LoadConfig(write(tc.content)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q error, got %v", name, tc.want, err)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
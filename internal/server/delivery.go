@@ -0,0 +1,76 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "sync"
+
+// singleDelivery is the single-delivery policy of one socket or route:
+// key names matching patterns are released once, then refused until the
+// server restarts or gets SIGHUP. scope tells sockets and routes apart.
+type singleDelivery struct {
+	scope    string
+	patterns []string
+}
+
+// deliveryLog records the single-delivery keys released so far, over all
+// sockets and routes.
+type deliveryLog struct {
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+var delivered = &deliveryLog{done: map[string]bool{}}
+
+// claim reserves the keys that policy covers. It returns the reserved keys,
+// or the first key that was already released (or is being released by a
+// concurrent request), in which case nothing is reserved.
+func (d *deliveryLog) claim(policy singleDelivery, keys map[string]struct{}) (claimed []string, taken string) {
+	if len(policy.patterns) == 0 {
+		return nil, ""
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k := range keys {
+		if !keyAllowed(policy.patterns, k) {
+			continue
+		}
+		if d.done[policy.scope+"\x00"+k] {
+			return nil, k
+		}
+		claimed = append(claimed, k)
+	}
+	for _, k := range claimed {
+		d.done[policy.scope+"\x00"+k] = true
+	}
+	return claimed, ""
+}
+
+// release undoes the claim of keys that were not released after all.
+func (d *deliveryLog) release(policy singleDelivery, keys []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range keys {
+		delete(d.done, policy.scope+"\x00"+k)
+	}
+}
+
+// reset forgets all releases and returns how many there were.
+func (d *deliveryLog) reset() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.done)
+	clear(d.done)
+	return n
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ojster/ojster/internal/protocol"
+)
+
+func TestSingleDelivery(t *testing.T) {
+	origDelivered, origUnseal := delivered, unsealMapFunc
+	t.Cleanup(func() { delivered, unsealMapFunc = origDelivered, origUnseal })
+	delivered = &deliveryLog{done: map[string]bool{}}
+	fail := true
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		if fail {
+			return nil, errors.New("decryption failed")
+		}
+		return envMap, nil
+	}
+
+	mux := newMux(Socket{Path: "/s", PrivateKeyFile: "/k", SingleDelivery: []string{"BOOTSTRAP_*"}, Routes: []Route{
+		{Path: "/projectA", PrivateKeyFile: "/a", SingleDelivery: []string{"BOOTSTRAP_*"}},
+	}}, testConfig())
+	post := func(path, body string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		ExpectStatus(t, rec, want)
+		return rec
+	}
+
+	// A failed decryption does not use up the delivery.
+	post("/", `{"BOOTSTRAP_TOKEN": "x"}`, http.StatusBadGateway)
+	fail = false
+	rec := post("/", `{"BOOTSTRAP_TOKEN": "x", "DB_PASS": "y"}`, http.StatusOK)
+	if got := rec.Header().Get(protocol.SingleDeliveryHeader); got != "BOOTSTRAP_TOKEN" {
+		t.Fatalf("%s = %q, want BOOTSTRAP_TOKEN", protocol.SingleDeliveryHeader, got)
+	}
+	rec = post("/", `{"BOOTSTRAP_TOKEN": "x", "DB_PASS": "y"}`, http.StatusGone)
+	if !strings.Contains(rec.Body.String(), "key BOOTSTRAP_TOKEN was already delivered") {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+	if rec := post("/", `{"DB_PASS": "y"}`, http.StatusOK); rec.Header().Get(protocol.SingleDeliveryHeader) != "" {
+		t.Fatalf("reply without single-delivery keys has %s", protocol.SingleDeliveryHeader)
+	}
+	// Routes keep their own record, and batches follow the same policy.
+	post("/projectA", `{"BOOTSTRAP_TOKEN": "x"}`, http.StatusOK)
+	rec = post("/projectA/batch", `[{"name": "a", "env": {"BOOTSTRAP_TOKEN": "x"}}]`, http.StatusOK)
+	var got []BatchEntry
+	_ = json.Unmarshal(rec.Body.Bytes(), &got)
+	if len(got) != 1 || got[0].Status != http.StatusGone {
+		t.Fatalf("unexpected batch reply %s", rec.Body.String())
+	}
+
+	if n := delivered.reset(); n != 2 {
+		t.Fatalf("reset forgot %d deliveries, want 2", n)
+	}
+	post("/", `{"BOOTSTRAP_TOKEN": "x"}`, http.StatusOK)
+}
+
+func TestResetOnHangup(t *testing.T) {
+	origDelivered := delivered
+	t.Cleanup(func() { delivered = origDelivered })
+	delivered = &deliveryLog{done: map[string]bool{"/s/\x00K": true}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hup := make(chan os.Signal)
+	var errBuf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		resetOnHangup(ctx, hup, &errBuf)
+		close(done)
+	}()
+	hup <- syscall.SIGHUP
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resetOnHangup did not return after cancel")
+	}
+	if len(delivered.done) != 0 || !strings.Contains(errBuf.String(), "1 single-delivery keys can be delivered again") {
+		t.Fatalf("not reset: done=%v log=%q", delivered.done, errBuf.String())
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/ojster/ojster/internal/harden"
+)
+
+// IsolatedExecCommand is the hidden ojster subcommand that subprocess
+// commands start through when ServeOptions.IsolateSubprocess is set.
+const IsolatedExecCommand = "isolated-exec"
+
+// isolateHelper returns the program and leading arguments that run
+// IsolatedExec. It is a var so tests can use the test binary.
+var isolateHelper = func() (string, []string, error) {
+	self, err := os.Executable()
+	return self, []string{IsolatedExecCommand}, err
+}
+
+// libraryPaths hold the ELF interpreter and shared libraries of dynamically
+// linked commands. IsolatedExec grants those that exist. It is a var so tests
+// can replace it.
+var libraryPaths = []string{"/lib", "/lib64", "/usr/lib", "/usr/lib64", "/usr/local/lib", "/etc/ld.so.cache"}
+
+// IsolatedExec confines itself with Landlock to the command, /dev/null, the
+// libraryPaths and the paths given with --read, then execs the command given
+// after "--". It refuses to run the command unconfined. It only returns on
+// failure.
+func IsolatedExec(args []string, errw io.Writer) int {
+	fs := flag.NewFlagSet(IsolatedExecCommand, flag.ContinueOnError)
+	fs.SetOutput(errw)
+	var readable []string
+	fs.Func("read", "path the command may read (repeatable)", func(p string) error {
+		readable = append(readable, p)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fmt.Fprintf(errw, "%s needs a command\n", IsolatedExecCommand)
+		return 2
+	}
+
+	rules := []harden.FSRule{
+		{Path: argv[0], Access: harden.FSRead | harden.FSExec},
+		{Path: os.DevNull, Access: harden.FSRead | harden.FSWrite},
+	}
+	for _, p := range libraryPaths {
+		if _, err := os.Stat(p); err == nil {
+			rules = append(rules, harden.FSRule{Path: p, Access: harden.FSRead | harden.FSExec})
+		}
+	}
+	for _, p := range readable {
+		rules = append(rules, harden.FSRule{Path: p, Access: harden.FSRead})
+	}
+	if err := landlockFunc(rules); err != nil {
+		fmt.Fprintf(errw, "refusing to run %s unconfined: %v\n", argv[0], err)
+		return 1
+	}
+	err := syscall.Exec(argv[0], argv, os.Environ())
+	fmt.Fprintf(errw, "failed to exec %s: %v\n", argv[0], err)
+	return 1
+}
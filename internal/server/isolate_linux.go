@@ -0,0 +1,53 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// isolate makes cmd start in fresh user, mount, PID, network, IPC and UTS
+// namespaces, through IsolatedExec, which limits the files it can read to
+// readable. Inside, the command runs as the server's own UID and GID, sees
+// no network, and cannot see or signal processes outside its namespace.
+func isolate(cmd *exec.Cmd, readable []string) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	helper, helperArgs, err := isolateHelper()
+	if err != nil {
+		return err
+	}
+	args := append([]string{helper}, helperArgs...)
+	for _, p := range readable {
+		args = append(args, "--read", p)
+	}
+	args = append(args, "--", cmd.Path)
+	cmd.Path, cmd.Args = helper, append(args, cmd.Args[1:]...)
+
+	uid, gid := os.Getuid(), os.Getgid()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	return nil
+}
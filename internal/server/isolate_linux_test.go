@@ -0,0 +1,103 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/harden"
+)
+
+// TestHandlePost_Isolated uses the test binary as both IsolatedExec and the
+// command, so it only runs where Landlock works: a static build
+// (CGO_ENABLED=0) on a kernel that allows user namespaces.
+func TestHandlePost_Isolated(t *testing.T) {
+	if os.Getenv("OJSTER_TEST_ISOLATED_EXEC") != "" {
+		args := flag.Args()
+		if len(args) == 3 && args[0] == "check" {
+			env, _ := os.ReadFile(".env")
+			key, _ := os.ReadFile(".env.keys")
+			_, otherErr := os.ReadFile(args[2])
+			if !strings.HasPrefix(string(env), "FOO=bar") || string(key) != args[1] || otherErr == nil || os.Getpid() != 1 {
+				fmt.Printf(`{"FOO":"env=%q key=%q other=%v pid=%d"}`, env, key, otherErr, os.Getpid())
+				os.Exit(0)
+			}
+			fmt.Print(`{"FOO":"ok"}`)
+			os.Exit(0)
+		}
+		os.Exit(IsolatedExec(args, os.Stderr))
+	}
+	t.Setenv("OJSTER_TEST_ISOLATED_EXEC", "1")
+	origHelper := isolateHelper
+	t.Cleanup(func() { isolateHelper = origHelper })
+	testArgs := []string{"-test.run=^TestHandlePost_Isolated$", "--"}
+	isolateHelper = func() (string, []string, error) { return os.Args[0], testArgs, nil }
+
+	td := t.TempDir()
+	key := filepath.Join(td, "key")
+	other := filepath.Join(td, "other")
+	for _, p := range []string{key, other} {
+		if err := os.WriteFile(p, []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := append(append([]string{os.Args[0]}, testArgs...), "check", "secret", other)
+	cfg := testConfig()
+	cfg.isolateSubprocess = true
+	rec := runPostWith(t, cfg, []byte(`{"FOO":"bar"}`), cmd, key)
+	if rec.Code == http.StatusBadGateway {
+		t.Skipf("isolation unavailable here: %s", rec.Body.String())
+	}
+	ExpectStatus(t, rec, http.StatusOK)
+	expectBodyContains(t, rec, `"FOO":"ok"`)
+}
+
+func TestIsolatedExec_LibraryPaths(t *testing.T) {
+	td := t.TempDir()
+	origPaths, origLandlock := libraryPaths, landlockFunc
+	t.Cleanup(func() { libraryPaths, landlockFunc = origPaths, origLandlock })
+	libraryPaths = []string{td, filepath.Join(td, "missing")}
+	var got []harden.FSRule
+	landlockFunc = func(rules []harden.FSRule) error {
+		got = rules
+		return errors.New("no landlock in this test")
+	}
+
+	var errb bytes.Buffer
+	if code := IsolatedExec([]string{"--read", "/r", "--", "/bin/cmd"}, &errb); code != 1 {
+		t.Fatalf("exit %d, want 1: %s", code, errb.String())
+	}
+	if !strings.Contains(errb.String(), "refusing to run /bin/cmd unconfined") {
+		t.Fatalf("unexpected stderr %q", errb.String())
+	}
+	want := []harden.FSRule{
+		{Path: "/bin/cmd", Access: harden.FSRead | harden.FSExec},
+		{Path: os.DevNull, Access: harden.FSRead | harden.FSWrite},
+		{Path: td, Access: harden.FSRead | harden.FSExec},
+		{Path: "/r", Access: harden.FSRead},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rules = %v, want %v", got, want)
+	}
+}
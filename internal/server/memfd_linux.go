@@ -0,0 +1,79 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (amd64 || arm64)
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const mfdCloexec = 0x1
+
+// stageFiles gives the subprocess its .env in dir as a symlink to
+// /proc/self/fd/3, which resolves to a memfd only inside the subprocess,
+// which gets it as ExtraFiles[0]. The request's values thus never have a
+// pathname another process could open. .env.keys links to the private key
+// file as before: that file is on disk anyway. Without memfd_create or /proc
+// it falls back to writeFiles.
+func stageFiles(dir string, envData []byte, privateKeyFile string) ([]*os.File, error) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		return nil, writeFiles(dir, envData, privateKeyFile)
+	}
+	envFile, err := memfdWith("ojster-env", envData)
+	if errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EPERM) {
+		return nil, writeFiles(dir, envData, privateKeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write .env file: %v", err)
+	}
+	if err := os.Symlink("/proc/self/fd/3", filepath.Join(dir, ".env")); err != nil {
+		envFile.Close()
+		return nil, fmt.Errorf("failed to write .env file: %v", err)
+	}
+	if err := os.Symlink(privateKeyFile, filepath.Join(dir, ".env.keys")); err != nil {
+		envFile.Close()
+		return nil, fmt.Errorf("failed to create symlink to private key file: %v", err)
+	}
+	return []*os.File{envFile}, nil
+}
+
+// memfdWith returns a memfd named name holding data, positioned at the start.
+func memfdWith(name string, data []byte) (*os.File, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), mfdCloexec, 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("memfd_create: %w", errno)
+	}
+	f := os.NewFile(fd, "memfd:"+name)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
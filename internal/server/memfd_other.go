@@ -0,0 +1,25 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux || !(amd64 || arm64)
+
+package server
+
+import "os"
+
+// stageFiles writes the subprocess's .env and .env.keys into dir; memfds
+// are only used on Linux.
+func stageFiles(dir string, envData []byte, privateKeyFile string) ([]*os.File, error) {
+	return nil, writeFiles(dir, envData, privateKeyFile)
+}
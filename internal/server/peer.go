@@ -0,0 +1,201 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Peer identifies the process at the other end of a Unix socket connection.
+type Peer struct {
+	PID, UID int
+	// ContainerID is the full ID of the peer's container, empty when it is
+	// not in one or its PID is not visible to the server (which needs the
+	// host PID namespace, e.g. "pid: host", to see other containers).
+	ContainerID string
+	// Name is the container name, when a Docker socket is configured.
+	Name string
+}
+
+// String formats p for log lines.
+func (p Peer) String() string {
+	s := "pid=" + strconv.Itoa(p.PID) + " uid=" + strconv.Itoa(p.UID)
+	if p.ContainerID != "" {
+		s += " container=" + p.ContainerID[:12]
+	}
+	if p.Name != "" {
+		s += " name=" + p.Name
+	}
+	return s
+}
+
+// containerIDRegex finds a container ID in a cgroup path, as Docker,
+// containerd and Podman write them (/docker/<id>, docker-<id>.scope,
+// cri-containerd-<id>.scope, libpod-<id>.scope).
+var containerIDRegex = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// procRoot is a var so tests can use a fake /proc.
+var procRoot = "/proc"
+
+// containerID returns the ID of the container pid runs in, if any.
+func containerID(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	ids := containerIDRegex.FindAll(data, -1)
+	if len(ids) == 0 {
+		return ""
+	}
+	return string(ids[len(ids)-1])
+}
+
+// peerListener identifies the peer of each accepted Unix socket connection.
+type peerListener struct {
+	net.Listener
+	names *containerNames
+}
+
+type peerConn struct {
+	net.Conn
+	peer  Peer
+	names *containerNames
+}
+
+func (l peerListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return c, err
+	}
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return c, nil
+	}
+	pid, uid, err := peerCred(uc)
+	if err != nil {
+		return c, nil
+	}
+	peer := Peer{PID: pid, UID: uid}
+	if pid > 0 {
+		peer.ContainerID = containerID(pid)
+	}
+	return &peerConn{Conn: c, peer: peer, names: l.names}, nil
+}
+
+type peerKey struct{}
+
+// peerConnContext makes the peer of a peerConn available to handlers.
+func peerConnContext(ctx context.Context, c net.Conn) context.Context {
+	if pc, ok := c.(*peerConn); ok {
+		return context.WithValue(ctx, peerKey{}, pc)
+	}
+	return ctx
+}
+
+// peerFromContext returns the peer of the request's connection, with its
+// container name looked up if possible.
+func peerFromContext(ctx context.Context) (Peer, bool) {
+	pc, ok := ctx.Value(peerKey{}).(*peerConn)
+	if !ok {
+		return Peer{}, false
+	}
+	peer := pc.peer
+	if peer.ContainerID != "" && pc.names != nil {
+		peer.Name = pc.names.lookup(peer.ContainerID)
+	}
+	return peer, true
+}
+
+// containerNamesTTL is how long a looked-up container name is reused. A
+// container can be renamed, and an ACL that matches by name must not keep
+// granting the old name's keys for long after that.
+const containerNamesTTL = 10 * time.Second
+
+// containerNames looks container names up in the Docker Engine API and
+// caches them by ID for containerNamesTTL.
+type containerNames struct {
+	client *http.Client
+	now    func() time.Time
+	mu     sync.Mutex
+	names  map[string]containerName
+}
+
+type containerName struct {
+	name    string
+	expires time.Time
+}
+
+func newContainerNames(dockerSocket string) *containerNames {
+	return &containerNames{
+		client: &http.Client{
+			Timeout: 2 * time.Second,
+			Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", dockerSocket)
+			}},
+		},
+		now:   time.Now,
+		names: map[string]containerName{},
+	}
+}
+
+// lookup returns the name of container id, or "" when Docker cannot tell.
+// Failures are not cached, so a later request tries again.
+func (n *containerNames) lookup(id string) string {
+	n.mu.Lock()
+	cached, ok := n.names[id]
+	if ok && !n.now().Before(cached.expires) {
+		delete(n.names, id)
+		ok = false
+	}
+	n.mu.Unlock()
+	if ok {
+		return cached.name
+	}
+	resp, err := n.client.Get("http://docker/containers/" + id + "/json")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	var info struct{ Name string }
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+		return ""
+	}
+	name := strings.TrimPrefix(info.Name, "/")
+	n.mu.Lock()
+	n.names[id] = containerName{name: name, expires: n.now().Add(containerNamesTTL)}
+	n.mu.Unlock()
+	return name
+}
+
+// checkDockerSocket fails early for a Docker socket that cannot be reached.
+func checkDockerSocket(dockerSocket string) error {
+	c, err := net.DialTimeout("unix", dockerSocket, 2*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to reach Docker socket: %v", err)
+	}
+	return c.Close()
+}
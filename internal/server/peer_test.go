@@ -0,0 +1,155 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testContainerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestContainerID(t *testing.T) {
+	orig := procRoot
+	t.Cleanup(func() { procRoot = orig })
+	procRoot = t.TempDir()
+	for pid, cgroup := range map[int]string{
+		1: "0::/system.slice/docker-" + testContainerID + ".scope\n",
+		2: "12:memory:/docker/" + testContainerID + "\n0::/\n",
+		3: "0::/kubepods/besteffort/pod1/cri-containerd-" + testContainerID + ".scope\n",
+		4: "0::/user.slice/user-1000.slice/session-1.scope\n",
+	} {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for pid, want := range map[int]string{1: testContainerID, 2: testContainerID, 3: testContainerID, 4: "", 5: ""} {
+		if got := containerID(pid); got != want {
+			t.Errorf("containerID(%d) = %q, want %q", pid, got, want)
+		}
+	}
+}
+
+// startFakeDocker serves the container inspect endpoint for testContainerID,
+// answering with the current result of name.
+func startFakeDocker(t *testing.T, name func() string) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/"+testContainerID+"/json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Id": "` + testContainerID + `", "Name": "/` + name() + `"}`))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return sock
+}
+
+func TestPeerListener_LogsPeer(t *testing.T) {
+	origLogw := logw
+	t.Cleanup(func() { logw = origLogw })
+	var logBuf bytes.Buffer
+	logw = &logBuf
+
+	// The real credentials of the test process, with a container faked.
+	origProc := procRoot
+	t.Cleanup(func() { procRoot = origProc })
+	procRoot = t.TempDir()
+	dir := filepath.Join(procRoot, strconv.Itoa(os.Getpid()))
+	_ = os.MkdirAll(dir, 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "cgroup"), []byte("0::/docker/"+testContainerID+"\n"), 0o644)
+
+	sock := filepath.Join(t.TempDir(), "ojster.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := peerListener{Listener: ln, names: newContainerNames(startFakeDocker(t, func() string { return "stack-web-1" }))}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- serveHTTP(pl, http.NotFoundHandler(), ctx, &bytes.Buffer{}) }()
+
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return net.Dial("unix", sock)
+	}}}
+	resp, err := client.Get("http://ojster/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cancel()
+	<-done
+
+	want := "pid=" + strconv.Itoa(os.Getpid()) + " uid=" + strconv.Itoa(os.Getuid()) + " container=0123456789ab name=stack-web-1"
+	if !strings.Contains(logBuf.String(), want) {
+		t.Fatalf("log %q lacks %q", logBuf.String(), want)
+	}
+}
+
+func TestContainerNames_Expire(t *testing.T) {
+	var mu sync.Mutex
+	name := "web"
+	names := newContainerNames(startFakeDocker(t, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return name
+	}))
+	now := time.Unix(1000, 0)
+	names.now = func() time.Time { return now }
+
+	if got := names.lookup(testContainerID); got != "web" {
+		t.Fatalf("lookup = %q, want web", got)
+	}
+	mu.Lock()
+	name = "renamed"
+	mu.Unlock()
+	if got := names.lookup(testContainerID); got != "web" {
+		t.Fatalf("cached lookup = %q, want web", got)
+	}
+	now = now.Add(containerNamesTTL)
+	if got := names.lookup(testContainerID); got != "renamed" {
+		t.Fatalf("lookup after the TTL = %q, want renamed", got)
+	}
+	if got := names.lookup(strings.Repeat("f", 64)); got != "" {
+		t.Fatalf("lookup of an unknown container = %q", got)
+	}
+}
+
+func TestServeSockets_DockerSocketNeedsNoSeccomp(t *testing.T) {
+	var errBuf bytes.Buffer
+	code := serveSockets([]Socket{{Path: filepath.Join(t.TempDir(), "s"), PrivateKeyFile: "/k"}}, context.Background(), ServeOptions{Seccomp: true, DockerSocket: "/var/run/docker.sock"}, &bytes.Buffer{}, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "--no-seccomp") {
+		t.Fatalf("expected refusal, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/util/env"
+)
+
+// Secret labels understood by the Docker secrets plugin.
+const (
+	// PluginLabelValue carries the sealed value itself.
+	PluginLabelValue = "ojster.value"
+	// PluginLabelKey names the key to look up in the plugin's env file; by
+	// default the secret name is used.
+	PluginLabelKey = "ojster.key"
+)
+
+// DefaultPluginSocket is where dockerd discovers a plugin named "ojster".
+const DefaultPluginSocket = "/run/docker/plugins/ojster.sock"
+
+const pluginContentType = "application/vnd.docker.plugins.v1.2+json"
+
+// secretRequest is the subset of the SecretProvider.GetSecret request used here.
+type secretRequest struct {
+	SecretName   string            `json:"SecretName"`
+	SecretLabels map[string]string `json:"SecretLabels"`
+}
+
+type secretResponse struct {
+	Value      []byte `json:"Value,omitempty"`
+	Err        string `json:"Err,omitempty"`
+	DoNotReuse bool   `json:"DoNotReuse,omitempty"`
+}
+
+// ServePlugin runs ojster as a Docker secrets provider plugin on socketPath.
+// Swarm secrets created with "--driver ojster" are decrypted with the private
+// key at privateKeyFile and handed to dockerd, which mounts them under
+// /run/secrets in the service's containers. The sealed value is taken from
+// the PluginLabelValue label, or looked up in envFile (re-read per request)
+// under the PluginLabelKey label or the secret name.
+func ServePlugin(privateKeyFile, envFile, socketPath string, ctx context.Context, outw io.Writer, errw io.Writer) int {
+	if err := checkTempIsTmpfs(os.TempDir()); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+
+	// Only dockerd (root) needs to reach the plugin.
+	ln, code := listenUnix(socketPath, 0o600, errw)
+	if ln == nil {
+		return code
+	}
+
+	fmt.Fprintf(errw, "ojster secrets plugin serving on unix socket %s\n", socketPath)
+	return serveListener(ln, newPluginMux(privateKeyFile, envFile), ctx, errw)
+}
+
+func newPluginMux(privateKeyFile, envFile string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		writePluginJSON(w, http.StatusOK, map[string][]string{"Implements": {"secretprovider"}})
+	})
+	mux.HandleFunc("POST /SecretProvider.GetSecret", func(w http.ResponseWriter, r *http.Request) {
+		handleGetSecret(w, r, privateKeyFile, envFile)
+	})
+	return mux
+}
+
+func handleGetSecret(w http.ResponseWriter, r *http.Request, privateKeyFile, envFile string) {
+	defer r.Body.Close()
+	fail := func(format string, args ...any) {
+		writePluginJSON(w, http.StatusInternalServerError, secretResponse{Err: fmt.Sprintf(format, args...)})
+	}
+
+	var req secretRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024*1024)).Decode(&req); err != nil {
+		fail("invalid request: %v", err)
+		return
+	}
+
+	sealed, ok := req.SecretLabels[PluginLabelValue]
+	if !ok {
+		key := req.SecretName
+		if k, ok := req.SecretLabels[PluginLabelKey]; ok {
+			key = k
+		}
+		envMap, err := env.ParseEnvFile(envFile)
+		if err != nil {
+			fail("failed to read env file %s: %v", envFile, err)
+			return
+		}
+		if sealed, ok = envMap[key]; !ok {
+			fail("secret %s: key %s not found in %s", req.SecretName, key, envFile)
+			return
+		}
+	}
+	if !pqc.IsSealed(sealed) {
+		fail("secret %s: value is not sealed", req.SecretName)
+		return
+	}
+
+	out, err := unsealMapFunc(r.Context(), map[string]string{"SECRET": sealed}, privateKeyFile, []string{"SECRET"})
+	if err != nil {
+		fail("secret %s: %v", req.SecretName, err)
+		return
+	}
+	writePluginJSON(w, http.StatusOK, secretResponse{Value: []byte(out["SECRET"])})
+}
+
+func writePluginJSON(w http.ResponseWriter, status int, v any) {
+	j, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", pluginContentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(j)
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+//
+// ─────────────────────────────────────────────────────────────
+//   TEST HELPERS
+// ─────────────────────────────────────────────────────────────
+//
+
+// pluginFixture creates a keypair and an env file with DB_PASSWORD sealed.
+func pluginFixture(t *testing.T) (priv, envFile, sealed string) {
+	t.Helper()
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv.b64"), filepath.Join(td, "pub.b64")
+	envFile = filepath.Join(td, ".env")
+	var outBuf, errBuf bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: %q", errBuf.String())
+	}
+	sealed, err := pqc.Seal(pub, []byte("s3cret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if err := os.WriteFile(envFile, []byte("DB_PASSWORD="+sealed+"\nPLAIN=hello\n"), 0o600); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+	return priv, envFile, sealed
+}
+
+func getSecret(t *testing.T, mux http.Handler, req secretRequest) (int, secretResponse) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/SecretProvider.GetSecret", bytes.NewReader(body)))
+	var resp secretResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   Docker secrets plugin
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestPlugin_Activate(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newPluginMux("", "").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/Plugin.Activate", nil))
+	ExpectStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != `{"Implements":["secretprovider"]}` {
+		t.Fatalf("unexpected activation response: %q", rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != pluginContentType {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+}
+
+func TestPlugin_GetSecret(t *testing.T) {
+	priv, envFile, sealed := pluginFixture(t)
+	mux := newPluginMux(priv, envFile)
+
+	cases := []struct {
+		name string
+		req  secretRequest
+	}{
+		{"by secret name", secretRequest{SecretName: "DB_PASSWORD"}},
+		{"by key label", secretRequest{SecretName: "db_password", SecretLabels: map[string]string{PluginLabelKey: "DB_PASSWORD"}}},
+		{"by value label", secretRequest{SecretName: "other", SecretLabels: map[string]string{PluginLabelValue: sealed}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := getSecret(t, mux, tc.req)
+			if code != http.StatusOK || string(resp.Value) != "s3cret" || resp.Err != "" {
+				t.Fatalf("unexpected response: code=%d %+v", code, resp)
+			}
+		})
+	}
+}
+
+func TestPlugin_GetSecret_Errors(t *testing.T) {
+	priv, envFile, _ := pluginFixture(t)
+
+	cases := []struct {
+		name            string
+		envFile         string
+		req             secretRequest
+		wantErrContains string
+	}{
+		{"missing key", envFile, secretRequest{SecretName: "NOPE"}, "key NOPE not found"},
+		{"not sealed", envFile, secretRequest{SecretName: "PLAIN"}, "value is not sealed"},
+		{"missing env file", filepath.Join(t.TempDir(), "nope", ".env"), secretRequest{SecretName: "X"}, "key X not found"},
+		{"bad ciphertext", envFile, secretRequest{SecretName: "x", SecretLabels: map[string]string{PluginLabelValue: pqc.BuildSealed([]byte{1}, []byte{2})}}, "secret x:"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := getSecret(t, newPluginMux(priv, tc.envFile), tc.req)
+			if code != http.StatusInternalServerError || !strings.Contains(resp.Err, tc.wantErrContains) {
+				t.Fatalf("expected 500 with %q, got code=%d %+v", tc.wantErrContains, code, resp)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	newPluginMux(priv, envFile).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/SecretProvider.GetSecret", strings.NewReader("{")))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "invalid request") {
+		t.Fatalf("expected invalid request error, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServePlugin_Startup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	socketPath := filepath.Join(t.TempDir(), "ojster.sock")
+
+	errCh := make(chan int, 1)
+	var outBuf, errBuf bytes.Buffer
+	go func() { errCh <- ServePlugin("", "", socketPath, ctx, &outBuf, &errBuf) }()
+	waitForServer(t, socketPath)
+
+	fi, err := os.Stat(socketPath)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("expected socket with mode 0600, got %v err=%v", fi.Mode().Perm(), err)
+	}
+	resp, err := getUnixHTTPClient(socketPath).Post("http://unix/Plugin.Activate", "application/json", nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("activate failed: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	if code := <-errCh; code != 0 {
+		t.Fatalf("ServePlugin returned %d stderr=%q", code, errBuf.String())
+	}
+}
@@ -24,80 +24,313 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ojster/ojster/internal/cbor"
 	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
 	"github.com/ojster/ojster/internal/util/env"
 )
 
 // Assign functions to vars so tests can override them
 var environFunc = os.Environ
 
+// unsealConfig holds the settings of the unseal path that serveSockets takes
+// from ServeOptions; every handler of a server shares one.
+type unsealConfig struct {
+	// subprocessStdin hands the subprocess its input on stdin and fd 3
+	// rather than in files (see ServeOptions.SubprocessStdin).
+	subprocessStdin bool
+	// isolateSubprocess runs the command in its own namespaces (see
+	// ServeOptions.IsolateSubprocess).
+	isolateSubprocess bool
+	// valueRe is what incoming values must match (see
+	// ServeOptions.ValueRegex).
+	valueRe *regexp.Regexp
+	// maxKeys is the number of keys a request may hold.
+	maxKeys int
+	// commandTimeout is how long a subprocess command may run.
+	commandTimeout time.Duration
+	// limits bound the values of a request.
+	limits env.Limits
+}
+
+// newUnsealConfig returns the unseal settings of opts.
+func newUnsealConfig(opts ServeOptions) *unsealConfig {
+	cfg := &unsealConfig{
+		subprocessStdin:   opts.SubprocessStdin,
+		isolateSubprocess: opts.IsolateSubprocess,
+		valueRe:           defaultValueRe,
+		maxKeys:           DefaultMaxKeys,
+		commandTimeout:    DefaultCommandTimeout,
+		limits:            env.DefaultLimits(),
+	}
+	if opts.ValueRegex != nil {
+		cfg.valueRe = opts.ValueRegex
+	}
+	if opts.MaxKeys > 0 {
+		cfg.maxKeys = opts.MaxKeys
+	}
+	if opts.CommandTimeout > 0 {
+		cfg.commandTimeout = opts.CommandTimeout
+	}
+	if opts.MaxValueLen > 0 {
+		cfg.limits.MaxValueLen = opts.MaxValueLen
+	}
+	return cfg
+}
+
+// defaultValueRe matches the values ojster seal writes.
+var defaultValueRe = regexp.MustCompile(pqc.DefaultValueRegex())
+
+// DefaultMaxKeys is the number of keys a request may hold unless
+// ServeOptions.MaxKeys says otherwise.
+const DefaultMaxKeys = 512
+
+// DefaultCommandTimeout is how long a subprocess command may run unless
+// ServeOptions.CommandTimeout says otherwise.
+const DefaultCommandTimeout = 30 * time.Second
+
+// keyCache holds the parsed private keys of the direct unseal path, which
+// serveSockets loads at startup.
+var keyCache = pqc.NewKeyCache()
+
 // allow tests to override the unseal implementation used by handlePost
-var unsealMapFunc = func(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
-	return pqc.UnsealMap(envMap, privPath, keys)
+var unsealMapFunc = func(ctx context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+	dk, err := keyCache.Load(privPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", pqc.ErrConfig, err)
+	}
+	return pqc.UnsealMapWithKey(ctx, envMap, dk, keys)
 }
 
-func handlePost(w http.ResponseWriter, r *http.Request, cmdArgs []string, privateKeyFile string) {
-	cmd := []string{"/ojster", "unseal", "-json", "-priv-file", "./.env.keys"}
-	if len(cmdArgs) > 0 {
-		cmd = cmdArgs
+// bufPool holds the buffers handlePost reads bodies, writes replies and
+// collects subprocess output into, so that many replicas starting at once do
+// not each allocate their own.
+var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBuf caps the buffers returned to bufPool, so one huge request does
+// not pin its memory for the life of the server.
+const maxPooledBuf = 1 << 20
+
+func getBuf() *bytes.Buffer {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuf wipes buf, which may hold plaintext, and returns it to bufPool.
+// Reset alone would leave the old contents in the buffer's memory.
+func putBuf(buf *bytes.Buffer) {
+	buf.Reset()
+	mem := buf.AvailableBuffer()
+	clear(mem[:cap(mem)])
+	if buf.Cap() <= maxPooledBuf {
+		bufPool.Put(buf)
+	}
+}
+
+// maxBodyBytes limits how much of a request body is read.
+const maxBodyBytes = 10 * 1024 * 1024
+
+// readBody reads up to maxBodyBytes of the request body into a pooled buffer,
+// which the caller returns with putBuf.
+func readBody(r *http.Request) (*bytes.Buffer, error) {
+	buf := getBuf()
+	if _, err := buf.ReadFrom(io.LimitReader(r.Body, maxBodyBytes)); err != nil {
+		putBuf(buf)
+		return nil, err
 	}
+	return buf, nil
+}
 
+// statusError is an error reply: the message and the HTTP status to send it with.
+type statusError struct {
+	msg  string
+	code int
+}
+
+// errCanceled is the reply for an unseal abandoned because its client went
+// away; there is normally no one left to receive it.
+var errCanceled = &statusError{"request canceled", http.StatusServiceUnavailable}
+
+// handlePost decrypts the request body. A non-nil allow limits the key names
+// that may be requested (see Route.Allow), and single lists the keys that are
+// released only once (see Route.SingleDelivery); the reply names those in
+// protocol.SingleDeliveryHeader.
+func handlePost(w http.ResponseWriter, r *http.Request, cfg *unsealConfig, cmdArgs []string, privateKeyFile string, allow []string, single singleDelivery) {
 	defer r.Body.Close()
 
 	var incoming map[string]string
 	{
-		const maxBytes = 10 * 1024 * 1024
-		data, err := io.ReadAll(io.LimitReader(r.Body, maxBytes))
+		buf, err := readBody(r)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+			httpError(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
 			return
 		}
-		if err := json.Unmarshal(data, &incoming); err != nil {
-			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		defer putBuf(buf)
+		// Both decoders copy keys and values, so buf can go back to the pool.
+		if isCBOR(r.Header.Get("Content-Type")) {
+			if incoming, err = cbor.DecodeMap(buf.Bytes()); err != nil {
+				httpError(w, fmt.Sprintf("invalid CBOR: %v", err), http.StatusBadRequest)
+				return
+			}
+		} else if err := json.Unmarshal(buf.Bytes(), &incoming); err != nil {
+			httpError(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 
+	if serr := checkACL(w, r, incoming); serr != nil {
+		httpError(w, serr.msg, serr.code)
+		return
+	}
+	finalMap, serr := unsealRequest(r.Context(), cfg, incoming, r.URL.Path, cmdArgs, privateKeyFile, allow, single)
+	if serr != nil {
+		httpError(w, serr.msg, serr.code)
+		return
+	}
+
+	var once []string
+	for k := range finalMap {
+		if keyAllowed(single.patterns, k) {
+			once = append(once, k)
+		}
+	}
+	if len(once) > 0 {
+		slices.Sort(once)
+		w.Header().Set(protocol.SingleDeliveryHeader, strings.Join(once, ","))
+	}
+
+	// Reply in CBOR only to clients that ask for it, whatever they sent.
+	replyCBOR := false
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		replyCBOR = replyCBOR || isCBOR(accept)
+	}
+	writeReply(w, finalMap, replyCBOR)
+}
+
+// unsealRequest checks the requested key names against allow and single and
+// decrypts incoming, directly or through cmdArgs. urlPath is only used in
+// messages. The unseal is abandoned once ctx, and that of every identical
+// request waiting on it, is done.
+func unsealRequest(ctx context.Context, cfg *unsealConfig, incoming map[string]string, urlPath string, cmdArgs []string, privateKeyFile string, allow []string, single singleDelivery) (map[string]string, *statusError) {
+	cmd := []string{"/ojster", "unseal", "-json", "-priv-file", "./.env.keys"}
+	if len(cmdArgs) > 0 {
+		cmd = cmdArgs
+	}
+
+	if len(incoming) > cfg.maxKeys {
+		return nil, &statusError{fmt.Sprintf("request has %d keys, more than the limit of %d", len(incoming), cfg.maxKeys), http.StatusRequestEntityTooLarge}
+	}
 	requestedKeys := make(map[string]struct{}, len(incoming))
-	for k := range incoming {
+	var plain []string
+	for k, v := range incoming {
 		if !env.KeyNameRegex.MatchString(k) {
-			http.Error(w, "invalid key name in request: "+k, http.StatusBadRequest)
-			return
+			return nil, &statusError{"invalid key name in request: " + k, http.StatusBadRequest}
+		}
+		if allow != nil && !keyAllowed(allow, k) {
+			return nil, &statusError{"key not allowed on " + urlPath + ": " + k, http.StatusForbidden}
+		}
+		if err := cfg.limits.CheckValue(k, v); err != nil {
+			return nil, &statusError{err.Error(), http.StatusRequestEntityTooLarge}
+		}
+		if !cfg.valueRe.MatchString(v) {
+			plain = append(plain, k)
 		}
 		requestedKeys[k] = struct{}{}
 	}
+	// A client with too broad an OJSTER_REGEX would otherwise have plaintext
+	// written to the subprocess's temp files; only the key names are echoed.
+	if len(plain) > 0 {
+		slices.Sort(plain)
+		return nil, &statusError{"values are not sealed: " + strings.Join(plain, ", "), http.StatusBadRequest}
+	}
 
-	// Dispatch to the appropriate branch
-	if len(cmdArgs) == 0 {
-		handlePostDirectUnseal(w, incoming, requestedKeys, privateKeyFile)
-		return
+	claimed, taken := delivered.claim(single, requestedKeys)
+	if taken != "" {
+		return nil, &statusError{"key " + taken + " was already delivered and is single-delivery", http.StatusGone}
+	}
+
+	// Dispatch to the appropriate branch, once for identical concurrent requests
+	out, serr := unsealFlights.do(ctx, flightKey(incoming, cmdArgs, privateKeyFile), func(ctx context.Context) (map[string]string, *statusError) {
+		if len(cmdArgs) == 0 {
+			return unsealDirect(ctx, incoming, requestedKeys, privateKeyFile)
+		}
+		return unsealSubprocess(ctx, cfg, incoming, requestedKeys, cmd, privateKeyFile)
+	})
+	// Keys that were not released after all may be requested again.
+	var unreleased []string
+	for _, k := range claimed {
+		if _, ok := out[k]; !ok || serr != nil {
+			unreleased = append(unreleased, k)
+		}
+	}
+	delivered.release(single, unreleased)
+	if serr == nil {
+		keyUsage.record(out)
+	}
+	return out, serr
+}
+
+// isCBOR reports whether a Content-Type or Accept entry names CBOR.
+func isCBOR(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), cbor.ContentType)
+}
+
+// writeReply writes the decrypted values as JSON, or as CBOR (which keeps
+// binary values intact) when replyCBOR is set.
+func writeReply(w http.ResponseWriter, finalMap map[string]string, replyCBOR bool) {
+	buf := getBuf()
+	defer putBuf(buf)
+	if replyCBOR {
+		buf.Write(cbor.AppendMap(buf.AvailableBuffer(), finalMap))
+		w.Header().Set("Content-Type", cbor.ContentType)
+	} else {
+		_ = json.NewEncoder(buf).Encode(finalMap)
+		buf.Truncate(buf.Len() - 1) // drop the newline Encode appends
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func keyAllowed(allow []string, key string) bool {
+	for _, pattern := range allow {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
 	}
-	handlePostSubprocessUnseal(w, incoming, requestedKeys, cmd, privateKeyFile)
+	return false
 }
 
-// handlePostDirectUnseal handles the path where the server calls UnsealMap directly.
-func handlePostDirectUnseal(w http.ResponseWriter, incoming map[string]string, requestedKeys map[string]struct{}, privateKeyFile string) {
-	outMap, err := unsealMapFunc(incoming, privateKeyFile, nil)
+// unsealDirect handles the path where the server calls UnsealMap directly.
+func unsealDirect(ctx context.Context, incoming map[string]string, requestedKeys map[string]struct{}, privateKeyFile string) (map[string]string, *statusError) {
+	outMap, err := unsealMapFunc(ctx, incoming, privateKeyFile, nil)
 	if err != nil {
 		switch {
+		case ctx.Err() != nil:
+			return nil, errCanceled
 		case errors.Is(err, pqc.ErrConfig):
-			http.Error(w, err.Error(), http.StatusInternalServerError) // 500
-			return
+			return nil, &statusError{err.Error(), http.StatusInternalServerError} // 500
 		default:
-			http.Error(w, err.Error(), http.StatusBadGateway) // 502
-			return
+			return nil, &statusError{err.Error(), http.StatusBadGateway} // 502
 		}
 	}
 
 	// Ensure returned keys are subset of requested keys
 	for k := range outMap {
 		if _, ok := requestedKeys[k]; !ok {
-			http.Error(w, "unseal returned unexpected keys", http.StatusBadGateway)
-			return
+			return nil, &statusError{"unseal returned unexpected keys", http.StatusBadGateway}
 		}
 	}
 
@@ -108,80 +341,109 @@ func handlePostDirectUnseal(w http.ResponseWriter, incoming map[string]string, r
 		}
 	}
 	if len(finalMap) == 0 {
-		http.Error(w, "unseal produced no acceptable env entries", http.StatusBadGateway)
-		return
+		return nil, &statusError{"unseal produced no acceptable env entries", http.StatusBadGateway}
 	}
 
-	j, _ := json.Marshal(finalMap)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(j)
+	return finalMap, nil
 }
 
-// handlePostSubprocessUnseal handles the path where the server writes files and runs a subprocess.
-func handlePostSubprocessUnseal(w http.ResponseWriter, incoming map[string]string, requestedKeys map[string]struct{}, cmd []string, privateKeyFile string) {
-	tmpDir, err := os.MkdirTemp("", "ojster-")
-	if err != nil {
-		http.Error(w, "failed to create temp dir: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer func() { _ = os.RemoveAll(tmpDir) }()
-
-	// Write .env using the formatted lines (ensure trailing newline)
-	lines := make([]string, 0, len(incoming))
-	for k, v := range incoming {
-		lines = append(lines, env.FormatEnvEntry(k, v))
+// writeFiles writes envData to dir/.env and links dir/.env.keys to the
+// private key file.
+func writeFiles(dir string, envData []byte, privateKeyFile string) error {
+	if err := os.WriteFile(filepath.Join(dir, ".env"), envData, 0600); err != nil {
+		return fmt.Errorf("failed to write .env file: %v", err)
 	}
-	envPath := filepath.Join(tmpDir, ".env")
-	s := strings.Join(lines, "\n")
-	if !strings.HasSuffix(s, "\n") {
-		s += "\n"
+	if err := os.Symlink(privateKeyFile, filepath.Join(dir, ".env.keys")); err != nil {
+		return fmt.Errorf("failed to create symlink to private key file: %v", err)
 	}
-	if err := os.WriteFile(envPath, []byte(s), 0600); err != nil {
-		http.Error(w, "failed to write .env file: "+err.Error(), http.StatusInternalServerError)
-		return
+	return nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
 	}
+}
 
-	if err := os.Symlink(privateKeyFile, filepath.Join(tmpDir, ".env.keys")); err != nil {
-		http.Error(w, "failed to create symlink to private key file: "+err.Error(), http.StatusInternalServerError)
-		return
+// unsealSubprocess handles the path where the server writes files and runs a subprocess.
+func unsealSubprocess(ctx context.Context, cfg *unsealConfig, incoming map[string]string, requestedKeys map[string]struct{}, cmd []string, privateKeyFile string) (map[string]string, *statusError) {
+	// Compose .env with one formatted entry per line
+	envBuf := getBuf()
+	defer putBuf(envBuf)
+	for k, v := range incoming {
+		envBuf.WriteString(env.FormatEnvEntry(k, v))
+		envBuf.WriteByte('\n')
+	}
+	if envBuf.Len() == 0 {
+		envBuf.WriteByte('\n')
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, cfg.commandTimeout)
 	defer cancel()
 
 	execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
-	execCmd.Dir = tmpDir
 	execCmd.Env = environFunc()
+	if cfg.subprocessStdin {
+		// Nothing touches the filesystem: .env arrives on stdin and the
+		// private key as fd 3 (/dev/fd/3).
+		keyFile, err := os.Open(privateKeyFile)
+		if err != nil {
+			return nil, &statusError{"failed to open private key file: " + err.Error(), http.StatusInternalServerError}
+		}
+		defer keyFile.Close()
+		execCmd.Stdin = bytes.NewReader(envBuf.Bytes())
+		execCmd.ExtraFiles = []*os.File{keyFile}
+	} else {
+		tmpDir, err := os.MkdirTemp("", "ojster-")
+		if err != nil {
+			return nil, &statusError{"failed to create temp dir: " + err.Error(), http.StatusInternalServerError}
+		}
+		defer func() { _ = os.RemoveAll(tmpDir) }()
+		extraFiles, err := stageFiles(tmpDir, envBuf.Bytes(), privateKeyFile)
+		if err != nil {
+			return nil, &statusError{err.Error(), http.StatusInternalServerError}
+		}
+		defer closeFiles(extraFiles)
+		execCmd.Dir = tmpDir
+		execCmd.ExtraFiles = extraFiles
+	}
+	if cfg.isolateSubprocess {
+		readable := []string{privateKeyFile}
+		if execCmd.Dir != "" {
+			readable = append(readable, execCmd.Dir)
+		}
+		if err := isolate(execCmd, readable); err != nil {
+			return nil, &statusError{"failed to isolate subprocess: " + err.Error(), http.StatusInternalServerError}
+		}
+	}
 
-	var stdoutBuf bytes.Buffer
-	execCmd.Stdout = &stdoutBuf
+	stdoutBuf := getBuf()
+	defer putBuf(stdoutBuf)
+	execCmd.Stdout = stdoutBuf
 
 	start := time.Now()
 	if err := execCmd.Run(); err != nil {
 		dur := time.Since(start)
 		if ctx.Err() == context.DeadlineExceeded {
-			http.Error(w, "subprocess timed out", http.StatusGatewayTimeout)
-			return
+			return nil, &statusError{"subprocess timed out", http.StatusGatewayTimeout}
+		}
+		if ctx.Err() != nil {
+			return nil, errCanceled
 		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			http.Error(w, fmt.Sprintf("subprocess failed (exit %d) after %s", exitErr.ExitCode(), dur), http.StatusBadGateway)
-			return
+			return nil, &statusError{fmt.Sprintf("subprocess failed (exit %d) after %s", exitErr.ExitCode(), dur), http.StatusBadGateway}
 		}
-		http.Error(w, "failed to run subprocess: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, &statusError{"failed to run subprocess: " + err.Error(), http.StatusInternalServerError}
 	}
 
 	var outMap map[string]string
 	if err := json.Unmarshal(stdoutBuf.Bytes(), &outMap); err != nil {
-		http.Error(w, fmt.Sprintf("subprocess produced invalid JSON after %s", time.Since(start)), http.StatusBadGateway)
-		return
+		return nil, &statusError{fmt.Sprintf("subprocess produced invalid JSON after %s", time.Since(start)), http.StatusBadGateway}
 	}
 
 	for k := range outMap {
 		if _, ok := requestedKeys[k]; !ok {
-			http.Error(w, "subprocess returned unexpected keys", http.StatusBadGateway)
-			return
+			return nil, &statusError{"subprocess returned unexpected keys", http.StatusBadGateway}
 		}
 	}
 
@@ -193,12 +455,8 @@ func handlePostSubprocessUnseal(w http.ResponseWriter, incoming map[string]strin
 	}
 
 	if len(finalMap) == 0 {
-		http.Error(w, "subprocess produced no acceptable env entries", http.StatusBadGateway)
-		return
+		return nil, &statusError{"subprocess produced no acceptable env entries", http.StatusBadGateway}
 	}
 
-	j, _ := json.Marshal(finalMap)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(j)
+	return finalMap, nil
 }
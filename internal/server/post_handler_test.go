@@ -16,15 +16,19 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ojster/ojster/internal/cbor"
 	"github.com/ojster/ojster/internal/pqc"
 )
 
@@ -65,6 +69,95 @@ func TestHandlePost_Success(t *testing.T) {
 	}
 }
 
+func TestHandlePost_CBOR(t *testing.T) {
+	cmd := sh(`printf '{"FOO":"ok"}'`)
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(cbor.EncodeMap(map[string]string{"FOO": "bar"})))
+	req.Header.Set("Content-Type", cbor.ContentType)
+	req.Header.Set("Accept", "application/json, application/cbor")
+	rec := httptest.NewRecorder()
+	handlePost(rec, req, testConfig(), cmd, "/tmp/key", nil, singleDelivery{})
+	ExpectStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Type"); got != cbor.ContentType {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	out, err := cbor.DecodeMap(rec.Body.Bytes())
+	if err != nil || out["FOO"] != "ok" {
+		t.Fatalf("reply = %v, %v", out, err)
+	}
+
+	// Without Accept the reply stays JSON, and bad CBOR is rejected.
+	req = httptest.NewRequest("POST", "/", bytes.NewReader([]byte{0xa1}))
+	req.Header.Set("Content-Type", "application/cbor; charset=binary")
+	rec = httptest.NewRecorder()
+	handlePost(rec, req, testConfig(), cmd, "/tmp/key", nil, singleDelivery{})
+	ExpectStatus(t, rec, http.StatusBadRequest)
+	expectBodyContains(t, rec, "invalid CBOR")
+}
+
+func TestHandlePost_Stdin(t *testing.T) {
+	cfg := testConfig()
+	cfg.subprocessStdin = true
+	key := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(key, []byte("secret-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := sh(`grep -q "^FOO=bar" && [ "$(cat /dev/fd/3)" = secret-key ] && [ ! -e .env ] && printf '{"FOO":"ok"}'`)
+	rec := runPostWith(t, cfg, []byte(`{"FOO":"bar"}`), cmd, key)
+	ExpectStatus(t, rec, http.StatusOK)
+
+	rec = runPostWith(t, cfg, []byte(`{"FOO":"bar"}`), cmd, key+".missing")
+	ExpectStatus(t, rec, http.StatusInternalServerError)
+	expectBodyContains(t, rec, "failed to open private key file")
+}
+
+func TestHandlePost_NotSealed(t *testing.T) {
+	cfg := newUnsealConfig(ServeOptions{})
+
+	// The command would fail the test if it ran.
+	cmd := sh(`exit 3`)
+	rec := runPostWith(t, cfg, []byte(`{"A":"`+pqc.Prefix+`abc:def","PASSWORD":"hunter2","B":"plain"}`), cmd, "/x")
+	ExpectStatus(t, rec, http.StatusBadRequest)
+	expectBodyContains(t, rec, "values are not sealed: B, PASSWORD")
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Fatalf("reply echoes the plaintext: %q", rec.Body.String())
+	}
+
+	// A configured pattern replaces the default.
+	cfg = newUnsealConfig(ServeOptions{ValueRegex: regexp.MustCompile(`^enc:`)})
+	rec = runPostWith(t, cfg, []byte(`{"A":"enc:x"}`), cmd, "/x")
+	ExpectStatus(t, rec, http.StatusBadGateway)
+}
+
+func TestHandlePost_MaxKeys(t *testing.T) {
+	cfg := testConfig()
+	cfg.maxKeys = 2
+
+	cmd := sh(`printf '{"A":"1","B":"2"}'`)
+	ExpectStatus(t, runPostWith(t, cfg, []byte(`{"A":"x","B":"y"}`), cmd, "/x"), http.StatusOK)
+	rec := runPostWith(t, cfg, []byte(`{"A":"x","B":"y","C":"z"}`), cmd, "/x")
+	ExpectStatus(t, rec, http.StatusRequestEntityTooLarge)
+	expectBodyContains(t, rec, "request has 3 keys, more than the limit of 2")
+}
+
+func TestHandlePost_ValueTooLong(t *testing.T) {
+	cfg := testConfig()
+	cfg.limits.MaxValueLen = 4
+
+	rec := runPostWith(t, cfg, []byte(`{"A":"x","B":"12345"}`), sh(`printf '{"A":"1"}'`), "/x")
+	ExpectStatus(t, rec, http.StatusRequestEntityTooLarge)
+	expectBodyContains(t, rec, "value of B is longer than 4 bytes")
+}
+
+func TestHandlePost_CommandTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.commandTimeout = 50 * time.Millisecond
+
+	rec := runPostWith(t, cfg, []byte(`{"FOO":"bar"}`), sh(`exec sleep 5`), "/x")
+	ExpectStatus(t, rec, http.StatusGatewayTimeout)
+	expectBodyContains(t, rec, "timed out")
+}
+
 func TestHandlePost_Errors(t *testing.T) {
 
 	cases := []struct {
@@ -75,6 +168,7 @@ func TestHandlePost_Errors(t *testing.T) {
 		wantSub  string
 	}{
 		{"invalid_json", "{bad json", sh(`printf '{}'`), 400, "invalid JSON"},
+		{"trailing_json", `{"FOO":"bar"} {}`, sh(`printf '{}'`), 400, "invalid JSON"},
 		{"invalid_key", `{"BAD-NAME":"v"}`, sh(`printf '{}'`), 400, "invalid key"},
 		{"unexpected_keys", `{"GOOD":"v"}`, sh(`printf '{"GOOD":"1","BAD":"x"}'`), 502, "unexpected keys"},
 		{"subprocess_invalid_json", `{"GOOD":"v"}`, sh(`printf '{bad json'`), 502, "invalid JSON"},
@@ -201,7 +295,7 @@ func TestHandlePost_DirectUnsealPath_SimulatedBranches(t *testing.T) {
 
 	t.Run("unseal returned unexpected keys -> 502", func(t *testing.T) {
 		// Simulate UnsealMap returning an extra key not requested
-		unsealMapFunc = func(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
 			// return a map with an unexpected key "BAD"
 			return map[string]string{"GOOD": "v", "BAD": "x"}, nil
 		}
@@ -214,7 +308,7 @@ func TestHandlePost_DirectUnsealPath_SimulatedBranches(t *testing.T) {
 
 	t.Run("unseal produced no acceptable env entries -> 502", func(t *testing.T) {
 		// Simulate UnsealMap returning an empty map (no sealed entries)
-		unsealMapFunc = func(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
 			return map[string]string{}, nil
 		}
 
@@ -226,7 +320,7 @@ func TestHandlePost_DirectUnsealPath_SimulatedBranches(t *testing.T) {
 
 	t.Run("unseal missing keys -> 502", func(t *testing.T) {
 		// Simulate UnsealMap returning ErrMissingKeys
-		unsealMapFunc = func(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
 			return nil, fmt.Errorf("%w: missing", pqc.ErrMissingKeys)
 		}
 
@@ -238,7 +332,7 @@ func TestHandlePost_DirectUnsealPath_SimulatedBranches(t *testing.T) {
 
 	t.Run("unseal unknown worker error -> 502", func(t *testing.T) {
 		// Simulate UnsealMap returning ErrUnseal
-		unsealMapFunc = func(envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
 			return nil, fmt.Errorf("%w: decapsulation failed", pqc.ErrUnseal)
 		}
 
@@ -248,3 +342,45 @@ func TestHandlePost_DirectUnsealPath_SimulatedBranches(t *testing.T) {
 		expectBodyContains(t, rec, "decapsulation failed")
 	})
 }
+
+func TestPutBuf_Wipes(t *testing.T) {
+	buf := getBuf()
+	buf.WriteString("secret-value")
+	mem := buf.Bytes()
+	buf.Next(3) // a read offset must not hide the start from the wipe
+	putBuf(buf)
+	if !bytes.Equal(mem, make([]byte, len(mem))) {
+		t.Fatalf("buffer still holds the plaintext: %q", mem)
+	}
+}
+
+// BenchmarkHandlePost_Direct measures per-request allocations of the direct
+// unseal path with a stack of 200 sealed values, as when many replicas start
+// at once. Run with -benchmem.
+func BenchmarkHandlePost_Direct(b *testing.B) {
+	old := unsealMapFunc
+	b.Cleanup(func() { unsealMapFunc = old })
+	sealed := pqc.BuildSealed(bytes.Repeat([]byte{0x01}, 1088), bytes.Repeat([]byte{0x02}, 64))
+	incoming := make(map[string]string, 200)
+	plain := make(map[string]string, 200)
+	for i := range 200 {
+		incoming[fmt.Sprintf("KEY_%d", i)] = sealed
+		plain[fmt.Sprintf("KEY_%d", i)] = "secret-value"
+	}
+	unsealMapFunc = func(context.Context, map[string]string, string, []string) (map[string]string, error) {
+		return plain, nil
+	}
+	body, _ := json.Marshal(incoming)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			handlePost(rec, req, testConfig(), nil, "/x", nil, singleDelivery{})
+			if rec.Code != http.StatusOK {
+				b.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/ojster/ojster/internal/pqc"
+)
+
+// pubkeyPath is appended to a route path (or used alone for "/") to fetch
+// the public key that values for that path must be sealed with.
+const pubkeyPath = "/pubkey"
+
+// handlePubkey replies with the public key file of privateKeyFile, as
+// "ojster pubkey" would print it, so clients can seal against the key the
+// server actually uses.
+func handlePubkey(w http.ResponseWriter, privateKeyFile string) {
+	pub, _, err := pqc.PublicKeyFromPrivate(privateKeyFile)
+	if err != nil {
+		httpError(w, "failed to derive public key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pub)
+}
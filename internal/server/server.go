@@ -16,72 +16,742 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/ojster/ojster/internal/harden"
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
+	"github.com/ojster/ojster/internal/util/file"
+	"github.com/ojster/ojster/internal/util/ipc"
+	"github.com/ojster/ojster/internal/util/memlock"
+	"github.com/ojster/ojster/internal/util/redact"
 )
 
-const linuxTmpfsMagic = 0x01021994
+// checkTempIsTmpfs guards the directory the subprocess path writes to.
+var checkTempIsTmpfs = file.CheckTmpfs
 
-func checkTempIsTmpfs(path string) error {
-	var stat syscall.Statfs_t
-	if err := syscall.Statfs(path, &stat); err != nil {
-		return fmt.Errorf("failed to statfs %s: %v", path, err)
-	}
-	if uint64(stat.Type) != linuxTmpfsMagic {
-		return fmt.Errorf("path %s is not on tmpfs (statfs type 0x%x)", path, uint64(stat.Type))
-	}
-	return nil
-}
+// probeMemlock checks that key material can be kept out of swap.
+var probeMemlock = memlock.Probe
+
+// RequestIDHeader carries the ID that correlates a request across client and
+// server logs. The server echoes a valid incoming one, otherwise it makes one.
+const RequestIDHeader = "X-Request-ID"
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+		if peer, ok := peerFromContext(r.Context()); ok {
+			fmt.Fprintf(logw, "%s %s %s request_id=%s %s\n", r.Method, r.URL.Path, time.Since(start), id, peer)
+			return
+		}
+		fmt.Fprintf(logw, "%s %s %s request_id=%s\n", r.Method, r.URL.Path, time.Since(start), id)
+	})
+}
+
+// protocolMiddleware lists the protocol versions this server speaks on every
+// response, answers in the version of the request, and rejects requests made
+// with a version it does not speak, after which the client can retry with
+// one it does (see protocol.Negotiate). Requests without a version are from
+// clients that predate versioning and are served as version 1.
+func protocolMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(protocol.VersionsHeader, protocol.Versions())
+		v, err := protocol.Check(r.Header.Get(protocol.Header))
+		if err != nil {
+			w.Header().Set(protocol.Header, strconv.Itoa(protocol.Version))
+			httpError(w, "client: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(protocol.Header, strconv.Itoa(v))
 		next.ServeHTTP(w, r)
-		fmt.Fprintf(os.Stderr, "%s %s %s\n", r.Method, r.URL.Path, time.Since(start))
 	})
 }
 
+// validRequestID accepts short IDs made of characters that are safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// scrubber redacts sealed values from error replies and server logs, which
+// may quote decryption errors or request data.
+var scrubber = redact.New(pqc.SealedValueRegexp())
+
+// logw receives the request log.
+var logw io.Writer = scrubber.Writer(os.Stderr)
+
+// httpError is http.Error with the request ID appended, so a client that only
+// logs the body still reports something the server log can be searched for.
+// Secrets are scrubbed from msg.
+func httpError(w http.ResponseWriter, msg string, code int) {
+	msg = scrubber.String(msg)
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		msg += " (request_id=" + id + ")"
+	}
+	http.Error(w, msg, code)
+}
+
 // Serve starts the HTTP server and blocks until the server stops or ctx is cancelled.
 // It writes informational and error messages to the provided writers and returns an
 // integer exit code suitable for passing to os.Exit by the caller.
 func Serve(privateKeyFile string, socketPath string, ctx context.Context, cmdArgs []string, outw io.Writer, errw io.Writer) int {
+	return ServeWithOptions(privateKeyFile, socketPath, ctx, cmdArgs, ServeOptions{}, outw, errw)
+}
+
+// ServeTCP is like Serve but listens on addr using tlsConfig, which should
+// require client certificates (see mtls.ServerConfig). It exists for clients
+// that cannot share a Unix socket with the server, such as Kubernetes init
+// containers in other pods.
+func ServeTCP(privateKeyFile string, addr string, tlsConfig *tls.Config, ctx context.Context, cmdArgs []string, outw io.Writer, errw io.Writer) int {
+	return ServeWithOptions(privateKeyFile, "", ctx, cmdArgs, ServeOptions{Addr: addr, TLS: tlsConfig}, outw, errw)
+}
+
+// ServeOptions controls optional ServeWithOptions behaviour.
+type ServeOptions struct {
+	// Addr, when set, is a TCP address served with TLS instead of the Unix socket.
+	Addr string
+	TLS  *tls.Config
+	// DropTo is the user to switch to once the listener is bound and the
+	// private key has been read, so requests are never handled as root.
+	DropTo *harden.Credentials
+	// Landlock limits filesystem access to the paths landlockRules lists.
+	// It is skipped when a socket or route has a subprocess command, whose
+	// shared libraries would not be readable, unless LandlockCommands is set
+	// for statically linked commands.
+	Landlock         bool
+	LandlockCommands bool
+	// Seccomp installs harden.Seccomp right before serving, allowing exec
+	// only when cmdArgs selects the subprocess path.
+	Seccomp bool
+	// PprofAddr, when set, is a loopback TCP address serving net/http/pprof.
+	// It is bound together with the main listener, before any hardening.
+	PprofAddr string
+	// Once stops the server after the first successful POST (see onceHandler).
+	Once bool
+	// IdentifyPeers logs the PID, UID and container of the process behind
+	// each Unix socket request (see Peer). DockerSocket, when set, is used
+	// to add container names and implies IdentifyPeers; it needs Seccomp
+	// off, as the filter denies creating sockets.
+	IdentifyPeers bool
+	DockerSocket  string
+	// ACL, when set, limits the keys each peer may decrypt (see ACL). It
+	// needs Unix sockets and implies IdentifyPeers.
+	ACL *ACL
+	// SubprocessStdin pipes .env to the stdin of subprocess commands and
+	// passes the private key as fd 3, instead of preparing a temp directory
+	// with .env and .env.keys.
+	SubprocessStdin bool
+	// IsolateSubprocess runs subprocess commands in their own namespaces,
+	// able to read only their request's files (see isolate).
+	IsolateSubprocess bool
+	// ValueRegex is what every incoming value must match; other requests
+	// are refused with 400. Nil means pqc.DefaultValueRegex.
+	ValueRegex *regexp.Regexp
+	// MaxKeys limits the keys of one request, answering 413 beyond it.
+	// Zero means DefaultMaxKeys.
+	MaxKeys int
+	// CommandTimeout limits how long a subprocess command may run, answering
+	// 504 beyond it. Zero means DefaultCommandTimeout.
+	CommandTimeout time.Duration
+	// MaxValueLen limits the length of each value of a request, in bytes,
+	// answering 413 beyond it. Zero means env.DefaultLimits().MaxValueLen.
+	MaxValueLen int
+}
+
+// ServeWithOptions is Serve with the behaviour selected by opts.
+func ServeWithOptions(privateKeyFile string, socketPath string, ctx context.Context, cmdArgs []string, opts ServeOptions, outw io.Writer, errw io.Writer) int {
+	return serveSockets([]Socket{{Path: socketPath, PrivateKeyFile: privateKeyFile, Command: cmdArgs}}, ctx, opts, outw, errw)
+}
+
+// Socket is one Unix socket of ServeSockets with the private key and the
+// optional subprocess command its requests are decrypted with.
+type Socket struct {
+	Path           string   `json:"path"`
+	PrivateKeyFile string   `json:"private_key_file,omitempty"`
+	Command        []string `json:"command,omitempty"`
+	// Routes serve further URL paths of the same socket with their own key,
+	// a lighter alternative to a socket per project. With routes set,
+	// PrivateKeyFile only serves "/" and may be left empty.
+	Routes []Route `json:"routes,omitempty"`
+	// SingleDelivery lists the key names (path.Match patterns) that "/"
+	// releases only once, such as bootstrap tokens. Later requests for them
+	// get 410 Gone until the server restarts or gets SIGHUP.
+	SingleDelivery []string `json:"single_delivery,omitempty"`
+}
+
+// Route is one URL path of a Socket, such as "/projectA". Allow, when set,
+// lists the key names (path.Match patterns) that may be requested there, and
+// SingleDelivery those released only once (see Socket.SingleDelivery).
+type Route struct {
+	Path           string   `json:"path"`
+	PrivateKeyFile string   `json:"private_key_file"`
+	Command        []string `json:"command,omitempty"`
+	Allow          []string `json:"allow,omitempty"`
+	SingleDelivery []string `json:"single_delivery,omitempty"`
+}
+
+// keyFiles returns the private key fields of sockets and their routes, so
+// they can be pointed at the copies dropPrivilegesKeys makes.
+func keyFiles(sockets []Socket) []*string {
+	var keys []*string
+	for i := range sockets {
+		if sockets[i].PrivateKeyFile != "" {
+			keys = append(keys, &sockets[i].PrivateKeyFile)
+		}
+		for j := range sockets[i].Routes {
+			keys = append(keys, &sockets[i].Routes[j].PrivateKeyFile)
+		}
+	}
+	return keys
+}
+
+// ServeSockets serves every socket at once, each with its own key and
+// command, so one server can back several compose projects without them
+// being able to decrypt each other's values. Hardening covers all of them;
+// opts.Addr is not supported.
+func ServeSockets(sockets []Socket, ctx context.Context, opts ServeOptions, outw io.Writer, errw io.Writer) int {
+	if opts.Addr != "" {
+		fmt.Fprintln(errw, "serving several sockets cannot be combined with a TCP address")
+		return 1
+	}
+	if len(sockets) == 0 {
+		fmt.Fprintln(errw, "no sockets to serve")
+		return 1
+	}
+	return serveSockets(sockets, ctx, opts, outw, errw)
+}
+
+func serveSockets(sockets []Socket, ctx context.Context, opts ServeOptions, outw io.Writer, errw io.Writer) int {
+	errw = scrubber.Writer(errw)
+	sockets = slices.Clone(sockets)
+	for i := range sockets {
+		sockets[i].Routes = slices.Clone(sockets[i].Routes)
+	}
 
-	// Ensure /tmp is tmpfs (security expectation for ephemeral files)
-	if err := checkTempIsTmpfs(os.TempDir()); err != nil {
+	if opts.DockerSocket != "" {
+		if opts.Seccomp {
+			fmt.Fprintln(errw, "looking up container names needs the seccomp filter off (--no-seccomp), as it denies creating sockets")
+			return 1
+		}
+		if err := checkDockerSocket(opts.DockerSocket); err != nil {
+			fmt.Fprintln(errw, err)
+			return 1
+		}
+		opts.IdentifyPeers = true
+	}
+	if opts.ACL != nil {
+		switch {
+		case opts.Addr != "":
+			fmt.Fprintln(errw, "client ACLs need a Unix socket, where the peer of a request can be identified")
+			return 1
+		case opts.ACL.needsNames() && opts.DockerSocket == "":
+			fmt.Fprintln(errw, "client ACLs with container names need a Docker socket (--docker-socket)")
+			return 1
+		}
+		opts.IdentifyPeers = true
+	}
+
+	// Ensure /tmp is tmpfs (security expectation for ephemeral files).
+	// Windows and macOS have no tmpfs, so there a temp directory that is not
+	// memory-backed only draws a warning.
+	if err := checkTempIsTmpfs(os.TempDir()); errors.Is(err, file.ErrNoTmpfs) {
+		fmt.Fprintf(errw, "warning: %v; temporary files may reach disk\n", err)
+	} else if err != nil {
 		fmt.Fprintln(errw, err)
 		return 1
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
-		handlePost(w, r, cmdArgs, privateKeyFile)
-	})
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+	if opts.Addr != "" {
+		if opts.TLS == nil || opts.TLS.ClientAuth != tls.RequireAndVerifyClientCert {
+			fmt.Fprintln(errw, "refusing to serve over TCP without mutual TLS")
+			return 1
+		}
+		ln, err := tls.Listen("tcp", opts.Addr, opts.TLS)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to listen on %s: %v", opts.Addr, err))
+			return 1
+		}
+		fmt.Fprintf(errw, "ojster serving mutual TLS on %s\n", ln.Addr())
+		listeners = append(listeners, ln)
+		sockets[0].Path = ""
+	} else {
+		for _, sock := range sockets {
+			// Ensure socket is writable by client processes
+			ln, code := listenUnix(sock.Path, 0o666, errw)
+			if ln == nil {
+				closeAll()
+				return code
+			}
+			fmt.Fprintf(errw, "ojster serving on %s\n", ipc.Describe(sock.Path))
+			listeners = append(listeners, ln)
+		}
+	}
+
+	if opts.IdentifyPeers && opts.Addr == "" {
+		var names *containerNames
+		if opts.DockerSocket != "" {
+			names = newContainerNames(opts.DockerSocket)
+		}
+		for i, ln := range listeners {
+			listeners[i] = peerListener{Listener: ln, names: names}
+		}
+	}
 
-	// Ensure previous socket removed
+	if opts.PprofAddr != "" {
+		pln, err := net.Listen("tcp", opts.PprofAddr)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to listen on %s for pprof: %v", opts.PprofAddr, err))
+			closeAll()
+			return 1
+		}
+		defer pln.Close()
+		go func() { _ = http.Serve(pln, pprofMux()) }()
+		fmt.Fprintf(errw, "ojster serving pprof on http://%s/debug/pprof/\n", pln.Addr())
+	}
+
+	if opts.DropTo != nil {
+		keys := keyFiles(sockets)
+		paths := make([]string, len(keys))
+		for i, key := range keys {
+			paths[i] = *key
+		}
+		keyCopies, cleanup, err := dropPrivilegesKeys(paths, *opts.DropTo)
+		if err != nil {
+			fmt.Fprintln(errw, err)
+			closeAll()
+			return 1
+		}
+		defer cleanup()
+		for i, key := range keys {
+			*key = keyCopies[i]
+		}
+		fmt.Fprintf(errw, "ojster dropped privileges to uid %d gid %d\n", opts.DropTo.UID, opts.DropTo.GID)
+	}
+	// Keys of the direct unseal path are parsed once here rather than on the
+	// first request; keyCache reloads them when their files change.
+	for _, sock := range sockets {
+		preloadKey(sock.PrivateKeyFile, sock.Command, errw)
+		for _, route := range sock.Routes {
+			preloadKey(route.PrivateKeyFile, route.Command, errw)
+		}
+	}
+	allowExec := false
+	var rules []harden.FSRule
+	if opts.IdentifyPeers {
+		rules = append(rules, harden.FSRule{Path: procRoot, Access: harden.FSRead})
+	}
+	for _, sock := range sockets {
+		rules = append(rules, landlockRules(sock.PrivateKeyFile, sock.Path, sock.Command)...)
+		allowExec = allowExec || len(sock.Command) > 0
+		for _, route := range sock.Routes {
+			rules = append(rules, landlockRules(route.PrivateKeyFile, "", route.Command)...)
+			allowExec = allowExec || len(route.Command) > 0
+		}
+	}
+	if allowExec && opts.IsolateSubprocess {
+		// Isolated commands start through ojster itself (see isolate).
+		if self, err := os.Executable(); err == nil {
+			rules = append(rules, harden.FSRule{Path: self, Access: harden.FSRead | harden.FSExec})
+		}
+	}
+	single := false
+	for _, sock := range sockets {
+		single = single || len(sock.SingleDelivery) > 0
+		for _, route := range sock.Routes {
+			single = single || len(route.SingleDelivery) > 0
+		}
+	}
+	if single {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go resetOnHangup(ctx, hup, errw)
+	}
+	if opts.Landlock && allowExec && !opts.LandlockCommands {
+		fmt.Fprintln(errw, "running without Landlock filesystem restrictions, as the command may need shared libraries; --landlock applies them to a statically linked command")
+	} else if opts.Landlock {
+		if err := landlockFunc(rules); err != nil {
+			fmt.Fprintf(errw, "warning: running without Landlock filesystem restrictions: %v\n", err)
+		}
+	}
+	if opts.Seccomp {
+		if err := seccompFunc(allowExec); err != nil {
+			fmt.Fprintf(errw, "warning: running without a seccomp filter: %v\n", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cfg := newUnsealConfig(opts)
+	handler := func(sock Socket) http.Handler { return newMux(sock, cfg) }
+	if opts.Once {
+		var o onceState
+		handler = func(sock Socket) http.Handler { return onceHandler(newMux(sock, cfg), &o, cancel) }
+	}
+	if opts.ACL != nil {
+		mux := handler
+		handler = func(sock Socket) http.Handler { return aclHandler(mux(sock), opts.ACL) }
+	}
+	if len(listeners) == 1 {
+		return serveListener(listeners[0], handler(sockets[0]), ctx, errw)
+	}
+
+	// A failing listener stops the others, so the server never keeps running
+	// with only some of its sockets.
+	warnWithoutMemlock(errw)
+	codes := make(chan int, len(listeners))
+	for i, ln := range listeners {
+		go func() {
+			code := serveHTTP(ln, handler(sockets[i]), ctx, errw)
+			cancel()
+			codes <- code
+		}()
+	}
+	worst := 0
+	for range listeners {
+		worst = max(worst, <-codes)
+	}
+	return worst
+}
+
+// preloadKey loads privateKeyFile into keyCache unless a subprocess command
+// decrypts with it. Failing is not fatal: requests report the error until the
+// file is fixed.
+func preloadKey(privateKeyFile string, cmdArgs []string, errw io.Writer) {
+	if privateKeyFile == "" || len(cmdArgs) > 0 {
+		return
+	}
+	if _, err := keyCache.Load(privateKeyFile); err != nil {
+		fmt.Fprintf(errw, "warning: failed to load private key: %v\n", err)
+	}
+}
+
+// resetOnHangup makes single-delivery keys available again on each SIGHUP.
+func resetOnHangup(ctx context.Context, hup <-chan os.Signal, errw io.Writer) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			fmt.Fprintf(errw, "SIGHUP: %d single-delivery keys can be delivered again\n", delivered.reset())
+		}
+	}
+}
+
+// landlockRules lists the paths serving needs: the private key, the socket's
+// directory (to remove the socket on shutdown), and the temp dir, subprocess
+// binary and /dev/null for the subprocess path.
+func landlockRules(privateKeyFile, socketPath string, cmdArgs []string) []harden.FSRule {
+	var rules []harden.FSRule
+	if privateKeyFile != "" {
+		rules = append(rules, harden.FSRule{Path: privateKeyFile, Access: harden.FSRead})
+	}
+	if socketPath != "" {
+		rules = append(rules, harden.FSRule{Path: filepath.Dir(socketPath), Access: harden.FSWrite})
+	}
+	if len(cmdArgs) > 0 {
+		rules = append(rules,
+			harden.FSRule{Path: os.TempDir(), Access: harden.FSRead | harden.FSWrite},
+			harden.FSRule{Path: os.DevNull, Access: harden.FSRead | harden.FSWrite},
+		)
+		if bin, err := exec.LookPath(cmdArgs[0]); err == nil {
+			rules = append(rules, harden.FSRule{Path: bin, Access: harden.FSRead | harden.FSExec})
+		}
+	}
+	return rules
+}
+
+// landlockFunc and seccompFunc are vars so tests can check the wiring
+// without restricting themselves.
+var landlockFunc = harden.Landlock
+var seccompFunc = harden.Seccomp
+
+// dropFunc is a var so tests can check dropPrivileges without losing root.
+var dropFunc = harden.Drop
+
+// dropPrivileges reads the private key while still privileged, copies it into
+// a fresh directory on the (tmpfs) temp dir owned by creds, and switches the
+// process to creds. The copy keeps the per-request key reads and the
+// subprocess symlink working; cleanup removes it.
+func dropPrivileges(privateKeyFile string, creds harden.Credentials) (string, func(), error) {
+	copies, cleanup, err := dropPrivilegesKeys([]string{privateKeyFile}, creds)
+	if err != nil {
+		return "", nil, err
+	}
+	return copies[0], cleanup, nil
+}
+
+// dropPrivilegesKeys is dropPrivileges for several keys, each copied into its
+// own directory so keys with the same file name do not collide.
+func dropPrivilegesKeys(privateKeyFiles []string, creds harden.Credentials) ([]string, func(), error) {
+	var dirs []string
+	cleanup := func() {
+		for _, dir := range dirs {
+			_ = os.RemoveAll(dir)
+		}
+	}
+	copies := make([]string, len(privateKeyFiles))
+	for i, privateKeyFile := range privateKeyFiles {
+		data, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to read private key file %s: %w", privateKeyFile, err)
+		}
+		dir, err := os.MkdirTemp("", "ojster-key-")
+		if err != nil {
+			memlock.Wipe(data)
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create key dir: %w", err)
+		}
+		dirs = append(dirs, dir)
+		copies[i] = filepath.Join(dir, filepath.Base(privateKeyFile))
+		err = os.WriteFile(copies[i], data, 0o600)
+		memlock.Wipe(data)
+		if err == nil {
+			err = os.Chown(copies[i], creds.UID, creds.GID)
+		}
+		if err == nil {
+			err = os.Chown(dir, creds.UID, creds.GID)
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to drop privileges: %w", err)
+		}
+	}
+	if err := dropFunc(creds); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to drop privileges: %w", err)
+	}
+	return copies, cleanup, nil
+}
+
+// listenUnix replaces any stale socket at socketPath, listens on it and
+// applies perm. A named pipe on Windows keeps its default access instead.
+// On failure it returns a nil listener and an exit code.
+func listenUnix(socketPath string, perm os.FileMode, errw io.Writer) (net.Listener, int) {
+	if ipc.IsPipe(socketPath) {
+		ln, err := ipc.Listen(socketPath)
+		if err != nil {
+			fmt.Fprintln(errw, fmt.Errorf("failed to listen on %s: %v", ipc.Describe(socketPath), err))
+			return nil, 1
+		}
+		return ln, 0
+	}
+	if err := prepareSocketDir(filepath.Dir(socketPath)); err != nil {
+		fmt.Fprintln(errw, err)
+		return nil, 1
+	}
 	_ = os.RemoveAll(socketPath)
 
-	ln, err := net.Listen("unix", socketPath)
+	ln, err := ipc.Listen(socketPath)
 	if err != nil {
 		fmt.Fprintln(errw, fmt.Errorf("failed to listen on unix socket %s: %v", socketPath, err))
-		return 1
+		return nil, 1
 	}
 
-	// Ensure socket is writable by client processes
-	if err := os.Chmod(socketPath, 0o666); err != nil {
+	if err := os.Chmod(socketPath, perm); err != nil {
 		fmt.Fprintln(errw, fmt.Errorf("failed to chmod socket %s: %v", socketPath, err))
 		ln.Close()
-		return 1
+		return nil, 1
 	}
+	return ln, 0
+}
 
-	server := &http.Server{Handler: loggingMiddleware(mux)}
+// prepareSocketDir creates dir (and its parents) with mode 0750 if missing and
+// refuses a world-writable one, where anyone could swap the socket for their
+// own. Sticky directories such as /tmp are accepted: there only the owner
+// may remove or rename the socket.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create socket directory %s: %v", dir, err)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat socket directory %s: %v", dir, err)
+	}
+	if fi.Mode().Perm()&0o002 != 0 && fi.Mode()&os.ModeSticky == 0 {
+		return fmt.Errorf("socket directory %s is world-writable; run chmod o-w on it", dir)
+	}
+	return nil
+}
+
+// pprofMux serves the net/http/pprof handlers under /debug/pprof/.
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// newMux serves sock's key on "/". Without routes every path ends up there;
+// with routes, each route path gets its own key and other paths are not found.
+// Each key also serves batch requests on its path plus batchPath, and its
+// public key on its path plus pubkeyPath. All of them unseal with cfg.
+func newMux(sock Socket, cfg *unsealConfig) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+statsPath, func(w http.ResponseWriter, r *http.Request) { handleStats(w) })
+	mux.HandleFunc("GET "+metricsPath, func(w http.ResponseWriter, r *http.Request) { handleMetrics(w) })
+	handle := func(pattern, prefix string, cmdArgs []string, privateKeyFile string, allow, singleKeys []string) {
+		single := singleDelivery{scope: sock.Path + pattern, patterns: singleKeys}
+		mux.HandleFunc("POST "+pattern, func(w http.ResponseWriter, r *http.Request) {
+			setKeyHeaders(w, r, privateKeyFile, cmdArgs)
+			handlePost(w, r, cfg, cmdArgs, privateKeyFile, allow, single)
+		})
+		mux.HandleFunc("POST "+prefix+batchPath, func(w http.ResponseWriter, r *http.Request) {
+			setKeyHeaders(w, r, privateKeyFile, cmdArgs)
+			handleBatch(w, r, cfg, cmdArgs, privateKeyFile, allow, single)
+		})
+		mux.HandleFunc("GET "+prefix+pubkeyPath, func(w http.ResponseWriter, r *http.Request) {
+			setKeyHeaders(w, r, privateKeyFile, cmdArgs)
+			handlePubkey(w, privateKeyFile)
+		})
+	}
+	if len(sock.Routes) == 0 {
+		handle("/", "", sock.Command, sock.PrivateKeyFile, nil, sock.SingleDelivery)
+		return mux
+	}
+	if sock.PrivateKeyFile != "" {
+		handle("/{$}", "", sock.Command, sock.PrivateKeyFile, nil, sock.SingleDelivery)
+	}
+	for _, route := range sock.Routes {
+		handle(route.Path, route.Path, route.Command, route.PrivateKeyFile, route.Allow, route.SingleDelivery)
+	}
+	return mux
+}
+
+// setKeyHeaders advertises the fingerprint of privateKeyFile so clients can
+// pin it, hands out the public key when r asks for it, and answers the key
+// challenge of r, if any, to prove the server holds the key. The key comes
+// from keyCache, which parses it once and again only when its file changes.
+// Keys of a subprocess command are the command's business and get no
+// headers, nor do keys that fail to load.
+func setKeyHeaders(w http.ResponseWriter, r *http.Request, privateKeyFile string, cmdArgs []string) {
+	if len(cmdArgs) > 0 {
+		return
+	}
+	dk, err := keyCache.Load(privateKeyFile)
+	if err != nil {
+		return
+	}
+	ek := dk.EncapsulationKey()
+	w.Header().Set(protocol.KeyFingerprintHeader, pqc.Fingerprint(ek))
+	if r.Header.Get(protocol.PublicKeyHeader) != "" {
+		w.Header().Set(protocol.PublicKeyHeader, base64.StdEncoding.EncodeToString(ek.Bytes()))
+	}
+	if c := r.Header.Get(protocol.KeyProofHeader); c != "" {
+		ct, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return
+		}
+		if proof, err := pqc.KeyProof(dk, ct); err == nil {
+			w.Header().Set(protocol.KeyProofHeader, base64.StdEncoding.EncodeToString(proof))
+		}
+	}
+}
+
+// onceState is shared by the onceHandlers of all sockets of a server.
+type onceState struct {
+	mu   sync.Mutex
+	done bool
+}
+
+// onceHandler serves POSTs one at a time and calls stop after the first one
+// that succeeds; later requests are refused with 503 while the server shuts
+// down. Other requests, such as GET /pubkey, pass through.
+func onceHandler(next http.Handler, o *onceState, stop func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.done {
+			httpError(w, "server already answered its one request (serve --once)", http.StatusServiceUnavailable)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status >= 200 && sw.status < 300 {
+			o.done = true
+			stop()
+		}
+	})
+}
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// serveListener serves handler on ln until ctx is cancelled or the server fails.
+func serveListener(ln net.Listener, handler http.Handler, ctx context.Context, errw io.Writer) int {
+	warnWithoutMemlock(errw)
+	return serveHTTP(ln, handler, ctx, errw)
+}
+
+func warnWithoutMemlock(errw io.Writer) {
+	if err := probeMemlock(); err != nil {
+		fmt.Fprintf(errw, "warning: key material may be swapped to disk (grant CAP_IPC_LOCK or raise RLIMIT_MEMLOCK): %v\n", err)
+	}
+}
 
-	fmt.Fprintf(errw, "ojster serving on unix socket %s\n", socketPath)
+// serveHTTP serves handler on ln until ctx is cancelled or the server fails.
+func serveHTTP(ln net.Listener, handler http.Handler, ctx context.Context, errw io.Writer) int {
+	server := &http.Server{Handler: loggingMiddleware(protocolMiddleware(handler)), ConnContext: peerConnContext}
 
 	// Graceful shutdown on context cancellation
 	go func() {
@@ -17,14 +17,26 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ojster/ojster/internal/harden"
+	"github.com/ojster/ojster/internal/pqc"
+	"github.com/ojster/ojster/internal/protocol"
 )
 
 //
@@ -40,11 +52,24 @@ func ExpectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
 	}
 }
 
+// testConfig is the unseal config of tests that do not need another. Most
+// tests stand in plaintext for sealed values, so it accepts any value;
+// TestHandlePost_NotSealed covers the check.
+func testConfig() *unsealConfig {
+	return newUnsealConfig(ServeOptions{ValueRegex: regexp.MustCompile("")})
+}
+
 func runPost(t *testing.T, body []byte, cmd []string, priv string) *httptest.ResponseRecorder {
+	t.Helper()
+	return runPostWith(t, testConfig(), body, cmd, priv)
+}
+
+// runPostWith is runPost with the unseal config cfg.
+func runPostWith(t *testing.T, cfg *unsealConfig, body []byte, cmd []string, priv string) *httptest.ResponseRecorder {
 	t.Helper()
 	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
 	rec := httptest.NewRecorder()
-	handlePost(rec, req, cmd, priv)
+	handlePost(rec, req, cfg, cmd, priv, nil, singleDelivery{})
 	return rec
 }
 
@@ -175,15 +200,219 @@ func TestServe_Startup(t *testing.T) {
 }
 
 func TestServe_InvalidSocketPath(t *testing.T) {
-	// point to a directory that cannot be created/listened on
-	invalidSocket := "/definitely-not-existing-dir/ojster.sock"
+	// point below a regular file, so the socket directory cannot be created
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invalidSocket := filepath.Join(notDir, "ojster.sock")
 
 	// privateKeyFile can be empty for this test
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
 
 	code := Serve("", invalidSocket, context.Background(), nil, &outBuf, &errBuf)
-	if code == 0 || !strings.Contains(errBuf.String(), "failed to listen") {
+	if code == 0 || !strings.Contains(errBuf.String(), "failed to create socket directory") {
+		t.Fatalf("expected directory failure, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
+
+func TestServeListener_WarnsWithoutMemlock(t *testing.T) {
+	orig := probeMemlock
+	probeMemlock = func() error { return errors.New("mlock failed: operation not permitted") }
+	defer func() { probeMemlock = orig }()
+
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "ojster.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errBuf bytes.Buffer
+	if code := serveListener(ln, http.NotFoundHandler(), ctx, &errBuf); code != 0 {
+		t.Fatalf("serveListener returned %d: %s", code, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "may be swapped to disk") {
+		t.Fatalf("expected memlock warning, got %q", errBuf.String())
+	}
+}
+
+func TestLandlockRules(t *testing.T) {
+	rules := landlockRules("/run/secrets/private_key", "/mnt/ojster/ipc.sock", nil)
+	want := []harden.FSRule{
+		{Path: "/run/secrets/private_key", Access: harden.FSRead},
+		{Path: "/mnt/ojster", Access: harden.FSWrite},
+	}
+	if !slices.Equal(rules, want) {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	// The subprocess path also needs the temp dir, /dev/null and its binary.
+	bin, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true binary")
+	}
+	rules = landlockRules("/k", "", []string{"true"})
+	want = []harden.FSRule{
+		{Path: "/k", Access: harden.FSRead},
+		{Path: os.TempDir(), Access: harden.FSRead | harden.FSWrite},
+		{Path: os.DevNull, Access: harden.FSRead | harden.FSWrite},
+		{Path: bin, Access: harden.FSRead | harden.FSExec},
+	}
+	if !slices.Equal(rules, want) {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+}
+
+func TestDropPrivileges(t *testing.T) {
+	orig := dropFunc
+	defer func() { dropFunc = orig }()
+	var dropped *harden.Credentials
+	dropFunc = func(c harden.Credentials) error { dropped = &c; return nil }
+
+	privateKeyFile := filepath.Join(t.TempDir(), "private_key")
+	if err := os.WriteFile(privateKeyFile, []byte("key-data"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	creds := harden.Credentials{UID: os.Getuid(), GID: os.Getgid()}
+	keyCopy, cleanup, err := dropPrivileges(privateKeyFile, creds)
+	if err != nil {
+		t.Fatalf("dropPrivileges failed: %v", err)
+	}
+	if dropped == nil || *dropped != creds {
+		t.Fatalf("expected drop to %+v, got %+v", creds, dropped)
+	}
+	data, err := os.ReadFile(keyCopy)
+	if err != nil || string(data) != "key-data" {
+		t.Fatalf("unexpected key copy %q: %v", data, err)
+	}
+	if fi, err := os.Stat(keyCopy); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected key copy mode: %v %v", fi.Mode(), err)
+	}
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(keyCopy)); !os.IsNotExist(err) {
+		t.Fatalf("expected key dir removed, got %v", err)
+	}
+
+	dropFunc = func(harden.Credentials) error { return errors.New("operation not permitted") }
+	if _, _, err := dropPrivileges(privateKeyFile, creds); err == nil || !strings.Contains(err.Error(), "failed to drop privileges") {
+		t.Fatalf("expected drop error, got %v", err)
+	}
+	if _, _, err := dropPrivileges(filepath.Join(t.TempDir(), "missing"), creds); err == nil || !strings.Contains(err.Error(), "failed to read private key file") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func TestServeWithOptions(t *testing.T) {
+	orig := dropFunc
+	defer func() { dropFunc = orig }()
+	dropFunc = func(harden.Credentials) error { return nil }
+
+	privateKeyFile := filepath.Join(t.TempDir(), "private_key")
+	if err := os.WriteFile(privateKeyFile, []byte("key-data"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	socketPath := filepath.Join(t.TempDir(), "ojster.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	var outBuf, errBuf bytes.Buffer
+	done := make(chan int, 1)
+	opts := ServeOptions{DropTo: &harden.Credentials{UID: os.Getuid(), GID: os.Getgid()}}
+	go func() { done <- ServeWithOptions(privateKeyFile, socketPath, ctx, nil, opts, &outBuf, &errBuf) }()
+	waitForServer(t, socketPath)
+	cancel()
+	if code := <-done; code != 0 {
+		t.Fatalf("ServeWithOptions returned %d: %s", code, errBuf.String())
+	}
+	if !strings.Contains(errBuf.String(), "dropped privileges to uid") {
+		t.Fatalf("expected drop message, got %q", errBuf.String())
+	}
+
+	// The seccomp filter is requested with exec allowed only for cmdArgs.
+	origSeccomp := seccompFunc
+	defer func() { seccompFunc = origSeccomp }()
+	var allowExec []bool
+	seccompFunc = func(exec bool) error { allowExec = append(allowExec, exec); return errors.New("not supported") }
+	for _, cmdArgs := range [][]string{nil, {"/bin/true"}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		errBuf.Reset()
+		go func() {
+			done <- ServeWithOptions(privateKeyFile, socketPath, ctx, cmdArgs, ServeOptions{Seccomp: true}, &outBuf, &errBuf)
+		}()
+		waitForServer(t, socketPath)
+		cancel()
+		if code := <-done; code != 0 || !strings.Contains(errBuf.String(), "running without a seccomp filter") {
+			t.Fatalf("unexpected result code=%d stderr=%q", code, errBuf.String())
+		}
+	}
+	if len(allowExec) != 2 || allowExec[0] || !allowExec[1] {
+		t.Fatalf("unexpected allowExec calls: %v", allowExec)
+	}
+
+	// Landlock gets the rules for the configured paths.
+	origLandlock := landlockFunc
+	defer func() { landlockFunc = origLandlock }()
+	var gotRules []harden.FSRule
+	landlockFunc = func(rules []harden.FSRule) error { gotRules = rules; return errors.New("not available") }
+	ctx, cancel = context.WithCancel(context.Background())
+	errBuf.Reset()
+	go func() {
+		done <- ServeWithOptions(privateKeyFile, socketPath, ctx, nil, ServeOptions{Landlock: true}, &outBuf, &errBuf)
+	}()
+	waitForServer(t, socketPath)
+	cancel()
+	if code := <-done; code != 0 || !strings.Contains(errBuf.String(), "running without Landlock") {
+		t.Fatalf("unexpected result code=%d stderr=%q", code, errBuf.String())
+	}
+	if len(gotRules) != 2 || gotRules[0].Path != privateKeyFile {
+		t.Fatalf("unexpected Landlock rules: %+v", gotRules)
+	}
+
+	// With a command, Landlock is only applied when asked for.
+	for _, commands := range []bool{false, true} {
+		gotRules = nil
+		ctx, cancel = context.WithCancel(context.Background())
+		errBuf.Reset()
+		go func() {
+			done <- ServeWithOptions(privateKeyFile, socketPath, ctx, []string{"true"}, ServeOptions{Landlock: true, LandlockCommands: commands}, &outBuf, &errBuf)
+		}()
+		waitForServer(t, socketPath)
+		cancel()
+		if code := <-done; code != 0 || !strings.Contains(errBuf.String(), "running without Landlock") {
+			t.Fatalf("unexpected result code=%d stderr=%q", code, errBuf.String())
+		}
+		if (gotRules != nil) != commands {
+			t.Fatalf("LandlockCommands=%v: Landlock rules %+v", commands, gotRules)
+		}
+	}
+
+	// Without a readable key the server refuses to start.
+	code := ServeWithOptions(filepath.Join(t.TempDir(), "missing"), socketPath, context.Background(), nil, opts, &outBuf, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "failed to read private key file") {
+		t.Fatalf("expected startup failure, got code=%d stderr=%q", code, errBuf.String())
+	}
+}
+
+//
+// ─────────────────────────────────────────────────────────────
+//   ServeTCP()
+// ─────────────────────────────────────────────────────────────
+//
+
+func TestServeTCP_RequiresMutualTLS(t *testing.T) {
+	for _, cfg := range []*tls.Config{nil, {ClientAuth: tls.RequestClientCert}} {
+		var outBuf, errBuf bytes.Buffer
+		code := ServeTCP("", "127.0.0.1:0", cfg, context.Background(), nil, &outBuf, &errBuf)
+		if code != 1 || !strings.Contains(errBuf.String(), "without mutual TLS") {
+			t.Fatalf("expected refusal, got code=%d stderr=%q", code, errBuf.String())
+		}
+	}
+}
+
+func TestServeTCP_InvalidAddr(t *testing.T) {
+	var outBuf, errBuf bytes.Buffer
+	cfg := &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}
+	code := ServeTCP("", "not-an-address", cfg, context.Background(), nil, &outBuf, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "failed to listen on not-an-address") {
 		t.Fatalf("expected listen failure, got code=%d stderr=%q", code, errBuf.String())
 	}
 }
@@ -221,3 +450,320 @@ func TestLoggingMiddleware(t *testing.T) {
 	}
 	ExpectStatus(t, rec, http.StatusTeapot)
 }
+
+func TestLoggingMiddleware_RequestID(t *testing.T) {
+	mw := loggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, "boom", http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(RequestIDHeader, "client-42")
+	rec := httptest.NewRecorder()
+	mw.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got != "client-42" {
+		t.Fatalf("incoming request ID not echoed, got %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "boom (request_id=client-42)") {
+		t.Fatalf("error body lacks the request ID: %q", rec.Body.String())
+	}
+
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	rec = httptest.NewRecorder()
+	mw.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); len(got) != 16 || !validRequestID(got) {
+		t.Fatalf("invalid incoming ID should be replaced, got %q", got)
+	}
+}
+
+func TestProtocolMiddleware(t *testing.T) {
+	h := protocolMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	for version, want := range map[string]int{"": http.StatusTeapot, strconv.Itoa(protocol.Version): http.StatusTeapot, "99": http.StatusBadRequest} {
+		req := httptest.NewRequest("POST", "/", nil)
+		if version != "" {
+			req.Header.Set(protocol.Header, version)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		ExpectStatus(t, rec, want)
+		if got := rec.Header().Get(protocol.Header); got != strconv.Itoa(protocol.Version) {
+			t.Fatalf("response protocol header = %q", got)
+		}
+		if got := rec.Header().Get(protocol.VersionsHeader); got != protocol.Versions() {
+			t.Fatalf("response versions header = %q", got)
+		}
+		if want == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "client: protocol version mismatch") {
+			t.Fatalf("unclear rejection: %q", rec.Body.String())
+		}
+	}
+}
+
+func TestHTTPError_Redacts(t *testing.T) {
+	sealed := pqc.BuildSealed([]byte{0x01, 0x02}, []byte{0x03})
+	rec := httptest.NewRecorder()
+	httpError(rec, "failed to decrypt "+sealed, http.StatusBadGateway)
+	if got := rec.Body.String(); strings.Contains(got, sealed) || !strings.Contains(got, "failed to decrypt [REDACTED]") {
+		t.Fatalf("sealed value not redacted: %q", got)
+	}
+}
+
+func TestPprofMux(t *testing.T) {
+	rec := httptest.NewRecorder()
+	pprofMux().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	ExpectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "heap") {
+		t.Fatalf("pprof index lacks profiles: %q", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	pprofMux().ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	ExpectStatus(t, rec, http.StatusNotFound)
+}
+
+func TestPrepareSocketDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if err := prepareSocketDir(dir); err != nil {
+		t.Fatalf("prepareSocketDir: %v", err)
+	}
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o750 {
+		t.Fatalf("expected a 0750 directory, got %v (%v)", fi.Mode(), err)
+	}
+
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSocketDir(dir); err == nil || !strings.Contains(err.Error(), "world-writable") {
+		t.Fatalf("expected world-writable error, got %v", err)
+	}
+	if err := os.Chmod(dir, 0o777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareSocketDir(dir); err != nil {
+		t.Fatalf("sticky directory should be accepted: %v", err)
+	}
+}
+
+func TestServeSockets(t *testing.T) {
+	orig := unsealMapFunc
+	defer func() { unsealMapFunc = orig }()
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		return map[string]string{"K": privPath}, nil
+	}
+
+	td := t.TempDir()
+	sockets := []Socket{
+		{Path: filepath.Join(td, "a", "ipc.sock"), PrivateKeyFile: "/keys/a"},
+		{Path: filepath.Join(td, "b", "ipc.sock"), PrivateKeyFile: "/keys/b"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var outBuf, errBuf bytes.Buffer
+	done := make(chan int, 1)
+	go func() { done <- ServeSockets(sockets, ctx, ServeOptions{}, &outBuf, &errBuf) }()
+	for _, sock := range sockets {
+		waitForServer(t, sock.Path)
+		resp, err := getUnixHTTPClient(sock.Path).Post("http://unix/", "application/json", strings.NewReader(`{"K":"`+pqc.Prefix+`v:v"}`))
+		if err != nil {
+			t.Fatalf("POST %s: %v", sock.Path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := `{"K":"` + sock.PrivateKeyFile + `"}`; string(body) != want {
+			t.Fatalf("socket %s answered %s, want %s", sock.Path, body, want)
+		}
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Fatalf("ServeSockets returned %d: %s", code, errBuf.String())
+	}
+
+	if code := ServeSockets(sockets, context.Background(), ServeOptions{Addr: "127.0.0.1:0"}, &outBuf, &errBuf); code != 1 {
+		t.Fatalf("expected TCP to be refused, got %d", code)
+	}
+}
+
+func TestNewMux_Routes(t *testing.T) {
+	orig := unsealMapFunc
+	defer func() { unsealMapFunc = orig }()
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		out := make(map[string]string, len(envMap))
+		for k := range envMap {
+			out[k] = privPath
+		}
+		return out, nil
+	}
+
+	mux := newMux(Socket{Routes: []Route{
+		{Path: "/projectA", PrivateKeyFile: "/keys/a", Allow: []string{"DB_*"}},
+		{Path: "/projectB", PrivateKeyFile: "/keys/b"},
+	}}, testConfig())
+	for _, tc := range []struct {
+		path, body string
+		code       int
+		want       string
+	}{
+		{"/projectA", `{"DB_PASS":"x"}`, http.StatusOK, `{"DB_PASS":"/keys/a"}`},
+		{"/projectA", `{"DB_PASS":"x","API_KEY":"y"}`, http.StatusForbidden, "key not allowed on /projectA: API_KEY"},
+		{"/projectB", `{"API_KEY":"y"}`, http.StatusOK, `{"API_KEY":"/keys/b"}`},
+		{"/projectB/x", `{"API_KEY":"y"}`, http.StatusNotFound, ""},
+		{"/", `{"API_KEY":"y"}`, http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("POST %s %s: got %d %q, want %d %q", tc.path, tc.body, rec.Code, rec.Body.String(), tc.code, tc.want)
+		}
+	}
+
+	// A socket key next to routes only serves "/".
+	mux = newMux(Socket{PrivateKeyFile: "/keys/root", Routes: []Route{{Path: "/projectA", PrivateKeyFile: "/keys/a"}}}, testConfig())
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"K":"v"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"K":"/keys/root"}` {
+		t.Fatalf("POST / got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNewMux_KeyFingerprint(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv"), filepath.Join(td, "pub")
+	var outBuf, errBuf bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: %q", errBuf.String())
+	}
+	info, err := pqc.InspectKeyFile(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{priv: info.Fingerprint, filepath.Join(td, "missing"): ""} {
+		rec := httptest.NewRecorder()
+		newMux(Socket{PrivateKeyFile: key}, testConfig()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+		if got := rec.Header().Get(protocol.KeyFingerprintHeader); got != want {
+			t.Errorf("%s: fingerprint %q, want %q", key, got, want)
+		}
+	}
+
+	// Asked for, the public key and the answer to a challenge are sent too.
+	dk, err := pqc.LoadDecapsulationKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, want := pqc.KeyChallenge(dk.EncapsulationKey())
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set(protocol.PublicKeyHeader, "1")
+	req.Header.Set(protocol.KeyProofHeader, base64.StdEncoding.EncodeToString(ct))
+	rec := httptest.NewRecorder()
+	newMux(Socket{PrivateKeyFile: priv}, testConfig()).ServeHTTP(rec, req)
+	if got := rec.Header().Get(protocol.PublicKeyHeader); got != base64.StdEncoding.EncodeToString(dk.EncapsulationKey().Bytes()) {
+		t.Errorf("public key %q", got)
+	}
+	if got := rec.Header().Get(protocol.KeyProofHeader); got != base64.StdEncoding.EncodeToString(want) {
+		t.Errorf("key proof %q, want %x", got, want)
+	}
+
+	// The key of a subprocess command is not parsed by the server.
+	rec = httptest.NewRecorder()
+	newMux(Socket{PrivateKeyFile: priv, Command: sh(`exit 3`)}, testConfig()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+	if got := rec.Header().Get(protocol.KeyFingerprintHeader); got != "" {
+		t.Errorf("command key: fingerprint %q, want none", got)
+	}
+}
+
+func TestNewMux_Pubkey(t *testing.T) {
+	td := t.TempDir()
+	priv, pub := filepath.Join(td, "priv"), filepath.Join(td, "pub")
+	var outBuf, errBuf bytes.Buffer
+	if code := pqc.KeypairWithPaths(priv, pub, &outBuf, &errBuf); code != 0 {
+		t.Fatalf("KeypairWithPaths failed: %q", errBuf.String())
+	}
+	want, err := os.ReadFile(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := newMux(Socket{PrivateKeyFile: priv, Routes: []Route{{Path: "/projectA", PrivateKeyFile: filepath.Join(td, "missing")}}}, testConfig())
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/pubkey", http.StatusOK},
+		{"/projectA/pubkey", http.StatusInternalServerError},
+		{"/projectB/pubkey", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.code {
+			t.Errorf("GET %s: got %d %q, want %d", tc.path, rec.Code, rec.Body.String(), tc.code)
+		}
+		if tc.code == http.StatusOK && rec.Body.String() != string(want) {
+			t.Errorf("GET %s = %q, want %q", tc.path, rec.Body.String(), want)
+		}
+	}
+}
+
+func TestOnceHandler(t *testing.T) {
+	var o onceState
+	stops := 0
+	codes := []int{http.StatusBadGateway, http.StatusOK}
+	h := onceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(codes[0])
+			codes = codes[1:]
+		}
+	}), &o, func() { stops++ })
+
+	for i, want := range []int{http.StatusBadGateway, http.StatusOK, http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		if rec.Code != want {
+			t.Fatalf("POST %d: got %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pubkey", nil))
+	if rec.Code != http.StatusOK || stops != 1 {
+		t.Fatalf("GET after stop: %d, stops=%d", rec.Code, stops)
+	}
+}
+
+func TestKeyFiles(t *testing.T) {
+	sockets := []Socket{
+		{PrivateKeyFile: "/a"},
+		{Routes: []Route{{PrivateKeyFile: "/b"}, {PrivateKeyFile: "/c"}}},
+	}
+	var got []string
+	for _, k := range keyFiles(sockets) {
+		got = append(got, *k)
+	}
+	if !slices.Equal(got, []string{"/a", "/b", "/c"}) {
+		t.Fatalf("keyFiles = %v", got)
+	}
+}
+
+func TestDropPrivilegesKeys_SameName(t *testing.T) {
+	orig := dropFunc
+	defer func() { dropFunc = orig }()
+	dropFunc = func(harden.Credentials) error { return nil }
+
+	var keys []string
+	for _, d := range []string{"a", "b"} {
+		dir := filepath.Join(t.TempDir(), d)
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, filepath.Join(dir, "private_key"))
+		if err := os.WriteFile(keys[len(keys)-1], []byte(d), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copies, cleanup, err := dropPrivilegesKeys(keys, harden.Credentials{UID: os.Getuid(), GID: os.Getgid()})
+	if err != nil {
+		t.Fatalf("dropPrivilegesKeys: %v", err)
+	}
+	defer cleanup()
+	for i, c := range copies {
+		if b, err := os.ReadFile(c); err != nil || string(b) != []string{"a", "b"}[i] {
+			t.Fatalf("copy %d = %q (%v)", i, b, err)
+		}
+	}
+}
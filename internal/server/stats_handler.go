@@ -0,0 +1,114 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsPath and metricsPath serve the per-key usage of the whole server,
+// as JSON and in the Prometheus text format.
+const (
+	statsPath   = "/stats"
+	metricsPath = "/metrics"
+)
+
+// KeyStats is the usage of one key name since the server started.
+type KeyStats struct {
+	Key string `json:"key"`
+	// Count is the number of times the key was decrypted.
+	Count uint64 `json:"count"`
+	// Last is when the key was last decrypted.
+	Last time.Time `json:"last"`
+}
+
+// keyUsage counts decryptions per key name, over all sockets and routes, so
+// stale secrets that nothing requests anymore can be found. Only names are
+// kept, never values.
+var keyUsage = &usage{keys: map[string]*KeyStats{}}
+
+// statsNow is a var so tests can fix the timestamps.
+var statsNow = time.Now
+
+type usage struct {
+	mu   sync.Mutex
+	keys map[string]*KeyStats
+}
+
+// record counts one decryption of each key of m.
+func (u *usage) record(m map[string]string) {
+	now := statsNow().UTC()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for k := range m {
+		s, ok := u.keys[k]
+		if !ok {
+			s = &KeyStats{Key: k}
+			u.keys[k] = s
+		}
+		s.Count++
+		s.Last = now
+	}
+}
+
+// snapshot returns the stats sorted by key name.
+func (u *usage) snapshot() []KeyStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make([]KeyStats, 0, len(u.keys))
+	for _, k := range slices.Sorted(maps.Keys(u.keys)) {
+		out = append(out, *u.keys[k])
+	}
+	return out
+}
+
+// handleStats replies with the stats as a JSON array of KeyStats.
+func handleStats(w http.ResponseWriter) {
+	buf := getBuf()
+	defer putBuf(buf)
+	_ = json.NewEncoder(buf).Encode(keyUsage.snapshot())
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// handleMetrics replies with the stats in the Prometheus text format.
+func handleMetrics(w http.ResponseWriter) {
+	stats := keyUsage.snapshot()
+	buf := getBuf()
+	defer putBuf(buf)
+	buf.WriteString("# HELP ojster_key_decryptions_total Number of times a key was decrypted.\n")
+	buf.WriteString("# TYPE ojster_key_decryptions_total counter\n")
+	for _, s := range stats {
+		fmt.Fprintf(buf, "ojster_key_decryptions_total{key=%q} %d\n", s.Key, s.Count)
+	}
+	buf.WriteString("# HELP ojster_key_last_decryption_timestamp_seconds When a key was last decrypted.\n")
+	buf.WriteString("# TYPE ojster_key_last_decryption_timestamp_seconds gauge\n")
+	for _, s := range stats {
+		fmt.Fprintf(buf, "ojster_key_last_decryption_timestamp_seconds{key=%q} %d\n", s.Key, s.Last.Unix())
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
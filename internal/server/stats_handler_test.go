@@ -0,0 +1,80 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyUsage(t *testing.T) {
+	origUsage, origNow, origUnseal := keyUsage, statsNow, unsealMapFunc
+	t.Cleanup(func() { keyUsage, statsNow, unsealMapFunc = origUsage, origNow, origUnseal })
+	keyUsage = &usage{keys: map[string]*KeyStats{}}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	statsNow = func() time.Time { return now }
+	unsealMapFunc = func(_ context.Context, envMap map[string]string, privPath string, keys []string) (map[string]string, error) {
+		if _, ok := envMap["BROKEN"]; ok {
+			return nil, errors.New("decryption failed")
+		}
+		return envMap, nil
+	}
+
+	mux := newMux(Socket{PrivateKeyFile: "/keys/root"}, testConfig())
+	post := func(path, body string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	}
+	post("/", `{"DB_PASS": "x", "API_KEY": "y"}`)
+	now = now.Add(time.Hour)
+	post("/", `{"DB_PASS": "x"}`)
+	post("/batch", `[{"name": "a", "env": {"DB_PASS": "x"}}]`)
+	post("/", `{"BROKEN": "x", "OTHER": "y"}`) // failures are not counted
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	ExpectStatus(t, rec, http.StatusOK)
+	var got []KeyStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	want := []KeyStats{
+		{Key: "API_KEY", Count: 1, Last: now.Add(-time.Hour)},
+		{Key: "DB_PASS", Count: 3, Last: now},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stats = %+v, want %+v", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	ExpectStatus(t, rec, http.StatusOK)
+	for _, line := range []string{
+		`ojster_key_decryptions_total{key="DB_PASS"} 3`,
+		`ojster_key_decryptions_total{key="API_KEY"} 1`,
+		`ojster_key_last_decryption_timestamp_seconds{key="DB_PASS"} 1767326645`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ojster/ojster/internal/util/file"
+)
+
+// Document is an env file that can be edited and serialized again without
+// touching anything but the edited entries: comments, blank lines, unknown
+// lines, ordering, line endings and the presence of a final newline are kept
+// exactly. Lines written by Set and SetAnnotation end in "\r\n" when the first
+// line of the source does, so Windows-edited files keep their style.
+type Document struct {
+	blocks []block
+	// noFinalNewline is set when the source did not end with "\n".
+	noFinalNewline bool
+	// crlf is set when the first line of the source ended with "\r\n".
+	crlf bool
+	// bom is set when the source started with a UTF-8 byte order mark.
+	bom bool
+	// limits are those the source was parsed with; Get applies them too.
+	limits Limits
+}
+
+// block is one or more source lines, each keeping a "\r" that preceded its
+// "\n"; key is empty for non-entry lines.
+type block struct {
+	lines  []string
+	key    string
+	export bool
+}
+
+// ParseDocument parses env file content into a Document. Content beyond
+// DefaultLimits is an error wrapping ErrLimit, as is invalid UTF-8.
+func ParseDocument(data []byte) (*Document, error) {
+	return ParseDocumentWithOptions(data, ParseOptions{})
+}
+
+// ParseDocumentWithOptions is ParseDocument with the limits of opts.
+func ParseDocumentWithOptions(data []byte, opts ParseOptions) (*Document, error) {
+	l := opts.limits()
+	lines, err := splitLines(bytes.NewReader(data), l)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseEntries(lines, l); err != nil {
+		return nil, err
+	}
+	return parseDocument(data, l), nil
+}
+
+// parseDocument splits data, which is within l, into the blocks of a
+// Document.
+func parseDocument(data []byte, l Limits) *Document {
+	d := &Document{limits: l}
+	if len(data) == 0 {
+		return d
+	}
+	s, bom := strings.CutPrefix(string(data), utf8BOM)
+	d.bom = bom
+	if trimmed, ok := strings.CutSuffix(s, "\n"); ok {
+		s = trimmed
+	} else {
+		d.noFinalNewline = true
+	}
+	lines := strings.Split(s, "\n")
+	d.crlf = len(lines) > 1 && strings.HasSuffix(lines[0], "\r")
+
+	for i := 0; i < len(lines); {
+		trim := strings.TrimSpace(lines[i])
+		m := entryRe.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+		if trim == "" || strings.HasPrefix(trim, "#") || m == nil {
+			d.blocks = append(d.blocks, block{lines: lines[i : i+1]})
+			i++
+			continue
+		}
+		end := blockEnd(lines, i, m[4])
+		d.blocks = append(d.blocks, block{lines: lines[i:end], key: m[2], export: m[1] != ""})
+		i = end
+	}
+	return d
+}
+
+// blockEnd returns the index after the entry starting at lines[i], consuming
+// the continuation lines of a multi-line single-quoted value the same way the
+// parser does.
+func blockEnd(lines []string, i int, rawVal string) int {
+	rawTrim := strings.TrimLeft(rawVal, " \t")
+	if !strings.HasPrefix(rawTrim, "'") || strings.HasSuffix(strings.TrimRight(rawTrim, " \t"), "'") {
+		return i + 1
+	}
+	j := i + 1
+	for j < len(lines) {
+		line := strings.TrimSuffix(lines[j], "\r")
+		if entryRe.MatchString(line) {
+			break
+		}
+		j++
+		if strings.HasSuffix(line, "'") {
+			break
+		}
+	}
+	return j
+}
+
+// LoadDocument reads path into a Document. A missing file yields an empty one.
+func LoadDocument(path string) (*Document, error) {
+	return LoadDocumentWithOptions(path, ParseOptions{})
+}
+
+// LoadDocumentWithOptions is LoadDocument with the limits of opts.
+func LoadDocumentWithOptions(path string, opts ParseOptions) (*Document, error) {
+	b, err := readFile(path, opts.limits())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	d, err := ParseDocumentWithOptions(b, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Keys returns the entry keys in file order, including duplicates.
+func (d *Document) Keys() []string {
+	var keys []string
+	for _, b := range d.blocks {
+		if b.key != "" {
+			keys = append(keys, b.key)
+		}
+	}
+	return keys
+}
+
+// Get returns the logical value of key (the last one if it is duplicated).
+// A value Set beyond the limits of d is an error wrapping ErrLimit.
+func (d *Document) Get(key string) (string, bool, error) {
+	for i := len(d.blocks) - 1; i >= 0; i-- {
+		if d.blocks[i].key != key {
+			continue
+		}
+		entries, err := parseEntries(cutCR(d.blocks[i].lines), d.limits)
+		if err != nil {
+			return "", false, err
+		}
+		if len(entries) == 0 {
+			return "", false, nil
+		}
+		return entries[0].Value, true, nil
+	}
+	return "", false, nil
+}
+
+// Line returns the 1-based line number on which the last entry for key starts,
+// or 0 if key is absent.
+func (d *Document) Line(key string) int {
+	line, found := 1, 0
+	for _, b := range d.blocks {
+		if b.key == key {
+			found = line
+		}
+		line += len(b.lines)
+	}
+	return found
+}
+
+// Set replaces every entry for key with value formatted by FormatEnvEntry,
+// keeping an "export " prefix, or appends a new entry if key is absent.
+func (d *Document) Set(key, value string) {
+	found := false
+	for i := range d.blocks {
+		b := &d.blocks[i]
+		if b.key != key {
+			continue
+		}
+		found = true
+		line := FormatEnvEntry(key, value)
+		if b.export {
+			line = "export " + line
+		}
+		b.lines = d.newLines(line)
+	}
+	if !found {
+		// Bytes separates the appended entry from the last line even when
+		// the file has no final newline, so that can be kept too.
+		if n := len(d.blocks); n > 0 && d.crlf && d.noFinalNewline {
+			last := d.blocks[n-1].lines
+			last[len(last)-1] += "\r"
+		}
+		d.blocks = append(d.blocks, block{lines: d.newLines(FormatEnvEntry(key, value)), key: key})
+	}
+	// The last line of a file without a final newline has no "\r" either.
+	if n := len(d.blocks); n > 0 && d.crlf && d.noFinalNewline && d.blocks[n-1].key == key {
+		last := d.blocks[n-1].lines
+		last[len(last)-1] = strings.TrimSuffix(last[len(last)-1], "\r")
+	}
+}
+
+// newLines splits text into lines in the line ending style of d.
+func (d *Document) newLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	if d.crlf {
+		for i := range lines {
+			lines[i] += "\r"
+		}
+	}
+	return lines
+}
+
+// cutCR returns lines without the "\r" of "\r\n" line endings, as the
+// parser expects them.
+func cutCR(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.TrimSuffix(l, "\r")
+	}
+	return out
+}
+
+// Delete removes every entry for key and reports whether any was present.
+func (d *Document) Delete(key string) bool {
+	kept := d.blocks[:0]
+	for _, b := range d.blocks {
+		if b.key != key {
+			kept = append(kept, b)
+		}
+	}
+	removed := len(kept) != len(d.blocks)
+	d.blocks = kept
+	return removed
+}
+
+// Annotation returns the text after prefix on the comment line directly above
+// the last entry for key, if there is one.
+func (d *Document) Annotation(key, prefix string) (string, bool) {
+	for i := len(d.blocks) - 1; i >= 0; i-- {
+		if d.blocks[i].key != key {
+			continue
+		}
+		if i == 0 || d.blocks[i-1].key != "" {
+			return "", false
+		}
+		return strings.CutPrefix(strings.TrimSuffix(d.blocks[i-1].lines[0], "\r"), prefix)
+	}
+	return "", false
+}
+
+// SetAnnotation writes prefix+text as the comment line directly above every
+// entry for key, replacing one that already starts with prefix. An empty text
+// removes it instead. prefix must start with "#" so parsers skip the line.
+func (d *Document) SetAnnotation(key, prefix, text string) {
+	out := make([]block, 0, len(d.blocks)+1)
+	for i, b := range d.blocks {
+		if b.key == "" && i+1 < len(d.blocks) && d.blocks[i+1].key == key && strings.HasPrefix(b.lines[0], prefix) {
+			continue
+		}
+		if b.key == key && text != "" {
+			out = append(out, block{lines: d.newLines(prefix + text)})
+		}
+		out = append(out, b)
+	}
+	d.blocks = out
+}
+
+// Bytes serializes the document.
+func (d *Document) Bytes() []byte {
+	var sb strings.Builder
+	if d.bom {
+		sb.WriteString(utf8BOM)
+	}
+	for i, b := range d.blocks {
+		for j, l := range b.lines {
+			sb.WriteString(l)
+			if !d.noFinalNewline || i < len(d.blocks)-1 || j < len(b.lines)-1 {
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	return []byte(sb.String())
+}
+
+// WriteFile atomically writes the document to path, creating parent directories.
+// perm applies to a new file; an existing one keeps its mode and owner (see
+// file.ReplaceFileAtomic).
+func (d *Document) WriteFile(path string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return file.ReplaceFileAtomic(path, d.Bytes(), perm)
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// docSample exercises everything a Document must carry through untouched.
+const docSample = "# header\n\n  INDENTED = spaced   # note\nexport EXP=1\nML='a\nb'\nnot an entry\r\nDUP=1\nDUP=2\n\n# trailer"
+
+func mustParseDocument(t *testing.T, src string) *Document {
+	t.Helper()
+	d, err := ParseDocument([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseDocument(%q): %v", src, err)
+	}
+	return d
+}
+
+func TestDocument_RoundtripExact(t *testing.T) {
+	for _, src := range []string{"", "\n", docSample, docSample + "\n", "A=1"} {
+		if got := string(mustParseDocument(t, src).Bytes()); got != src {
+			t.Fatalf("roundtrip mismatch:\nwant %q\ngot  %q", src, got)
+		}
+	}
+}
+
+func TestDocument_GetKeys(t *testing.T) {
+	d := mustParseDocument(t, docSample)
+	if want := []string{"INDENTED", "EXP", "ML", "DUP", "DUP"}; !reflect.DeepEqual(d.Keys(), want) {
+		t.Fatalf("Keys = %v, want %v", d.Keys(), want)
+	}
+	for k, want := range map[string]string{"INDENTED": "spaced", "EXP": "1", "ML": "a\nb", "DUP": "2"} {
+		if got, ok, err := d.Get(k); err != nil || !ok || got != want {
+			t.Fatalf("Get(%s) = %q, %v, %v; want %q", k, got, ok, err, want)
+		}
+	}
+	if _, ok, err := d.Get("NOPE"); ok || err != nil {
+		t.Fatalf("expected missing key")
+	}
+	for k, want := range map[string]int{"INDENTED": 3, "ML": 5, "DUP": 9, "NOPE": 0} {
+		if got := d.Line(k); got != want {
+			t.Fatalf("Line(%s) = %d, want %d", k, got, want)
+		}
+	}
+}
+
+func TestDocument_SetDelete(t *testing.T) {
+	d := mustParseDocument(t, docSample)
+	d.Set("ML", "single")
+	d.Set("EXP", "two words")
+	d.Set("NEW", "x")
+	if !d.Delete("DUP") || d.Delete("DUP") {
+		t.Fatalf("Delete should report removal once")
+	}
+	want := "# header\n\n  INDENTED = spaced   # note\nexport EXP=\"two words\"\nML=single\nnot an entry\r\n\n# trailer\nNEW=x"
+	if got := string(d.Bytes()); got != want {
+		t.Fatalf("unexpected document:\nwant %q\ngot  %q", want, got)
+	}
+
+	// replacing in a file without a final newline keeps it that way
+	d = mustParseDocument(t, "A=1\nB=2")
+	d.Set("B", "3")
+	if got := string(d.Bytes()); got != "A=1\nB=3" {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestDocument_CRLF(t *testing.T) {
+	const src = "# header\r\nA=1\r\nML='a\r\nb'\r\n"
+	d := mustParseDocument(t, src)
+	if got := string(d.Bytes()); got != src {
+		t.Fatalf("roundtrip mismatch:\nwant %q\ngot  %q", src, got)
+	}
+	for k, want := range map[string]string{"A": "1", "ML": "a\nb"} {
+		if got, ok, err := d.Get(k); err != nil || !ok || got != want {
+			t.Fatalf("Get(%s) = %q, %v, %v; want %q", k, got, ok, err, want)
+		}
+	}
+	d.Set("A", "2")
+	d.Set("NEW", "x\ny")
+	d.SetAnnotation("NEW", "# note: ", "n")
+	want := "# header\r\nA=2\r\nML='a\r\nb'\r\n# note: n\r\nNEW='x\r\ny'\r\n"
+	if got := string(d.Bytes()); got != want {
+		t.Fatalf("unexpected document:\nwant %q\ngot  %q", want, got)
+	}
+	if got, ok := d.Annotation("NEW", "# note: "); !ok || got != "n" {
+		t.Fatalf("Annotation(NEW) = %q, %v", got, ok)
+	}
+	if got, ok, err := mustParseDocument(t, want).Get("NEW"); err != nil || !ok || got != "x\ny" {
+		t.Fatalf("Get(NEW) = %q, %v, %v", got, ok, err)
+	}
+
+	// without a final newline, appending keeps it that way
+	d = mustParseDocument(t, "A=1\r\nB=2")
+	d.Set("C", "3")
+	if got := string(d.Bytes()); got != "A=1\r\nB=2\r\nC=3" {
+		t.Fatalf("unexpected document %q", got)
+	}
+}
+
+func TestDocument_Annotation(t *testing.T) {
+	const pfx = "# note: "
+	d := mustParseDocument(t, "# header\nA=1\n# note: old\nB=2\n")
+	if _, ok := d.Annotation("A", pfx); ok {
+		t.Fatalf("A has an unrelated comment above it, not an annotation")
+	}
+	if got, ok := d.Annotation("B", pfx); !ok || got != "old" {
+		t.Fatalf("Annotation(B) = %q, %v", got, ok)
+	}
+	d.SetAnnotation("B", pfx, "new")
+	d.SetAnnotation("A", pfx, "a")
+	if got, want := string(d.Bytes()), "# header\n# note: a\nA=1\n# note: new\nB=2\n"; got != want {
+		t.Fatalf("unexpected document:\nwant %q\ngot  %q", want, got)
+	}
+	d.SetAnnotation("B", pfx, "")
+	if got, want := string(d.Bytes()), "# header\n# note: a\nA=1\nB=2\n"; got != want {
+		t.Fatalf("unexpected document:\nwant %q\ngot  %q", want, got)
+	}
+}
+
+func TestDocument_LoadWrite(t *testing.T) {
+	p := tmpPath(t, "sub/doc.env")
+	d, err := LoadDocument(p)
+	if err != nil || len(d.Keys()) != 0 {
+		t.Fatalf("expected empty document for missing file, got %v %v", d.Keys(), err)
+	}
+	d.Set("A", "1")
+	if err := d.WriteFile(p, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	b, _ := os.ReadFile(p)
+	if string(b) != "A=1\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
+
+// TestUpdateEnvFile_NoChurn ensures updating one key leaves every other byte alone.
+func TestUpdateEnvFile_NoChurn(t *testing.T) {
+	p := tmpPath(t, "churn.env")
+	writeFile(t, p, docSample)
+	if err := UpdateEnvFile(p, "INDENTED", "new"); err != nil {
+		t.Fatalf("UpdateEnvFile: %v", err)
+	}
+	b, _ := os.ReadFile(p)
+	want := strings.Replace(docSample, "  INDENTED = spaced   # note", "INDENTED=new", 1)
+	if string(b) != want {
+		t.Fatalf("unexpected content:\nwant %q\ngot  %q", want, b)
+	}
+}
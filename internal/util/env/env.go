@@ -21,137 +21,35 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
-
-	"github.com/ojster/ojster/internal/util/file"
+	"unicode/utf8"
 )
 
 // KeyNameRegex is the canonical regexp for valid environment key names.
 var KeyNameRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
 
+// entryRe matches a KEY=VALUE (or KEY: VALUE) line with an optional shell
+// "export " prefix. Groups: 1 export prefix, 2 key, 3 delimiter, 4 raw value.
+var entryRe = regexp.MustCompile(`^\s*(export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*([:=])\s*(.*)$`)
+
 // UpdateEnvFile replaces or appends KEY=VALUE in path. VALUE should be the raw value
 // (no surrounding quotes). If VALUE contains newlines, it will be written as a
 // single-quoted multiline value unless it contains single quotes or ends with a newline,
-// in which case a double-quoted escaped form is used. The function preserves comments and other lines.
+// in which case a double-quoted escaped form is used. Everything except the
+// updated entry is preserved byte for byte (see Document), and a replaced entry
+// keeps its "export " prefix. A new file is created 0644; an existing one keeps
+// its mode and, where allowed, its owner.
 func UpdateEnvFile(path, key, value string) error {
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if dir == "" {
-		dir = "."
-	}
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	// Read existing file if present
-	var lines []string
-	if b, err := os.ReadFile(path); err == nil {
-		// Split into lines preserving trailing newline semantics
-		scanner := bufio.NewScanner(bytes.NewReader(b))
-		for scanner.Scan() {
-			lines = append(lines, scanner.Text())
-		}
-		if err := scanner.Err(); err != nil {
-			return err
-		}
-	} else if !errors.Is(err, os.ErrNotExist) {
+	d, err := LoadDocument(path)
+	if err != nil {
 		return err
 	}
-
-	// Parser helpers
-	keyRe := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*([:=])\s*(.*)$`)
-
-	// Walk lines and detect existing key (taking multi-line single-quoted values into account)
-	outLines := make([]string, 0, len(lines)+2)
-	found := false
-	i := 0
-	for i < len(lines) {
-		line := lines[i]
-
-		// If comment or blank, copy as-is
-		trim := strings.TrimSpace(line)
-		if trim == "" || strings.HasPrefix(trim, "#") {
-			outLines = append(outLines, line)
-			i++
-			continue
-		}
-
-		// Try to match key line
-		m := keyRe.FindStringSubmatch(line)
-		if m == nil {
-			// Not a key-value line, copy as-is
-			outLines = append(outLines, line)
-			i++
-			continue
-		}
-
-		k := m[1]
-		rawVal := m[3]
-
-		// If this key is the one we want to replace, consume the whole value (including multi-line single-quoted)
-		if k == key {
-			found = true
-
-			rawTrim := strings.TrimLeft(rawVal, " \t")
-			// Detect single-quoted multi-line: starts with ' and does not end with ' (after trimming trailing spaces)
-			if strings.HasPrefix(rawTrim, "'") && !strings.HasSuffix(strings.TrimRight(rawTrim, " \t"), "'") {
-				// multi-line single-quoted: consume until a line that ends with a single quote
-				j := i + 1
-				for j < len(lines) {
-					if strings.HasSuffix(lines[j], "'") {
-						j++
-						break
-					}
-					j++
-				}
-				// skip original key block by advancing i to j
-				i = j
-			} else {
-				// single-line value: just skip this line
-				i++
-			}
-
-			// Append replacement entry (formatted)
-			outLines = append(outLines, FormatEnvEntry(key, value))
-			// continue without copying original block
-			continue
-		}
-
-		// Not the key we want, copy original line (and if it was a multi-line single-quoted value, copy the whole block)
-		outLines = append(outLines, line)
-		rawTrim := strings.TrimLeft(rawVal, " \t")
-		if strings.HasPrefix(rawTrim, "'") && !strings.HasSuffix(strings.TrimRight(rawTrim, " \t"), "'") {
-			j := i + 1
-			for j < len(lines) {
-				outLines = append(outLines, lines[j])
-				if strings.HasSuffix(lines[j], "'") {
-					j++
-					break
-				}
-				j++
-			}
-			i = j
-		} else {
-			i++
-		}
-	}
-
-	// If not found, append new entry (do not insert an extra blank line)
-	if !found {
-		outLines = append(outLines, FormatEnvEntry(key, value))
-	}
-
-	// Join lines with newline and ensure trailing newline
-	var buf bytes.Buffer
-	for _, l := range outLines {
-		buf.WriteString(l)
-		buf.WriteByte('\n')
-	}
-
-	// Atomically write file
-	return file.WriteFileAtomic(path, buf.Bytes(), 0o644)
+	d.Set(key, value)
+	return d.WriteFile(path, 0o644)
 }
 
 // FormatEnvEntry formats key and value according to Docker env rules.
@@ -184,6 +82,140 @@ func FormatEnvEntry(key, value string) string {
 	return fmt.Sprintf("%s=%s", key, value)
 }
 
+// Output formats accepted by FormatAs.
+const (
+	// FormatEnv is the .env syntax written by FormatEnvEntry.
+	FormatEnv = "env"
+	// FormatSystemd is for systemd's EnvironmentFile=.
+	FormatSystemd = "systemd"
+	// FormatDocker is for docker run --env-file, which takes values verbatim.
+	FormatDocker = "docker"
+	// FormatShell is a POSIX shell script of export statements for source.
+	FormatShell = "shell"
+)
+
+// Formats lists the formats FormatAs accepts.
+var Formats = []string{FormatEnv, FormatSystemd, FormatDocker, FormatShell}
+
+// FormatAs renders key=value as one entry in the given format. It fails for
+// values the format cannot represent (newlines in docker env files).
+func FormatAs(format, key, value string) (string, error) {
+	switch format {
+	case FormatEnv:
+		return FormatEnvEntry(key, value), nil
+	case FormatDocker:
+		// docker reads everything after the first "=" literally, quotes
+		// included, and has no way to continue a value on the next line.
+		if strings.ContainsAny(value, "\n\r") {
+			return "", fmt.Errorf("%s: docker env files cannot hold values with newlines", key)
+		}
+		return key + "=" + value, nil
+	case FormatSystemd:
+		// systemd does not expand variables in EnvironmentFile=, but inside
+		// double quotes a backslash escapes \, ", ` and $; newlines are kept.
+		if value != "" && strings.Trim(value, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_./:@%+,-=") == "" {
+			return key + "=" + value, nil
+		}
+		var b strings.Builder
+		b.WriteString(key + `="`)
+		for _, r := range value {
+			if strings.ContainsRune("\\\"`$", r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+		return b.String(), nil
+	case FormatShell:
+		return "export " + key + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'", nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// FormatEnvEntries formats the given keys of m (in order) as newline-separated
+// entries using FormatEnvEntry. The result has no trailing newline.
+func FormatEnvEntries(m map[string]string, keys []string) string {
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, FormatEnvEntry(k, m[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SelectKeys resolves which entries of envMap an operation applies to.
+// If keys is empty, all keys whose value satisfies match are selected in sorted order.
+// Otherwise each key is kept as given, except that a glob pattern (path.Match
+// syntax, e.g. "DB_*") expands to the matching keys whose value satisfies
+// match (all of them if match is nil), sorted. Keys absent from envMap and
+// patterns that match nothing are returned as missing.
+func SelectKeys(envMap map[string]string, keys []string, match func(string) bool) (selected []string, missing []string) {
+	if len(keys) == 0 {
+		for k, v := range envMap {
+			if match(v) {
+				selected = append(selected, k)
+			}
+		}
+		sort.Strings(selected)
+		return selected, nil
+	}
+	if !slices.ContainsFunc(keys, IsKeyPattern) {
+		for _, k := range keys {
+			if _, ok := envMap[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		return keys, missing
+	}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if !IsKeyPattern(k) {
+			if _, ok := envMap[k]; !ok {
+				missing = append(missing, k)
+			}
+			if !seen[k] {
+				seen[k] = true
+				selected = append(selected, k)
+			}
+			continue
+		}
+		var matched []string
+		for name, v := range envMap {
+			if ok, _ := path.Match(k, name); ok && (match == nil || match(v)) {
+				matched = append(matched, name)
+			}
+		}
+		if len(matched) == 0 {
+			missing = append(missing, k)
+		}
+		sort.Strings(matched)
+		for _, name := range matched {
+			if !seen[name] {
+				seen[name] = true
+				selected = append(selected, name)
+			}
+		}
+	}
+	return selected, missing
+}
+
+// IsKeyPattern reports whether key is a glob pattern rather than a key name.
+func IsKeyPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// PrefixPattern returns the pattern matching every key that starts with prefix.
+func PrefixPattern(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "*"
+}
+
 func escapeDoubleQuoted(s string) string {
 	// escape backslash, double quote, and common sequences \n \r \t
 	var b strings.Builder
@@ -210,9 +242,15 @@ func escapeDoubleQuoted(s string) string {
 // It understands Docker-style env syntax including single-quoted multiline values.
 // The returned values are the logical unquoted/unescaped values.
 func ParseEnvFile(path string) (map[string]string, error) {
+	return ParseEnvFileWithOptions(path, ParseOptions{})
+}
+
+// ParseEnvFileWithOptions is ParseEnvFile with the limits of opts.
+func ParseEnvFileWithOptions(path string, opts ParseOptions) (map[string]string, error) {
 	out := make(map[string]string)
+	l := opts.limits()
 
-	b, err := os.ReadFile(path)
+	b, err := readFile(path, l)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return out, nil
@@ -220,65 +258,192 @@ func ParseEnvFile(path string) (map[string]string, error) {
 		return nil, err
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(b))
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	lines, err := splitLines(bytes.NewReader(b), l)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return parseLines(lines, l)
+}
+
+// Entry is a single parsed KEY=VALUE pair. Literal is set for single-quoted
+// values, which are never interpolated.
+type Entry struct {
+	Key     string
+	Value   string
+	Literal bool
+}
+
+// EntriesMap collapses entries into a map; later duplicates of a key win.
+func EntriesMap(entries []Entry) map[string]string {
+	out := make(map[string]string, len(entries))
+	for _, e := range entries {
+		out[e.Key] = e.Value
 	}
-	if err := scanner.Err(); err != nil {
+	return out
+}
+
+// ParseEnvFileEntries is like ParseEnvFile but returns the entries in file
+// order, which Interpolate needs to resolve references the way compose does.
+func ParseEnvFileEntries(path string) ([]Entry, error) {
+	return ParseEnvFileEntriesWithOptions(path, ParseOptions{})
+}
+
+// ParseEnvFileEntriesWithOptions is ParseEnvFileEntries with the limits of opts.
+func ParseEnvFileEntriesWithOptions(path string, opts ParseOptions) ([]Entry, error) {
+	l := opts.limits()
+	b, err := readFile(path, l)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return parseLines(lines)
+	lines, err := splitLines(bytes.NewReader(b), l)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return parseEntries(lines, l)
 }
 
 // ParseEnvReader parses environment entries from any io.Reader and returns the map.
 // This is a full replacement for in-memory parsing helpers used in tests.
 func ParseEnvReader(r io.Reader) (map[string]string, error) {
-	scanner := bufio.NewScanner(r)
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
+	return ParseEnvReaderWithOptions(r, ParseOptions{})
+}
+
+// ParseEnvReaderWithOptions is ParseEnvReader with the limits of opts.
+func ParseEnvReaderWithOptions(r io.Reader, opts ParseOptions) (map[string]string, error) {
+	l := opts.limits()
+	lines, err := splitLines(r, l)
+	if err != nil {
 		return nil, err
 	}
-	return parseLines(lines)
+	return parseLines(lines, l)
 }
 
 // ParseEnvString parses environment entries from a string and returns the map.
 func ParseEnvString(s string) (map[string]string, error) {
-	scanner := bufio.NewScanner(strings.NewReader(s))
+	return ParseEnvReader(strings.NewReader(s))
+}
+
+// utf8BOM is the byte order mark some Windows editors put at the start of
+// UTF-8 files.
+const utf8BOM = "\ufeff"
+
+// splitLines reads r into lines, without a leading UTF-8 BOM (which would
+// otherwise become part of the first key). Invalid UTF-8 is an error naming
+// the line and column (in characters) of the first bad byte. Input beyond
+// l is an error wrapping ErrLimit.
+func splitLines(r io.Reader, l Limits) ([]string, error) {
+	cr := &countReader{r: l.limitReader(r)}
+	br := bufio.NewReader(cr)
 	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	for {
+		line, err := readLine(br, l.MaxLineLen)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, errLineTooLong) {
+			return nil, fmt.Errorf("line %d is longer than %d bytes (%w)", len(lines)+1, l.MaxLineLen, ErrLimit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if l.MaxLines > 0 && len(lines) == l.MaxLines {
+			return nil, fmt.Errorf("more than %d lines (%w)", l.MaxLines, ErrLimit)
+		}
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		if !utf8.ValidString(line) {
+			col := 1
+			for i, c := range line {
+				if c == utf8.RuneError {
+					if _, size := utf8.DecodeRuneInString(line[i:]); size == 1 {
+						break
+					}
+				}
+				col++
+			}
+			return nil, fmt.Errorf("line %d, column %d: invalid UTF-8", len(lines)+1, col)
+		}
+		lines = append(lines, line)
 	}
-	if err := scanner.Err(); err != nil {
+	if err := l.checkSize(cr.n); err != nil {
 		return nil, err
 	}
-	return parseLines(lines)
+	return lines, nil
+}
+
+// errLineTooLong is returned by readLine for a line over its maxLen.
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads the next line from br without its "\n" or "\r\n". A final
+// line without a newline is returned too; io.EOF means there are no more.
+// Unlike bufio.Scanner, whose 64 KiB default breaks on large sealed values,
+// the line may be as long as maxLen, or any length when that is not positive.
+func readLine(br *bufio.Reader, maxLen int) (string, error) {
+	var line []byte
+	for {
+		frag, err := br.ReadSlice('\n')
+		line = append(line, frag...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// A trailing '\r' may still turn out to be part of the line ending.
+			if maxLen > 0 && len(line) > maxLen+1 {
+				return "", errLineTooLong
+			}
+			continue
+		}
+		if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+			return "", err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if maxLen > 0 && len(line) > maxLen {
+			return "", errLineTooLong
+		}
+		return string(line), nil
+	}
 }
 
 // parseLines contains the core parsing logic shared by file/reader/string entry points.
-func parseLines(lines []string) (map[string]string, error) {
-	out := make(map[string]string)
-	keyRe := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*([:=])\s*(.*)$`)
+// Later duplicates of a key win.
+func parseLines(lines []string, l Limits) (map[string]string, error) {
+	entries, err := parseEntries(lines, l)
+	if err != nil {
+		return nil, err
+	}
+	return EntriesMap(entries), nil
+}
+
+// parseEntries parses lines into entries in file order. A value longer than
+// l.MaxValueLen is an error wrapping ErrLimit.
+func parseEntries(lines []string, l Limits) ([]Entry, error) {
+	var out []Entry
+	var tooLong error
 
 	i := 0
-	for i < len(lines) {
+	add := func(e Entry) {
+		if l.MaxValueLen > 0 && len(e.Value) > l.MaxValueLen {
+			tooLong = fmt.Errorf("line %d: value of %s is longer than %d bytes (%w)", i+1, e.Key, l.MaxValueLen, ErrLimit)
+		}
+		out = append(out, e)
+	}
+	for i < len(lines) && tooLong == nil {
 		line := lines[i]
 		trim := strings.TrimSpace(line)
 		if trim == "" || strings.HasPrefix(trim, "#") {
 			i++
 			continue
 		}
-		m := keyRe.FindStringSubmatch(line)
+		m := entryRe.FindStringSubmatch(line)
 		if m == nil {
 			i++
 			continue
 		}
-		k := m[1]
-		rawVal := m[3]
+		k := m[2]
+		rawVal := m[4]
 
 		rawTrim := strings.TrimLeft(rawVal, " \t")
 		// Single-quoted multiline
@@ -291,7 +456,7 @@ func parseLines(lines []string) (map[string]string, error) {
 			for j < len(lines) {
 				linej := lines[j]
 				// If this line looks like a new key, stop consuming — treat the block as malformed but do not swallow the next key.
-				if keyRe.MatchString(linej) {
+				if entryRe.MatchString(linej) {
 					break
 				}
 				if before, ok := strings.CutSuffix(linej, "'"); ok {
@@ -305,11 +470,11 @@ func parseLines(lines []string) (map[string]string, error) {
 			}
 			if !foundEnd {
 				// malformed: take what we have (do not consume the next key line)
-				out[k] = strings.Join(parts, "\n")
+				add(Entry{Key: k, Value: strings.Join(parts, "\n"), Literal: true})
 				i = j
 				continue
 			}
-			out[k] = strings.Join(parts, "\n")
+			add(Entry{Key: k, Value: strings.Join(parts, "\n"), Literal: true})
 			i = j
 			continue
 		}
@@ -317,7 +482,7 @@ func parseLines(lines []string) (map[string]string, error) {
 		// Single-line (could be single-quoted, double-quoted, or unquoted)
 		trimmed := strings.TrimSpace(rawVal)
 		if trimmed == "" {
-			out[k] = ""
+			add(Entry{Key: k})
 			i++
 			continue
 		}
@@ -354,7 +519,7 @@ func parseLines(lines []string) (map[string]string, error) {
 				}
 				sb.WriteByte(c)
 			}
-			out[k] = sb.String()
+			add(Entry{Key: k, Value: sb.String()})
 			i++
 			continue
 		}
@@ -366,7 +531,7 @@ func parseLines(lines []string) (map[string]string, error) {
 			// Unescape escaped quotes and backslashes inside single-quoted single-line values
 			inner = strings.ReplaceAll(inner, `\'`, `'`)
 			inner = strings.ReplaceAll(inner, `\\`, `\`)
-			out[k] = inner
+			add(Entry{Key: k, Value: inner, Literal: true})
 			i++
 			continue
 		}
@@ -375,9 +540,12 @@ func parseLines(lines []string) (map[string]string, error) {
 			trimmed = strings.TrimSpace(trimmed[:idx])
 		}
 		// Value is the rest of the trimmed string
-		out[k] = trimmed
+		add(Entry{Key: k, Value: trimmed})
 		i++
 	}
+	if tooLong != nil {
+		return nil, tooLong
+	}
 
 	return out, nil
 }
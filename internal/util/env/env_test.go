@@ -16,6 +16,7 @@ package env
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -348,3 +349,206 @@ func TestDoubleQuotedEscapes_DefaultBranch(t *testing.T) {
 		t.Fatalf("double-quoted escapes parsed incorrectly\ngot = %#v\nwant= %#v", m, want)
 	}
 }
+
+// TestSelectKeys covers implicit selection by predicate and missing-key reporting.
+func TestSelectKeys(t *testing.T) {
+	envMap := map[string]string{"B": "sealed:2", "A": "sealed:1", "C": "plain"}
+	isSealed := func(v string) bool { return strings.HasPrefix(v, "sealed:") }
+
+	sel, missing := SelectKeys(envMap, nil, isSealed)
+	if !reflect.DeepEqual(sel, []string{"A", "B"}) || len(missing) != 0 {
+		t.Fatalf("unexpected implicit selection: sel=%v missing=%v", sel, missing)
+	}
+
+	sel, missing = SelectKeys(envMap, []string{"C", "X", "A"}, isSealed)
+	if !reflect.DeepEqual(sel, []string{"C", "X", "A"}) || !reflect.DeepEqual(missing, []string{"X"}) {
+		t.Fatalf("unexpected explicit selection: sel=%v missing=%v", sel, missing)
+	}
+}
+
+// TestSelectKeys_Patterns expands globs against sealed entries only.
+func TestSelectKeys_Patterns(t *testing.T) {
+	envMap := map[string]string{"DB_PASS": "sealed:1", "DB_HOST": "plain", "DB_USER": "sealed:2", "SMTP_PASS": "sealed:3", "A*B": "sealed:4"}
+	isSealed := func(v string) bool { return strings.HasPrefix(v, "sealed:") }
+
+	sel, missing := SelectKeys(envMap, []string{"SMTP_PASS", "DB_*", "DB_USER", "NOPE_*"}, isSealed)
+	if !reflect.DeepEqual(sel, []string{"SMTP_PASS", "DB_PASS", "DB_USER"}) || !reflect.DeepEqual(missing, []string{"NOPE_*"}) {
+		t.Fatalf("unexpected selection: sel=%v missing=%v", sel, missing)
+	}
+	if sel, _ := SelectKeys(envMap, []string{"DB_*"}, nil); len(sel) != 3 {
+		t.Fatalf("nil match should select every matching key, got %v", sel)
+	}
+	if sel, _ := SelectKeys(envMap, []string{PrefixPattern("A*")}, isSealed); !reflect.DeepEqual(sel, []string{"A*B"}) {
+		t.Fatalf("prefix metacharacters must be literal, got %v", sel)
+	}
+}
+
+// TestFormatEnvEntries keeps key order and omits a trailing newline.
+func TestFormatEnvEntries(t *testing.T) {
+	m := map[string]string{"A": "1", "B": "two words"}
+	if got, want := FormatEnvEntries(m, []string{"B", "A"}), "B=\"two words\"\nA=1"; got != want {
+		t.Fatalf("FormatEnvEntries = %q; want %q", got, want)
+	}
+	if got := FormatEnvEntries(m, nil); got != "" {
+		t.Fatalf("expected empty output for no keys, got %q", got)
+	}
+}
+
+// TestExportPrefix ensures export-prefixed lines parse and keep their prefix on rewrite.
+func TestFormatAs(t *testing.T) {
+	cases := []struct {
+		format, value, want string
+	}{
+		{FormatEnv, "a b", `K="a b"`},
+		{FormatDocker, `it's "raw" $HOME`, `K=it's "raw" $HOME`},
+		{FormatSystemd, "plain-value_1.2:3", "K=plain-value_1.2:3"},
+		{FormatSystemd, "", `K=""`},
+		{FormatSystemd, "a \\ \"b\" `c` $D\nline2", "K=\"a \\\\ \\\"b\\\" \\`c\\` \\$D\nline2\""},
+		{FormatShell, "it's $x\ny", "export K='it'\\''s $x\ny'"},
+	}
+	for _, tc := range cases {
+		got, err := FormatAs(tc.format, "K", tc.value)
+		if err != nil || got != tc.want {
+			t.Fatalf("FormatAs(%s, %q) = %q, %v; want %q", tc.format, tc.value, got, err, tc.want)
+		}
+	}
+	if _, err := FormatAs(FormatDocker, "K", "a\nb"); err == nil || !strings.Contains(err.Error(), "newlines") {
+		t.Fatalf("expected newline error, got %v", err)
+	}
+	if _, err := FormatAs("toml", "K", "v"); err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Fatalf("expected unknown format error, got %v", err)
+	}
+}
+
+func TestFormatAs_ShellRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	value := "it's \"quoted\" $HOME `cmd` \\ \nsecond line"
+	line, err := FormatAs(FormatShell, "K", value)
+	if err != nil {
+		t.Fatalf("FormatAs: %v", err)
+	}
+	p := tmpPath(t, "vars.sh")
+	writeFile(t, p, line+"\n")
+	out, err := exec.Command("sh", "-c", `. "$1" && printf %s "$K"`, "sh", p).Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	if string(out) != value {
+		t.Fatalf("shell round trip = %q, want %q", out, value)
+	}
+}
+
+func TestExportPrefix(t *testing.T) {
+	content := strings.Join([]string{
+		"export FOO=bar",
+		"export\tQUOTED='a b'",
+		"  export ML='one",
+		"two'",
+		"export=literal-key",
+		"PLAIN=1",
+	}, "\n") + "\n"
+
+	got, err := ParseEnvString(content)
+	if err != nil {
+		t.Fatalf("ParseEnvString error: %v", err)
+	}
+	want := map[string]string{"FOO": "bar", "QUOTED": "a b", "ML": "one\ntwo", "export": "literal-key", "PLAIN": "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("export parsing mismatch\ngot = %#v\nwant= %#v", got, want)
+	}
+
+	path := tmpPath(t, "export.env")
+	writeFile(t, path, content)
+	if err := UpdateEnvFile(path, "FOO", "new value"); err != nil {
+		t.Fatalf("UpdateEnvFile FOO failed: %v", err)
+	}
+	if err := UpdateEnvFile(path, "QUOTED", "x"); err != nil {
+		t.Fatalf("UpdateEnvFile QUOTED failed: %v", err)
+	}
+	if err := UpdateEnvFile(path, "PLAIN", "2"); err != nil {
+		t.Fatalf("UpdateEnvFile PLAIN failed: %v", err)
+	}
+	b, _ := os.ReadFile(path)
+	wantFile := strings.Join([]string{
+		`export FOO="new value"`,
+		"export QUOTED=x",
+		"  export ML='one",
+		"two'",
+		"export=literal-key",
+		"PLAIN=2",
+	}, "\n") + "\n"
+	if string(b) != wantFile {
+		t.Fatalf("unexpected rewritten file\ngot = %q\nwant= %q", b, wantFile)
+	}
+}
+
+func TestParseEnvFile_BOMAndInvalidUTF8(t *testing.T) {
+	path := tmpPath(t, "bom.env")
+	writeFile(t, path, "\ufeffFIRST=1\nSECOND=é\n")
+	if got, want := readMapOrFail(t, path), map[string]string{"FIRST": "1", "SECOND": "é"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("BOM parsing mismatch\ngot = %#v\nwant= %#v", got, want)
+	}
+	if err := UpdateEnvFile(path, "FIRST", "2"); err != nil {
+		t.Fatalf("UpdateEnvFile failed: %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "\ufeffFIRST=2\nSECOND=é\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+
+	writeFile(t, path, "A=1\nB=é\xffx\n")
+	_, err := ParseEnvFile(path)
+	if err == nil || err.Error() != path+": line 2, column 4: invalid UTF-8" {
+		t.Fatalf("expected a line and column, got %v", err)
+	}
+	if _, err := ParseEnvFileEntries(path); err == nil {
+		t.Fatal("ParseEnvFileEntries accepted invalid UTF-8")
+	}
+	if _, err := ParseEnvString("A=\ufffd\n"); err != nil {
+		t.Fatalf("U+FFFD itself is valid: %v", err)
+	}
+}
+
+func TestParseEnvFile_LongLines(t *testing.T) {
+	// Sealing a large plaintext yields a value far beyond bufio.Scanner's
+	// default 64 KiB token limit.
+	long := strings.Repeat("A", 600<<10)
+	path := tmpPath(t, "long.env")
+	writeFile(t, path, "BEFORE=1\r\nBIG="+long+"\r\nAFTER=2")
+	if got := readMapOrFail(t, path); got["BIG"] != long || got["BEFORE"] != "1" || got["AFTER"] != "2" {
+		t.Fatalf("long line not parsed: %d bytes, BEFORE=%q AFTER=%q", len(got["BIG"]), got["BEFORE"], got["AFTER"])
+	}
+	if err := UpdateEnvFile(path, "OTHER", long+"B"); err != nil {
+		t.Fatalf("UpdateEnvFile failed: %v", err)
+	}
+	if got := readMapOrFail(t, path); got["BIG"] != long || got["OTHER"] != long+"B" {
+		t.Fatal("long values did not survive an update")
+	}
+}
+
+func TestUpdateEnvFile_KeepsMode(t *testing.T) {
+	path := tmpPath(t, "mode.env")
+	writeFile(t, path, "A=1\n")
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateEnvFile(path, "B", "2"); err != nil {
+		t.Fatalf("UpdateEnvFile failed: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0o600 {
+		t.Fatalf("mode %o, want 600", fi.Mode().Perm())
+	}
+
+	fresh := tmpPath(t, "fresh.env")
+	if err := UpdateEnvFile(fresh, "A", "1"); err != nil {
+		t.Fatalf("UpdateEnvFile failed: %v", err)
+	}
+	if fi, err := os.Stat(fresh); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0o644 {
+		t.Fatalf("mode %o, want 644", fi.Mode().Perm())
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupFunc resolves a variable name; ok reports whether it is set.
+type LookupFunc func(name string) (value string, ok bool)
+
+// Interpolate resolves entries in file order the way docker compose reads .env
+// files: a value may reference variables from lookup (typically os.LookupEnv,
+// may be nil) or, failing that, entries defined earlier in the file. Literal
+// entries are kept as-is. Later duplicates of a key win.
+func Interpolate(entries []Entry, lookup LookupFunc) (map[string]string, error) {
+	out := make(map[string]string, len(entries))
+	resolve := func(name string) (string, bool) {
+		if lookup != nil {
+			if v, ok := lookup(name); ok {
+				return v, true
+			}
+		}
+		v, ok := out[name]
+		return v, ok
+	}
+	for _, e := range entries {
+		if e.Literal {
+			out[e.Key] = e.Value
+			continue
+		}
+		v, err := Expand(e.Value, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Key, err)
+		}
+		out[e.Key] = v
+	}
+	return out, nil
+}
+
+// HasReference reports whether s contains anything Expand would substitute.
+func HasReference(s string) bool {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		c := s[i+1]
+		if c == '$' || c == '{' || isNameStart(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// References returns the names of the variables s references, in order of
+// first appearance. Defaults and alternatives are not followed.
+func References(s string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	_, _ = Expand(s, func(name string) (string, bool) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		// Report every variable as set and non-empty so ":?" never fails.
+		return "x", true
+	})
+	return names
+}
+
+// Expand substitutes variable references in s using lookup, following the
+// compose syntax:
+//
+//	$VAR, ${VAR}       value of VAR, empty if unset
+//	${VAR:-default}    default if VAR is unset or empty
+//	${VAR-default}     default if VAR is unset
+//	${VAR:+alt}        alt if VAR is set and non-empty, otherwise empty
+//	${VAR+alt}         alt if VAR is set, otherwise empty
+//	${VAR:?message}    error if VAR is unset or empty
+//	${VAR?message}     error if VAR is unset
+//	$$                 a literal $
+//
+// Defaults, alternatives and messages may themselves contain references.
+// A "$" that does not start a reference is kept.
+func Expand(s string, lookup LookupFunc) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i += 2
+		case next == '{':
+			end := matchBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			v, err := expandBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end + 1
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			v, _ := lookup(s[i+1 : j])
+			b.WriteString(v)
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// matchBrace returns the index of the "}" closing the "{" at open, or -1.
+func matchBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandBraced evaluates the inside of a ${...} reference.
+func expandBraced(expr string, lookup LookupFunc) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	val, set := lookup(name)
+	if rest == "" {
+		return val, nil
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return "", fmt.Errorf("invalid substitution ${%s}", expr)
+	}
+	op, arg := rest[0], rest[1:]
+	// With a colon, an empty value counts as unset.
+	present := set && (!colon || val != "")
+
+	switch op {
+	case '-':
+		if present {
+			return val, nil
+		}
+		return Expand(arg, lookup)
+	case '+':
+		if present {
+			return Expand(arg, lookup)
+		}
+		return "", nil
+	case '?':
+		if present {
+			return val, nil
+		}
+		msg, err := Expand(arg, lookup)
+		if err != nil {
+			return "", err
+		}
+		if msg == "" {
+			msg = "required variable is missing a value"
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	}
+	return "", fmt.Errorf("invalid substitution ${%s}", expr)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
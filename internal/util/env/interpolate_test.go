@@ -0,0 +1,166 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func mapLookup(m map[string]string) LookupFunc {
+	return func(k string) (string, bool) {
+		v, ok := m[k]
+		return v, ok
+	}
+}
+
+func TestExpand(t *testing.T) {
+	lookup := mapLookup(map[string]string{"A": "a", "EMPTY": "", "NESTED": "n"})
+	cases := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"$A", "a"},
+		{"${A}", "a"},
+		{"x${A}y$A.z", "xaya.z"},
+		{"$UNSET", ""},
+		{"$$A", "$A"},
+		{"cost: 5$", "cost: 5$"},
+		{"$-", "$-"},
+		{"${UNSET:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${EMPTY-def}", ""},
+		{"${A:-def}", "a"},
+		{"${UNSET:-${NESTED}}", "n"},
+		{"${A:+alt}", "alt"},
+		{"${EMPTY:+alt}", ""},
+		{"${EMPTY+alt}", "alt"},
+		{"${UNSET+alt}", ""},
+		{"${A:?boom}", "a"},
+		{"${EMPTY?boom}", ""},
+	}
+	for _, c := range cases {
+		got, err := Expand(c.in, lookup)
+		if err != nil || got != c.want {
+			t.Fatalf("Expand(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+
+	errCases := []struct {
+		in, want string
+	}{
+		{"${A", "unterminated"},
+		{"${}", "invalid variable name"},
+		{"${1A}", "invalid variable name"},
+		{"${A:}", "invalid substitution"},
+		{"${A*x}", "invalid substitution"},
+		{"${UNSET:?must be set}", "UNSET: must be set"},
+		{"${EMPTY:?}", "missing a value"},
+	}
+	for _, c := range errCases {
+		if _, err := Expand(c.in, lookup); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("Expand(%q) error = %v; want %q", c.in, err, c.want)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	entries, err := parseEntries([]string{
+		"DB_PASSWORD=s3cret",
+		"DATABASE_URL=postgres://user:${DB_PASSWORD}@db",
+		"LIT='${DB_PASSWORD}'",
+		"LATER=${DEFINED_BELOW:-none}",
+		"DEFINED_BELOW=x",
+		"FROM_ENV=$HOME_DIR",
+	}, DefaultLimits())
+	if err != nil {
+		t.Fatalf("parseEntries: %v", err)
+	}
+	got, err := Interpolate(entries, mapLookup(map[string]string{"HOME_DIR": "/home/u", "DB_PASSWORD": "from-env"}))
+	if err != nil {
+		t.Fatalf("Interpolate: %v", err)
+	}
+	want := map[string]string{
+		// the process environment wins over the file, as in compose
+		"DB_PASSWORD":   "s3cret",
+		"DATABASE_URL":  "postgres://user:from-env@db",
+		"LIT":           "${DB_PASSWORD}",
+		"LATER":         "none",
+		"DEFINED_BELOW": "x",
+		"FROM_ENV":      "/home/u",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	got, _ = Interpolate(entries, nil)
+	if got["DATABASE_URL"] != "postgres://user:s3cret@db" {
+		t.Fatalf("expected file value without lookup, got %q", got["DATABASE_URL"])
+	}
+
+	if _, err := Interpolate([]Entry{{Key: "BAD", Value: "${X:?}"}}, nil); err == nil || !strings.HasPrefix(err.Error(), "BAD: ") {
+		t.Fatalf("expected key-prefixed error, got %v", err)
+	}
+}
+
+func TestHasReference(t *testing.T) {
+	for s, want := range map[string]bool{
+		"":       false,
+		"plain":  false,
+		"5$":     false,
+		"$1":     false,
+		"$A":     true,
+		"${A}":   true,
+		"a$$b":   true,
+		"x $_ok": true,
+	} {
+		if got := HasReference(s); got != want {
+			t.Fatalf("HasReference(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	for s, want := range map[string]string{
+		"plain":                  "",
+		"$A and ${B} and $A":     "A,B",
+		"${DB:?required}@$$HOST": "DB",
+		"${A:-$B}":               "A",
+	} {
+		if got := strings.Join(References(s), ","); got != want {
+			t.Fatalf("References(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestParseEnvFileEntries(t *testing.T) {
+	p := tmpPath(t, "e.env")
+	writeFile(t, p, "B=1\nA='x'\nB=2\n")
+	entries, err := ParseEnvFileEntries(p)
+	if err != nil {
+		t.Fatalf("ParseEnvFileEntries: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Key != "B" || !entries[1].Literal || entries[2].Value != "2" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if m := EntriesMap(entries); m["B"] != "2" {
+		t.Fatalf("expected last duplicate to win, got %q", m["B"])
+	}
+	if entries, err := ParseEnvFileEntries(tmpPath(t, "missing.env")); err != nil || entries != nil {
+		t.Fatalf("expected no entries for missing file, got %v %v", entries, err)
+	}
+}
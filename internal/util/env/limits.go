@@ -0,0 +1,123 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Limits bounds the env input the parsers accept, so a huge or pathological
+// file fails with a descriptive error instead of using up memory. A zero
+// field means no limit.
+type Limits struct {
+	// MaxFileSize is the size of a file or reader, in bytes.
+	MaxFileSize int64
+	// MaxLines is the number of lines, including blank and comment lines.
+	MaxLines int
+	// MaxLineLen is the length of one line, in bytes, without its line
+	// ending. It must leave room for the longest value, quoted and escaped,
+	// after its key.
+	MaxLineLen int
+	// MaxValueLen is the length of one logical value, in bytes, after
+	// unquoting and joining multiline values.
+	MaxValueLen int
+}
+
+// DefaultLimits returns the limits the parsers apply unless ParseOptions say
+// otherwise. They are far beyond any real env file, yet keep a parse within
+// a few times MaxFileSize of memory.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFileSize: 32 << 20,
+		MaxLines:    100_000,
+		MaxLineLen:  10 << 20,
+		MaxValueLen: 4 << 20,
+	}
+}
+
+// ErrLimit is wrapped by the errors for input beyond the Limits in force.
+var ErrLimit = errors.New("env limit exceeded")
+
+// ParseOptions are the options of the parsers with a WithOptions suffix; the
+// others use the zero value.
+type ParseOptions struct {
+	// Limits, when set, replaces DefaultLimits. A zero Limits parses
+	// without any.
+	Limits *Limits
+}
+
+func (o ParseOptions) limits() Limits {
+	if o.Limits != nil {
+		return *o.Limits
+	}
+	return DefaultLimits()
+}
+
+// CheckValue returns an error wrapping ErrLimit if value is longer than
+// MaxValueLen, for values that arrive other than in an env file.
+func (l Limits) CheckValue(key, value string) error {
+	if l.MaxValueLen > 0 && len(value) > l.MaxValueLen {
+		return fmt.Errorf("value of %s is longer than %d bytes (%w)", key, l.MaxValueLen, ErrLimit)
+	}
+	return nil
+}
+
+// readFile is os.ReadFile that stops reading past l.MaxFileSize.
+func readFile(path string, l Limits) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(l.limitReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if err := l.checkSize(int64(len(b))); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// limitReader returns r cut off one byte past MaxFileSize, so that checkSize
+// can tell an oversized input from one of exactly the limit.
+func (l Limits) limitReader(r io.Reader) io.Reader {
+	if l.MaxFileSize <= 0 {
+		return r
+	}
+	return io.LimitReader(r, l.MaxFileSize+1)
+}
+
+func (l Limits) checkSize(n int64) error {
+	if l.MaxFileSize > 0 && n > l.MaxFileSize {
+		return fmt.Errorf("file is larger than %d bytes (%w)", l.MaxFileSize, ErrLimit)
+	}
+	return nil
+}
+
+// countReader counts the bytes read through it.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	small := Limits{MaxFileSize: 64, MaxLines: 4, MaxValueLen: 8}
+	parse := func(l Limits, in string) error {
+		_, err := ParseEnvReaderWithOptions(strings.NewReader(in), ParseOptions{Limits: &l})
+		return err
+	}
+	if err := parse(small, "A=12345678\nB='1234\n567'\n# comment\n"); err != nil {
+		t.Fatalf("input within the limits rejected: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		limits Limits
+		in     string
+		want   string
+	}{
+		"file size":       {small, "A=1\n" + strings.Repeat("#", 64) + "\n", "file is larger than 64 bytes"},
+		"lines":           {small, "A=1\n\n\n\nB=2\n", "more than 4 lines"},
+		"value":           {small, "A=1\nB=123456789\n", "line 2: value of B is longer than 8 bytes"},
+		"multiline value": {small, "A='12345\n6789'\n", "line 1: value of A is longer than 8 bytes"},
+		"line":            {Limits{MaxLineLen: 64}, "A=1\r\nB=" + strings.Repeat("x", 63) + "\r\n", "line 2 is longer than 64 bytes"},
+	} {
+		err := parse(tc.limits, tc.in)
+		if !errors.Is(err, ErrLimit) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q wrapping ErrLimit, got %v", name, tc.want, err)
+		}
+	}
+
+	// The line ending does not count towards MaxLineLen.
+	if err := parse(Limits{MaxLineLen: 64}, "B="+strings.Repeat("x", 62)+"\r\n"); err != nil {
+		t.Errorf("line of exactly MaxLineLen rejected: %v", err)
+	}
+
+	// Without options the parsers apply DefaultLimits.
+	if _, err := ParseEnvString("A=" + strings.Repeat("x", DefaultLimits().MaxValueLen+1)); !errors.Is(err, ErrLimit) {
+		t.Errorf("expected DefaultLimits to apply, got %v", err)
+	}
+
+	path := tmpPath(t, "big.env")
+	writeFile(t, path, "A="+strings.Repeat("x", 100)+"\n")
+	opts := ParseOptions{Limits: &small}
+	for _, parse := range []func(string) error{
+		func(p string) error { _, err := ParseEnvFileWithOptions(p, opts); return err },
+		func(p string) error { _, err := ParseEnvFileEntriesWithOptions(p, opts); return err },
+		func(p string) error { _, err := LoadDocumentWithOptions(p, opts); return err },
+	} {
+		if err := parse(path); !errors.Is(err, ErrLimit) || !strings.Contains(err.Error(), path+": file is larger than 64 bytes") {
+			t.Errorf("expected the file to be rejected, got %v", err)
+		}
+	}
+
+	// Documents apply every limit, not only the file size, and keep them for
+	// Get.
+	for in, want := range map[string]string{
+		"A=1\n\n\n\nB=2\n": "more than 4 lines",
+		"A=123456789\n":    "line 1: value of A is longer than 8 bytes",
+	} {
+		writeFile(t, path, in)
+		if _, err := LoadDocumentWithOptions(path, opts); !errors.Is(err, ErrLimit) || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadDocumentWithOptions(%q): expected %q, got %v", in, want, err)
+		}
+	}
+	d, err := ParseDocumentWithOptions([]byte("A=1\n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Set("B", "123456789")
+	if _, _, err := d.Get("B"); !errors.Is(err, ErrLimit) {
+		t.Errorf("Get of a value beyond the limits: expected ErrLimit, got %v", err)
+	}
+
+	if err := small.CheckValue("K", "123456789"); !errors.Is(err, ErrLimit) || err.Error() != "value of K is longer than 8 bytes (env limit exceeded)" {
+		t.Errorf("unexpected CheckValue error: %v", err)
+	}
+	if err := (Limits{}).CheckValue("K", strings.Repeat("x", 2<<20)); err != nil {
+		t.Errorf("zero limits should not limit: %v", err)
+	}
+}
@@ -15,14 +15,38 @@
 package file
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 )
 
+// ErrNoTmpfs is wrapped by CheckTmpfs on platforms without tmpfs: always on
+// Windows, and on macOS when path is not on a RAM disk.
+var ErrNoTmpfs = errors.New("this platform has no tmpfs")
+
 // WriteFileAtomic writes data to path atomically.
 // It writes to a temporary file in the same directory, fsyncs it,
-// then renames it over the target. Permissions are applied to the final file.
+// then renames it over the target. The temporary file is created 0600 and
+// gets perm before any data is written, so the file is never readable beyond
+// perm and appears at path with its final mode.
 func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(path, data, perm, nil)
+}
+
+// ReplaceFileAtomic is WriteFileAtomic for files users may have locked down:
+// an existing path keeps its permission bits, and its owner and group as far
+// as the process may set them, while perm only applies to a new file.
+func ReplaceFileAtomic(path string, data []byte, perm os.FileMode) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return writeFileAtomic(path, data, perm, nil)
+	}
+	return writeFileAtomic(path, data, fi.Mode().Perm(), fi)
+}
+
+// writeFileAtomic is WriteFileAtomic that, given the FileInfo of an existing
+// file, first gives the temporary file its owner.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, owner os.FileInfo) error {
 	dir := filepath.Dir(path)
 
 	// Create temporary file in same directory
@@ -38,6 +62,16 @@ func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
 		os.Remove(tmpName)
 	}()
 
+	// Give it the owner of the file it replaces
+	if owner != nil {
+		chownLike(tmp, owner)
+	}
+
+	// Apply permissions (via fchmod, unaffected by the umask)
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+
 	// Write data
 	if _, err := tmp.Write(data); err != nil {
 		return err
@@ -54,10 +88,23 @@ func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
 	}
 
 	// Rename atomically
-	if err := os.Rename(tmpName, path); err != nil {
+	return os.Rename(tmpName, path)
+}
+
+// Overwrite replaces the contents of f with zeros in place and syncs it. On
+// copy-on-write or journaling filesystems and on SSDs the old blocks may
+// survive elsewhere, so this reduces rather than removes exposure.
+func Overwrite(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
 		return err
 	}
-
-	// Apply permissions
-	return os.Chmod(path, perm)
+	zeros := make([]byte, 32*1024)
+	for off := int64(0); off < fi.Size(); off += int64(len(zeros)) {
+		n := min(int64(len(zeros)), fi.Size()-off)
+		if _, err := f.WriteAt(zeros[:n], off); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
 }
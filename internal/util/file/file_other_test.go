@@ -0,0 +1,82 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReplaceFileAtomic_KeepsModeAndOwner(t *testing.T) {
+	td := t.TempDir()
+	path := filepath.Join(td, "env")
+
+	// A new file gets perm.
+	if err := ReplaceFileAtomic(path, []byte("v1"), 0o644); err != nil {
+		t.Fatalf("ReplaceFileAtomic failed: %v", err)
+	}
+	if got, mode := readFileAndMode(t, path); got != "v1" || mode != 0o644 {
+		t.Fatalf("want %q with mode 644, got %q with mode %o", "v1", got, mode)
+	}
+
+	// An existing file keeps its own.
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		// Only root can give the file away to check that it stays there.
+		uid, gid = 12345, 23456
+		if err := os.Chown(path, uid, gid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ReplaceFileAtomic(path, []byte("v2"), 0o644); err != nil {
+		t.Fatalf("ReplaceFileAtomic failed: %v", err)
+	}
+	if got, mode := readFileAndMode(t, path); got != "v2" || mode != 0o600 {
+		t.Fatalf("want %q with mode 600, got %q with mode %o", "v2", got, mode)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := fi.Sys().(*syscall.Stat_t); int(st.Uid) != uid || int(st.Gid) != gid {
+		t.Fatalf("owner %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+	}
+}
+
+func TestWriteFileAtomic_IgnoresUmask(t *testing.T) {
+	old := syscall.Umask(0o277)
+	defer syscall.Umask(old)
+
+	td := t.TempDir()
+	for _, perm := range []os.FileMode{0o600, 0o644} {
+		path := filepath.Join(td, perm.String())
+		if err := WriteFileAtomic(path, []byte("data"), perm); err != nil {
+			t.Fatalf("WriteFileAtomic failed: %v", err)
+		}
+		if got, mode := readFileAndMode(t, path); got != "data" || mode != perm {
+			t.Fatalf("want %q with mode %o, got %q with mode %o", "data", perm, got, mode)
+		}
+	}
+	if entries, _ := os.ReadDir(td); len(entries) != 2 {
+		t.Fatalf("expected no leftover temp files, got %d entries", len(entries))
+	}
+}
@@ -17,6 +17,7 @@ package file
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -107,3 +108,42 @@ func TestPermissionBehavior(t *testing.T) {
 		t.Fatalf("expected 0600 on non-windows, got %o", mode)
 	}
 }
+
+func TestCheckTmpfs(t *testing.T) {
+	if err := CheckTmpfs("/definitely-not-existing"); err == nil {
+		t.Fatalf("expected statfs error for missing path")
+	}
+	if err := CheckTmpfs("/dev/shm"); err != nil {
+		t.Skipf("/dev/shm is not tmpfs here: %v", err)
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.env")
+	data := make([]byte, 70*1024)
+	for i := range data {
+		data[i] = 'x'
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := Overwrite(f); err != nil {
+		t.Fatalf("Overwrite: %v", err)
+	}
+	f.Close()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != len(data) || strings.Trim(string(got), "\x00") != "" {
+		t.Fatalf("expected %d zero bytes, got %d bytes with non-zero content", len(data), len(got))
+	}
+
+	if err := Overwrite(f); err == nil {
+		t.Fatalf("expected error on closed file")
+	}
+}
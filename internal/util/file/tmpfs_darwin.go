@@ -0,0 +1,85 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+
+package file
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// hdiutilInfo is a var so tests can substitute the output of hdiutil info.
+var hdiutilInfo = func() ([]byte, error) {
+	return exec.Command("hdiutil", "info").Output()
+}
+
+// CheckTmpfs returns nil when path is on an in-memory filesystem. macOS has
+// no tmpfs, but a RAM disk attached with hdiutil attach ram://SIZE counts,
+// as does a file system that calls itself tmpfs. Any other path fails with
+// an error wrapping ErrNoTmpfs, so callers can degrade to a warning.
+func CheckTmpfs(path string) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return fmt.Errorf("failed to statfs %s: %v", path, err)
+	}
+	fsType, device := cString(stat.Fstypename[:]), cString(stat.Mntfromname[:])
+	if fsType == "tmpfs" {
+		return nil
+	}
+	if out, err := hdiutilInfo(); err == nil {
+		for _, d := range ramDiskDevices(string(out)) {
+			if device == d {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("path %s is not on a RAM disk (%s on %s): %w", path, fsType, device, ErrNoTmpfs)
+}
+
+// ramDiskDevices returns the /dev entries of the images that hdiutil info
+// lists with a ram:// image path. Images are separated by lines of "=".
+func ramDiskDevices(info string) []string {
+	var devices []string
+	for _, image := range strings.Split(info, "\n=") {
+		ram := false
+		var entries []string
+		for _, line := range strings.Split(image, "\n") {
+			if k, v, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(k) == "image-path" {
+				ram = strings.HasPrefix(strings.TrimSpace(v), "ram://")
+			}
+			if fields := strings.Fields(line); len(fields) > 0 && strings.HasPrefix(fields[0], "/dev/") {
+				entries = append(entries, fields[0])
+			}
+		}
+		if ram {
+			devices = append(devices, entries...)
+		}
+	}
+	return devices
+}
+
+func cString(b []int8) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c == 0 {
+			break
+		}
+		sb.WriteByte(byte(c))
+	}
+	return sb.String()
+}
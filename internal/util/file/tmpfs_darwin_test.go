@@ -0,0 +1,62 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+
+package file
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+const hdiutilSample = `framework       : 671.100.2
+driver          : 10.0.0
+================================================
+image-path      : /Users/dev/Downloads/tool.dmg
+image-type      : read-only
+/dev/disk5	GUID_partition_scheme
+/dev/disk5s1	Apple_HFS	/Volumes/tool
+================================================
+image-path      : ram://2097152
+image-type      : read/write
+/dev/disk4	                               	
+/dev/disk4s1	41504653-0000-11AA-AA11-0030654	/Volumes/RAMDisk
+`
+
+func TestRamDiskDevices(t *testing.T) {
+	got := ramDiskDevices(hdiutilSample)
+	if want := []string{"/dev/disk4", "/dev/disk4s1"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := ramDiskDevices(""); len(got) != 0 {
+		t.Fatalf("got %v for no images", got)
+	}
+}
+
+func TestCheckTmpfs_NotRAMDisk(t *testing.T) {
+	orig := hdiutilInfo
+	t.Cleanup(func() { hdiutilInfo = orig })
+	hdiutilInfo = func() ([]byte, error) { return []byte(hdiutilSample), nil }
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckTmpfs(dir); !errors.Is(err, ErrNoTmpfs) {
+		t.Fatalf("expected ErrNoTmpfs, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package file
+
+import "fmt"
+
+// CheckTmpfs always fails with ErrNoTmpfs.
+func CheckTmpfs(path string) error {
+	return fmt.Errorf("path %s is not on tmpfs: %w", path, ErrNoTmpfs)
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ipc is the local transport between ojster processes. Paths name
+// Unix sockets, except on Windows, where a path under \\.\pipe\ names a
+// named pipe, so serve and run work in Windows containers and on Docker
+// Desktop without a socket shared through WSL.
+package ipc
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Describe names path with its kind, for messages.
+func Describe(path string) string {
+	if IsPipe(path) {
+		return "named pipe " + path
+	}
+	return "unix socket " + path
+}
+
+// DialTimeout is DialContext with a timeout.
+func DialTimeout(path string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return DialContext(ctx, path)
+}
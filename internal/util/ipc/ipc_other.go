@@ -0,0 +1,40 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package ipc
+
+import (
+	"context"
+	"net"
+)
+
+// DefaultPath is where ojster looks for the server without
+// OJSTER_SOCKET_PATH.
+const DefaultPath = "/mnt/ojster/ipc.sock"
+
+// IsPipe reports whether path names a named pipe; never outside Windows.
+func IsPipe(path string) bool { return false }
+
+// Listen listens on the Unix socket at path.
+func Listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// DialContext connects to the Unix socket at path.
+func DialContext(ctx context.Context, path string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", path)
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func testPath(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf(`\\.\pipe\ojster-test-%d-%s`, os.Getpid(), t.Name())
+	}
+	return filepath.Join(t.TempDir(), "ipc.sock")
+}
+
+func TestListenDial(t *testing.T) {
+	path := testPath(t)
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan error, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				accepted <- err
+				return
+			}
+			go func() {
+				defer c.Close()
+				line, _ := bufio.NewReader(c).ReadString('\n')
+				fmt.Fprint(c, "echo "+line)
+			}()
+		}
+	}()
+
+	for i := range 3 {
+		c, err := DialTimeout(path, 2*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(c, "ping %d\n", i)
+		got, err := bufio.NewReader(c).ReadString('\n')
+		c.Close()
+		if want := fmt.Sprintf("echo ping %d\n", i); err != nil || got != want {
+			t.Fatalf("got %q (%v), want %q", got, err, want)
+		}
+	}
+
+	ln.Close()
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Accept after Close: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not interrupt Accept")
+	}
+	if _, err := DialTimeout(path, 100*time.Millisecond); err == nil {
+		t.Fatal("dial succeeded after Close")
+	}
+}
+
+func TestIsPipe(t *testing.T) {
+	want := runtime.GOOS == "windows"
+	for _, p := range []string{`\\.\pipe\ojster`, `\\.\PIPE\ojster`} {
+		if IsPipe(p) != want {
+			t.Errorf("IsPipe(%q) = %v, want %v", p, !want, want)
+		}
+	}
+	for _, p := range []string{`\\.\pipe\`, "/mnt/ojster/ipc.sock", `C:\ojster\ipc.sock`} {
+		if IsPipe(p) {
+			t.Errorf("IsPipe(%q) = true", p)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package ipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// DefaultPath is where ojster looks for the server without
+// OJSTER_SOCKET_PATH.
+const DefaultPath = `\\.\pipe\ojster`
+
+const pipePrefix = `\\.\pipe\`
+
+var (
+	kernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW       = kernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe       = kernel32.NewProc("ConnectNamedPipe")
+	procWaitNamedPipeW         = kernel32.NewProc("WaitNamedPipeW")
+	procCreateEventW           = kernel32.NewProc("CreateEventW")
+	procSetEvent               = kernel32.NewProc("SetEvent")
+	procWaitForMultipleObjects = kernel32.NewProc("WaitForMultipleObjects")
+	procGetOverlappedResult    = kernel32.NewProc("GetOverlappedResult")
+)
+
+const (
+	pipeAccessDuplex          = 0x3
+	fileFlagFirstPipeInstance = 0x80000
+	pipeRejectRemoteClients   = 0x8
+	pipeUnlimitedInstances    = 255
+	pipeBufferSize            = 64 << 10
+	securitySQOSPresent       = 0x100000
+	securityIdentification    = 0x10000
+
+	errorPipeBusy      syscall.Errno = 231
+	errorNoData        syscall.Errno = 232
+	errorPipeConnected syscall.Errno = 535
+)
+
+// IsPipe reports whether path names a named pipe, like \\.\pipe\ojster.
+// Other paths are Unix sockets, which Windows 10 and later also support.
+func IsPipe(path string) bool {
+	return len(path) > len(pipePrefix) && strings.EqualFold(path[:len(pipePrefix)], pipePrefix)
+}
+
+// Listen listens on the named pipe or Unix socket at path. A pipe that
+// another process already serves is refused rather than shared. The pipe
+// keeps its default security descriptor, which gives write access only to
+// its creator, SYSTEM and administrators, and remote clients are rejected.
+func Listen(path string) (net.Listener, error) {
+	if !IsPipe(path) {
+		return net.Listen("unix", path)
+	}
+	l := &pipeListener{addr: pipeAddr(path)}
+	var err error
+	if l.next, err = l.newInstance(true); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: l.addr, Err: err}
+	}
+	if l.closing, err = newEvent(); err != nil {
+		syscall.CloseHandle(l.next)
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: l.addr, Err: err}
+	}
+	return l, nil
+}
+
+// DialContext connects to the named pipe or Unix socket at path. While every
+// instance of the pipe is busy, it waits until one frees up or ctx is done.
+func DialContext(ctx context.Context, path string) (net.Conn, error) {
+	if !IsPipe(path) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(path), Err: err}
+	}
+	for {
+		// Identification level keeps the server from impersonating us.
+		h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING,
+			syscall.FILE_FLAG_OVERLAPPED|securitySQOSPresent|securityIdentification, 0)
+		if err == nil {
+			return newPipeConn(h, path), nil
+		}
+		if err != errorPipeBusy {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(path), Err: os.NewSyscallError("CreateFile", err)}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(path), Err: err}
+		}
+		procWaitNamedPipeW.Call(uintptr(unsafe.Pointer(name)), 50)
+	}
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is a connected pipe instance. Its handle was opened for
+// overlapped I/O, so os.File runs it on the runtime poller and supports
+// deadlines.
+type pipeConn struct {
+	*os.File
+	addr pipeAddr
+}
+
+func newPipeConn(h syscall.Handle, path string) *pipeConn {
+	return &pipeConn{File: os.NewFile(uintptr(h), path), addr: pipeAddr(path)}
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.addr }
+
+// pipeListener always holds an unconnected instance, next, so the pipe name
+// stays taken between clients.
+type pipeListener struct {
+	addr    pipeAddr
+	closing syscall.Handle // event set by Close to interrupt Accept
+
+	mu        sync.Mutex
+	next      syscall.Handle
+	accepting bool
+	closed    bool
+}
+
+func (l *pipeListener) newInstance(first bool) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(string(l.addr))
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	mode := uint32(pipeAccessDuplex | syscall.FILE_FLAG_OVERLAPPED)
+	if first {
+		mode |= fileFlagFirstPipeInstance
+	}
+	r, _, err := procCreateNamedPipeW.Call(uintptr(unsafe.Pointer(name)), uintptr(mode), pipeRejectRemoteClients,
+		pipeUnlimitedInstances, pipeBufferSize, pipeBufferSize, 0, 0)
+	if syscall.Handle(r) == syscall.InvalidHandle {
+		if first && err == syscall.ERROR_ACCESS_DENIED {
+			return syscall.InvalidHandle, fmt.Errorf("pipe %s is already served by another process", l.addr)
+		}
+		return syscall.InvalidHandle, os.NewSyscallError("CreateNamedPipe", err)
+	}
+	return syscall.Handle(r), nil
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	for {
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			return nil, net.ErrClosed
+		}
+		if l.next == syscall.InvalidHandle {
+			h, err := l.newInstance(false)
+			if err != nil {
+				l.mu.Unlock()
+				return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.addr, Err: err}
+			}
+			l.next = h
+		}
+		h := l.next
+		l.accepting = true
+		l.mu.Unlock()
+
+		err := l.connect(h)
+
+		l.mu.Lock()
+		l.accepting = false
+		if l.closed {
+			syscall.CloseHandle(h)
+			syscall.CloseHandle(l.closing)
+			l.next = syscall.InvalidHandle
+			l.mu.Unlock()
+			return nil, net.ErrClosed
+		}
+		if err != nil {
+			// The instance is unusable; the next round creates another.
+			syscall.CloseHandle(h)
+			l.next = syscall.InvalidHandle
+			l.mu.Unlock()
+			if errors.Is(err, errorNoData) {
+				continue // the client left before we saw it
+			}
+			return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.addr, Err: err}
+		}
+		// Keep the name taken; a failure here is reported by the next Accept.
+		l.next, _ = l.newInstance(false)
+		l.mu.Unlock()
+		return newPipeConn(h, string(l.addr)), nil
+	}
+}
+
+// connect waits for a client to open instance h, or for Close.
+func (l *pipeListener) connect(h syscall.Handle) error {
+	ev, err := newEvent()
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(ev)
+	ov := syscall.Overlapped{HEvent: ev}
+	r, _, err := procConnectNamedPipe.Call(uintptr(h), uintptr(unsafe.Pointer(&ov)))
+	switch {
+	case r != 0, err == errorPipeConnected:
+		return nil
+	case err != syscall.ERROR_IO_PENDING:
+		return os.NewSyscallError("ConnectNamedPipe", err)
+	}
+
+	events := [2]syscall.Handle{ev, l.closing}
+	w, _, err := procWaitForMultipleObjects.Call(2, uintptr(unsafe.Pointer(&events[0])), 0, syscall.INFINITE)
+	if w != syscall.WAIT_OBJECT_0 {
+		syscall.CancelIoEx(h, &ov)
+	}
+	var n uint32
+	if r, _, err := procGetOverlappedResult.Call(uintptr(h), uintptr(unsafe.Pointer(&ov)), uintptr(unsafe.Pointer(&n)), 1); r == 0 {
+		return os.NewSyscallError("ConnectNamedPipe", err)
+	}
+	if w == syscall.WAIT_FAILED {
+		return os.NewSyscallError("WaitForMultipleObjects", err)
+	}
+	return nil
+}
+
+// Close stops Accept. Connections already accepted stay open.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return net.ErrClosed
+	}
+	l.closed = true
+	procSetEvent.Call(uintptr(l.closing))
+	if !l.accepting {
+		// Otherwise Accept closes them once it is interrupted.
+		if l.next != syscall.InvalidHandle {
+			syscall.CloseHandle(l.next)
+			l.next = syscall.InvalidHandle
+		}
+		syscall.CloseHandle(l.closing)
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return l.addr }
+
+func newEvent() (syscall.Handle, error) {
+	r, _, err := procCreateEventW.Call(0, 1, 0, 0)
+	if r == 0 {
+		return 0, os.NewSyscallError("CreateEvent", err)
+	}
+	return syscall.Handle(r), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+// Package memlock keeps secret key material out of swap by locking the pages
+// that hold it in RAM. Locking is best effort: it needs CAP_IPC_LOCK or a
+// large enough RLIMIT_MEMLOCK, and callers keep working without it.
+package memlock
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Lock pins the pages backing b in RAM. The Go heap never moves objects, so
+// the lock holds for as long as b is reachable. Pages are deliberately never
+// unlocked, as other key material may share them.
+func Lock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := syscall.Mlock(b); err != nil {
+		return fmt.Errorf("mlock failed: %w", err)
+	}
+	return nil
+}
+
+// Probe reports whether Lock can work in this process, so servers can warn
+// once at startup instead of on every request.
+func Probe() error {
+	return Lock(make([]byte, 1))
+}
+
+// Wipe overwrites b with zeros once the secret it holds is no longer needed.
+func Wipe(b []byte) {
+	clear(b)
+}
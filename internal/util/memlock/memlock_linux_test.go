@@ -0,0 +1,50 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package memlock
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	if err := Lock(nil); err != nil {
+		t.Fatalf("Lock(nil) = %v, want nil", err)
+	}
+	b := []byte("secret key material")
+	err := Lock(b)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOMEM) {
+		t.Skipf("mlock not permitted here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if err := Probe(); err != nil {
+		t.Fatalf("Probe failed after Lock succeeded: %v", err)
+	}
+}
+
+func TestWipe(t *testing.T) {
+	b := []byte("secret")
+	Wipe(b)
+	for i, c := range b {
+		if c != 0 {
+			t.Fatalf("byte %d not wiped: %q", i, b)
+		}
+	}
+}
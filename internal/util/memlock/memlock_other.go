@@ -0,0 +1,35 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package memlock
+
+import "errors"
+
+// Lock is only implemented on Linux.
+func Lock(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return errors.ErrUnsupported
+}
+
+func Probe() error {
+	return errors.ErrUnsupported
+}
+
+func Wipe(b []byte) {
+	clear(b)
+}
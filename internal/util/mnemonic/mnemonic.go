@@ -0,0 +1,118 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mnemonic encodes binary secrets as word lists for paper backups,
+// using the BIP39 English word list and checksum scheme. BIP39 itself stops
+// at 256 bits; the same rules (one checksum bit per 32 bits of entropy)
+// extend to the 512-bit ML-KEM seed, which takes 48 words.
+package mnemonic
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//go:embed english.txt
+var wordFile string
+
+var (
+	words  = strings.Fields(wordFile)
+	index  = make(map[string]int, len(words))
+	prefix = make(map[string]int, len(words))
+)
+
+func init() {
+	for i, w := range words {
+		index[w] = i
+		// BIP39 words are identified by their first four letters.
+		prefix[w[:min(4, len(w))]] = i
+	}
+}
+
+// Encode returns the words for entropy, whose length must be a multiple of
+// 4 bytes.
+func Encode(entropy []byte) ([]string, error) {
+	if len(entropy) == 0 || len(entropy)%4 != 0 {
+		return nil, fmt.Errorf("entropy must be a non-empty multiple of 4 bytes, not %d", len(entropy))
+	}
+	sum := sha256.Sum256(entropy)
+	bits := len(entropy) * 8
+	total := bits + bits/32
+	bit := func(i int) int {
+		if i < bits {
+			return int(entropy[i/8]>>(7-i%8)) & 1
+		}
+		i -= bits
+		return int(sum[i/8]>>(7-i%8)) & 1
+	}
+	out := make([]string, 0, total/11)
+	for i := 0; i < total; i += 11 {
+		n := 0
+		for j := range 11 {
+			n = n<<1 | bit(i+j)
+		}
+		out = append(out, words[n])
+	}
+	return out, nil
+}
+
+// ErrChecksum is returned for a word list whose checksum does not match,
+// which usually means a word was mistyped or the order is wrong.
+var ErrChecksum = errors.New("mnemonic checksum mismatch")
+
+// Decode reverses Encode. Words are case-insensitive and may be abbreviated
+// to their first four letters.
+func Decode(list []string) ([]byte, error) {
+	n := len(list)
+	// 33 words per 32 bytes of entropy: the counts Encode can produce.
+	if n == 0 || n%3 != 0 {
+		return nil, fmt.Errorf("a mnemonic has a multiple of 3 words, not %d", n)
+	}
+	total := n * 11
+	bits := total * 32 / 33
+	buf := make([]byte, (total+7)/8)
+	for i, w := range list {
+		w = strings.ToLower(w)
+		v, ok := index[w]
+		if !ok && len(w) == 4 {
+			v, ok = prefix[w]
+		}
+		if !ok {
+			return nil, fmt.Errorf("word %d (%q) is not in the word list", i+1, w)
+		}
+		for j := range 11 {
+			if v>>(10-j)&1 == 1 {
+				p := i*11 + j
+				buf[p/8] |= 1 << (7 - p%8)
+			}
+		}
+	}
+	entropy := buf[:bits/8]
+	sum := sha256.Sum256(entropy)
+	for i := bits; i < total; i++ {
+		got := buf[i/8] >> (7 - i%8) & 1
+		want := sum[(i-bits)/8] >> (7 - (i-bits)%8) & 1
+		if got != want {
+			clear(buf)
+			return nil, ErrChecksum
+		}
+	}
+	out := make([]byte, len(entropy))
+	copy(out, entropy)
+	clear(buf)
+	return out, nil
+}
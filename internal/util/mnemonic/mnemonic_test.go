@@ -0,0 +1,93 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mnemonic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWordList(t *testing.T) {
+	if len(words) != 2048 || words[0] != "abandon" || words[2047] != "zoo" {
+		t.Fatalf("unexpected word list: %d words", len(words))
+	}
+	if len(prefix) != 2048 {
+		t.Fatalf("four-letter prefixes are not unique")
+	}
+}
+
+// BIP39 reference vectors (trezor/python-mnemonic vectors.json).
+func TestEncode_Vectors(t *testing.T) {
+	tests := []struct {
+		entropy []byte
+		want    string
+	}{
+		{make([]byte, 16), "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+		{bytes.Repeat([]byte{0xff}, 16), "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong"},
+		{make([]byte, 32), strings.Repeat("abandon ", 23) + "art"},
+		{bytes.Repeat([]byte{0x7f}, 32), "legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title"},
+	}
+	for _, tt := range tests {
+		got, err := Encode(tt.entropy)
+		if err != nil || strings.Join(got, " ") != tt.want {
+			t.Errorf("Encode(%x) = %q, %v; want %q", tt.entropy, got, err, tt.want)
+		}
+		back, err := Decode(strings.Fields(tt.want))
+		if err != nil || !bytes.Equal(back, tt.entropy) {
+			t.Errorf("Decode(%q) = %x, %v", tt.want, back, err)
+		}
+	}
+}
+
+func TestRoundtrip_Seed(t *testing.T) {
+	seed := make([]byte, 64)
+	_, _ = rand.Read(seed)
+	list, err := Encode(seed)
+	if err != nil || len(list) != 48 {
+		t.Fatalf("Encode = %d words, %v", len(list), err)
+	}
+	// Case and four-letter abbreviations do not matter.
+	typed := make([]string, len(list))
+	for i, w := range list {
+		typed[i] = strings.ToUpper(w[:min(4, len(w))])
+	}
+	got, err := Decode(typed)
+	if err != nil || !bytes.Equal(got, seed) {
+		t.Fatalf("Decode = %x, %v", got, err)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	valid := strings.Fields("legal winner thank year wave sausage worth useful legal winner thank yellow")
+	if _, err := Decode(valid); err != nil {
+		t.Fatalf("Decode(valid): %v", err)
+	}
+	swapped := append([]string{valid[1], valid[0]}, valid[2:]...)
+	if _, err := Decode(swapped); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("swapped words: err = %v", err)
+	}
+	if _, err := Decode(append(valid[:11:11], "ojster")); err == nil || !strings.Contains(err.Error(), `word 12 ("ojster")`) {
+		t.Fatalf("unknown word: err = %v", err)
+	}
+	if _, err := Decode(valid[:10]); err == nil {
+		t.Fatal("expected an error for 10 words")
+	}
+	if _, err := Encode(make([]byte, 5)); err == nil {
+		t.Fatal("expected an error for 5 bytes")
+	}
+}
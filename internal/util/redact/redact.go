@@ -0,0 +1,94 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact scrubs secrets from log and error text before it is
+// written: anything matching a pattern (such as a sealed value) and any
+// known plaintext value is replaced with Placeholder.
+package redact
+
+import (
+	"cmp"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces every redacted secret.
+const Placeholder = "[REDACTED]"
+
+// MinValueLen is the shortest known value that is redacted. Shorter values
+// would garble unrelated text (a value "1" would hit every status code).
+const MinValueLen = 4
+
+// Scrubber replaces secrets in text. It is safe for concurrent use.
+type Scrubber struct {
+	re     *regexp.Regexp
+	mu     sync.RWMutex
+	values []string
+}
+
+// New returns a Scrubber for matches of re (nil for none) and values.
+func New(re *regexp.Regexp, values ...string) *Scrubber {
+	s := &Scrubber{re: re}
+	s.Add(values...)
+	return s
+}
+
+// Add registers further known values, for example once they are decrypted.
+func (s *Scrubber) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		if len(v) >= MinValueLen && !slices.Contains(s.values, v) {
+			s.values = append(s.values, v)
+		}
+	}
+	// Longest first, so a value containing another is replaced whole.
+	slices.SortFunc(s.values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+}
+
+// String returns in with every secret replaced by Placeholder.
+func (s *Scrubber) String(in string) string {
+	if s.re != nil {
+		in = s.re.ReplaceAllLiteralString(in, Placeholder)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, v := range s.values {
+		in = strings.ReplaceAll(in, v, Placeholder)
+	}
+	return in
+}
+
+// Writer returns a writer that scrubs each Write before passing it to w.
+// Secrets split across Write calls are not caught; fmt.Fprint and friends
+// write once per call.
+func (s *Scrubber) Writer(w io.Writer) io.Writer {
+	return &writer{s: s, w: w}
+}
+
+type writer struct {
+	s *Scrubber
+	w io.Writer
+}
+
+// Write reports len(p) on success, since the scrubbed text can differ in length.
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.s.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Jip de Beer (Jip-Hop) and Ojster contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestScrubber_String(t *testing.T) {
+	s := New(regexp.MustCompile(`SEALED:[a-z]+`), "hunter22", "hunter2", "x")
+	for in, want := range map[string]string{
+		"body=SEALED:abc rest":     "body=[REDACTED] rest",
+		"password hunter22 leaked": "password [REDACTED] leaked",
+		"short hunter2":            "short [REDACTED]",
+		"status=401 x":             "status=401 x",
+		"nothing here":             "nothing here",
+	} {
+		if got := s.String(in); got != want {
+			t.Errorf("String(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	s.Add("later-secret")
+	if got := s.String("got later-secret"); got != "got [REDACTED]" {
+		t.Fatalf("added value not redacted: %q", got)
+	}
+	if got := New(nil).String("plain"); got != "plain" {
+		t.Fatalf("nil pattern changed text: %q", got)
+	}
+}
+
+func TestScrubber_Writer(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(nil, "s3cret").Writer(&buf)
+	n, err := fmt.Fprintf(w, "value %q\n", "s3cret")
+	if err != nil || n != len("value \"s3cret\"\n") {
+		t.Fatalf("Fprintf = %d, %v", n, err)
+	}
+	if buf.String() != "value \"[REDACTED]\"\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestScrubber_Concurrent(t *testing.T) {
+	s := New(nil)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Add(fmt.Sprintf("value-%d", i))
+			_ = s.String("value-0 value-1")
+		}()
+	}
+	wg.Wait()
+	if got := s.String("value-3"); got != Placeholder {
+		t.Fatalf("got %q", got)
+	}
+}